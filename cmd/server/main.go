@@ -8,13 +8,13 @@ import (
 	"appointment-api/internal/services"
 	"context"
 	"database/sql"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
@@ -28,8 +28,7 @@ func main() {
 	log.Printf("Database: %s@%s:%d/%s", cfg.Database.User, cfg.Database.Host, cfg.Database.Port, cfg.Database.DBName)
 
 	// Build database URL
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.Database.User, cfg.Database.Password, cfg.Database.Host, cfg.Database.Port, cfg.Database.DBName, cfg.Database.SSLMode)
+	dbURL := cfg.Database.ConnectionString()
 
 	// Database connection
 	db, err := sql.Open("postgres", dbURL)
@@ -55,13 +54,21 @@ func main() {
 		log.Fatal("Failed to start tenant cache:", err)
 	}
 
+	// Start background workers (calendar sync, ...)
+	svc.StartWorkers()
+
 	// Initialize API handlers
-	handlers := api.NewHandlers(svc)
+	handlers := api.NewHandlers(svc, cfg)
 
 	// Setup router
 	gin.SetMode(gin.DebugMode)
 	router := gin.Default()
 
+	// Client IPs are read from forwarding headers only when set by our own proxies
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// Add CORS middleware
 	router.Use(middleware.CORSMiddleware())
 
@@ -69,8 +76,10 @@ func main() {
 
 	// Create HTTP server
 	srv := &http.Server{
-		Addr:    ":" + cfg.Server.Port,
-		Handler: router,
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.RequestTimeout,
+		WriteTimeout: cfg.Server.RequestTimeout,
 	}
 
 	// Start server in a goroutine
@@ -88,7 +97,8 @@ func main() {
 
 	log.Println("Shutting down server...")
 
-	// Stop tenant cache
+	// Stop background workers and tenant cache
+	svc.StopWorkers()
 	svc.TenantCache.Stop()
 
 	// Shutdown server with timeout
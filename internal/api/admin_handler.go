@@ -1,9 +1,14 @@
 package api
 
 import (
+	"appointment-api/internal/middleware"
 	"appointment-api/internal/models"
 	"appointment-api/internal/services"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,8 +27,13 @@ type AdminHandler struct {
 	specialistService  services.SpecialistService
 	appointmentService services.AppointmentService
 	paymentService     services.PaymentService
+	promoCodeService   services.PromoCodeService
+	packageService     services.PackageService
+	walletService      services.WalletService
+	cashSessionService services.CashSessionService
 	contactService     services.ContactService
 	uploadService      services.UploadService
+	auditService       services.AuditService
 	validator          *validator.Validate
 }
 
@@ -37,8 +47,13 @@ func NewAdminHandler(
 	specialistService services.SpecialistService,
 	appointmentService services.AppointmentService,
 	paymentService services.PaymentService,
+	promoCodeService services.PromoCodeService,
+	packageService services.PackageService,
+	walletService services.WalletService,
+	cashSessionService services.CashSessionService,
 	contactService services.ContactService,
 	uploadService services.UploadService,
+	auditService services.AuditService,
 	validator *validator.Validate,
 ) *AdminHandler {
 	return &AdminHandler{
@@ -51,12 +66,79 @@ func NewAdminHandler(
 		specialistService:  specialistService,
 		appointmentService: appointmentService,
 		paymentService:     paymentService,
+		promoCodeService:   promoCodeService,
+		packageService:     packageService,
+		walletService:      walletService,
+		cashSessionService: cashSessionService,
 		contactService:     contactService,
 		uploadService:      uploadService,
+		auditService:       auditService,
 		validator:          validator,
 	}
 }
 
+// audit records a change made through the admin API. A failed write is only
+// logged, the change itself has already happened.
+func (h *AdminHandler) audit(c *gin.Context, entityType string, entityID interface{}, action string, before, after interface{}) {
+	recordAudit(h.auditService, c, entityType, entityID, action, before, after)
+}
+
+// recordAudit is audit for the admin routes served by the other handlers
+func recordAudit(auditService services.AuditService, c *gin.Context, entityType string, entityID interface{}, action string, before, after interface{}) {
+	entry := &models.AuditLogEntry{
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Action:     action,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if tenant, exists := middleware.GetCurrentTenant(c); exists {
+		entry.TenantID = tenant.ID
+	}
+	entry.ActorType, entry.ActorID, entry.Actor = requestActor(c)
+
+	if err := auditService.Record(entry, before, after); err != nil {
+		log.Printf("Warning: Failed to write audit log for %s %v: %v", entityType, entityID, err)
+	}
+}
+
+// requestActor names the admin or API key behind the request, as the audit
+// log records it
+func requestActor(c *gin.Context) (string, *int, string) {
+	if user, exists := middleware.GetCurrentUser(c); exists {
+		return models.AuditActorUser, &user.ID, user.Email
+	}
+	if key, exists := middleware.GetCurrentAPIKey(c); exists {
+		return models.AuditActorAPIKey, &key.ID, key.Prefix
+	}
+	return "", nil, ""
+}
+
+// apiKeyManagesAdmin reports whether an API key is trying to create, change or
+// delete an admin account, which only admins may do: changing an admin's email
+// is enough to take the account over with a password reset
+func apiKeyManagesAdmin(c *gin.Context, role models.UserRole) bool {
+	_, isAPIKey := middleware.GetCurrentAPIKey(c)
+	return isAPIKey && role.IsStaff()
+}
+
+// respondAdminAccountForbidden answers an API key request for an admin account
+func respondAdminAccountForbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error":   "API keys cannot manage admin accounts",
+	})
+}
+
+// auditState wraps a lookup for the before/after state of an audit entry; a
+// failed lookup only leaves that side of the diff empty
+func auditState(entity interface{}, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return entity
+}
+
 // Categories
 func (h *AdminHandler) CreateCategory(c *gin.Context) {
 	var category models.Category
@@ -77,6 +159,8 @@ func (h *AdminHandler) CreateCategory(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "category", category.ID, models.AuditActionCreate, nil, &category)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data":    category,
@@ -121,6 +205,7 @@ func (h *AdminHandler) UpdateCategory(c *gin.Context) {
 	}
 
 	category.ID = id
+	before := auditState(h.categoryService.GetByID(id))
 	err = h.categoryService.Update(&category)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -130,6 +215,8 @@ func (h *AdminHandler) UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "category", id, models.AuditActionUpdate, before, auditState(h.categoryService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    category,
@@ -148,6 +235,7 @@ func (h *AdminHandler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.categoryService.GetByID(id))
 	err = h.categoryService.Delete(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -157,6 +245,8 @@ func (h *AdminHandler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "category", id, models.AuditActionDelete, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Category deleted successfully",
@@ -183,6 +273,8 @@ func (h *AdminHandler) CreateService(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "service", service.ID, models.AuditActionCreate, nil, &service)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data":    service,
@@ -227,6 +319,7 @@ func (h *AdminHandler) UpdateService(c *gin.Context) {
 	}
 
 	service.ID = id
+	before := auditState(h.serviceService.GetByID(id))
 	err = h.serviceService.Update(&service)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -236,6 +329,8 @@ func (h *AdminHandler) UpdateService(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "service", id, models.AuditActionUpdate, before, auditState(h.serviceService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    service,
@@ -254,6 +349,7 @@ func (h *AdminHandler) DeleteService(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.serviceService.GetByID(id))
 	err = h.serviceService.Delete(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -263,6 +359,8 @@ func (h *AdminHandler) DeleteService(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "service", id, models.AuditActionDelete, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Service deleted successfully",
@@ -289,6 +387,8 @@ func (h *AdminHandler) CreateDevice(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "device", device.ID, models.AuditActionCreate, nil, &device)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data":    device,
@@ -333,6 +433,7 @@ func (h *AdminHandler) UpdateDevice(c *gin.Context) {
 	}
 
 	device.ID = id
+	before := auditState(h.deviceService.GetByID(id))
 	err = h.deviceService.Update(&device)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -342,6 +443,8 @@ func (h *AdminHandler) UpdateDevice(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "device", id, models.AuditActionUpdate, before, auditState(h.deviceService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    device,
@@ -360,6 +463,7 @@ func (h *AdminHandler) DeleteDevice(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.deviceService.GetByID(id))
 	err = h.deviceService.Delete(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -369,6 +473,8 @@ func (h *AdminHandler) DeleteDevice(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "device", id, models.AuditActionDelete, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Device deleted successfully",
@@ -421,15 +527,31 @@ func (h *AdminHandler) UpdateSetting(c *gin.Context) {
 		Description: request.Description,
 	}
 
+	before := auditState(h.settingsService.GetByKey(key))
 	err := h.settingsService.Update(setting)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		switch {
+		case err.Error() == "setting not found":
+			statusCode = http.StatusNotFound
+		case strings.HasPrefix(err.Error(), "invalid reminder"), strings.HasPrefix(err.Error(), "invalid payment_provider"),
+			strings.HasPrefix(err.Error(), "invalid deposit_percentage"), strings.HasPrefix(err.Error(), "invalid currency"),
+			strings.HasPrefix(err.Error(), "invalid invoice_prefix"), strings.HasPrefix(err.Error(), "invalid credit_note_prefix"),
+			strings.HasPrefix(err.Error(), "invalid invoice_seller_tax_number"), strings.HasPrefix(err.Error(), "invalid einvoice_profile"),
+			strings.HasPrefix(err.Error(), "invalid tax_rate"), strings.HasPrefix(err.Error(), "invalid gift_card_validity_days"),
+			strings.HasPrefix(err.Error(), "invalid prices_include_tax"), strings.HasPrefix(err.Error(), "invalid idempotency_key_retention_hours"):
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	h.audit(c, "setting", key, models.AuditActionUpdate, before, auditState(h.settingsService.GetByKey(key)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Setting updated successfully",
@@ -449,6 +571,7 @@ func (h *AdminHandler) UpdateAppointmentDuration(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.settingsService.GetByKey("appointment_duration"))
 	err := h.settingsService.UpdateAppointmentDuration(request.Duration)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -458,6 +581,8 @@ func (h *AdminHandler) UpdateAppointmentDuration(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "setting", "appointment_duration", models.AuditActionUpdate, before, auditState(h.settingsService.GetByKey("appointment_duration")))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Appointment duration updated successfully",
@@ -511,6 +636,11 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		UstBel    *float64        `json:"ust_bel"`
 		OrtaBel   *float64        `json:"orta_bel"`
 		AltBel    *float64        `json:"alt_bel"`
+		TaxNumber string          `json:"tax_number"`
+		TaxOffice string          `json:"tax_office"`
+		Address   string          `json:"address"`
+		District  string          `json:"district"`
+		City      string          `json:"city"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -521,6 +651,11 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if apiKeyManagesAdmin(c, request.Role) {
+		respondAdminAccountForbidden(c)
+		return
+	}
+
 	user := &models.User{
 		Email:     request.Email,
 		Password:  "123456", // Default password
@@ -531,11 +666,20 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		UstBel:    request.UstBel,
 		OrtaBel:   request.OrtaBel,
 		AltBel:    request.AltBel,
+		TaxNumber: request.TaxNumber,
+		TaxOffice: request.TaxOffice,
+		Address:   request.Address,
+		District:  request.District,
+		City:      request.City,
 	}
 
 	err := h.userService.Create(user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid tax number" {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -544,6 +688,8 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 
 	user.Password = ""
 
+	h.audit(c, "user", user.ID, models.AuditActionCreate, nil, user)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data":    user,
@@ -571,10 +717,21 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	existing, err := h.userService.GetByID(id)
+	if err == nil && apiKeyManagesAdmin(c, existing.Role) {
+		respondAdminAccountForbidden(c)
+		return
+	}
+
 	user.ID = id
+	before := auditState(existing, err)
 	err = h.userService.Update(&user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid tax number" {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -584,6 +741,8 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 	// Clear password from response
 	user.Password = ""
 
+	h.audit(c, "user", id, models.AuditActionUpdate, before, auditState(h.userService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    user,
@@ -602,6 +761,13 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	existing, err := h.userService.GetByID(id)
+	if err == nil && apiKeyManagesAdmin(c, existing.Role) {
+		respondAdminAccountForbidden(c)
+		return
+	}
+
+	before := auditState(existing, err)
 	err = h.userService.Delete(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -611,6 +777,8 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "user", id, models.AuditActionDelete, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "User deleted successfully",
@@ -640,6 +808,7 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.userService.GetByID(id))
 	err = h.userService.UpdateRole(id, request.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -649,12 +818,243 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "user", id, models.AuditActionUpdateRole, before, auditState(h.userService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "User role updated successfully",
 	})
 }
 
+// UnlockUser clears the failed logins of a locked account
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.userService.UnlockAccount(id, clientInfo(c)); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "user", id, models.AuditActionUnlock, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User account unlocked successfully",
+	})
+}
+
+// GetLoginAttempts lists login events, filtered by ?email, ?ip and ?event
+func (h *AdminHandler) GetLoginAttempts(c *gin.Context) {
+	limit := 20
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	filter := models.LoginAttemptFilter{
+		Email:     c.Query("email"),
+		IPAddress: c.Query("ip"),
+		Event:     models.LoginEvent(c.Query("event")),
+	}
+
+	attempts, total, err := h.userService.ListLoginAttempts(filter, limit, offset)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid login event" {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"attempts": attempts,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+		},
+	})
+}
+
+// GetAuditLog lists admin changes, newest first. Filters: ?actor_type,
+// ?actor_id, ?entity_type, ?entity_id, ?action, ?start_date and ?end_date
+func (h *AdminHandler) GetAuditLog(c *gin.Context) {
+	limit := 20
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	filter, ok := auditLogFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.auditService.List(filter, limit, offset)
+	if err != nil {
+		respondAuditError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"entries": entries,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		},
+	})
+}
+
+// ExportAuditLog downloads the whole filtered audit log as ?format=csv (default) or json
+func (h *AdminHandler) ExportAuditLog(c *gin.Context) {
+	filter, ok := auditLogFilter(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", services.AuditExportCSV)
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case services.AuditExportCSV:
+	case services.AuditExportJSON:
+		contentType = "application/json; charset=utf-8"
+	default:
+		respondAuditError(c, errors.New("invalid export format"))
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-log-%s.%s"`, time.Now().Format("20060102"), format))
+	c.Status(http.StatusOK)
+	if err := h.auditService.Export(filter, format, c.Writer); err != nil {
+		// Headers are already sent, the truncated download is all we can do
+		log.Printf("Warning: Audit log export failed: %v", err)
+		c.Error(err)
+	}
+}
+
+func auditLogFilter(c *gin.Context) (models.AuditLogFilter, bool) {
+	filter := models.AuditLogFilter{
+		ActorType:  c.Query("actor_type"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Action:     c.Query("action"),
+	}
+
+	if a := c.Query("actor_id"); a != "" {
+		actorID, err := strconv.Atoi(a)
+		if err != nil || actorID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid actor ID",
+			})
+			return filter, false
+		}
+		filter.ActorID = actorID
+	}
+
+	if d := c.Query("start_date"); d != "" {
+		start, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid start_date format, use YYYY-MM-DD",
+			})
+			return filter, false
+		}
+		filter.From = &start
+	}
+
+	// end_date is inclusive
+	if d := c.Query("end_date"); d != "" {
+		end, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid end_date format, use YYYY-MM-DD",
+			})
+			return filter, false
+		}
+		end = end.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	return filter, true
+}
+
+func respondAuditError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	if err.Error() == "invalid actor type" || err.Error() == "invalid export format" {
+		statusCode = http.StatusBadRequest
+	}
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// RevokeUserSessions logs a user out everywhere, e.g. when staff leave
+func (h *AdminHandler) RevokeUserSessions(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.userService.RevokeSessions(id); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "user", id, models.AuditActionRevokeSessions, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User sessions revoked successfully",
+	})
+}
+
 // Specialists
 func (h *AdminHandler) GetSpecialists(c *gin.Context) {
 	specialists, err := h.specialistService.List()
@@ -691,6 +1091,8 @@ func (h *AdminHandler) CreateSpecialist(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "specialist", specialist.ID, models.AuditActionCreate, nil, &specialist)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data":    specialist,
@@ -719,6 +1121,7 @@ func (h *AdminHandler) UpdateSpecialist(c *gin.Context) {
 	}
 
 	specialist.ID = id
+	before := auditState(h.specialistService.GetByID(id))
 	err = h.specialistService.Update(&specialist)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -728,6 +1131,8 @@ func (h *AdminHandler) UpdateSpecialist(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "specialist", id, models.AuditActionUpdate, before, auditState(h.specialistService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    specialist,
@@ -746,6 +1151,7 @@ func (h *AdminHandler) DeleteSpecialist(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.specialistService.GetByID(id))
 	err = h.specialistService.Delete(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -755,6 +1161,8 @@ func (h *AdminHandler) DeleteSpecialist(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "specialist", id, models.AuditActionDelete, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Specialist deleted successfully",
@@ -807,6 +1215,7 @@ func (h *AdminHandler) UpdateSpecialistWorkingHours(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.specialistService.GetWorkingHours(id))
 	err = h.specialistService.UpdateWorkingHours(id, workingHours)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -816,6 +1225,8 @@ func (h *AdminHandler) UpdateSpecialistWorkingHours(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "specialist_working_hours", id, models.AuditActionUpdate, before, auditState(h.specialistService.GetWorkingHours(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Working hours updated successfully",
@@ -878,6 +1289,8 @@ func (h *AdminHandler) CreateAppointment(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "appointment", appointment.ID, models.AuditActionCreate, nil, &appointment)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data":    appointment,
@@ -906,15 +1319,27 @@ func (h *AdminHandler) UpdateAppointment(c *gin.Context) {
 	}
 
 	appointment.ID = id
+	before := auditState(h.appointmentService.GetByID(id))
 	err = h.appointmentService.Update(&appointment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		switch {
+		case err.Error() == "appointment not found":
+			statusCode = http.StatusNotFound
+		case err.Error() == "appointment time is already booked", err.Error() == "cannot update cancelled appointment",
+			err.Error() == "service not found", err.Error() == "service is not active",
+			strings.HasPrefix(err.Error(), "cannot change the service"):
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	h.audit(c, "appointment", id, models.AuditActionUpdate, before, auditState(h.appointmentService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    appointment,
@@ -933,6 +1358,7 @@ func (h *AdminHandler) DeleteAppointment(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.appointmentService.GetByID(id))
 	err = h.appointmentService.Delete(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -942,6 +1368,8 @@ func (h *AdminHandler) DeleteAppointment(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "appointment", id, models.AuditActionDelete, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Appointment deleted successfully",
@@ -971,15 +1399,27 @@ func (h *AdminHandler) UpdateAppointmentStatus(c *gin.Context) {
 		return
 	}
 
+	before := auditState(h.appointmentService.GetByID(id))
 	err = h.appointmentService.UpdateStatus(id, request.Status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "appointment not found":
+			statusCode = http.StatusNotFound
+		case "invalid status":
+			statusCode = http.StatusBadRequest
+		case "deposit has not been paid":
+			statusCode = http.StatusConflict
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	h.audit(c, "appointment", id, models.AuditActionUpdateStatus, before, auditState(h.appointmentService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Appointment status updated successfully",
@@ -1034,13 +1474,22 @@ func (h *AdminHandler) CreatePayment(c *gin.Context) {
 
 	err := h.paymentService.Create(&payment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "appointment not found":
+			statusCode = http.StatusNotFound
+		case "invalid payment amount", "amount exceeds balance due", "insufficient wallet balance":
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	h.audit(c, "payment", payment.ID, models.AuditActionCreate, nil, &payment)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data":    payment,
@@ -1069,15 +1518,22 @@ func (h *AdminHandler) UpdatePayment(c *gin.Context) {
 	}
 
 	payment.ID = id
+	before := auditState(h.paymentService.GetByID(id))
 	err = h.paymentService.Update(&payment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "wallet payments cannot be changed, refund them instead" || strings.HasSuffix(err.Error(), "use POST /admin/payments/:id/refunds") {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	h.audit(c, "payment", id, models.AuditActionUpdate, before, auditState(h.paymentService.GetByID(id)))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    payment,
@@ -1085,52 +1541,54 @@ func (h *AdminHandler) UpdatePayment(c *gin.Context) {
 	})
 }
 
-func (h *AdminHandler) DeletePayment(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Invalid payment ID",
-		})
+// SyncPayment refreshes a gateway payment from its provider, for missed webhooks
+func (h *AdminHandler) SyncPayment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid payment ID")
+	if !ok {
 		return
 	}
 
-	err = h.paymentService.Delete(id)
+	before := auditState(h.paymentService.GetByID(id))
+	payment, err := h.paymentService.SyncStatus(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "payment not found":
+			statusCode = http.StatusNotFound
+		case "payment was not made through a payment provider":
+			statusCode = http.StatusBadRequest
+		case "payment provider error":
+			statusCode = http.StatusBadGateway
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	h.audit(c, "payment", id, models.AuditActionSync, before, payment)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Payment deleted successfully",
+		"data":    payment,
+		"message": "Payment status synchronized",
 	})
 }
 
-// Contact Messages
-func (h *AdminHandler) GetContactMessages(c *gin.Context) {
-	limit := 50
-	offset := 0
-
-	if l := c.Query("limit"); l != "" {
-		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	if o := c.Query("offset"); o != "" {
-		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+func (h *AdminHandler) GetPaymentRefunds(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid payment ID")
+	if !ok {
+		return
 	}
 
-	messages, total, err := h.contactService.List(limit, offset)
+	refunds, err := h.paymentService.GetRefunds(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "payment not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -1139,53 +1597,82 @@ func (h *AdminHandler) GetContactMessages(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"messages": messages,
-			"total":    total,
-			"limit":    limit,
-			"offset":   offset,
-		},
+		"data":    refunds,
 	})
 }
 
-func (h *AdminHandler) MarkContactMessageRead(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
+// RefundPayment refunds part or all of a payment. A payment can be refunded
+// several times until its whole amount is returned.
+func (h *AdminHandler) RefundPayment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid payment ID")
+	if !ok {
+		return
+	}
+
+	var req models.CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Invalid message ID",
+			"error":   "Invalid request format",
 		})
 		return
 	}
 
-	err = h.contactService.MarkAsRead(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	refund := &models.Refund{Amount: req.Amount, Reason: req.Reason, ToWallet: req.ToWallet}
+	refund.ActorType, refund.ActorID, refund.Actor = requestActor(c)
+
+	before := auditState(h.paymentService.GetByID(id))
+	if err := h.paymentService.Refund(id, refund); err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case err.Error() == "payment not found":
+			statusCode = http.StatusNotFound
+		case err.Error() == "only completed payments can be refunded", err.Error() == "invalid refund amount",
+			err.Error() == "refund exceeds refundable amount":
+			statusCode = http.StatusBadRequest
+		case strings.HasPrefix(err.Error(), "refund failed"):
+			statusCode = http.StatusUnprocessableEntity
+		case err.Error() == "payment provider error":
+			statusCode = http.StatusBadGateway
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	h.audit(c, "payment", id, models.AuditActionRefund, before, auditState(h.paymentService.GetByID(id)))
+
+	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
-		"message": "Message marked as read",
+		"data":    refund,
+		"message": "Payment refunded successfully",
 	})
 }
 
-func (h *AdminHandler) DeleteContactMessage(c *gin.Context) {
+func (h *AdminHandler) DeletePayment(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Invalid message ID",
+			"error":   "Invalid payment ID",
 		})
 		return
 	}
 
-	err = h.contactService.Delete(id)
+	before := auditState(h.paymentService.GetByID(id))
+	err = h.paymentService.Delete(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -1194,6 +1681,902 @@ func (h *AdminHandler) DeleteContactMessage(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "payment", id, models.AuditActionDelete, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Payment deleted successfully",
+	})
+}
+
+// Promo Codes
+func (h *AdminHandler) GetPromoCodes(c *gin.Context) {
+	promos, err := h.promoCodeService.List()
+	if err != nil {
+		respondPromoCodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    promos,
+	})
+}
+
+func (h *AdminHandler) GetPromoCode(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid promo code ID")
+	if !ok {
+		return
+	}
+
+	promo, err := h.promoCodeService.GetByID(id)
+	if err != nil {
+		respondPromoCodeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    promo,
+	})
+}
+
+func (h *AdminHandler) CreatePromoCode(c *gin.Context) {
+	var req models.PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	promo, err := h.promoCodeService.Create(&req)
+	if err != nil {
+		respondPromoCodeError(c, err)
+		return
+	}
+
+	h.audit(c, "promo_code", promo.ID, models.AuditActionCreate, nil, promo)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    promo,
+		"message": "Promo code created successfully",
+	})
+}
+
+func (h *AdminHandler) UpdatePromoCode(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid promo code ID")
+	if !ok {
+		return
+	}
+
+	var req models.PromoCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	before := auditState(h.promoCodeService.GetByID(id))
+	promo, err := h.promoCodeService.Update(id, &req)
+	if err != nil {
+		respondPromoCodeError(c, err)
+		return
+	}
+
+	h.audit(c, "promo_code", id, models.AuditActionUpdate, before, promo)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    promo,
+		"message": "Promo code updated successfully",
+	})
+}
+
+func (h *AdminHandler) DeletePromoCode(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid promo code ID")
+	if !ok {
+		return
+	}
+
+	before := auditState(h.promoCodeService.GetByID(id))
+	if err := h.promoCodeService.Delete(id); err != nil {
+		respondPromoCodeError(c, err)
+		return
+	}
+
+	h.audit(c, "promo_code", id, models.AuditActionDelete, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Promo code deleted successfully",
+	})
+}
+
+func respondPromoCodeError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "promo code not found":
+		statusCode = http.StatusNotFound
+	case err.Error() == "promo code already exists", err.Error() == "promo code has been redeemed, deactivate it instead":
+		statusCode = http.StatusConflict
+	case strings.HasPrefix(err.Error(), "invalid"):
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// Packages
+func (h *AdminHandler) GetPackages(c *gin.Context) {
+	packages, err := h.packageService.List(false)
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    packages,
+	})
+}
+
+func (h *AdminHandler) GetPackage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid package ID")
+	if !ok {
+		return
+	}
+
+	pkg, err := h.packageService.GetByID(id)
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    pkg,
+	})
+}
+
+func (h *AdminHandler) CreatePackage(c *gin.Context) {
+	var req models.PackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	pkg, err := h.packageService.Create(&req)
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	h.audit(c, "package", pkg.ID, models.AuditActionCreate, nil, pkg)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    pkg,
+		"message": "Package created successfully",
+	})
+}
+
+func (h *AdminHandler) UpdatePackage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid package ID")
+	if !ok {
+		return
+	}
+
+	var req models.PackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	before := auditState(h.packageService.GetByID(id))
+	pkg, err := h.packageService.Update(id, &req)
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	h.audit(c, "package", id, models.AuditActionUpdate, before, pkg)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    pkg,
+		"message": "Package updated successfully",
+	})
+}
+
+func (h *AdminHandler) DeletePackage(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid package ID")
+	if !ok {
+		return
+	}
+
+	before := auditState(h.packageService.GetByID(id))
+	if err := h.packageService.Delete(id); err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	h.audit(c, "package", id, models.AuditActionDelete, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Package deleted successfully",
+	})
+}
+
+// GetUserPackages lists the packages a customer bought with their balances
+func (h *AdminHandler) GetUserPackages(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	customerPackages, err := h.packageService.GetUserPackages(userID)
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    customerPackages,
+	})
+}
+
+// SellPackage records a package the customer bought at the desk
+func (h *AdminHandler) SellPackage(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req models.SellPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	customerPackage, err := h.packageService.Sell(userID, &req)
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	h.audit(c, "customer_package", customerPackage.ID, models.AuditActionCreate, nil, customerPackage)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    customerPackage,
+		"message": "Package sold successfully",
+	})
+}
+
+// AdjustUserPackage changes the balance or expiry of a customer's package
+func (h *AdminHandler) AdjustUserPackage(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	customerPackageID, ok := parseIDParam(c, "packageId", "Invalid package ID")
+	if !ok {
+		return
+	}
+
+	var req models.AdjustPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	before, err := h.packageService.GetCustomerPackage(customerPackageID)
+	if err == nil && before.UserID != userID {
+		err = errors.New("customer package not found")
+	}
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	actorType, actorID, actor := requestActor(c)
+	customerPackage, err := h.packageService.Adjust(customerPackageID, &req, actorType, actorID, actor)
+	if err != nil {
+		respondPackageError(c, err)
+		return
+	}
+
+	before.History = nil
+	after := *customerPackage
+	after.History = nil
+	h.audit(c, "customer_package", customerPackageID, models.AuditActionUpdate, before, &after)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    customerPackage,
+		"message": "Package adjusted successfully",
+	})
+}
+
+func respondPackageError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "package not found", err.Error() == "customer package not found", err.Error() == "user not found":
+		statusCode = http.StatusNotFound
+	case err.Error() == "package has been sold, deactivate it instead":
+		statusCode = http.StatusConflict
+	case strings.HasPrefix(err.Error(), "invalid"), err.Error() == "package is not active",
+		err.Error() == "package name is required", err.Error() == "memberships have no sessions to adjust",
+		strings.HasPrefix(err.Error(), "nothing to adjust"):
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// GetUserWallet returns a customer's wallet balances with their ledger, newest first
+func (h *AdminHandler) GetUserWallet(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	limit := 50
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	balances, err := h.walletService.GetBalances(userID)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	entries, total, err := h.walletService.ListEntries(userID, limit, offset)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"balances": balances,
+			"entries":  entries,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+		},
+	})
+}
+
+// AdjustUserWallet credits a customer's wallet or, with a negative amount, debits it
+func (h *AdminHandler) AdjustUserWallet(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req models.WalletAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	actorType, actorID, actor := requestActor(c)
+	entry, err := h.walletService.Adjust(userID, &req, actorType, actorID, actor)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	h.audit(c, "wallet_entry", entry.ID, models.AuditActionCreate, nil, entry)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    entry,
+		"message": "Wallet adjusted successfully",
+	})
+}
+
+// Gift Cards
+func (h *AdminHandler) GetGiftCards(c *gin.Context) {
+	limit := 50
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	cards, total, err := h.walletService.ListGiftCards(limit, offset)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"gift_cards": cards,
+			"total":      total,
+			"limit":      limit,
+			"offset":     offset,
+		},
+	})
+}
+
+func (h *AdminHandler) GetGiftCard(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid gift card ID")
+	if !ok {
+		return
+	}
+
+	card, err := h.walletService.GetGiftCard(id)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    card,
+	})
+}
+
+// CreateGiftCard records a gift card sold at the desk and returns its code
+func (h *AdminHandler) CreateGiftCard(c *gin.Context) {
+	var req models.CreateGiftCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	actorType, actorID, actor := requestActor(c)
+	card, err := h.walletService.SellGiftCard(&req, actorType, actorID, actor)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	h.audit(c, "gift_card", card.ID, models.AuditActionCreate, nil, card)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    card,
+		"message": "Gift card created successfully",
+	})
+}
+
+func respondWalletError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "user not found", err.Error() == "gift card not found":
+		statusCode = http.StatusNotFound
+	case err.Error() == "gift card has already been redeemed":
+		statusCode = http.StatusConflict
+	case strings.HasPrefix(err.Error(), "invalid"), err.Error() == "insufficient wallet balance",
+		err.Error() == "gift card has expired":
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// Cash Sessions
+func (h *AdminHandler) GetCashSessions(c *gin.Context) {
+	limit := 50
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	sessions, total, err := h.cashSessionService.List(models.CashSessionStatus(c.Query("status")), limit, offset)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"cash_sessions": sessions,
+			"total":         total,
+			"limit":         limit,
+			"offset":        offset,
+		},
+	})
+}
+
+// GetCurrentCashSession returns the open session, 404 when the drawer is closed
+func (h *AdminHandler) GetCurrentCashSession(c *gin.Context) {
+	session, err := h.cashSessionService.GetOpen()
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    session,
+	})
+}
+
+func (h *AdminHandler) GetCashSession(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid cash session ID")
+	if !ok {
+		return
+	}
+
+	session, err := h.cashSessionService.GetByID(id)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    session,
+	})
+}
+
+// GetCashSessionZReport returns the session's Z-report, the running totals
+// while it is open
+func (h *AdminHandler) GetCashSessionZReport(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid cash session ID")
+	if !ok {
+		return
+	}
+
+	report, err := h.cashSessionService.ZReport(id)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// OpenCashSession opens the drawer with its float
+func (h *AdminHandler) OpenCashSession(c *gin.Context) {
+	var req models.OpenCashSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	_, _, actor := requestActor(c)
+	session, err := h.cashSessionService.Open(&req, actor)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	h.audit(c, "cash_session", session.ID, models.AuditActionCreate, nil, session)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    session,
+		"message": "Cash session opened successfully",
+	})
+}
+
+// CloseCashSession takes the counted amounts and returns the Z-report; a
+// closed session can be counted again until it is approved
+func (h *AdminHandler) CloseCashSession(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid cash session ID")
+	if !ok {
+		return
+	}
+
+	var req models.CloseCashSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	before := auditState(h.cashSessionService.GetByID(id))
+	_, _, actor := requestActor(c)
+	session, err := h.cashSessionService.Close(id, &req, actor)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	h.audit(c, "cash_session", id, models.AuditActionClose, before, session)
+
+	report, err := h.cashSessionService.ZReport(id)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+		"message": "Cash session closed successfully",
+	})
+}
+
+// ApproveCashSession locks a closed session and its Z-report
+func (h *AdminHandler) ApproveCashSession(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid cash session ID")
+	if !ok {
+		return
+	}
+
+	before := auditState(h.cashSessionService.GetByID(id))
+	_, _, actor := requestActor(c)
+	session, err := h.cashSessionService.Approve(id, actor)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return
+	}
+
+	h.audit(c, "cash_session", id, models.AuditActionApprove, before, session)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    session,
+		"message": "Cash session approved successfully",
+	})
+}
+
+func respondCashSessionError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "cash session not found", err.Error() == "no cash session is open":
+		statusCode = http.StatusNotFound
+	case err.Error() == "a cash session is already open", strings.HasPrefix(err.Error(), "cash session"):
+		statusCode = http.StatusConflict
+	case strings.HasPrefix(err.Error(), "invalid"):
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// Contact Messages
+func (h *AdminHandler) GetContactMessages(c *gin.Context) {
+	limit := 50
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	messages, total, err := h.contactService.List(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"messages": messages,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+		},
+	})
+}
+
+func (h *AdminHandler) MarkContactMessageRead(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid message ID",
+		})
+		return
+	}
+
+	before := auditState(h.contactService.GetByID(id))
+	err = h.contactService.MarkAsRead(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "contact_message", id, models.AuditActionMarkRead, before, auditState(h.contactService.GetByID(id)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Message marked as read",
+	})
+}
+
+func (h *AdminHandler) DeleteContactMessage(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid message ID",
+		})
+		return
+	}
+
+	before := auditState(h.contactService.GetByID(id))
+	err = h.contactService.Delete(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "contact_message", id, models.AuditActionDelete, before, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Message deleted successfully",
@@ -1242,11 +2625,96 @@ func (h *AdminHandler) GetSalesReports(c *gin.Context) {
 		return
 	}
 
+	byTaxRate, err := h.paymentService.GetRevenueByTaxRate(start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Packages are paid at the desk without a payment record, they are
+	// revenue when sold
+	packageByTaxRate, err := h.packageService.GetSalesByTaxRate(start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Gift cards are money taken in advance, the revenue is the appointments
+	// paid from the wallet later
+	giftCardSales, err := h.walletService.GetGiftCardSales(start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Every amount is read in the tenant's currency, a mix means the report
+	// cannot be added up
+	for _, line := range append(append([]*models.SalesByTaxRate{}, byTaxRate...), packageByTaxRate...) {
+		if !line.Gross.SameCurrency(totalRevenue) || !line.Net.SameCurrency(totalRevenue) || !line.Tax.SameCurrency(totalRevenue) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   models.ErrCurrencyMismatch.Error(),
+			})
+			return
+		}
+	}
+	if !giftCardSales.Amount.SameCurrency(totalRevenue) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   models.ErrCurrencyMismatch.Error(),
+		})
+		return
+	}
+
+	packageSales := models.SalesTotal{Amount: models.NewMoney(0, totalRevenue.Currency)}
+	for _, packageLine := range packageByTaxRate {
+		packageSales.Count += packageLine.Payments
+		packageSales.Amount = packageSales.Amount.Add(packageLine.Gross)
+		totalRevenue = totalRevenue.Add(packageLine.Gross)
+
+		merged := false
+		for _, line := range byTaxRate {
+			if line.TaxRate == packageLine.TaxRate {
+				line.Payments += packageLine.Payments
+				line.Net = line.Net.Add(packageLine.Net)
+				line.Tax = line.Tax.Add(packageLine.Tax)
+				line.Gross = line.Gross.Add(packageLine.Gross)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			byTaxRate = append(byTaxRate, packageLine)
+		}
+	}
+	sort.Slice(byTaxRate, func(i, j int) bool { return byTaxRate[i].TaxRate < byTaxRate[j].TaxRate })
+
+	netRevenue := models.NewMoney(0, totalRevenue.Currency)
+	taxTotal := models.NewMoney(0, totalRevenue.Currency)
+	for _, line := range byTaxRate {
+		netRevenue = netRevenue.Add(line.Net)
+		taxTotal = taxTotal.Add(line.Tax)
+	}
+
 	report := gin.H{
-		"start_date":    startDate,
-		"end_date":      endDate,
-		"total_revenue": totalRevenue,
-		"currency":      "TRY",
+		"start_date":      startDate,
+		"end_date":        endDate,
+		"total_revenue":   totalRevenue,
+		"net_revenue":     netRevenue,
+		"tax_total":       taxTotal,
+		"by_tax_rate":     byTaxRate,
+		"package_sales":   packageSales,
+		"gift_card_sales": giftCardSales,
+		"currency":        totalRevenue.Currency,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -1282,17 +2750,20 @@ func (h *AdminHandler) GetPaymentReports(c *gin.Context) {
 		return
 	}
 
-	// Calculate totals
-	var totalAmount float64
+	// Calculate totals in the tenant's currency, payments in an earlier
+	// currency are listed but not added up
+	totalAmount := models.NewMoney(0, h.paymentService.Currency())
 	for _, payment := range completedPayments {
-		totalAmount += payment.Amount
+		if payment.Amount.SameCurrency(totalAmount) {
+			totalAmount = totalAmount.Add(payment.Amount.Sub(payment.RefundedAmount))
+		}
 	}
 
 	report := gin.H{
 		"payments":      completedPayments,
 		"total_amount":  totalAmount,
 		"payment_count": len(completedPayments),
-		"currency":      "TRY",
+		"currency":      totalAmount.Currency,
 		"limit":         limit,
 		"offset":        offset,
 	}
@@ -1349,6 +2820,129 @@ func (h *AdminHandler) GetAppointmentReports(c *gin.Context) {
 	})
 }
 
+// GetWalletReports sums the wallet ledger per currency between start_date and
+// end_date (inclusive) and reconciles it with payments, refunds and gift cards
+func (h *AdminHandler) GetWalletReports(c *gin.Context) {
+	var filter models.WalletReportFilter
+	if d := c.Query("start_date"); d != "" {
+		start, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid start_date format, use YYYY-MM-DD",
+			})
+			return
+		}
+		filter.From = &start
+	}
+
+	if d := c.Query("end_date"); d != "" {
+		end, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid end_date format, use YYYY-MM-DD",
+			})
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	reports, err := h.walletService.Report(filter)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reports,
+	})
+}
+
+// GetPromoCodeReports lists promo code redemptions with totals per code,
+// filtered by ?promo_code_id, start_date and end_date (inclusive)
+func (h *AdminHandler) GetPromoCodeReports(c *gin.Context) {
+	var filter models.PromoRedemptionFilter
+	if p := c.Query("promo_code_id"); p != "" {
+		promoCodeID, err := strconv.Atoi(p)
+		if err != nil || promoCodeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid promo code ID",
+			})
+			return
+		}
+		filter.PromoCodeID = promoCodeID
+	}
+
+	if d := c.Query("start_date"); d != "" {
+		start, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid start_date format, use YYYY-MM-DD",
+			})
+			return
+		}
+		filter.From = &start
+	}
+
+	if d := c.Query("end_date"); d != "" {
+		end, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid end_date format, use YYYY-MM-DD",
+			})
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	limit := 50
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	codes, err := h.promoCodeService.SummarizeRedemptions(filter)
+	if err != nil {
+		respondPromoCodeError(c, err)
+		return
+	}
+
+	redemptions, total, err := h.promoCodeService.ListRedemptions(filter, limit, offset)
+	if err != nil {
+		respondPromoCodeError(c, err)
+		return
+	}
+
+	report := gin.H{
+		"codes":       codes,
+		"redemptions": redemptions,
+		"total_count": total,
+		"limit":       limit,
+		"offset":      offset,
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
 // Dashboard Stats - Legacy (Simple)
 func (h *AdminHandler) GetStats(c *gin.Context) {
 	// Demo stats - gerçek veriler için servis methodları gerekli
@@ -1462,13 +3056,13 @@ func (h *AdminHandler) GetDashboardStats(c *gin.Context) {
 
 	// Calculate growth percentages
 	var revenueMonthlyGrowth float64
-	if prevMonthlyRevenue > 0 {
-		revenueMonthlyGrowth = ((monthlyRevenue - prevMonthlyRevenue) / prevMonthlyRevenue) * 100
+	if prevMonthlyRevenue.Amount > 0 {
+		revenueMonthlyGrowth = float64(monthlyRevenue.Amount-prevMonthlyRevenue.Amount) / float64(prevMonthlyRevenue.Amount) * 100
 	}
 
 	var revenueYearlyGrowth float64
-	if prevYearlyRevenue > 0 {
-		revenueYearlyGrowth = ((yearlyRevenue - prevYearlyRevenue) / prevYearlyRevenue) * 100
+	if prevYearlyRevenue.Amount > 0 {
+		revenueYearlyGrowth = float64(yearlyRevenue.Amount-prevYearlyRevenue.Amount) / float64(prevYearlyRevenue.Amount) * 100
 	}
 
 	var appointmentsTodayGrowth int = todayAppointments - prevTodayAppointments
@@ -1483,8 +3077,9 @@ func (h *AdminHandler) GetDashboardStats(c *gin.Context) {
 	// Prepare response data
 	stats := gin.H{
 		"revenue": gin.H{
-			"monthly": monthlyRevenue,
-			"yearly":  yearlyRevenue,
+			"monthly":  monthlyRevenue,
+			"yearly":   yearlyRevenue,
+			"currency": monthlyRevenue.Currency,
 		},
 		"appointments": gin.H{
 			"today":   todayAppointments,
@@ -1550,6 +3145,8 @@ func (h *AdminHandler) UploadServiceImage(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "service_image", result.PublicID, models.AuditActionUpload, nil, result)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    result,
@@ -1598,6 +3195,8 @@ func (h *AdminHandler) DeleteServiceImage(c *gin.Context) {
 		return
 	}
 
+	h.audit(c, "service_image", request.PublicID, models.AuditActionDelete, nil, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Image deleted successfully",
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"appointment-api/internal/middleware"
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type APIKeyHandler struct {
+	apiKeyService services.APIKeyService
+	auditService  services.AuditService
+	validator     *validator.Validate
+}
+
+func NewAPIKeyHandler(apiKeyService services.APIKeyService, auditService services.AuditService, validator *validator.Validate) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		auditService:  auditService,
+		validator:     validator,
+	}
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.List()
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeyService.GetByID(id)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key,
+	})
+}
+
+// CreateAPIKey returns the key itself; it cannot be retrieved again later
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	createdBy := 0
+	if user, exists := middleware.GetCurrentUser(c); exists {
+		createdBy = user.ID
+	}
+
+	key, err := h.apiKeyService.Create(&req, createdBy)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "api_key", key.ID, models.AuditActionCreate, nil, &key.APIKey)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    key,
+		"message": "API key created, store it now as it will not be shown again",
+	})
+}
+
+func (h *APIKeyHandler) UpdateAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	before := auditState(h.apiKeyService.GetByID(id))
+
+	key, err := h.apiKeyService.Update(id, &req)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "api_key", id, models.AuditActionUpdate, before, key)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key,
+		"message": "API key updated successfully",
+	})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	before := auditState(h.apiKeyService.GetByID(id))
+
+	if err := h.apiKeyService.Revoke(id); err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "api_key", id, models.AuditActionRevoke, before, auditState(h.apiKeyService.GetByID(id)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "API key revoked successfully",
+	})
+}
+
+func respondAPIKeyError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "api key not found":
+		statusCode = http.StatusNotFound
+	case err.Error() == "api key is revoked":
+		statusCode = http.StatusConflict
+	case err.Error() == "at least one scope is required" ||
+		err.Error() == "expiry must be in the future" ||
+		strings.HasPrefix(err.Error(), "invalid api key scope"):
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
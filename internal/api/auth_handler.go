@@ -4,7 +4,10 @@ import (
 	"appointment-api/internal/middleware"
 	"appointment-api/internal/models"
 	"appointment-api/internal/services"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -43,7 +46,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Register(&req)
+	response, err := h.authService.Register(&req, clientInfo(c))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "email already exists" {
@@ -82,11 +85,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	response, challenge, err := h.authService.Login(&req, clientInfo(c))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "invalid credentials" {
 			statusCode = http.StatusUnauthorized
+		} else if throttledLogin(c, err) {
+			statusCode = http.StatusTooManyRequests
 		}
 		c.JSON(statusCode, gin.H{
 			"success": false,
@@ -95,6 +100,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if challenge != nil {
+		respondTwoFactorChallenge(c, challenge)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    response,
@@ -121,11 +131,13 @@ func (h *AuthHandler) AdminLogin(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	response, challenge, err := h.authService.AdminLogin(&req, clientInfo(c))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "invalid credentials" || err.Error() == "user is not an admin" {
 			statusCode = http.StatusUnauthorized
+		} else if throttledLogin(c, err) {
+			statusCode = http.StatusTooManyRequests
 		}
 		c.JSON(statusCode, gin.H{
 			"success": false,
@@ -134,6 +146,11 @@ func (h *AuthHandler) AdminLogin(c *gin.Context) {
 		return
 	}
 
+	if challenge != nil {
+		respondTwoFactorChallenge(c, challenge)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    response,
@@ -189,7 +206,12 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	err := h.authService.ChangePassword(user.ID, req.CurrentPassword, req.NewPassword)
+	sessionID := 0
+	if session, ok := middleware.GetCurrentSession(c); ok {
+		sessionID = session.ID
+	}
+
+	err := h.authService.ChangePassword(user.ID, sessionID, req.CurrentPassword, req.NewPassword)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "current password is incorrect" {
@@ -233,7 +255,11 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 
 	updatedUser, err := h.authService.UpdateProfile(&req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid tax number" {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -273,14 +299,15 @@ func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   err.Error(),
+			"error":   "Failed to process password reset request",
 		})
 		return
 	}
 
+	// Same answer whether or not the email has an account
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Password reset instructions sent to your email",
+		"message": "If an account exists for this email, password reset instructions have been sent",
 	})
 }
 
@@ -325,3 +352,258 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		"message": "Password reset successfully",
 	})
 }
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.authService.VerifyEmail(req.Token)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid or expired token" {
+			statusCode = http.StatusBadRequest
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user,
+		"message": "Email verified successfully",
+	})
+}
+
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	if err := h.authService.ResendVerification(user.ID); err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "email is already verified":
+			statusCode = http.StatusConflict
+		case "too many verification requests, try again later":
+			statusCode = http.StatusTooManyRequests
+		case "user not found":
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Verification email sent",
+	})
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response, err := h.authService.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid refresh token" {
+			statusCode = http.StatusUnauthorized
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+	})
+}
+
+// Logout revokes the session of the access token used for the request
+func (h *AuthHandler) Logout(c *gin.Context) {
+	user, userExists := middleware.GetCurrentUser(c)
+	session, sessionExists := middleware.GetCurrentSession(c)
+	if !userExists || !sessionExists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	if err := h.authService.Logout(user.ID, session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll revokes every session of the user, including the current one
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	if err := h.authService.LogoutAll(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Logged out of all sessions",
+	})
+}
+
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	currentSessionID := 0
+	if session, ok := middleware.GetCurrentSession(c); ok {
+		currentSessionID = session.ID
+	}
+
+	sessions, err := h.authService.ListSessions(user.ID, currentSessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    sessions,
+	})
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	id, ok := parseIDParam(c, "id", "Invalid session ID")
+	if !ok {
+		return
+	}
+
+	if err := h.authService.RevokeSession(user.ID, id); err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "session not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Session revoked successfully",
+	})
+}
+
+// throttledLogin reports whether a login was rejected by throttling and sets
+// the Retry-After header
+func throttledLogin(c *gin.Context, err error) bool {
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	return true
+}
+
+// clientInfo identifies the device and tenant a login or refresh comes from
+func clientInfo(c *gin.Context) models.ClientInfo {
+	client := models.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+	if tenant, exists := middleware.GetCurrentTenant(c); exists {
+		client.TenantID = tenant.ID
+	}
+	return client
+}
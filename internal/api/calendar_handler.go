@@ -0,0 +1,262 @@
+package api
+
+import (
+	"appointment-api/internal/middleware"
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalendarHandler struct {
+	calendarService    services.CalendarService
+	appointmentService services.AppointmentService
+	auditService       services.AuditService
+	trustedProxies     []netip.Prefix
+}
+
+// NewCalendarHandler takes the TRUSTED_PROXIES list, only those proxies may set
+// the scheme of feed URLs through X-Forwarded-Proto
+func NewCalendarHandler(calendarService services.CalendarService, appointmentService services.AppointmentService, auditService services.AuditService, trustedProxies []string) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService:    calendarService,
+		appointmentService: appointmentService,
+		auditService:       auditService,
+		trustedProxies:     parseTrustedProxies(trustedProxies),
+	}
+}
+
+// GetFeed serves the read-only ICS feed identified by its token (no auth header,
+// calendar clients only know the URL)
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	tenant, exists := middleware.GetCurrentTenant(c)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Tenant not found",
+		})
+		return
+	}
+
+	feed, err := h.calendarService.GetFeed(token, tenant)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "calendar feed not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", feed)
+}
+
+func (h *CalendarHandler) DownloadAppointment(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	appointmentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid appointment ID",
+		})
+		return
+	}
+
+	appointment, err := h.appointmentService.GetByID(appointmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Appointment not found",
+		})
+		return
+	}
+
+	// Verify ownership
+	if appointment.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Access denied to this appointment",
+		})
+		return
+	}
+
+	tenant, exists := middleware.GetCurrentTenant(c)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Tenant not found",
+		})
+		return
+	}
+
+	ics, err := h.calendarService.GetAppointmentICS(appointment.ID, tenant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="appointment-%d.ics"`, appointment.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", ics)
+}
+
+func (h *CalendarHandler) GetUserFeed(c *gin.Context) {
+	h.userFeed(c, false)
+}
+
+func (h *CalendarHandler) RegenerateUserFeed(c *gin.Context) {
+	h.userFeed(c, true)
+}
+
+func (h *CalendarHandler) GetSpecialistFeed(c *gin.Context) {
+	h.specialistFeed(c, false)
+}
+
+func (h *CalendarHandler) RegenerateSpecialistFeed(c *gin.Context) {
+	h.specialistFeed(c, true)
+}
+
+func (h *CalendarHandler) userFeed(c *gin.Context, regenerate bool) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	h.respondWithFeed(c, models.CalendarOwnerUser, user.ID, regenerate)
+}
+
+func (h *CalendarHandler) specialistFeed(c *gin.Context, regenerate bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid specialist ID",
+		})
+		return
+	}
+
+	h.respondWithFeed(c, models.CalendarOwnerSpecialist, id, regenerate)
+}
+
+func (h *CalendarHandler) respondWithFeed(c *gin.Context, ownerType models.CalendarOwnerType, ownerID int, regenerate bool) {
+	var feedToken *models.CalendarFeedToken
+	var before interface{}
+	var err error
+	if regenerate {
+		if existing, findErr := h.calendarService.FindFeedToken(ownerType, ownerID); findErr == nil && existing != nil {
+			before = existing
+		}
+		feedToken, err = h.calendarService.RegenerateFeedToken(ownerType, ownerID)
+	} else {
+		feedToken, err = h.calendarService.GetFeedToken(ownerType, ownerID)
+	}
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "specialist not found" || err.Error() == "user not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if regenerate {
+		// the plain token must not reach the audit log
+		after := *feedToken
+		after.Token = ""
+		recordAudit(h.auditService, c, "calendar_feed", feedToken.ID, models.AuditActionRotateSecret, before, &after)
+	}
+
+	info := models.CalendarFeedInfo{CreatedAt: feedToken.CreatedAt}
+	message := "Calendar feed exists, its URL is only shown when created; regenerate it to get a new URL"
+	// Only a token created by this request still has its plain value
+	if feedToken.Token != "" {
+		path := "/api/calendar/feeds/" + feedToken.Token + ".ics"
+		info.Token = feedToken.Token
+		info.URL = h.requestScheme(c) + "://" + c.Request.Host + path
+		info.WebcalURL = "webcal://" + c.Request.Host + path
+		message = "Calendar feed created, store the URL now, it is not shown again"
+		if regenerate {
+			message = "Calendar feed regenerated, previous URL no longer works"
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    info,
+		"message": message,
+	})
+}
+
+// requestScheme believes X-Forwarded-Proto only from a trusted proxy, anyone
+// else could point the feed URL at plain http
+func (h *CalendarHandler) requestScheme(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" && h.fromTrustedProxy(c) {
+		switch scheme := strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0])); scheme {
+		case "http", "https":
+			return scheme
+		}
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func (h *CalendarHandler) fromTrustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies reads IPs and CIDRs like gin's SetTrustedProxies, which
+// has already refused invalid entries at startup
+func parseTrustedProxies(entries []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type ExternalCalendarHandler struct {
+	externalCalendarService services.ExternalCalendarService
+	auditService            services.AuditService
+	validator               *validator.Validate
+}
+
+func NewExternalCalendarHandler(externalCalendarService services.ExternalCalendarService, auditService services.AuditService, validator *validator.Validate) *ExternalCalendarHandler {
+	return &ExternalCalendarHandler{
+		externalCalendarService: externalCalendarService,
+		auditService:            auditService,
+		validator:               validator,
+	}
+}
+
+func (h *ExternalCalendarHandler) GetExternalCalendars(c *gin.Context) {
+	specialistID, ok := parseIDParam(c, "id", "Invalid specialist ID")
+	if !ok {
+		return
+	}
+
+	calendars, err := h.externalCalendarService.List(specialistID)
+	if err != nil {
+		respondExternalCalendarError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    calendars,
+	})
+}
+
+// CreateExternalCalendar accepts either a JSON body with a URL or a multipart
+// upload with a "file" field (and "name")
+func (h *ExternalCalendarHandler) CreateExternalCalendar(c *gin.Context) {
+	specialistID, ok := parseIDParam(c, "id", "Invalid specialist ID")
+	if !ok {
+		return
+	}
+
+	var calendar *models.ExternalCalendar
+	var err error
+
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, header, fileErr := c.Request.FormFile("file")
+		if fileErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "No ICS file provided",
+			})
+			return
+		}
+		defer file.Close()
+
+		data, readErr := io.ReadAll(io.LimitReader(file, 5*1024*1024+1))
+		if readErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Failed to read ICS file",
+			})
+			return
+		}
+
+		name := c.PostForm("name")
+		if name == "" {
+			name = strings.TrimSuffix(header.Filename, ".ics")
+		}
+
+		calendar, err = h.externalCalendarService.CreateFromFile(specialistID, name, data)
+	} else {
+		var req models.CreateExternalCalendarRequest
+		if bindErr := c.ShouldBindJSON(&req); bindErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid request format",
+			})
+			return
+		}
+
+		if validateErr := h.validator.Struct(&req); validateErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Validation failed: " + validateErr.Error(),
+			})
+			return
+		}
+
+		calendar, err = h.externalCalendarService.CreateFromURL(specialistID, &req)
+	}
+
+	if err != nil {
+		respondExternalCalendarError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "external_calendar", calendar.ID, models.AuditActionCreate, nil, calendar)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    calendar,
+		"message": "External calendar added successfully",
+	})
+}
+
+func (h *ExternalCalendarHandler) DeleteExternalCalendar(c *gin.Context) {
+	specialistID, ok := parseIDParam(c, "id", "Invalid specialist ID")
+	if !ok {
+		return
+	}
+	calendarID, ok := parseIDParam(c, "calendarId", "Invalid calendar ID")
+	if !ok {
+		return
+	}
+
+	before := auditState(h.externalCalendarService.Get(specialistID, calendarID))
+
+	if err := h.externalCalendarService.Delete(specialistID, calendarID); err != nil {
+		respondExternalCalendarError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "external_calendar", calendarID, models.AuditActionDelete, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "External calendar deleted successfully",
+	})
+}
+
+func (h *ExternalCalendarHandler) SyncExternalCalendar(c *gin.Context) {
+	specialistID, ok := parseIDParam(c, "id", "Invalid specialist ID")
+	if !ok {
+		return
+	}
+	calendarID, ok := parseIDParam(c, "calendarId", "Invalid calendar ID")
+	if !ok {
+		return
+	}
+
+	before := auditState(h.externalCalendarService.Get(specialistID, calendarID))
+
+	calendar, err := h.externalCalendarService.Sync(specialistID, calendarID)
+	if err != nil {
+		respondExternalCalendarError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "external_calendar", calendarID, models.AuditActionSync, before, calendar)
+
+	message := "External calendar synced successfully"
+	if calendar.LastError != "" {
+		message = "External calendar sync failed, previous busy times are kept"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    calendar,
+		"message": message,
+	})
+}
+
+// GetBusyBlocks lists imported busy times, defaults to the next 7 days
+func (h *ExternalCalendarHandler) GetBusyBlocks(c *gin.Context) {
+	specialistID, ok := parseIDParam(c, "id", "Invalid specialist ID")
+	if !ok {
+		return
+	}
+
+	from := time.Now().Truncate(24 * time.Hour)
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid from date format, use YYYY-MM-DD",
+			})
+			return
+		}
+		from = parsed
+	}
+
+	to := from.AddDate(0, 0, 7)
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid to date format, use YYYY-MM-DD",
+			})
+			return
+		}
+		// Inclusive end date
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	blocks, err := h.externalCalendarService.GetBusyBlocks(specialistID, from, to)
+	if err != nil {
+		respondExternalCalendarError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    blocks,
+	})
+}
+
+func respondExternalCalendarError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "specialist not found" || err.Error() == "external calendar not found":
+		statusCode = http.StatusNotFound
+	case err.Error() == "invalid specialist ID" ||
+		err.Error() == "calendar name is required" ||
+		strings.HasPrefix(err.Error(), "invalid calendar URL") ||
+		err.Error() == "ICS file too large" ||
+		strings.HasPrefix(err.Error(), "invalid ICS data"):
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+func parseIDParam(c *gin.Context, name string, message string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   message,
+		})
+		return 0, false
+	}
+	return id, true
+}
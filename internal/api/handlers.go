@@ -3,25 +3,49 @@ package api
 import (
 	"appointment-api/internal/config"
 	"appointment-api/internal/middleware"
+	"appointment-api/internal/models"
 	"appointment-api/internal/services"
 	"database/sql"
+	"reflect"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
 type Handlers struct {
-	Auth   *AuthHandler
-	Public *PublicHandler
-	Admin  *AdminHandler
+	Auth             *AuthHandler
+	Public           *PublicHandler
+	Admin            *AdminHandler
+	Calendar         *CalendarHandler
+	ExternalCalendar *ExternalCalendarHandler
+	Notification     *NotificationHandler
+	Reminder         *ReminderHandler
+	Webhook          *WebhookHandler
+	TwoFactor        *TwoFactorHandler
+	APIKey           *APIKeyHandler
+	Payment          *PaymentHandler
+	Invoice          *InvoiceHandler
 }
 
-func NewHandlers(svc *services.Services) *Handlers {
+func NewHandlers(svc *services.Services, cfg *config.Config) *Handlers {
 	validate := validator.New()
+	// Amounts are validated by their minor units, so "gt=0" and "min=0" still apply
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(models.Money).Amount
+	}, models.Money{})
 	return &Handlers{
-		Auth:   NewAuthHandler(svc.Auth),
-		Public: NewPublicHandler(svc.Category, svc.Service, svc.Specialist, svc.Appointment, svc.Payment, svc.Contact, validate),
-		Admin:  NewAdminHandler(svc.Category, svc.Service, svc.Device, svc.Settings, svc.Auth, svc.User, svc.Specialist, svc.Appointment, svc.Payment, svc.Contact, svc.Upload, validate),
+		Auth:             NewAuthHandler(svc.Auth),
+		Public:           NewPublicHandler(svc.Category, svc.Service, svc.Specialist, svc.Appointment, svc.Payment, svc.Package, svc.Wallet, svc.Contact, validate),
+		Admin:            NewAdminHandler(svc.Category, svc.Service, svc.Device, svc.Settings, svc.Auth, svc.User, svc.Specialist, svc.Appointment, svc.Payment, svc.PromoCode, svc.Package, svc.Wallet, svc.CashSession, svc.Contact, svc.Upload, svc.Audit, validate),
+		Calendar:         NewCalendarHandler(svc.Calendar, svc.Appointment, svc.Audit, cfg.Server.TrustedProxies),
+		ExternalCalendar: NewExternalCalendarHandler(svc.ExternalCalendar, svc.Audit, validate),
+		Notification:     NewNotificationHandler(svc.Notification, svc.Audit, validate),
+		Reminder:         NewReminderHandler(svc.Reminder),
+		Webhook:          NewWebhookHandler(svc.Webhook, svc.Audit, validate),
+		TwoFactor:        NewTwoFactorHandler(svc.TwoFactor, svc.Auth, svc.Audit, validate),
+		APIKey:           NewAPIKeyHandler(svc.APIKey, svc.Audit, validate),
+		Payment:          NewPaymentHandler(svc.Payment, svc.PaymentAccount, svc.Audit, validate, cfg.Reminder.LinkScheme),
+		Invoice:          NewInvoiceHandler(svc.Invoice, svc.Audit),
 	}
 }
 
@@ -45,6 +69,11 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 		})
 	})
 
+	// Payment provider webhooks (signed by the provider, no auth). Each tenant
+	// registers its own endpoint at its gateway account, the tenant is taken
+	// from the path and the signature is checked with that tenant's secret.
+	router.POST("/api/payments/webhooks/:provider/:tenant", middleware.TenantByIDMiddleware("tenant", svc.Tenant, svc.TenantCache, mainDB), handlers.Payment.HandleWebhook)
+
 	api := router.Group("/api")
 	api.Use(middleware.TenantMiddleware(svc.Tenant, svc.TenantCache, mainDB))
 	// Simple tenant context middleware
@@ -59,8 +88,14 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 			auth.POST("/register", handlers.Auth.Register)
 			auth.POST("/login", handlers.Auth.Login)
 			auth.POST("/admin/login", handlers.Auth.AdminLogin)
+			auth.POST("/2fa/setup", handlers.TwoFactor.SetupLogin)
+			auth.POST("/2fa/verify", handlers.TwoFactor.VerifyLogin)
 			auth.POST("/forgot-password", handlers.Auth.ForgotPassword)
 			auth.POST("/reset-password", handlers.Auth.ResetPassword)
+			auth.POST("/verify-email", handlers.Auth.VerifyEmail)
+			auth.POST("/refresh", handlers.Auth.Refresh)
+			auth.POST("/logout", middleware.AuthMiddleware(svc.Auth, svc.APIKey), handlers.Auth.Logout)
+			auth.POST("/logout-all", middleware.AuthMiddleware(svc.Auth, svc.APIKey), handlers.Auth.LogoutAll)
 		}
 
 		// Categories routes (public)
@@ -87,9 +122,19 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 			specialists.GET("/:id/available-slots", handlers.Public.GetSpecialistAvailableSlots)
 		}
 
+		// Packages on sale (public)
+		api.GET("/packages", handlers.Public.GetPackages)
+
 		// Contact route (public)
 		api.POST("/contact", handlers.Public.ContactMessage)
 
+		// Calendar feeds (token protected, read-only)
+		api.GET("/calendar/feeds/:token", handlers.Calendar.GetFeed)
+
+		// Reminder confirm/cancel links (signed, no auth)
+		api.GET("/appointment-links/:id/:action", handlers.Reminder.ShowLink)
+		api.POST("/appointment-links/:id/:action", handlers.Reminder.HandleLink)
+
 		// Public routes (categories & services)
 		public := api.Group("/public")
 		{
@@ -115,37 +160,59 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 
 		// User routes (authenticated)
 		user := api.Group("/user")
-		user.Use(middleware.AuthMiddleware(svc.Auth))
+		user.Use(middleware.AuthMiddleware(svc.Auth, svc.APIKey))
+		user.Use(middleware.IdempotencyMiddleware(svc.Idempotency))
 		{
 			user.GET("/profile", handlers.Auth.GetProfile)
 			user.PUT("/profile", handlers.Auth.UpdateProfile)
 			user.PUT("/change-password", handlers.Auth.ChangePassword)
+			user.POST("/resend-verification", handlers.Auth.ResendVerification)
+			user.GET("/sessions", handlers.Auth.GetSessions)
+			user.DELETE("/sessions/:id", handlers.Auth.RevokeSession)
+			user.GET("/calendar-feed", handlers.Calendar.GetUserFeed)
+			user.POST("/calendar-feed/regenerate", handlers.Calendar.RegenerateUserFeed)
+			user.GET("/packages", handlers.Public.GetUserPackages)
+			user.GET("/packages/:id", handlers.Public.GetUserPackageByID)
+			user.GET("/wallet", handlers.Public.GetUserWallet)
+			user.POST("/wallet/redeem", handlers.Public.RedeemGiftCard)
 		}
 
 		// Appointments routes (authenticated)
 		appointments := api.Group("/appointments")
-		appointments.Use(middleware.AuthMiddleware(svc.Auth))
+		appointments.Use(middleware.AuthMiddleware(svc.Auth, svc.APIKey))
+		appointments.Use(middleware.IdempotencyMiddleware(svc.Idempotency))
 		{
-			appointments.POST("", handlers.Public.CreateAppointment)
+			appointments.POST("", middleware.VerifiedEmailMiddleware(svc.Settings), handlers.Public.CreateAppointment)
 			appointments.GET("", handlers.Public.GetUserAppointments)
 			appointments.GET("/:id", handlers.Public.GetAppointmentByID)
 			appointments.PUT("/:id", handlers.Public.UpdateAppointment)
 			appointments.DELETE("/:id", handlers.Public.CancelAppointment)
 			appointments.POST("/:id/payment", handlers.Public.PayAppointment)
+			appointments.GET("/:id/calendar", handlers.Calendar.DownloadAppointment)
 		}
 
 		// Payments routes (authenticated)
 		payments := api.Group("/payments")
-		payments.Use(middleware.AuthMiddleware(svc.Auth))
+		payments.Use(middleware.AuthMiddleware(svc.Auth, svc.APIKey))
 		{
 			payments.GET("", handlers.Public.GetUserPayments)
 			payments.GET("/:id", handlers.Public.GetPaymentByID)
 		}
 
+		// Invoices and credit notes of the current user (authenticated)
+		invoices := api.Group("/invoices")
+		invoices.Use(middleware.AuthMiddleware(svc.Auth, svc.APIKey))
+		{
+			invoices.GET("", handlers.Invoice.GetUserInvoices)
+			invoices.GET("/:id", handlers.Invoice.GetUserInvoice)
+			invoices.GET("/:id/download", handlers.Invoice.DownloadUserInvoice)
+		}
+
 		// Admin routes (admin only)
 		admin := api.Group("/admin")
-		admin.Use(middleware.AuthMiddleware(svc.Auth))
+		admin.Use(middleware.AuthMiddleware(svc.Auth, svc.APIKey))
 		admin.Use(middleware.AdminMiddleware())
+		admin.Use(middleware.IdempotencyMiddleware(svc.Idempotency))
 		{
 			// Dashboard Stats
 			admin.GET("/stats", handlers.Admin.GetStats)
@@ -201,6 +268,41 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 				adminUsers.PUT("/:id", handlers.Admin.UpdateUser)
 				adminUsers.DELETE("/:id", handlers.Admin.DeleteUser)
 				adminUsers.PUT("/:id/role", handlers.Admin.UpdateUserRole)
+				adminUsers.POST("/:id/revoke-sessions", handlers.Admin.RevokeUserSessions)
+				adminUsers.POST("/:id/unlock", handlers.Admin.UnlockUser)
+				adminUsers.DELETE("/:id/2fa", handlers.TwoFactor.ResetUser)
+				adminUsers.GET("/:id/packages", handlers.Admin.GetUserPackages)
+				adminUsers.POST("/:id/packages", handlers.Admin.SellPackage)
+				adminUsers.POST("/:id/packages/:packageId/adjustments", handlers.Admin.AdjustUserPackage)
+				adminUsers.GET("/:id/wallet", handlers.Admin.GetUserWallet)
+				adminUsers.POST("/:id/wallet/adjustments", handlers.Admin.AdjustUserWallet)
+			}
+
+			// Two-factor authentication of the current admin
+			adminTwoFactor := admin.Group("/2fa")
+			{
+				adminTwoFactor.GET("", handlers.TwoFactor.GetStatus)
+				adminTwoFactor.POST("/setup", handlers.TwoFactor.Setup)
+				adminTwoFactor.POST("/enable", handlers.TwoFactor.Enable)
+				adminTwoFactor.POST("/disable", handlers.TwoFactor.Disable)
+				adminTwoFactor.POST("/recovery-codes", handlers.TwoFactor.RegenerateRecoveryCodes)
+			}
+
+			// Login audit
+			admin.GET("/login-attempts", handlers.Admin.GetLoginAttempts)
+
+			// Audit log of admin changes
+			admin.GET("/audit-log", handlers.Admin.GetAuditLog)
+			admin.GET("/audit-log/export", handlers.Admin.ExportAuditLog)
+
+			// API keys for server-to-server integrations
+			adminAPIKeys := admin.Group("/api-keys")
+			{
+				adminAPIKeys.GET("", handlers.APIKey.GetAPIKeys)
+				adminAPIKeys.POST("", handlers.APIKey.CreateAPIKey)
+				adminAPIKeys.GET("/:id", handlers.APIKey.GetAPIKey)
+				adminAPIKeys.PUT("/:id", handlers.APIKey.UpdateAPIKey)
+				adminAPIKeys.DELETE("/:id", handlers.APIKey.RevokeAPIKey)
 			}
 
 			// Specialists Management
@@ -212,6 +314,13 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 				adminSpecialists.DELETE("/:id", handlers.Admin.DeleteSpecialist)
 				adminSpecialists.GET("/:id/working-hours", handlers.Admin.GetSpecialistWorkingHours)
 				adminSpecialists.PUT("/:id/working-hours", handlers.Admin.UpdateSpecialistWorkingHours)
+				adminSpecialists.GET("/:id/calendar-feed", handlers.Calendar.GetSpecialistFeed)
+				adminSpecialists.POST("/:id/calendar-feed/regenerate", handlers.Calendar.RegenerateSpecialistFeed)
+				adminSpecialists.GET("/:id/external-calendars", handlers.ExternalCalendar.GetExternalCalendars)
+				adminSpecialists.POST("/:id/external-calendars", handlers.ExternalCalendar.CreateExternalCalendar)
+				adminSpecialists.DELETE("/:id/external-calendars/:calendarId", handlers.ExternalCalendar.DeleteExternalCalendar)
+				adminSpecialists.POST("/:id/external-calendars/:calendarId/sync", handlers.ExternalCalendar.SyncExternalCalendar)
+				adminSpecialists.GET("/:id/busy-blocks", handlers.ExternalCalendar.GetBusyBlocks)
 			}
 
 			// Appointments Management
@@ -222,6 +331,7 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 				adminAppointments.PUT("/:id", handlers.Admin.UpdateAppointment)
 				adminAppointments.DELETE("/:id", handlers.Admin.DeleteAppointment)
 				adminAppointments.PUT("/:id/status", handlers.Admin.UpdateAppointmentStatus)
+				adminAppointments.GET("/:id/reminders", handlers.Reminder.GetAppointmentReminders)
 			}
 
 			// Payments Management
@@ -231,6 +341,67 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 				adminPayments.POST("", handlers.Admin.CreatePayment)
 				adminPayments.PUT("/:id", handlers.Admin.UpdatePayment)
 				adminPayments.DELETE("/:id", handlers.Admin.DeletePayment)
+				adminPayments.POST("/:id/sync", handlers.Admin.SyncPayment)
+				adminPayments.GET("/:id/refunds", handlers.Admin.GetPaymentRefunds)
+				adminPayments.POST("/:id/refunds", handlers.Admin.RefundPayment)
+				adminPayments.POST("/:id/invoice", handlers.Invoice.IssuePaymentInvoice)
+			}
+
+			// Payment gateway credentials of the tenant
+			adminPaymentProviders := admin.Group("/payment-providers")
+			{
+				adminPaymentProviders.GET("/:provider", handlers.Payment.GetProviderAccount)
+				adminPaymentProviders.PUT("/:provider", handlers.Payment.SaveProviderAccount)
+				adminPaymentProviders.DELETE("/:provider", handlers.Payment.DeleteProviderAccount)
+			}
+
+			// Invoices and credit notes
+			adminInvoices := admin.Group("/invoices")
+			{
+				adminInvoices.GET("", handlers.Invoice.GetInvoices)
+				adminInvoices.GET("/export", handlers.Invoice.ExportInvoices)
+				adminInvoices.GET("/:id", handlers.Invoice.GetInvoice)
+				adminInvoices.GET("/:id/download", handlers.Invoice.DownloadInvoice)
+			}
+
+			// Promo codes applied at booking
+			adminPromoCodes := admin.Group("/promo-codes")
+			{
+				adminPromoCodes.GET("", handlers.Admin.GetPromoCodes)
+				adminPromoCodes.POST("", handlers.Admin.CreatePromoCode)
+				adminPromoCodes.GET("/:id", handlers.Admin.GetPromoCode)
+				adminPromoCodes.PUT("/:id", handlers.Admin.UpdatePromoCode)
+				adminPromoCodes.DELETE("/:id", handlers.Admin.DeletePromoCode)
+			}
+
+			// Prepaid session packages and memberships
+			adminPackages := admin.Group("/packages")
+			{
+				adminPackages.GET("", handlers.Admin.GetPackages)
+				adminPackages.POST("", handlers.Admin.CreatePackage)
+				adminPackages.GET("/:id", handlers.Admin.GetPackage)
+				adminPackages.PUT("/:id", handlers.Admin.UpdatePackage)
+				adminPackages.DELETE("/:id", handlers.Admin.DeletePackage)
+			}
+
+			// Gift cards sold at the desk
+			adminGiftCards := admin.Group("/gift-cards")
+			{
+				adminGiftCards.GET("", handlers.Admin.GetGiftCards)
+				adminGiftCards.POST("", handlers.Admin.CreateGiftCard)
+				adminGiftCards.GET("/:id", handlers.Admin.GetGiftCard)
+			}
+
+			// Cash drawer sessions and Z-reports
+			adminCashSessions := admin.Group("/cash-sessions")
+			{
+				adminCashSessions.GET("", handlers.Admin.GetCashSessions)
+				adminCashSessions.POST("", handlers.Admin.OpenCashSession)
+				adminCashSessions.GET("/current", handlers.Admin.GetCurrentCashSession)
+				adminCashSessions.GET("/:id", handlers.Admin.GetCashSession)
+				adminCashSessions.GET("/:id/z-report", handlers.Admin.GetCashSessionZReport)
+				adminCashSessions.POST("/:id/close", handlers.Admin.CloseCashSession)
+				adminCashSessions.POST("/:id/approve", handlers.Admin.ApproveCashSession)
 			}
 
 			// Contact Messages Management
@@ -247,6 +418,32 @@ func SetupRoutes(router *gin.Engine, handlers *Handlers, svc *services.Services,
 				adminReports.GET("/sales", handlers.Admin.GetSalesReports)
 				adminReports.GET("/payments", handlers.Admin.GetPaymentReports)
 				adminReports.GET("/appointments", handlers.Admin.GetAppointmentReports)
+				adminReports.GET("/promo-codes", handlers.Admin.GetPromoCodeReports)
+				adminReports.GET("/wallet", handlers.Admin.GetWalletReports)
+			}
+
+			// Notification templates & outbox
+			adminNotifications := admin.Group("/notifications")
+			{
+				adminNotifications.GET("/templates", handlers.Notification.GetTemplates)
+				adminNotifications.PUT("/templates/:event/:channel", handlers.Notification.UpdateTemplate)
+				adminNotifications.DELETE("/templates/:event/:channel", handlers.Notification.ResetTemplate)
+				adminNotifications.GET("/outbox", handlers.Notification.GetOutbox)
+				adminNotifications.POST("/outbox/:id/retry", handlers.Notification.RetryOutboxItem)
+			}
+
+			// Outgoing webhooks & delivery log
+			adminWebhooks := admin.Group("/webhooks")
+			{
+				adminWebhooks.GET("", handlers.Webhook.GetWebhooks)
+				adminWebhooks.POST("", handlers.Webhook.CreateWebhook)
+				adminWebhooks.GET("/deliveries", handlers.Webhook.GetDeliveries)
+				adminWebhooks.POST("/deliveries/:id/redeliver", handlers.Webhook.Redeliver)
+				adminWebhooks.GET("/:id", handlers.Webhook.GetWebhook)
+				adminWebhooks.PUT("/:id", handlers.Webhook.UpdateWebhook)
+				adminWebhooks.DELETE("/:id", handlers.Webhook.DeleteWebhook)
+				adminWebhooks.POST("/:id/rotate-secret", handlers.Webhook.RotateSecret)
+				adminWebhooks.GET("/:id/deliveries", handlers.Webhook.GetWebhookDeliveries)
 			}
 		}
 	}
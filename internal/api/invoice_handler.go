@@ -0,0 +1,313 @@
+package api
+
+import (
+	"appointment-api/internal/middleware"
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceHandler struct {
+	invoiceService services.InvoiceService
+	auditService   services.AuditService
+}
+
+func NewInvoiceHandler(invoiceService services.InvoiceService, auditService services.AuditService) *InvoiceHandler {
+	return &InvoiceHandler{
+		invoiceService: invoiceService,
+		auditService:   auditService,
+	}
+}
+
+// GetUserInvoices lists the current user's invoices and credit notes
+func (h *InvoiceHandler) GetUserInvoices(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	limit, offset := invoicePage(c, 10)
+	invoices, total, err := h.invoiceService.GetUserInvoices(user.ID, limit, offset)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"invoices": invoices,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+		},
+	})
+}
+
+func (h *InvoiceHandler) GetUserInvoice(c *gin.Context) {
+	invoice, ok := h.userInvoice(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invoice,
+	})
+}
+
+// DownloadUserInvoice sends one of the current user's invoices as ?format=pdf (default), html or xml (UBL-TR)
+func (h *InvoiceHandler) DownloadUserInvoice(c *gin.Context) {
+	invoice, ok := h.userInvoice(c)
+	if !ok {
+		return
+	}
+	h.download(c, invoice)
+}
+
+// userInvoice loads the invoice in the id parameter if it belongs to the current user
+func (h *InvoiceHandler) userInvoice(c *gin.Context) (*models.Invoice, bool) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return nil, false
+	}
+
+	id, ok := parseIDParam(c, "id", "Invalid invoice ID")
+	if !ok {
+		return nil, false
+	}
+
+	invoice, err := h.invoiceService.GetByID(id)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return nil, false
+	}
+
+	if invoice.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Access denied to this invoice",
+		})
+		return nil, false
+	}
+	return invoice, true
+}
+
+// GetInvoices lists all invoices, filtered by ?type, user_id, start_date and end_date
+func (h *InvoiceHandler) GetInvoices(c *gin.Context) {
+	filter, ok := invoiceFilter(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := invoicePage(c, 20)
+	invoices, total, err := h.invoiceService.List(filter, limit, offset)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"invoices": invoices,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+		},
+	})
+}
+
+func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid invoice ID")
+	if !ok {
+		return
+	}
+
+	invoice, err := h.invoiceService.GetByID(id)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invoice,
+	})
+}
+
+func (h *InvoiceHandler) DownloadInvoice(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid invoice ID")
+	if !ok {
+		return
+	}
+
+	invoice, err := h.invoiceService.GetByID(id)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+	h.download(c, invoice)
+}
+
+// IssuePaymentInvoice invoices a completed payment, for payments whose
+// automatic invoice failed. The existing invoice is returned if there is one.
+func (h *InvoiceHandler) IssuePaymentInvoice(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid payment ID")
+	if !ok {
+		return
+	}
+
+	existing, err := h.invoiceService.GetByPaymentID(id)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+
+	invoice, err := h.invoiceService.IssueForPayment(id)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+
+	if existing == nil {
+		recordAudit(h.auditService, c, "invoice", invoice.ID, models.AuditActionCreate, nil, invoice)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invoice,
+	})
+}
+
+// ExportInvoices downloads the filtered invoices as a zip of UBL-TR XML files for e-Arşiv/e-Fatura filing
+func (h *InvoiceHandler) ExportInvoices(c *gin.Context) {
+	filter, ok := invoiceFilter(c)
+	if !ok {
+		return
+	}
+
+	archive, err := h.invoiceService.ExportUBL(filter)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="invoices-%s.zip"`, time.Now().Format("20060102")))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+func (h *InvoiceHandler) download(c *gin.Context, invoice *models.Invoice) {
+	format := c.DefaultQuery("format", services.InvoiceFormatPDF)
+	body, contentType, err := h.invoiceService.Render(invoice, format)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, invoice.Number, format))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+func invoiceFilter(c *gin.Context) (models.InvoiceFilter, bool) {
+	filter := models.InvoiceFilter{Type: models.InvoiceType(c.Query("type"))}
+	if filter.Type != "" && filter.Type != models.InvoiceTypeInvoice && filter.Type != models.InvoiceTypeCreditNote {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid invoice type",
+		})
+		return filter, false
+	}
+
+	if u := c.Query("user_id"); u != "" {
+		userID, err := strconv.Atoi(u)
+		if err != nil || userID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid user ID",
+			})
+			return filter, false
+		}
+		filter.UserID = userID
+	}
+
+	if d := c.Query("start_date"); d != "" {
+		start, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid start_date format, use YYYY-MM-DD",
+			})
+			return filter, false
+		}
+		filter.From = &start
+	}
+
+	// end_date is inclusive
+	if d := c.Query("end_date"); d != "" {
+		end, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid end_date format, use YYYY-MM-DD",
+			})
+			return filter, false
+		}
+		end = end.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	return filter, true
+}
+
+func invoicePage(c *gin.Context, defaultLimit int) (int, int) {
+	limit := defaultLimit
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+	return limit, offset
+}
+
+func respondInvoiceError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch err.Error() {
+	case "invoice not found", "payment not found", "appointment not found":
+		statusCode = http.StatusNotFound
+	case "only completed payments can be invoiced":
+		statusCode = http.StatusConflict
+	case "unsupported invoice format":
+		statusCode = http.StatusBadRequest
+	}
+	if strings.HasPrefix(err.Error(), "invalid e-invoice") {
+		statusCode = http.StatusUnprocessableEntity
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type NotificationHandler struct {
+	notificationService services.NotificationService
+	auditService        services.AuditService
+	validator           *validator.Validate
+}
+
+func NewNotificationHandler(notificationService services.NotificationService, auditService services.AuditService, validator *validator.Validate) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+		auditService:        auditService,
+		validator:           validator,
+	}
+}
+
+func (h *NotificationHandler) GetTemplates(c *gin.Context) {
+	templates, err := h.notificationService.ListTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    templates,
+	})
+}
+
+func (h *NotificationHandler) UpdateTemplate(c *gin.Context) {
+	var req models.UpdateNotificationTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	event, channel := models.NotificationEvent(c.Param("event")), models.NotificationChannelType(c.Param("channel"))
+	before := h.templateState(event, channel)
+
+	template, err := h.notificationService.UpdateTemplate(event, channel, &req)
+	if err != nil {
+		respondNotificationError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "notification_template", string(event)+"/"+string(channel), models.AuditActionUpdate, before, template)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    template,
+		"message": "Notification template updated successfully",
+	})
+}
+
+// ResetTemplate drops the tenant's version so the built-in default is used again
+func (h *NotificationHandler) ResetTemplate(c *gin.Context) {
+	event, channel := models.NotificationEvent(c.Param("event")), models.NotificationChannelType(c.Param("channel"))
+	before := h.templateState(event, channel)
+
+	template, err := h.notificationService.ResetTemplate(event, channel)
+	if err != nil {
+		respondNotificationError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "notification_template", string(event)+"/"+string(channel), models.AuditActionReset, before, template)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    template,
+		"message": "Notification template reset to default",
+	})
+}
+
+func (h *NotificationHandler) GetOutbox(c *gin.Context) {
+	limit := 20
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	items, total, err := h.notificationService.ListOutbox(models.NotificationStatus(c.Query("status")), limit, offset)
+	if err != nil {
+		respondNotificationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"notifications": items,
+			"total":         total,
+			"limit":         limit,
+			"offset":        offset,
+		},
+	})
+}
+
+func (h *NotificationHandler) RetryOutboxItem(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid notification ID")
+	if !ok {
+		return
+	}
+
+	if err := h.notificationService.RetryOutboxItem(id); err != nil {
+		respondNotificationError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "notification", id, models.AuditActionRetry, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Notification queued for delivery",
+	})
+}
+
+// templateState is the template in use for the audit log, nil when it cannot be read
+func (h *NotificationHandler) templateState(event models.NotificationEvent, channel models.NotificationChannelType) interface{} {
+	templates, err := h.notificationService.ListTemplates()
+	if err != nil {
+		return nil
+	}
+	for _, template := range templates {
+		if template.Event == event && template.Channel == channel {
+			return template
+		}
+	}
+	return nil
+}
+
+func respondNotificationError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "notification not found":
+		statusCode = http.StatusNotFound
+	case err.Error() == "invalid notification event" ||
+		err.Error() == "invalid notification channel" ||
+		err.Error() == "invalid status" ||
+		err.Error() == "template body is required" ||
+		err.Error() == "email templates require a subject" ||
+		strings.HasPrefix(err.Error(), "invalid template"):
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
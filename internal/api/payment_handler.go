@@ -0,0 +1,184 @@
+package api
+
+import (
+	"appointment-api/internal/middleware"
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Gateways send small JSON events, anything larger is not one of them
+const maxPaymentWebhookBody = 1 << 20
+
+type PaymentHandler struct {
+	paymentService        services.PaymentService
+	paymentAccountService services.PaymentAccountService
+	auditService          services.AuditService
+	validator             *validator.Validate
+	urlScheme             string
+}
+
+func NewPaymentHandler(paymentService services.PaymentService, paymentAccountService services.PaymentAccountService, auditService services.AuditService, validator *validator.Validate, urlScheme string) *PaymentHandler {
+	return &PaymentHandler{
+		paymentService:        paymentService,
+		paymentAccountService: paymentAccountService,
+		auditService:          auditService,
+		validator:             validator,
+		urlScheme:             urlScheme,
+	}
+}
+
+// HandleWebhook receives status updates from payment providers. The signature
+// is checked on the raw body, so it is read before any parsing.
+func (h *PaymentHandler) HandleWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPaymentWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+		return
+	}
+
+	if err := h.paymentService.HandleProviderWebhook(c.Param("provider"), payload, c.Request.Header); err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "unknown payment provider":
+			statusCode = http.StatusNotFound
+		case "invalid payment webhook signature":
+			statusCode = http.StatusUnauthorized
+		case "invalid payment webhook payload":
+			statusCode = http.StatusBadRequest
+		case "payment provider is not configured":
+			statusCode = http.StatusServiceUnavailable
+		default:
+			log.Printf("Warning: payment webhook from %s failed: %v", c.Param("provider"), err)
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook processed",
+	})
+}
+
+// GetProviderAccount shows whether the tenant stored credentials for a
+// provider and the webhook URL to register at the provider
+func (h *PaymentHandler) GetProviderAccount(c *gin.Context) {
+	info, err := h.paymentAccountService.Get(c.Param("provider"))
+	if err != nil {
+		respondPaymentAccountError(c, err)
+		return
+	}
+	info.WebhookURL = h.webhookURL(c, info.Provider)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    info,
+	})
+}
+
+// SaveProviderAccount stores the tenant's API key and webhook signing secret
+// for a provider, replacing the ones stored before
+func (h *PaymentHandler) SaveProviderAccount(c *gin.Context) {
+	var req models.PaymentProviderAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	provider := c.Param("provider")
+	before := auditState(h.paymentAccountService.Get(provider))
+
+	info, err := h.paymentAccountService.Save(provider, &req)
+	if err != nil {
+		respondPaymentAccountError(c, err)
+		return
+	}
+	info.WebhookURL = h.webhookURL(c, info.Provider)
+
+	recordAudit(h.auditService, c, "payment_provider_account", provider, models.AuditActionUpdate, before, info)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    info,
+		"message": "Payment provider credentials saved successfully",
+	})
+}
+
+// DeleteProviderAccount removes the tenant's credentials, card payments
+// through the provider stop until new ones are saved
+func (h *PaymentHandler) DeleteProviderAccount(c *gin.Context) {
+	provider := c.Param("provider")
+	before := auditState(h.paymentAccountService.Get(provider))
+
+	if err := h.paymentAccountService.Delete(provider); err != nil {
+		respondPaymentAccountError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "payment_provider_account", provider, models.AuditActionDelete, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Payment provider credentials deleted successfully",
+	})
+}
+
+// webhookURL is the tenant's webhook endpoint for a provider, on the domain
+// the request came in on
+func (h *PaymentHandler) webhookURL(c *gin.Context, provider string) string {
+	tenant, exists := middleware.GetCurrentTenant(c)
+	if !exists {
+		return ""
+	}
+	link := url.URL{
+		Scheme: h.urlScheme,
+		Host:   c.GetString("tenant_domain"),
+		Path:   fmt.Sprintf("/api/payments/webhooks/%s/%d", provider, tenant.ID),
+	}
+	return link.String()
+}
+
+func respondPaymentAccountError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch err.Error() {
+	case "unknown payment provider", "payment provider is not configured":
+		statusCode = http.StatusNotFound
+	case "payment credentials key is not configured":
+		statusCode = http.StatusServiceUnavailable
+	default:
+		if strings.HasPrefix(err.Error(), "invalid ") {
+			statusCode = http.StatusBadRequest
+		}
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
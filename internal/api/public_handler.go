@@ -5,6 +5,7 @@ import (
 	"appointment-api/internal/services"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"appointment-api/internal/models"
@@ -19,17 +20,21 @@ type PublicHandler struct {
 	specialistService  services.SpecialistService
 	appointmentService services.AppointmentService
 	paymentService     services.PaymentService
+	packageService     services.PackageService
+	walletService      services.WalletService
 	contactService     services.ContactService
 	validator          *validator.Validate
 }
 
-func NewPublicHandler(categoryService services.CategoryService, serviceService services.ServiceService, specialistService services.SpecialistService, appointmentService services.AppointmentService, paymentService services.PaymentService, contactService services.ContactService, validator *validator.Validate) *PublicHandler {
+func NewPublicHandler(categoryService services.CategoryService, serviceService services.ServiceService, specialistService services.SpecialistService, appointmentService services.AppointmentService, paymentService services.PaymentService, packageService services.PackageService, walletService services.WalletService, contactService services.ContactService, validator *validator.Validate) *PublicHandler {
 	return &PublicHandler{
 		categoryService:    categoryService,
 		serviceService:     serviceService,
 		specialistService:  specialistService,
 		appointmentService: appointmentService,
 		paymentService:     paymentService,
+		packageService:     packageService,
+		walletService:      walletService,
 		contactService:     contactService,
 		validator:          validator,
 	}
@@ -315,9 +320,12 @@ func (h *PublicHandler) CreateAppointment(c *gin.Context) {
 		if err.Error() == "specialist not found" || err.Error() == "service not found" {
 			statusCode = http.StatusNotFound
 		} else if err.Error() == "appointment time is already booked" ||
+			err.Error() == "specialist is not available at this time" ||
 			err.Error() == "appointment cannot be in the past" ||
 			err.Error() == "specialist is not active" ||
-			err.Error() == "service is not active" {
+			err.Error() == "service is not active" ||
+			strings.HasPrefix(err.Error(), "promo code") || err.Error() == "invalid promo code" ||
+			err.Error() == "package has no sessions left" {
 			statusCode = http.StatusBadRequest
 		}
 
@@ -492,7 +500,10 @@ func (h *PublicHandler) UpdateAppointment(c *gin.Context) {
 		if err.Error() == "appointment not found" {
 			statusCode = http.StatusNotFound
 		} else if err.Error() == "appointment time is already booked" ||
-			err.Error() == "cannot update cancelled appointment" {
+			err.Error() == "specialist is not available at this time" ||
+			err.Error() == "cannot update cancelled appointment" ||
+			err.Error() == "service not found" || err.Error() == "service is not active" ||
+			strings.HasPrefix(err.Error(), "cannot change the service") {
 			statusCode = http.StatusBadRequest
 		}
 
@@ -579,9 +590,10 @@ func (h *PublicHandler) PayAppointment(c *gin.Context) {
 	}
 
 	var req struct {
-		PaymentMethod string `json:"payment_method" validate:"required"`
-		CardToken     string `json:"card_token"`
-		DeviceID      *int   `json:"device_id"`
+		PaymentMethod string       `json:"payment_method" validate:"required"`
+		Amount        models.Money `json:"amount" validate:"omitempty,gt=0"` // defaults to the deposit or the balance due
+		CardToken     string       `json:"card_token"`
+		DeviceID      *int         `json:"device_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -610,6 +622,8 @@ func (h *PublicHandler) PayAppointment(c *gin.Context) {
 		paymentMethod = models.PaymentMethodCash
 	case "transfer":
 		paymentMethod = models.PaymentMethodTransfer
+	case "wallet":
+		paymentMethod = models.PaymentMethodWallet
 	default:
 		paymentMethod = models.PaymentMethodCreditCard
 	}
@@ -634,13 +648,19 @@ func (h *PublicHandler) PayAppointment(c *gin.Context) {
 	}
 
 	// Process payment using service
-	payment, err := h.paymentService.ProcessPayment(appointmentID, paymentMethod, req.DeviceID)
+	payment, err := h.paymentService.ProcessPayment(appointmentID, paymentMethod, req.Amount, req.CardToken, req.DeviceID)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "appointment not found" {
+		switch err.Error() {
+		case "appointment not found":
 			statusCode = http.StatusNotFound
-		} else if err.Error() == "appointment already paid" {
+		case "appointment already paid", "balance due is covered by pending payments", "card token is required", "invalid payment amount", "amount exceeds balance due",
+			"insufficient wallet balance":
 			statusCode = http.StatusBadRequest
+		case "payment provider error":
+			statusCode = http.StatusBadGateway
+		case "payment provider is not configured":
+			statusCode = http.StatusServiceUnavailable
 		}
 		c.JSON(statusCode, gin.H{
 			"success": false,
@@ -649,11 +669,30 @@ func (h *PublicHandler) PayAppointment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    payment,
-		"message": "Payment processed successfully",
-	})
+	switch payment.Status {
+	case models.PaymentFailed:
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"success": false,
+			"data":    payment,
+			"error":   "Payment declined",
+		})
+	case models.PaymentPending:
+		message := "Payment is being processed"
+		if payment.PaymentMethod != models.PaymentMethodCreditCard {
+			message = "Payment will be collected at the clinic"
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"data":    payment,
+			"message": message,
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    payment,
+			"message": "Payment processed successfully",
+		})
+	}
 }
 
 func (h *PublicHandler) GetUserPayments(c *gin.Context) {
@@ -753,3 +792,188 @@ func (h *PublicHandler) GetPaymentByID(c *gin.Context) {
 		"data":    payment,
 	})
 }
+
+// Packages endpoints
+func (h *PublicHandler) GetPackages(c *gin.Context) {
+	packages, err := h.packageService.List(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to fetch packages",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    packages,
+	})
+}
+
+// GetUserPackages lists the current user's packages with their balances
+func (h *PublicHandler) GetUserPackages(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	customerPackages, err := h.packageService.GetUserPackages(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to fetch packages",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    customerPackages,
+	})
+}
+
+// GetUserPackageByID returns one of the current user's packages with its session history
+func (h *PublicHandler) GetUserPackageByID(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	customerPackageID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid package ID",
+		})
+		return
+	}
+
+	customerPackage, err := h.packageService.GetCustomerPackage(customerPackageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Package not found",
+		})
+		return
+	}
+
+	// Verify ownership
+	if customerPackage.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Access denied to this package",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    customerPackage,
+	})
+}
+
+// GetUserWallet returns the current user's wallet balances with their ledger, newest first
+func (h *PublicHandler) GetUserWallet(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	limit := 20
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	balances, err := h.walletService.GetBalances(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to fetch wallet",
+		})
+		return
+	}
+
+	entries, total, err := h.walletService.ListEntries(user.ID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to fetch wallet",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"balances": balances,
+			"entries":  entries,
+			"total":    total,
+			"limit":    limit,
+			"offset":   offset,
+		},
+	})
+}
+
+// RedeemGiftCard credits a gift card to the current user's wallet
+func (h *PublicHandler) RedeemGiftCard(c *gin.Context) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return
+	}
+
+	var req models.RedeemGiftCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entry, err := h.walletService.RedeemGiftCard(user.ID, req.Code)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    entry,
+		"message": "Gift card redeemed successfully",
+	})
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"appointment-api/internal/middleware"
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReminderHandler struct {
+	reminderService services.ReminderService
+}
+
+func NewReminderHandler(reminderService services.ReminderService) *ReminderHandler {
+	return &ReminderHandler{
+		reminderService: reminderService,
+	}
+}
+
+// appointmentLinkPage is shown to customers opening a reminder link. The action
+// itself needs a POST, so link previews and mail scanners cannot trigger it.
+var appointmentLinkPage = template.Must(template.New("appointment-link").Parse(`<!DOCTYPE html>
+<html lang="tr">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>body{font-family:sans-serif;max-width:480px;margin:40px auto;padding:0 16px;color:#222}button{padding:10px 20px;font-size:16px;cursor:pointer}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Appointment}}<p>Randevu: {{.Appointment.AppointmentDate.Format "02.01.2006"}} {{.Appointment.AppointmentTime.Format "15:04"}}</p>{{end}}
+{{if .ActionURL}}<form method="post" action="{{.ActionURL}}"><button type="submit">{{.Button}}</button></form>{{end}}
+</body>
+</html>
+`))
+
+type appointmentLinkView struct {
+	Title       string
+	Message     string
+	Button      string
+	ActionURL   string
+	Appointment *models.Appointment
+}
+
+var appointmentLinkTexts = map[string]struct{ Title, Question, Button, Done string }{
+	services.ReminderActionConfirm: {
+		Title:    "Randevu onayı",
+		Question: "Randevunuzu onaylamak istiyor musunuz?",
+		Button:   "Randevuyu onayla",
+		Done:     "Randevunuz onaylandı.",
+	},
+	services.ReminderActionCancel: {
+		Title:    "Randevu iptali",
+		Question: "Randevunuzu iptal etmek istiyor musunuz?",
+		Button:   "Randevuyu iptal et",
+		Done:     "Randevunuz iptal edildi.",
+	},
+}
+
+// ShowLink verifies a reminder link and asks the customer to confirm the action
+func (h *ReminderHandler) ShowLink(c *gin.Context) {
+	id, action, schema, ok := h.linkParams(c)
+	if !ok {
+		return
+	}
+
+	appointment, err := h.reminderService.GetLinkAppointment(schema, id, action, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		h.respondLinkError(c, err)
+		return
+	}
+
+	texts := appointmentLinkTexts[action]
+	h.respondLink(c, http.StatusOK, appointmentLinkView{
+		Title:       texts.Title,
+		Message:     texts.Question,
+		Button:      texts.Button,
+		ActionURL:   c.Request.URL.RequestURI(),
+		Appointment: appointment,
+	})
+}
+
+// HandleLink confirms or cancels the appointment of a reminder link
+func (h *ReminderHandler) HandleLink(c *gin.Context) {
+	id, action, schema, ok := h.linkParams(c)
+	if !ok {
+		return
+	}
+
+	appointment, err := h.reminderService.HandleLink(schema, id, action, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		h.respondLinkError(c, err)
+		return
+	}
+
+	texts := appointmentLinkTexts[action]
+	h.respondLink(c, http.StatusOK, appointmentLinkView{
+		Title:       texts.Title,
+		Message:     texts.Done,
+		Appointment: appointment,
+	})
+}
+
+func (h *ReminderHandler) GetAppointmentReminders(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid appointment ID")
+	if !ok {
+		return
+	}
+
+	reminders, err := h.reminderService.ListByAppointment(id)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "appointment not found" {
+			statusCode = http.StatusNotFound
+		}
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reminders,
+	})
+}
+
+func (h *ReminderHandler) linkParams(c *gin.Context) (int, string, string, bool) {
+	id, ok := parseIDParam(c, "id", "Invalid appointment ID")
+	if !ok {
+		return 0, "", "", false
+	}
+
+	tenant, exists := middleware.GetCurrentTenant(c)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Tenant not found",
+		})
+		return 0, "", "", false
+	}
+
+	return id, c.Param("action"), tenant.Schema, true
+}
+
+// respondLink answers with the HTML page for browsers and JSON for API clients.
+// Errors are rendered by respondLinkError.
+func (h *ReminderHandler) respondLink(c *gin.Context, statusCode int, view appointmentLinkView) {
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(statusCode, gin.H{
+			"success": true,
+			"data":    view.Appointment,
+			"message": view.Message,
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(statusCode)
+	if err := appointmentLinkPage.Execute(c.Writer, view); err != nil {
+		c.Error(err)
+	}
+}
+
+func (h *ReminderHandler) respondLinkError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	message := "Bir hata oluştu, lütfen daha sonra tekrar deneyin."
+	switch err.Error() {
+	case "invalid link action", "invalid link signature":
+		statusCode = http.StatusForbidden
+		message = "Bağlantı geçersiz."
+	case "link has expired":
+		statusCode = http.StatusGone
+		message = "Bağlantının süresi doldu."
+	case "appointment not found":
+		statusCode = http.StatusNotFound
+		message = "Randevu bulunamadı."
+	case "appointment can no longer be changed":
+		statusCode = http.StatusConflict
+		message = "Bu randevu artık değiştirilemez."
+	case "deposit has not been paid":
+		statusCode = http.StatusConflict
+		message = "Randevuyu onaylamak için önce kaporanın ödenmesi gerekiyor."
+	}
+
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(statusCode, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	h.respondLink(c, statusCode, appointmentLinkView{
+		Title:   "Randevu",
+		Message: message,
+	})
+}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"appointment-api/internal/middleware"
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService services.TwoFactorService
+	authService      services.AuthService
+	auditService     services.AuditService
+	validator        *validator.Validate
+}
+
+func NewTwoFactorHandler(twoFactorService services.TwoFactorService, authService services.AuthService, auditService services.AuditService, validator *validator.Validate) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		authService:      authService,
+		auditService:     auditService,
+		validator:        validator,
+	}
+}
+
+// GetStatus shows the current user's two-factor state
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.twoFactorService.Status(user)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// Setup starts enrollment and returns the secret and provisioning URI
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	before := auditState(h.twoFactorService.Status(user))
+
+	setup, err := h.twoFactorService.Setup(user, twoFactorIssuer(c))
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "user", user.ID, models.AuditActionSetupTwoFactor, before, auditState(h.twoFactorService.Status(user)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    setup,
+	})
+}
+
+// Enable confirms enrollment with a first code and returns the recovery codes
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+
+	before := auditState(h.twoFactorService.Status(user))
+
+	codes, err := h.twoFactorService.Enable(user.ID, req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "user", user.ID, models.AuditActionEnableTwoFactor, before, auditState(h.twoFactorService.Status(user)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"recovery_codes": codes},
+		"message": "Two-factor authentication enabled",
+	})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+
+	before := auditState(h.twoFactorService.Status(user))
+
+	if err := h.twoFactorService.Disable(user, req.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "user", user.ID, models.AuditActionDisableTwoFactor, before, auditState(h.twoFactorService.Status(user)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+
+	before := auditState(h.twoFactorService.Status(user))
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(user.ID, req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "user", user.ID, models.AuditActionRegenerateRecoveryCodes, before, auditState(h.twoFactorService.Status(user)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"recovery_codes": codes},
+		"message": "Recovery codes regenerated",
+	})
+}
+
+// ResetUser turns two-factor authentication off for another user who lost
+// their authenticator and recovery codes
+func (h *TwoFactorHandler) ResetUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.twoFactorService.Reset(id); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "user", id, models.AuditActionResetTwoFactor, gin.H{"two_factor_enabled": true}, gin.H{"two_factor_enabled": false})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Two-factor authentication reset",
+	})
+}
+
+// SetupLogin starts enrollment during a login that requires it
+func (h *TwoFactorHandler) SetupLogin(c *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" validate:"required"`
+	}
+	if !h.bind(c, &req) {
+		return
+	}
+
+	setup, err := h.authService.SetupTwoFactorLogin(req.ChallengeToken, twoFactorIssuer(c), clientInfo(c).TenantID)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    setup,
+	})
+}
+
+// VerifyLogin completes a two-factor login
+func (h *TwoFactorHandler) VerifyLogin(c *gin.Context) {
+	var req models.TwoFactorChallengeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	response, err := h.authService.VerifyTwoFactorLogin(&req, clientInfo(c))
+	if err != nil {
+		if throttledLogin(c, err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    response,
+		"message": "Login successful",
+	})
+}
+
+func (h *TwoFactorHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	user, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "User not authenticated",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *TwoFactorHandler) bindCode(c *gin.Context) (*models.TwoFactorCodeRequest, bool) {
+	var req models.TwoFactorCodeRequest
+	if !h.bind(c, &req) {
+		return nil, false
+	}
+	return &req, true
+}
+
+func (h *TwoFactorHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// twoFactorIssuer names the tenant in authenticator apps
+func twoFactorIssuer(c *gin.Context) string {
+	if tenant, exists := middleware.GetCurrentTenant(c); exists {
+		return tenant.Name
+	}
+	return ""
+}
+
+// respondTwoFactorChallenge answers the first step of a two-factor login
+func respondTwoFactorChallenge(c *gin.Context, challenge *models.TwoFactorChallenge) {
+	message := "Two-factor authentication required"
+	if challenge.SetupRequired {
+		message = "Two-factor authentication setup required"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    challenge,
+		"message": message,
+	})
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch err.Error() {
+	case "invalid two-factor code", "invalid or expired challenge":
+		statusCode = http.StatusUnauthorized
+	case "two-factor authentication is already enabled":
+		statusCode = http.StatusConflict
+	case "two-factor setup has not been started", "two-factor authentication is not enabled":
+		statusCode = http.StatusBadRequest
+	case "two-factor authentication is required for staff":
+		statusCode = http.StatusForbidden
+	case "user not found":
+		statusCode = http.StatusNotFound
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type WebhookHandler struct {
+	webhookService services.WebhookService
+	auditService   services.AuditService
+	validator      *validator.Validate
+}
+
+func NewWebhookHandler(webhookService services.WebhookService, auditService services.AuditService, validator *validator.Validate) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		auditService:   auditService,
+		validator:      validator,
+	}
+}
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	subscriptions, err := h.webhookService.List()
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    subscriptions,
+	})
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	subscription, err := h.webhookService.GetByID(id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    subscription,
+	})
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	subscription, err := h.webhookService.Create(&req)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "webhook", subscription.ID, models.AuditActionCreate, nil, subscription)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    subscription,
+		"message": "Webhook created successfully",
+	})
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	before := auditState(h.webhookService.GetByID(id))
+
+	subscription, err := h.webhookService.Update(id, &req)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "webhook", id, models.AuditActionUpdate, before, subscription)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    subscription,
+		"message": "Webhook updated successfully",
+	})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	before := auditState(h.webhookService.GetByID(id))
+
+	if err := h.webhookService.Delete(id); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "webhook", id, models.AuditActionDelete, before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook deleted successfully",
+	})
+}
+
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	subscription, err := h.webhookService.RotateSecret(id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "webhook", id, models.AuditActionRotateSecret, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    subscription,
+		"message": "Webhook secret rotated",
+	})
+}
+
+// GetDeliveries lists the delivery log, filtered by ?subscription_id and ?status
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	subscriptionID := 0
+	if s := c.Query("subscription_id"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid webhook ID",
+			})
+			return
+		}
+		subscriptionID = parsed
+	}
+
+	h.listDeliveries(c, subscriptionID)
+}
+
+// GetWebhookDeliveries lists the delivery log of one subscription
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	h.listDeliveries(c, id)
+}
+
+func (h *WebhookHandler) listDeliveries(c *gin.Context, subscriptionID int) {
+	limit := 20
+	offset := 0
+
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		if parsedOffset, err := strconv.Atoi(o); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	deliveries, total, err := h.webhookService.ListDeliveries(subscriptionID, models.WebhookDeliveryStatus(c.Query("status")), limit, offset)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"deliveries": deliveries,
+			"total":      total,
+			"limit":      limit,
+			"offset":     offset,
+		},
+	})
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid delivery ID")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	recordAudit(h.auditService, c, "webhook_delivery", id, models.AuditActionRedeliver, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    delivery,
+		"message": "Webhook queued for redelivery",
+	})
+}
+
+func respondWebhookError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err.Error() == "webhook not found" || err.Error() == "webhook delivery not found":
+		statusCode = http.StatusNotFound
+	case err.Error() == "webhook is disabled":
+		statusCode = http.StatusConflict
+	case err.Error() == "invalid status" ||
+		err.Error() == "at least one webhook event is required" ||
+		strings.HasPrefix(err.Error(), "invalid webhook"):
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
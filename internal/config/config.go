@@ -1,18 +1,27 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database   DatabaseConfig
-	JWT        JWTConfig
-	Server     ServerConfig
-	Cloudinary CloudinaryConfig
+	Database     DatabaseConfig
+	JWT          JWTConfig
+	Auth         AuthConfig
+	Server       ServerConfig
+	Cloudinary   CloudinaryConfig
+	Calendar     CalendarConfig
+	Notification NotificationConfig
+	Reminder     ReminderConfig
+	Webhook      WebhookConfig
+	Payment      PaymentConfig
 }
 
 type DatabaseConfig struct {
@@ -24,12 +33,45 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// ConnectionString builds the postgres URL, extra runtime parameters
+// (e.g. search_path) are appended as query arguments
+func (d DatabaseConfig) ConnectionString(params ...string) string {
+	url := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
+		d.User, d.Password, d.Host, d.Port, d.DBName, d.SSLMode)
+	for _, param := range params {
+		url += "&" + param
+	}
+	return url
+}
+
 type ServerConfig struct {
 	Port string
+	// TrustedProxies are the proxy IPs or CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers are believed for the client IP, and X-Forwarded-Proto
+	// for the scheme of generated URLs. Empty trusts none and the client IP is
+	// the connection's address.
+	TrustedProxies []string
+	// RequestTimeout is the longest a request may take to be answered
+	RequestTimeout time.Duration
+	// IdempotencyLockTimeout is how long an Idempotency-Key stays held by a
+	// request that never completed before a retry may take it over. It is at
+	// least RequestTimeout, so a slow request and its retry do not both run.
+	IdempotencyLockTimeout time.Duration
 }
 
 type JWTConfig struct {
-	Secret string
+	Secret     string
+	Audience   string        // aud claim of access tokens
+	AccessTTL  time.Duration // lifetime of access tokens
+	RefreshTTL time.Duration // a session expires after this long without a refresh
+}
+
+type AuthConfig struct {
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	LoginMaxAttempts     int           // failed logins per account before it is locked
+	LoginIPMaxAttempts   int           // failed logins per IP address before it is blocked
+	LoginLockout         time.Duration // lockout length, failures older than this are forgotten
 }
 
 type CloudinaryConfig struct {
@@ -38,6 +80,62 @@ type CloudinaryConfig struct {
 	APISecret string
 }
 
+type CalendarConfig struct {
+	TimeZone     string
+	SyncInterval time.Duration
+}
+
+type NotificationConfig struct {
+	EmailDriver      string // smtp | log
+	SMSDriver        string // http | log
+	LogFile          string // log driver output, stdout log when empty
+	DispatchInterval time.Duration
+	MaxAttempts      int
+	SMTP             SMTPConfig
+	SMS              SMSConfig
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type SMSConfig struct {
+	APIURL string
+	APIKey string
+	Sender string
+}
+
+type ReminderConfig struct {
+	Interval   time.Duration
+	LinkSecret string // Signs the confirm/cancel links
+	LinkScheme string // Scheme of the links, the host is the tenant's domain
+}
+
+type WebhookConfig struct {
+	DispatchInterval time.Duration
+	MaxAttempts      int
+	Timeout          time.Duration // Per delivery request
+}
+
+type PaymentConfig struct {
+	Currency          string        // ISO 4217 code sent to the gateway
+	Timeout           time.Duration // Per gateway request
+	FakeProvider      bool          // Offers the built-in fake provider to tenants, for development only
+	FakeWebhookSecret string        // Signs webhooks of the built-in fake provider
+	CredentialsKey    string        // Encrypts the provider credentials tenants store
+	Stripe            StripeConfig
+}
+
+// StripeConfig is shared by all tenants, the API keys and webhook signing
+// secrets are stored per tenant
+type StripeConfig struct {
+	APIURL string
+}
+
 func Load() *Config {
 	// Load .env file if exists
 	if err := godotenv.Load("config.env"); err != nil {
@@ -58,19 +156,128 @@ func Load() *Config {
 		SSLMode:  getEnv("DB_SSLMODE", "disable"),
 	}
 
+	reminderLinkSecret := getEnv("REMINDER_LINK_SECRET", "")
+	if reminderLinkSecret == "" {
+		log.Fatal("REMINDER_LINK_SECRET is required, it signs the reminder confirm/cancel links")
+	}
+
+	fakePaymentProvider := getEnvBool("PAYMENT_FAKE_PROVIDER", false)
+	fakeWebhookSecret := getEnv("PAYMENT_FAKE_WEBHOOK_SECRET", "")
+	if fakePaymentProvider && fakeWebhookSecret == "" {
+		log.Fatal("PAYMENT_FAKE_WEBHOOK_SECRET is required when PAYMENT_FAKE_PROVIDER is set")
+	}
+
+	requestTimeout := getEnvDuration("SERVER_REQUEST_TIMEOUT", time.Minute)
+	idempotencyLockTimeout := getEnvDuration("IDEMPOTENCY_LOCK_TIMEOUT", 10*time.Minute)
+	if idempotencyLockTimeout < requestTimeout {
+		log.Fatal("IDEMPOTENCY_LOCK_TIMEOUT must be at least SERVER_REQUEST_TIMEOUT")
+	}
+
 	return &Config{
 		Database: dbConfig,
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
+			Port:                   getEnv("SERVER_PORT", "8080"),
+			TrustedProxies:         getEnvList("TRUSTED_PROXIES"),
+			RequestTimeout:         requestTimeout,
+			IdempotencyLockTimeout: idempotencyLockTimeout,
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key"),
+			Secret:     getEnv("JWT_SECRET", "your-secret-key"),
+			Audience:   getEnv("JWT_AUDIENCE", "appointment-api"),
+			AccessTTL:  getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute),
+			RefreshTTL: getEnvDuration("JWT_REFRESH_TTL", 30*24*time.Hour),
+		},
+		Auth: AuthConfig{
+			PasswordResetTTL:     getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+			EmailVerificationTTL: getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			LoginMaxAttempts:     getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+			LoginIPMaxAttempts:   getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+			LoginLockout:         getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
 		},
 		Cloudinary: CloudinaryConfig{
 			CloudName: getEnv("CLOUDINARY_CLOUD_NAME", ""),
 			APIKey:    getEnv("CLOUDINARY_API_KEY", ""),
 			APISecret: getEnv("CLOUDINARY_API_SECRET", ""),
 		},
+		Calendar: CalendarConfig{
+			TimeZone:     getEnv("CALENDAR_TIMEZONE", "Europe/Istanbul"),
+			SyncInterval: getEnvDuration("CALENDAR_SYNC_INTERVAL", 15*time.Minute),
+		},
+		Notification: loadNotificationConfig(),
+		Reminder: ReminderConfig{
+			Interval:   getEnvDuration("REMINDER_INTERVAL", time.Minute),
+			LinkSecret: reminderLinkSecret,
+			LinkScheme: getEnv("PUBLIC_URL_SCHEME", "https"),
+		},
+		Webhook: loadWebhookConfig(),
+		Payment: PaymentConfig{
+			Currency:          getEnv("PAYMENT_CURRENCY", "TRY"),
+			Timeout:           getEnvDuration("PAYMENT_TIMEOUT", 30*time.Second),
+			FakeProvider:      fakePaymentProvider,
+			FakeWebhookSecret: fakeWebhookSecret,
+			CredentialsKey:    getEnv("PAYMENT_CREDENTIALS_KEY", ""),
+			Stripe: StripeConfig{
+				APIURL: getEnv("STRIPE_API_URL", "https://api.stripe.com"),
+			},
+		},
+	}
+}
+
+func loadNotificationConfig() NotificationConfig {
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		log.Fatalf("Invalid SMTP_PORT: %v", err)
+	}
+
+	maxAttempts, err := strconv.Atoi(getEnv("NOTIFICATION_MAX_ATTEMPTS", "5"))
+	if err != nil || maxAttempts <= 0 {
+		log.Fatalf("Invalid NOTIFICATION_MAX_ATTEMPTS: %v", err)
+	}
+
+	smtpConfig := SMTPConfig{
+		Host:     getEnv("SMTP_HOST", ""),
+		Port:     smtpPort,
+		Username: getEnv("SMTP_USERNAME", ""),
+		Password: getEnv("SMTP_PASSWORD", ""),
+		From:     getEnv("SMTP_FROM", "no-reply@localhost"),
+	}
+	smsConfig := SMSConfig{
+		APIURL: getEnv("SMS_API_URL", ""),
+		APIKey: getEnv("SMS_API_KEY", ""),
+		Sender: getEnv("SMS_SENDER", ""),
+	}
+
+	// Without provider settings messages only go to the log (development)
+	emailDriver := "log"
+	if smtpConfig.Host != "" {
+		emailDriver = "smtp"
+	}
+	smsDriver := "log"
+	if smsConfig.APIURL != "" {
+		smsDriver = "http"
+	}
+
+	return NotificationConfig{
+		EmailDriver:      getEnv("NOTIFICATION_EMAIL_DRIVER", emailDriver),
+		SMSDriver:        getEnv("NOTIFICATION_SMS_DRIVER", smsDriver),
+		LogFile:          getEnv("NOTIFICATION_LOG_FILE", ""),
+		DispatchInterval: getEnvDuration("NOTIFICATION_DISPATCH_INTERVAL", 30*time.Second),
+		MaxAttempts:      maxAttempts,
+		SMTP:             smtpConfig,
+		SMS:              smsConfig,
+	}
+}
+
+func loadWebhookConfig() WebhookConfig {
+	maxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "8"))
+	if err != nil || maxAttempts <= 0 {
+		log.Fatalf("Invalid WEBHOOK_MAX_ATTEMPTS: %v", err)
+	}
+
+	return WebhookConfig{
+		DispatchInterval: getEnvDuration("WEBHOOK_DISPATCH_INTERVAL", 30*time.Second),
+		MaxAttempts:      maxAttempts,
+		Timeout:          getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
 	}
 }
 
@@ -80,3 +287,56 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid %s %q, using default %v", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Printf("Invalid %s %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return enabled
+}
+
+// getEnvList splits a comma separated variable, nil when it is not set
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
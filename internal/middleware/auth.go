@@ -4,15 +4,20 @@ import (
 	"appointment-api/internal/models"
 	"appointment-api/internal/services"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
+// AuthMiddleware accepts a bearer JWT or, for server-to-server integrations, an
+// API key in the X-API-Key header or as the bearer token. API key requests have
+// no user in the context; their scopes are enforced by AdminMiddleware.
+func AuthMiddleware(authService services.AuthService, apiKeyService services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		apiKey := c.GetHeader("X-API-Key")
+		if authHeader == "" && apiKey == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error":   "Authorization header required",
@@ -22,18 +27,51 @@ func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
 		}
 
 		// Extract token from "Bearer <token>"
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		token := ""
+		if authHeader != "" {
+			tokenParts := strings.Split(authHeader, " ")
+			if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error":   "Invalid authorization format",
+				})
+				c.Abort()
+				return
+			}
+			token = tokenParts[1]
+		}
+		if apiKey == "" && services.IsAPIKey(token) {
+			apiKey = token
+		}
+
+		if apiKey != "" {
+			key, err := apiKeyService.Authenticate(apiKey, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error":   "Invalid API key",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("api_key", key)
+			c.Next()
+			return
+		}
+
+		// Tokens are bound to the tenant they were issued on
+		tenant, exists := GetCurrentTenant(c)
+		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
-				"error":   "Invalid authorization format",
+				"error":   "Tenant not found",
 			})
 			c.Abort()
 			return
 		}
 
-		token := tokenParts[1]
-		user, err := authService.ValidateToken(token)
+		user, session, err := authService.ValidateToken(token, tenant.ID)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
@@ -43,14 +81,40 @@ func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		// Store user in context
+		// Store user and session in context
 		c.Set("user", user)
+		c.Set("session", session)
 		c.Next()
 	}
 }
 
+// AdminMiddleware lets admins through, and API keys holding the scope of the
+// requested admin resource
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key, exists := GetCurrentAPIKey(c); exists {
+			if apiKeyDeniedRoutes[c.Request.Method+" "+c.FullPath()] {
+				c.JSON(http.StatusForbidden, gin.H{
+					"success": false,
+					"error":   "This endpoint is not available to API keys",
+				})
+				c.Abort()
+				return
+			}
+
+			if !key.HasScope(apiKeyScope(c)) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"success": false,
+					"error":   "API key scope required",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Next()
+			return
+		}
+
 		user, exists := c.Get("user")
 		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -75,6 +139,80 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
+// Admin routes that change who can sign in or with which rights. API keys
+// can't call them whatever their scopes, so a leaked users:write key can't be
+// turned into an admin account.
+var apiKeyDeniedRoutes = map[string]bool{
+	http.MethodPut + " /api/admin/users/:id/role":             true,
+	http.MethodPost + " /api/admin/users/:id/revoke-sessions": true,
+	http.MethodPost + " /api/admin/users/:id/unlock":          true,
+	http.MethodDelete + " /api/admin/users/:id/2fa":           true,
+}
+
+// Admin routes whose path segment differs from the scope resource
+var apiKeyResourceAliases = map[string]string{
+	"stats":     "reports",
+	"dashboard": "reports",
+	"upload":    "services",
+}
+
+// apiKeyScope maps an admin route to the scope it needs: the first path segment
+// after /api/admin, read for GET and write for everything else. Routes outside
+// models.APIKeyResources map to a scope no key can hold.
+func apiKeyScope(c *gin.Context) string {
+	path := strings.TrimPrefix(c.FullPath(), "/api/admin/")
+	resource, _, _ := strings.Cut(path, "/")
+	if alias, ok := apiKeyResourceAliases[resource]; ok {
+		resource = alias
+	}
+
+	access := models.APIKeyScopeWrite
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		access = models.APIKeyScopeRead
+	}
+	return resource + ":" + access
+}
+
+// VerifiedEmailMiddleware blocks users without a verified email while the
+// tenant's require_email_verification setting is on (the default). Admins are
+// never blocked.
+func VerifiedEmailMiddleware(settingsService services.SettingsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "User not authenticated",
+			})
+			c.Abort()
+			return
+		}
+
+		if user.Role != models.RoleAdmin && !user.EmailVerified() && emailVerificationRequired(settingsService) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Email verification required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func emailVerificationRequired(settingsService services.SettingsService) bool {
+	setting, err := settingsService.GetByKey("require_email_verification")
+	if err != nil || setting.Value == "" {
+		return true
+	}
+	required, err := strconv.ParseBool(strings.TrimSpace(setting.Value))
+	if err != nil {
+		return true
+	}
+	return required
+}
+
 // Helper function to get current user from context
 func GetCurrentUser(c *gin.Context) (*models.User, bool) {
 	user, exists := c.Get("user")
@@ -83,3 +221,21 @@ func GetCurrentUser(c *gin.Context) (*models.User, bool) {
 	}
 	return user.(*models.User), true
 }
+
+// GetCurrentSession returns the session of the request's access token
+func GetCurrentSession(c *gin.Context) (*models.Session, bool) {
+	session, exists := c.Get("session")
+	if !exists {
+		return nil, false
+	}
+	return session.(*models.Session), true
+}
+
+// GetCurrentAPIKey returns the API key a request authenticated with
+func GetCurrentAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	key, exists := c.Get("api_key")
+	if !exists {
+		return nil, false
+	}
+	return key.(*models.APIKey), true
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/services"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyMiddleware honours an Idempotency-Key header on requests that
+// change something. The first response per key and caller is stored and sent
+// again, headers included, for retries of the same request, marked with an
+// Idempotent-Replayed header; reusing the key for another request is rejected. Server errors are
+// not stored, so the request can be retried with the same key. Requests
+// without the header run as usual.
+func IdempotencyMiddleware(idempotencyService services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" || !idempotentMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		actorType, actorID, ok := idempotencyActor(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid request body",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, replay, err := idempotencyService.Begin(actorType, actorID, strings.TrimSpace(key), requestHash(c.Request, body))
+		if err != nil {
+			statusCode := http.StatusInternalServerError
+			switch {
+			case strings.HasPrefix(err.Error(), "invalid"):
+				statusCode = http.StatusBadRequest
+			case strings.HasPrefix(err.Error(), "a request with this idempotency key is in progress"):
+				statusCode = http.StatusConflict
+			case err.Error() == "idempotency key was already used with a different request":
+				statusCode = http.StatusUnprocessableEntity
+			}
+			c.JSON(statusCode, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		if replay {
+			for name, values := range record.Headers {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			// a panic is answered with a 500 by the recovery middleware
+			if recovered := recover(); recovered != nil {
+				if err := idempotencyService.Release(record); err != nil {
+					log.Printf("Warning: Failed to release idempotency key %d: %v", record.ID, err)
+				}
+				panic(recovered)
+			}
+		}()
+
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := idempotencyService.Release(record); err != nil {
+				log.Printf("Warning: Failed to release idempotency key %d: %v", record.ID, err)
+			}
+			return
+		}
+		if err := idempotencyService.Complete(record, writer.Status(), writer.Header(), writer.body.Bytes()); err != nil {
+			log.Printf("Warning: Failed to store response for idempotency key %d: %v", record.ID, err)
+		}
+	}
+}
+
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyActor is who the key belongs to: the signed-in user or the API key
+func idempotencyActor(c *gin.Context) (string, int, bool) {
+	if user, exists := GetCurrentUser(c); exists {
+		return models.AuditActorUser, user.ID, true
+	}
+	if key, exists := GetCurrentAPIKey(c); exists {
+		return models.AuditActorAPIKey, key.ID, true
+	}
+	return "", 0, false
+}
+
+// requestHash identifies a request by its method, path, query and body
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotencyWriter keeps a copy of the response body for replay
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
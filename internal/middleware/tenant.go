@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -40,46 +41,74 @@ func TenantMiddleware(tenantService services.TenantService, tenantCache services
 			return
 		}
 
-		// TenantInfo'yu TenantConfig'e çevir
-		tenant := tenantInfo.ConvertToTenantConfig()
-
-		// Schema'nın var olup olmadığını kontrol et, yoksa oluştur
-		var schemaExists bool
-		err = mainDB.QueryRow("SELECT EXISTS(SELECT 1 FROM information_schema.schemata WHERE schema_name = $1)", tenant.Schema).Scan(&schemaExists)
-		if err == nil && !schemaExists {
-			// Schema'yı oluştur
-			err = tenantService.CreateTenantSchema(tenant.Schema)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"error":   "Failed to create tenant schema: " + err.Error(),
-				})
-				c.Abort()
-				return
-			}
+		enterTenant(c, tenantInfo, domain, tenantService, mainDB)
+	}
+}
+
+// TenantByIDMiddleware tenant'ı domain yerine path'teki id'den bulur. Tüm
+// tenant'lar için tek bir adrese istek atan servisler içindir (ör. ödeme
+// sağlayıcı webhook'ları).
+func TenantByIDMiddleware(param string, tenantService services.TenantService, tenantCache services.TenantCacheService, mainDB *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param(param))
+		var tenantInfo *services.TenantInfo
+		if err == nil {
+			tenantInfo, err = tenantCache.GetTenantByID(id)
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   "Tenant not found",
+			})
+			c.Abort()
+			return
 		}
 
-		// Tenant schema'ya geç
-		_, err = mainDB.Exec(fmt.Sprintf("SET search_path TO %s, public", tenant.Schema))
+		enterTenant(c, tenantInfo, tenantInfo.Domain, tenantService, mainDB)
+	}
+}
+
+// enterTenant isteği tenant'ın schema'sında çalıştırır
+func enterTenant(c *gin.Context, tenantInfo *services.TenantInfo, domain string, tenantService services.TenantService, mainDB *sql.DB) {
+	// TenantInfo'yu TenantConfig'e çevir
+	tenant := tenantInfo.ConvertToTenantConfig()
+
+	// Schema'nın var olup olmadığını kontrol et, yoksa oluştur
+	var schemaExists bool
+	err := mainDB.QueryRow("SELECT EXISTS(SELECT 1 FROM information_schema.schemata WHERE schema_name = $1)", tenant.Schema).Scan(&schemaExists)
+	if err == nil && !schemaExists {
+		// Schema'yı oluştur
+		err = tenantService.CreateTenantSchema(tenant.Schema)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
-				"error":   "Failed to set tenant context",
+				"error":   "Failed to create tenant schema: " + err.Error(),
 			})
 			c.Abort()
 			return
 		}
+	}
+
+	// Tenant schema'ya geç
+	_, err = mainDB.Exec(fmt.Sprintf("SET search_path TO %s, public", tenant.Schema))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to set tenant context",
+		})
+		c.Abort()
+		return
+	}
 
-		// Context'e tenant bilgilerini kaydet
-		c.Set("tenant", tenant)
-		c.Set("tenant_schema", tenant.Schema)
-		c.Set("tenant_domain", domain)
+	// Context'e tenant bilgilerini kaydet
+	c.Set("tenant", tenant)
+	c.Set("tenant_schema", tenant.Schema)
+	c.Set("tenant_domain", domain)
 
-		c.Next()
+	c.Next()
 
-		// İstek bittikten sonra search_path'i resetle
-		mainDB.Exec("SET search_path TO public")
-	}
+	// İstek bittikten sonra search_path'i resetle
+	mainDB.Exec("SET search_path TO public")
 }
 
 // Domain'i request'ten al - Origin header veya Referer header'dan
@@ -13,25 +13,49 @@ const (
 	StatusCompleted AppointmentStatus = "completed"
 	StatusCancelled AppointmentStatus = "cancelled"
 
-	PaymentPending   PaymentStatus = "pending"
-	PaymentCompleted PaymentStatus = "completed"
-	PaymentFailed    PaymentStatus = "failed"
-	PaymentRefunded  PaymentStatus = "refunded"
+	PaymentPending       PaymentStatus = "pending"
+	PaymentPartiallyPaid PaymentStatus = "partially_paid" // appointments only, some but not all of the total is collected
+	PaymentCompleted     PaymentStatus = "completed"
+	PaymentFailed        PaymentStatus = "failed"
+	PaymentRefunded      PaymentStatus = "refunded"
+	// appointments only, part of what was paid is returned
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
 )
 
 type Appointment struct {
-	ID              int               `json:"id" db:"id"`
-	UserID          int               `json:"user_id" db:"user_id"`
-	SpecialistID    int               `json:"specialist_id" db:"specialist_id"`
-	ServiceID       int               `json:"service_id" db:"service_id"`
-	AppointmentDate time.Time         `json:"appointment_date" db:"appointment_date"`
-	AppointmentTime time.Time         `json:"appointment_time" db:"appointment_time"`
-	Status          AppointmentStatus `json:"status" db:"status"`
-	PaymentStatus   PaymentStatus     `json:"payment_status" db:"payment_status"`
-	TotalAmount     float64           `json:"total_amount" db:"total_amount"`
-	Notes           string            `json:"notes" db:"notes"`
-	CreatedAt       time.Time         `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time         `json:"updated_at" db:"updated_at"`
+	ID                int               `json:"id" db:"id"`
+	UserID            int               `json:"user_id" db:"user_id"`
+	SpecialistID      int               `json:"specialist_id" db:"specialist_id"`
+	ServiceID         int               `json:"service_id" db:"service_id"`
+	AppointmentDate   time.Time         `json:"appointment_date" db:"appointment_date"`
+	AppointmentTime   time.Time         `json:"appointment_time" db:"appointment_time"`
+	Status            AppointmentStatus `json:"status" db:"status"`
+	PaymentStatus     PaymentStatus     `json:"payment_status" db:"payment_status"`
+	TotalAmount       Money             `json:"total_amount" db:"total_amount"` // after the discount, tax included
+	DiscountAmount    Money             `json:"discount_amount" db:"discount_amount"`
+	TaxRate           float64           `json:"tax_rate" db:"tax_rate"` // VAT percent of the service at booking
+	NetAmount         Money             `json:"net_amount" db:"net_amount"`
+	TaxAmount         Money             `json:"tax_amount" db:"tax_amount"`                   // total_amount = net_amount + tax_amount
+	PromoCode         string            `json:"promo_code" db:"promo_code"`                   // the code the discount came from
+	CustomerPackageID *int              `json:"customer_package_id" db:"customer_package_id"` // the package a session was used from
+	PaidAmount        Money             `json:"paid_amount" db:"paid_amount"`                 // sum of the completed payments
+	BalanceDue        Money             `json:"balance_due" db:"-"`
+	Currency          string            `json:"currency" db:"currency"` // ISO 4217, the tenant's currency at booking
+	Notes             string            `json:"notes" db:"notes"`
+	Sequence          int               `json:"sequence" db:"sequence"` // revision of the booking, the iCalendar SEQUENCE
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// SetCurrency sets the appointment's currency on it and its amounts
+func (a *Appointment) SetCurrency(currency string) {
+	a.Currency = currency
+	a.TotalAmount.Currency = currency
+	a.DiscountAmount.Currency = currency
+	a.NetAmount.Currency = currency
+	a.TaxAmount.Currency = currency
+	a.PaidAmount.Currency = currency
+	a.BalanceDue.Currency = currency
 }
 
 type CreateAppointmentRequest struct {
@@ -40,6 +64,7 @@ type CreateAppointmentRequest struct {
 	AppointmentDate time.Time `json:"appointment_date" validate:"required"`
 	AppointmentTime time.Time `json:"appointment_time" validate:"required"`
 	Notes           string    `json:"notes"`
+	PromoCode       string    `json:"promo_code"` // optional
 }
 
 type Service struct {
@@ -47,7 +72,8 @@ type Service struct {
 	CategoryID  *int      `json:"category_id" db:"category_id"`
 	Name        string    `json:"name" db:"name" validate:"required"`
 	Description string    `json:"description" db:"description"`
-	Price       float64   `json:"price" db:"price" validate:"required,min=0"`
+	Price       Money     `json:"price" db:"price" validate:"required,min=0"` // in the tenant's currency
+	TaxRate     *float64  `json:"tax_rate" db:"tax_rate"`                     // VAT percent, nil uses the category's rate
 	ImageURL    string    `json:"image_url" db:"image_url"`
 	Active      bool      `json:"active" db:"active"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
@@ -72,3 +98,15 @@ type WorkingHour struct {
 	EndTime      string `json:"end_time" db:"end_time"`       // HH:MM format
 	Active       bool   `json:"active" db:"active"`           // Whether this working day is active
 }
+
+// AppointmentReminder records a reminder sent for an appointment start time, so
+// every offset is sent once (again after the appointment is moved)
+type AppointmentReminder struct {
+	ID               int       `json:"id" db:"id"`
+	AppointmentID    int       `json:"appointment_id" db:"appointment_id"`
+	OffsetMinutes    int       `json:"offset_minutes" db:"offset_minutes"`
+	AppointmentStart time.Time `json:"appointment_start" db:"appointment_start"`
+	Channels         string    `json:"channels" db:"channels"`
+	Sent             bool      `json:"sent" db:"sent"` // false when skipped for a closer offset
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
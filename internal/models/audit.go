@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Audit actors: a signed-in admin or an API key
+const (
+	AuditActorUser   = "user"
+	AuditActorAPIKey = "api_key"
+)
+
+// Audit actions. Routes that change one field get their own action so the log
+// reads without looking at the diff.
+const (
+	AuditActionCreate         = "create"
+	AuditActionUpdate         = "update"
+	AuditActionDelete         = "delete"
+	AuditActionUpdateRole     = "update_role"
+	AuditActionUpdateStatus   = "update_status"
+	AuditActionUnlock         = "unlock"
+	AuditActionRevokeSessions = "revoke_sessions"
+	AuditActionMarkRead       = "mark_read"
+	AuditActionUpload         = "upload"
+	AuditActionSync           = "sync"
+	AuditActionRefund         = "refund"
+	AuditActionClose          = "close"
+	AuditActionApprove        = "approve"
+	AuditActionRevoke         = "revoke"
+	AuditActionResetTwoFactor = "reset_2fa"
+	AuditActionRotateSecret   = "rotate_secret"
+	AuditActionRedeliver      = "redeliver"
+	AuditActionReset          = "reset"
+	AuditActionRetry          = "retry"
+
+	AuditActionSetupTwoFactor          = "setup_2fa"
+	AuditActionEnableTwoFactor         = "enable_2fa"
+	AuditActionDisableTwoFactor        = "disable_2fa"
+	AuditActionRegenerateRecoveryCodes = "regenerate_recovery_codes"
+)
+
+// AuditChange is the value of one field before and after a change; Before is
+// null on create and After is null on delete
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditLogEntry records who changed what through the admin API. Entries are
+// append-only. Actor keeps the email or API key prefix, so entries stay
+// readable after the actor is deleted.
+type AuditLogEntry struct {
+	ID         int64                  `json:"id" db:"id"`
+	TenantID   int                    `json:"tenant_id" db:"tenant_id"`
+	ActorType  string                 `json:"actor_type" db:"actor_type"`
+	ActorID    *int                   `json:"actor_id" db:"actor_id"`
+	Actor      string                 `json:"actor" db:"actor"`
+	EntityType string                 `json:"entity_type" db:"entity_type"`
+	EntityID   string                 `json:"entity_id" db:"entity_id"`
+	Action     string                 `json:"action" db:"action"`
+	Changes    map[string]AuditChange `json:"changes" db:"changes"`
+	IPAddress  string                 `json:"ip_address" db:"ip_address"`
+	UserAgent  string                 `json:"user_agent" db:"user_agent"`
+	CreatedAt  time.Time              `json:"created_at" db:"created_at"`
+}
+
+// AuditLogFilter narrows the audit log; zero values match everything
+type AuditLogFilter struct {
+	ActorType  string
+	ActorID    int
+	EntityType string
+	EntityID   string
+	Action     string
+	From       *time.Time
+	To         *time.Time
+}
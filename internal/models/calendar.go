@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+type CalendarOwnerType string
+
+const (
+	CalendarOwnerSpecialist CalendarOwnerType = "specialist"
+	CalendarOwnerUser       CalendarOwnerType = "user"
+)
+
+// CalendarFeedToken gives an owner a private feed URL. Only the SHA-256 hash of
+// the token is stored; Token is set when the token is created and shown once.
+type CalendarFeedToken struct {
+	ID        int               `json:"id" db:"id"`
+	OwnerType CalendarOwnerType `json:"owner_type" db:"owner_type"`
+	OwnerID   int               `json:"owner_id" db:"owner_id"`
+	Token     string            `json:"token,omitempty" db:"-"`
+	TokenHash string            `json:"-" db:"token_hash"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+}
+
+// CalendarFeedInfo carries the URLs only when the token was just created
+type CalendarFeedInfo struct {
+	Token     string    `json:"token,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	WebcalURL string    `json:"webcal_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
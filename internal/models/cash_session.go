@@ -0,0 +1,121 @@
+package models
+
+import "time"
+
+type CashSessionStatus string
+
+const (
+	CashSessionOpen     CashSessionStatus = "open"
+	CashSessionClosed   CashSessionStatus = "closed"   // counted, waiting for approval
+	CashSessionApproved CashSessionStatus = "approved" // locked
+)
+
+// CashSessionMethods are the payment methods taken at the desk and counted at
+// close. Wallet payments move no money and are left out.
+var CashSessionMethods = []PaymentMethod{PaymentMethodCash, PaymentMethodCreditCard, PaymentMethodTransfer}
+
+// CashSession is a front desk shift from opening the drawer with a float to
+// counting it at the end of the day. Its Z-report covers what was taken while
+// it was open; it is saved at close and locked once the session is approved.
+// OpenedBy, ClosedBy and ApprovedBy keep the email or API key prefix of whoever
+// did it.
+type CashSession struct {
+	ID                int               `json:"id" db:"id"`
+	Status            CashSessionStatus `json:"status" db:"status"`
+	Currency          string            `json:"currency" db:"currency"`
+	OpeningFloat      Money             `json:"opening_float" db:"opening_float"`
+	CountedCash       *Money            `json:"counted_cash" db:"counted_cash"` // nil until closed
+	CountedCreditCard *Money            `json:"counted_credit_card" db:"counted_credit_card"`
+	CountedTransfer   *Money            `json:"counted_transfer" db:"counted_transfer"`
+	Notes             string            `json:"notes" db:"notes"`
+	OpenedBy          string            `json:"opened_by" db:"opened_by"`
+	OpenedAt          time.Time         `json:"opened_at" db:"opened_at"`
+	ClosedBy          string            `json:"closed_by" db:"closed_by"`
+	ClosedAt          *time.Time        `json:"closed_at" db:"closed_at"`
+	ApprovedBy        string            `json:"approved_by" db:"approved_by"`
+	ApprovedAt        *time.Time        `json:"approved_at" db:"approved_at"`
+}
+
+// SetCurrency sets the session's currency on it and its amounts
+func (s *CashSession) SetCurrency(currency string) {
+	s.Currency = currency
+	for _, amount := range []*Money{&s.OpeningFloat, s.CountedCash, s.CountedCreditCard, s.CountedTransfer} {
+		if amount != nil {
+			amount.Currency = currency
+		}
+	}
+}
+
+// Counted is what was counted for method at close, nil while the session is open
+func (s *CashSession) Counted(method PaymentMethod) *Money {
+	switch method {
+	case PaymentMethodCash:
+		return s.CountedCash
+	case PaymentMethodCreditCard:
+		return s.CountedCreditCard
+	case PaymentMethodTransfer:
+		return s.CountedTransfer
+	}
+	return nil
+}
+
+type OpenCashSessionRequest struct {
+	OpeningFloat Money  `json:"opening_float" validate:"min=0"` // cash in the drawer at opening
+	Notes        string `json:"notes" validate:"max=1000"`
+}
+
+// CloseCashSessionRequest takes the counted cash and the terminal and bank
+// totals. A closed session can be counted again until it is approved.
+type CloseCashSessionRequest struct {
+	CountedCash       Money  `json:"counted_cash" validate:"min=0"`
+	CountedCreditCard Money  `json:"counted_credit_card" validate:"min=0"`
+	CountedTransfer   Money  `json:"counted_transfer" validate:"min=0"`
+	Notes             string `json:"notes" validate:"max=1000"`
+}
+
+type CashSessionSource string
+
+const (
+	CashSourcePayment  CashSessionSource = "payment"
+	CashSourceGiftCard CashSessionSource = "gift_card"
+	CashSourcePackage  CashSessionSource = "package"
+)
+
+// CashSessionLine sums what one source took with one method, and for payments
+// on one device terminal, while the session was open. Refunds are the refunds
+// paid back in the session, also of payments taken before it.
+type CashSessionLine struct {
+	Source   CashSessionSource `json:"source"`
+	Method   PaymentMethod     `json:"payment_method"`
+	DeviceID *int              `json:"device_id"` // payments only
+	Count    int               `json:"count"`
+	Sales    Money             `json:"sales"`
+	Refunds  Money             `json:"refunds"`
+}
+
+// CashSessionMethodTotal compares what a method should hold at close with
+// what was counted. Expected cash includes the opening float; Counted and
+// Discrepancy (counted - expected) are nil while the session is open.
+type CashSessionMethodTotal struct {
+	Method      PaymentMethod `json:"payment_method"`
+	Sales       Money         `json:"sales"`
+	Refunds     Money         `json:"refunds"`
+	Expected    Money         `json:"expected"`
+	Counted     *Money        `json:"counted"`
+	Discrepancy *Money        `json:"discrepancy"`
+}
+
+// ZReport is the end-of-day report of a cash session. While the session is
+// open it shows the running totals.
+type ZReport struct {
+	Session          *CashSession              `json:"session"`
+	Lines            []*CashSessionLine        `json:"lines"`
+	Methods          []*CashSessionMethodTotal `json:"methods"`
+	TotalSales       Money                     `json:"total_sales"`
+	TotalRefunds     Money                     `json:"total_refunds"`
+	TotalExpected    Money                     `json:"total_expected"`
+	TotalCounted     *Money                    `json:"total_counted"`
+	TotalDiscrepancy *Money                    `json:"total_discrepancy"`
+	Balanced         bool                      `json:"balanced"` // counted and no discrepancy
+	Locked           bool                      `json:"locked"`   // approved, it no longer changes
+}
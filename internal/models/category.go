@@ -8,6 +8,7 @@ type Category struct {
 	ID          int       `json:"id" db:"id"`
 	Name        string    `json:"name" db:"name" validate:"required"`
 	Description string    `json:"description" db:"description"`
+	TaxRate     *float64  `json:"tax_rate" db:"tax_rate"` // VAT percent, nil uses the tenant's tax_rate setting
 	Active      bool      `json:"active" db:"active"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+type ExternalCalendarSource string
+
+const (
+	ExternalCalendarSourceURL  ExternalCalendarSource = "url"
+	ExternalCalendarSourceFile ExternalCalendarSource = "file"
+)
+
+// ExternalCalendar is an ICS source whose events block a specialist's availability
+type ExternalCalendar struct {
+	ID           int                    `json:"id" db:"id"`
+	SpecialistID int                    `json:"specialist_id" db:"specialist_id"`
+	Name         string                 `json:"name" db:"name"`
+	SourceType   ExternalCalendarSource `json:"source_type" db:"source_type"`
+	URL          string                 `json:"url,omitempty" db:"url"`
+	ICSData      string                 `json:"-" db:"ics_data"` // Uploaded file content
+	Active       bool                   `json:"active" db:"active"`
+	LastSyncedAt *time.Time             `json:"last_synced_at" db:"last_synced_at"`
+	LastError    string                 `json:"last_error,omitempty" db:"last_error"`
+	BlockCount   int                    `json:"block_count" db:"-"`
+	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+type ExternalBusyBlock struct {
+	ID           int       `json:"id" db:"id"`
+	CalendarID   int       `json:"calendar_id" db:"calendar_id"`
+	SpecialistID int       `json:"specialist_id" db:"specialist_id"`
+	UID          string    `json:"uid" db:"uid"`
+	Summary      string    `json:"summary" db:"summary"`
+	StartsAt     time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt       time.Time `json:"ends_at" db:"ends_at"`
+}
+
+type CreateExternalCalendarRequest struct {
+	Name string `json:"name" validate:"required"`
+	URL  string `json:"url" validate:"required,url"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyKey is the first response to a request sent with an
+// Idempotency-Key header, kept per key and caller so a retry gets the same
+// response instead of running the request again. StatusCode is 0 while the
+// first request is still running.
+type IdempotencyKey struct {
+	ID           int         `json:"id" db:"id"`
+	Key          string      `json:"key" db:"key"`
+	ActorType    string      `json:"actor_type" db:"actor_type"` // user or api_key
+	ActorID      int         `json:"actor_id" db:"actor_id"`
+	RequestHash  string      `json:"request_hash" db:"request_hash"` // SHA-256 of the method, path and body
+	StatusCode   int         `json:"status_code" db:"status_code"`
+	ContentType  string      `json:"content_type" db:"content_type"`
+	Headers      http.Header `json:"-" db:"response_headers"` // replayed with the body, e.g. Location
+	ResponseBody []byte      `json:"-" db:"response_body"`
+	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
+	CompletedAt  *time.Time  `json:"completed_at" db:"completed_at"`
+}
+
+// Completed reports whether the first response has been stored
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
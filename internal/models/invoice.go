@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+type InvoiceType string
+
+const (
+	InvoiceTypeInvoice    InvoiceType = "invoice"
+	InvoiceTypeCreditNote InvoiceType = "credit_note" // issued for a refund, references the invoice
+)
+
+// Invoice is a numbered invoice for a completed payment or a package sale, or
+// a credit note for a refund of a payment. Numbers are the series prefix, the year and a gap-free
+// sequence, e.g. INV2026000000042. Seller and buyer details are copied when
+// the invoice is issued, invoices never change afterwards.
+type Invoice struct {
+	ID                int           `json:"id" db:"id"`
+	Number            string        `json:"number" db:"number"`
+	UUID              string        `json:"uuid" db:"uuid"` // ETTN of the e-invoice
+	Type              InvoiceType   `json:"type" db:"type"`
+	Year              int           `json:"year" db:"year"`
+	Sequence          int           `json:"sequence" db:"sequence"`
+	PaymentID         int           `json:"payment_id" db:"payment_id"` // 0 for package sales
+	RefundID          *int          `json:"refund_id" db:"refund_id"`
+	OriginalInvoiceID *int          `json:"original_invoice_id" db:"original_invoice_id"` // credit notes only
+	AppointmentID     int           `json:"appointment_id" db:"appointment_id"`           // 0 for package sales
+	CustomerPackageID *int          `json:"customer_package_id" db:"customer_package_id"` // the package sale invoiced
+	UserID            int           `json:"user_id" db:"user_id"`
+	Currency          string        `json:"currency" db:"currency"`
+	Total             Money         `json:"total" db:"total"`
+	Seller            InvoiceParty  `json:"seller" db:"seller"`
+	Buyer             InvoiceParty  `json:"buyer" db:"buyer"`
+	Items             []InvoiceItem `json:"items,omitempty" db:"-"`
+	IssuedAt          time.Time     `json:"issued_at" db:"issued_at"`
+}
+
+// SetCurrency sets the invoice's currency on it and its amounts
+func (i *Invoice) SetCurrency(currency string) {
+	i.Currency = currency
+	i.Total.Currency = currency
+	for n := range i.Items {
+		i.Items[n].UnitPrice.Currency = currency
+		i.Items[n].Total.Currency = currency
+	}
+}
+
+// InvoiceParty is the seller or buyer as printed on the invoice
+type InvoiceParty struct {
+	Name      string `json:"name"`
+	Address   string `json:"address,omitempty"`
+	District  string `json:"district,omitempty"`
+	City      string `json:"city,omitempty"`
+	Country   string `json:"country,omitempty"`
+	TaxOffice string `json:"tax_office,omitempty"`
+	TaxNumber string `json:"tax_number,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+}
+
+type InvoiceItem struct {
+	ID          int     `json:"id" db:"id"`
+	InvoiceID   int     `json:"invoice_id" db:"invoice_id"`
+	Description string  `json:"description" db:"description"`
+	Quantity    int     `json:"quantity" db:"quantity"`
+	UnitPrice   Money   `json:"unit_price" db:"unit_price"`
+	Total       Money   `json:"total" db:"total"`       // includes tax
+	TaxRate     float64 `json:"tax_rate" db:"tax_rate"` // VAT percent
+}
+
+// InvoiceFilter narrows the admin invoice list, zero values match everything
+type InvoiceFilter struct {
+	Type   InvoiceType
+	UserID int
+	From   *time.Time
+	To     *time.Time // exclusive
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when a tenant has not chosen a currency
+const DefaultCurrency = "TRY"
+
+// Currencies lists the ISO 4217 codes a tenant can use. Amounts are stored in
+// DECIMAL(10,2) columns, so only currencies with two decimal places are listed.
+var Currencies = []string{
+	"TRY", "EUR", "USD", "GBP", "CHF", "CAD", "AUD", "SEK", "NOK", "DKK",
+	"PLN", "CZK", "RON", "AED", "SAR", "AZN", "GEL",
+}
+
+// IsCurrency reports whether code is one of the supported currencies
+func IsCurrency(code string) bool {
+	for _, currency := range Currencies {
+		if currency == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Money is an exact amount in minor units (kuruş, cents) of an ISO 4217
+// currency. In JSON it is the decimal amount, e.g. 150.5 is written as 150.50,
+// so clients that read amounts as numbers keep working; the currency is sent
+// next to it. Amounts decoded from JSON or read from the database have no
+// currency until the owning record sets it.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney returns amount minor units of currency
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal amount such as "150", "150.5" or "-0.25" exactly.
+// Digits past the second decimal place are rounded half away from zero.
+func ParseMoney(value, currency string) (Money, error) {
+	s := strings.TrimSpace(value)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+
+	roundUp := len(fraction) > 2 && fraction[2] >= '5'
+	fraction = (fraction + "00")[:2]
+
+	units, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if roundUp {
+		units++
+	}
+	if negative {
+		units = -units
+	}
+	return Money{Amount: units, Currency: currency}, nil
+}
+
+// MoneyFromFloat converts a float amount, rounding half away from zero
+func MoneyFromFloat(amount float64, currency string) Money {
+	return Money{Amount: int64(math.Round(amount * 100)), Currency: currency}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrCurrencyMismatch is returned by callers that meet amounts of different
+// currencies, e.g. records from before a tenant changed its currency
+var ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+
+// Add returns m + other in m's currency, or other's when m has none. Adding
+// amounts of two different currencies is a bug and panics: code summing
+// stored amounts checks SameCurrency first and returns ErrCurrencyMismatch.
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currencyWith(other, "add")}
+}
+
+// Sub returns m - other in m's currency, or other's when m has none. It panics
+// like Add on different currencies.
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currencyWith(other, "subtract")}
+}
+
+// SameCurrency reports whether m and other can be added up, an amount
+// without a currency goes with any
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == "" || other.Currency == "" || m.Currency == other.Currency
+}
+
+func (m Money) currencyWith(other Money, op string) string {
+	if !m.SameCurrency(other) {
+		panic(fmt.Sprintf("money: cannot %s %s and %s: %v", op, m, other, ErrCurrencyMismatch))
+	}
+	if m.Currency == "" {
+		return other.Currency
+	}
+	return m.Currency
+}
+
+// Percent returns percent % of m, rounded half away from zero
+func (m Money) Percent(percent float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * percent / 100)), Currency: m.Currency}
+}
+
+// Max returns the larger of m and other, it panics like Add on different
+// currencies
+func (m Money) Max(other Money) Money {
+	m.currencyWith(other, "compare")
+	if other.Amount > m.Amount {
+		return other
+	}
+	return m
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Float64 is the amount in major units, for ratios and display only
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / 100
+}
+
+// Decimal formats the amount with two decimal places, e.g. "150.50"
+func (m Money) Decimal() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// String formats the amount with its currency, e.g. "150.50 TRY"
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+
+	// Exponents are valid JSON numbers but have no exact decimal reading here
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return errors.New("invalid amount")
+		}
+		*m = MoneyFromFloat(f, m.Currency)
+		return nil
+	}
+
+	parsed, err := ParseMoney(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads a DECIMAL column
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		m.Amount = 0
+	case []byte:
+		parsed, err := ParseMoney(string(v), m.Currency)
+		if err != nil {
+			return err
+		}
+		m.Amount = parsed.Amount
+	case string:
+		parsed, err := ParseMoney(v, m.Currency)
+		if err != nil {
+			return err
+		}
+		m.Amount = parsed.Amount
+	case int64:
+		m.Amount = v * 100
+	case float64:
+		m.Amount = MoneyFromFloat(v, "").Amount
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// Value writes the amount as a decimal string, exact for DECIMAL columns
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
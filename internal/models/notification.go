@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+)
+
+type NotificationEvent string
+type NotificationChannelType string
+type NotificationStatus string
+
+const (
+	NotificationUserRegistered           NotificationEvent = "user_registered"
+	NotificationPasswordReset            NotificationEvent = "password_reset"
+	NotificationEmailVerification        NotificationEvent = "email_verification"
+	NotificationAppointmentCreated       NotificationEvent = "appointment_created"
+	NotificationAppointmentStatusChanged NotificationEvent = "appointment_status_changed"
+	NotificationAppointmentCancelled     NotificationEvent = "appointment_cancelled"
+	NotificationAppointmentReminder      NotificationEvent = "appointment_reminder"
+	NotificationPaymentCompleted         NotificationEvent = "payment_completed"
+	NotificationPaymentRefunded          NotificationEvent = "payment_refunded"
+
+	NotificationChannelEmail NotificationChannelType = "email"
+	NotificationChannelSMS   NotificationChannelType = "sms"
+
+	NotificationPending NotificationStatus = "pending"
+	NotificationSent    NotificationStatus = "sent"
+	NotificationFailed  NotificationStatus = "failed"
+)
+
+// NotificationTemplate is a Go text/template for an event and channel. Tenants
+// override the built-in defaults by saving their own version.
+type NotificationTemplate struct {
+	ID        int                     `json:"id,omitempty" db:"id"`
+	Event     NotificationEvent       `json:"event" db:"event"`
+	Channel   NotificationChannelType `json:"channel" db:"channel"`
+	Subject   string                  `json:"subject" db:"subject"`
+	Body      string                  `json:"body" db:"body"`
+	Active    bool                    `json:"active" db:"active"`
+	IsDefault bool                    `json:"is_default" db:"-"`
+	CreatedAt *time.Time              `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt *time.Time              `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+type UpdateNotificationTemplateRequest struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body" validate:"required"`
+	Active  *bool  `json:"active"`
+}
+
+// NotificationMessage is a rendered message ready for a channel
+type NotificationMessage struct {
+	Channel   NotificationChannelType `json:"channel"`
+	Recipient string                  `json:"recipient"`
+	Subject   string                  `json:"subject"`
+	Body      string                  `json:"body"`
+}
+
+type NotificationRecipient struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+type NotificationOutboxItem struct {
+	ID            int                     `json:"id" db:"id"`
+	Event         NotificationEvent       `json:"event" db:"event"`
+	Channel       NotificationChannelType `json:"channel" db:"channel"`
+	Recipient     string                  `json:"recipient" db:"recipient"`
+	Subject       string                  `json:"subject" db:"subject"`
+	Body          string                  `json:"body" db:"body"`
+	Reference     string                  `json:"reference,omitempty" db:"reference"`
+	Status        NotificationStatus      `json:"status" db:"status"`
+	Attempts      int                     `json:"attempts" db:"attempts"`
+	MaxAttempts   int                     `json:"max_attempts" db:"max_attempts"`
+	NextAttemptAt time.Time               `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     string                  `json:"last_error,omitempty" db:"last_error"`
+	SentAt        *time.Time              `json:"sent_at" db:"sent_at"`
+	CreatedAt     time.Time               `json:"created_at" db:"created_at"`
+}
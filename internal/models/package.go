@@ -0,0 +1,126 @@
+package models
+
+import "time"
+
+// Package is a prepaid bundle customers buy, e.g. 10 sessions of a service.
+// A package without a session count is a membership that covers any number
+// of sessions until it expires. Packages cover the services in ServiceIDs and
+// in the categories of CategoryIDs, or every service when both are empty.
+type Package struct {
+	ID           int       `json:"id" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	Description  string    `json:"description" db:"description"`
+	Sessions     *int      `json:"sessions" db:"sessions"`           // nil for memberships
+	ValidityDays int       `json:"validity_days" db:"validity_days"` // after purchase, 0 never expires
+	Price        Money     `json:"price" db:"price"`
+	Currency     string    `json:"currency" db:"currency"`
+	ServiceIDs   []int     `json:"service_ids" db:"service_ids"`
+	CategoryIDs  []int     `json:"category_ids" db:"category_ids"`
+	Active       bool      `json:"active" db:"active"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type PackageRequest struct {
+	Name         string `json:"name" validate:"required,max=255"`
+	Description  string `json:"description"`
+	Sessions     *int   `json:"sessions" validate:"omitempty,min=1"`
+	ValidityDays int    `json:"validity_days" validate:"min=0"`
+	Price        Money  `json:"price" validate:"min=0"`
+	ServiceIDs   []int  `json:"service_ids"`
+	CategoryIDs  []int  `json:"category_ids"`
+	Active       *bool  `json:"active"` // defaults to true
+}
+
+type CustomerPackageStatus string
+
+const (
+	CustomerPackageActive  CustomerPackageStatus = "active"
+	CustomerPackageUsedUp  CustomerPackageStatus = "used_up"
+	CustomerPackageExpired CustomerPackageStatus = "expired"
+)
+
+// CustomerPackage is a package a customer bought and its session balance.
+// The package's terms are copied at purchase, later changes to the package
+// do not affect it.
+type CustomerPackage struct {
+	ID                int                   `json:"id" db:"id"`
+	PackageID         int                   `json:"package_id" db:"package_id"`
+	UserID            int                   `json:"user_id" db:"user_id"`
+	Name              string                `json:"name" db:"name"`
+	SessionsTotal     *int                  `json:"sessions_total" db:"sessions_total"` // nil for memberships
+	SessionsUsed      int                   `json:"sessions_used" db:"sessions_used"`
+	SessionsRemaining *int                  `json:"sessions_remaining" db:"-"`
+	ServiceIDs        []int                 `json:"service_ids" db:"service_ids"`
+	CategoryIDs       []int                 `json:"category_ids" db:"category_ids"`
+	Price             Money                 `json:"price" db:"price"`       // what the customer paid, tax included
+	TaxRate           float64               `json:"tax_rate" db:"tax_rate"` // VAT percent at sale
+	NetAmount         Money                 `json:"net_amount" db:"net_amount"`
+	TaxAmount         Money                 `json:"tax_amount" db:"tax_amount"`
+	Currency          string                `json:"currency" db:"currency"`
+	PaymentMethod     PaymentMethod         `json:"payment_method" db:"payment_method"`
+	ExpiresAt         *time.Time            `json:"expires_at" db:"expires_at"`
+	Status            CustomerPackageStatus `json:"status" db:"-"`
+	History           []*PackageSession     `json:"history,omitempty" db:"-"`
+	CreatedAt         time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time             `json:"updated_at" db:"updated_at"`
+}
+
+// SetCurrency sets the package's currency on it and its amounts
+func (p *CustomerPackage) SetCurrency(currency string) {
+	p.Currency = currency
+	p.Price.Currency = currency
+	p.NetAmount.Currency = currency
+	p.TaxAmount.Currency = currency
+}
+
+// SetBalance fills in the remaining sessions and status at now
+func (p *CustomerPackage) SetBalance(now time.Time) {
+	p.Status = CustomerPackageActive
+	p.SessionsRemaining = nil
+	if p.SessionsTotal != nil {
+		remaining := *p.SessionsTotal - p.SessionsUsed
+		p.SessionsRemaining = &remaining
+		if remaining <= 0 {
+			p.Status = CustomerPackageUsedUp
+		}
+	}
+	if p.ExpiresAt != nil && !now.Before(*p.ExpiresAt) {
+		p.Status = CustomerPackageExpired
+	}
+}
+
+type PackageSessionKind string
+
+const (
+	PackageSessionConsumed PackageSessionKind = "consumed" // a booking used a session
+	PackageSessionRestored PackageSessionKind = "restored" // the booking was cancelled
+	PackageSessionAdjusted PackageSessionKind = "adjusted" // changed by staff
+)
+
+// PackageSession is an entry in the session history of a customer package.
+// Change is the number of sessions added to the balance, negative when used.
+type PackageSession struct {
+	ID                int                `json:"id" db:"id"`
+	CustomerPackageID int                `json:"customer_package_id" db:"customer_package_id"`
+	AppointmentID     *int               `json:"appointment_id" db:"appointment_id"`
+	Kind              PackageSessionKind `json:"kind" db:"kind"`
+	Change            int                `json:"change" db:"change"`
+	Reason            string             `json:"reason" db:"reason"`
+	ActorType         string             `json:"actor_type" db:"actor_type"`
+	ActorID           *int               `json:"actor_id" db:"actor_id"`
+	Actor             string             `json:"actor" db:"actor"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
+}
+
+type SellPackageRequest struct {
+	PackageID     int           `json:"package_id" validate:"required"`
+	PaymentMethod PaymentMethod `json:"payment_method" validate:"required,oneof=credit_card cash transfer"`
+}
+
+// AdjustPackageRequest changes a customer package's balance or expiry
+type AdjustPackageRequest struct {
+	Sessions  int        `json:"sessions"` // added to the balance, negative to remove
+	ExpiresAt *time.Time `json:"expires_at"`
+	Reason    string     `json:"reason" validate:"required,max=500"`
+}
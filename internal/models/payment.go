@@ -10,22 +10,100 @@ const (
 	PaymentMethodCreditCard PaymentMethod = "credit_card"
 	PaymentMethodCash       PaymentMethod = "cash"
 	PaymentMethodTransfer   PaymentMethod = "transfer"
+	PaymentMethodWallet     PaymentMethod = "wallet" // the customer's wallet balance
 )
 
 type Payment struct {
-	ID            int           `json:"id" db:"id"`
-	AppointmentID int           `json:"appointment_id" db:"appointment_id"`
-	DeviceID      *int          `json:"device_id" db:"device_id"`
-	Amount        float64       `json:"amount" db:"amount" validate:"required,min=0"`
-	PaymentMethod PaymentMethod `json:"payment_method" db:"payment_method"`
-	TransactionID string        `json:"transaction_id" db:"transaction_id"`
-	Status        PaymentStatus `json:"status" db:"status"`
-	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	ID             int           `json:"id" db:"id"`
+	AppointmentID  int           `json:"appointment_id" db:"appointment_id"`
+	DeviceID       *int          `json:"device_id" db:"device_id"`
+	Amount         Money         `json:"amount" db:"amount" validate:"required,min=0"`
+	Currency       string        `json:"currency" db:"currency"` // ISO 4217, the appointment's currency
+	TaxRate        float64       `json:"tax_rate" db:"tax_rate"` // the appointment's VAT percent
+	NetAmount      Money         `json:"net_amount" db:"net_amount"`
+	TaxAmount      Money         `json:"tax_amount" db:"tax_amount"` // amount = net_amount + tax_amount
+	PaymentMethod  PaymentMethod `json:"payment_method" db:"payment_method"`
+	TransactionID  string        `json:"transaction_id" db:"transaction_id"` // reference at the provider
+	Provider       string        `json:"provider" db:"provider"`             // empty for payments taken at the desk
+	Status         PaymentStatus `json:"status" db:"status"`                 // stays completed while partially refunded
+	RefundedAmount Money         `json:"refunded_amount" db:"refunded_amount"`
+	FailureReason  string        `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// SetCurrency sets the payment's currency on it and its amounts
+func (p *Payment) SetCurrency(currency string) {
+	p.Currency = currency
+	p.Amount.Currency = currency
+	p.RefundedAmount.Currency = currency
+	p.NetAmount.Currency = currency
+	p.TaxAmount.Currency = currency
 }
 
 type CreatePaymentRequest struct {
 	AppointmentID int           `json:"appointment_id" validate:"required"`
-	Amount        float64       `json:"amount" validate:"required,min=0"`
+	Amount        Money         `json:"amount" validate:"required,min=0"`
 	PaymentMethod PaymentMethod `json:"payment_method" validate:"required"`
 	DeviceID      *int          `json:"device_id"`
 }
+
+type RefundStatus string
+
+const (
+	RefundProcessing RefundStatus = "processing" // amount reserved, waiting for the provider
+	RefundPending    RefundStatus = "pending"    // accepted by the provider, money not back yet
+	RefundCompleted  RefundStatus = "completed"
+	RefundFailed     RefundStatus = "failed" // refused by the provider, the amount is refundable again
+)
+
+// Refund is one full or partial refund of a payment. A payment can be refunded
+// several times until its whole amount is returned. Actor keeps the email or
+// API key prefix of whoever issued it.
+type Refund struct {
+	ID                int          `json:"id" db:"id"`
+	PaymentID         int          `json:"payment_id" db:"payment_id"`
+	Amount            Money        `json:"amount" db:"amount"`
+	Currency          string       `json:"currency" db:"currency"`
+	Reason            string       `json:"reason" db:"reason"`
+	Status            RefundStatus `json:"status" db:"status"`
+	ProviderReference string       `json:"provider_reference" db:"provider_reference"`
+	ToWallet          bool         `json:"to_wallet" db:"to_wallet"` // credited to the customer's wallet
+	ActorType         string       `json:"actor_type" db:"actor_type"`
+	ActorID           *int         `json:"actor_id" db:"actor_id"`
+	Actor             string       `json:"actor" db:"actor"`
+	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
+}
+
+type CreateRefundRequest struct {
+	Amount Money  `json:"amount" validate:"omitempty,gt=0"` // defaults to what is left to refund
+	Reason string `json:"reason" validate:"required,max=500"`
+	// ToWallet keeps the money as wallet credit instead of paying it back;
+	// wallet payments are always refunded to the wallet
+	ToWallet bool `json:"to_wallet"`
+}
+
+// PaymentProviderAccount holds a tenant's gateway credentials, encrypted at
+// rest. Only the last characters of the secret key are ever shown.
+type PaymentProviderAccount struct {
+	Provider      string    `json:"provider" db:"provider"`
+	SecretKey     string    `json:"-" db:"secret_key"`
+	WebhookSecret string    `json:"-" db:"webhook_secret"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PaymentProviderAccountInfo is what admins see of the credentials. WebhookURL
+// is the endpoint to register at the gateway.
+type PaymentProviderAccountInfo struct {
+	Provider      string     `json:"provider"`
+	Configured    bool       `json:"configured"`
+	SecretKeyHint string     `json:"secret_key_hint,omitempty"`
+	WebhookURL    string     `json:"webhook_url"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+type PaymentProviderAccountRequest struct {
+	SecretKey     string `json:"secret_key" validate:"required,max=255"`
+	WebhookSecret string `json:"webhook_secret" validate:"required,max=255"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage"
+	DiscountFixed      DiscountType = "fixed" // an amount in the tenant's currency
+)
+
+// PromoCode is a discount customers enter when booking. Codes are stored in
+// upper case and matched case-insensitively. A code restricted to services or
+// categories applies to a service that is in either list.
+type PromoCode struct {
+	ID             int          `json:"id" db:"id"`
+	Code           string       `json:"code" db:"code"`
+	Description    string       `json:"description" db:"description"`
+	DiscountType   DiscountType `json:"discount_type" db:"discount_type"`
+	PercentOff     float64      `json:"percent_off" db:"percent_off"` // percentage codes
+	AmountOff      Money        `json:"amount_off" db:"amount_off"`   // fixed codes
+	Currency       string       `json:"currency" db:"currency"`
+	ValidFrom      *time.Time   `json:"valid_from" db:"valid_from"`
+	ValidUntil     *time.Time   `json:"valid_until" db:"valid_until"`
+	MaxUses        *int         `json:"max_uses" db:"max_uses"`                   // nil for unlimited
+	MaxUsesPerUser *int         `json:"max_uses_per_user" db:"max_uses_per_user"` // nil for unlimited
+	ServiceIDs     []int        `json:"service_ids" db:"service_ids"`
+	CategoryIDs    []int        `json:"category_ids" db:"category_ids"`
+	Active         bool         `json:"active" db:"active"`
+	Uses           int          `json:"uses" db:"-"` // redemptions whose appointment is not cancelled
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+type PromoCodeRequest struct {
+	Code           string       `json:"code" validate:"required"`
+	Description    string       `json:"description"`
+	DiscountType   DiscountType `json:"discount_type" validate:"required,oneof=percentage fixed"`
+	PercentOff     float64      `json:"percent_off"`
+	AmountOff      Money        `json:"amount_off"`
+	ValidFrom      *time.Time   `json:"valid_from"`
+	ValidUntil     *time.Time   `json:"valid_until"`
+	MaxUses        *int         `json:"max_uses" validate:"omitempty,min=1"`
+	MaxUsesPerUser *int         `json:"max_uses_per_user" validate:"omitempty,min=1"`
+	ServiceIDs     []int        `json:"service_ids"`
+	CategoryIDs    []int        `json:"category_ids"`
+	Active         *bool        `json:"active"` // defaults to true
+}
+
+// PromoRedemption is a promo code applied to a booking. Redemptions of
+// cancelled appointments stay in reports but no longer count against limits.
+type PromoRedemption struct {
+	ID                int               `json:"id" db:"id"`
+	PromoCodeID       int               `json:"promo_code_id" db:"promo_code_id"`
+	Code              string            `json:"code" db:"-"`
+	AppointmentID     int               `json:"appointment_id" db:"appointment_id"`
+	AppointmentStatus AppointmentStatus `json:"appointment_status" db:"-"`
+	UserID            int               `json:"user_id" db:"-"`
+	UserName          string            `json:"user_name" db:"-"`
+	ServiceID         int               `json:"service_id" db:"-"`
+	Discount          Money             `json:"discount" db:"discount"`
+	Currency          string            `json:"currency" db:"currency"`
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+}
+
+// PromoRedemptionFilter narrows the redemption report, zero values match everything
+type PromoRedemptionFilter struct {
+	PromoCodeID int
+	From        *time.Time
+	To          *time.Time // exclusive
+}
+
+// PromoCodeSummary totals the redemptions of one code and currency in a report
+type PromoCodeSummary struct {
+	PromoCodeID   int    `json:"promo_code_id"`
+	Code          string `json:"code"`
+	Redemptions   int    `json:"redemptions"`
+	Cancelled     int    `json:"cancelled"`
+	DiscountTotal Money  `json:"discount_total"` // without cancelled appointments
+	Currency      string `json:"currency"`
+}
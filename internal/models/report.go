@@ -39,3 +39,20 @@ type ReportFilters struct {
 	UserID       *int       `json:"user_id"`
 	Status       *string    `json:"status"`
 }
+
+// SalesByTaxRate is the revenue kept of the completed payments, or of the
+// packages sold, at one VAT rate, gross = net + tax
+type SalesByTaxRate struct {
+	TaxRate  float64 `json:"tax_rate"`
+	Currency string  `json:"currency"`
+	Payments int     `json:"payments"`
+	Net      Money   `json:"net"`
+	Tax      Money   `json:"tax"`
+	Gross    Money   `json:"gross"`
+}
+
+// SalesTotal is the number and amount of the sales of one kind in a period
+type SalesTotal struct {
+	Count  int   `json:"count"`
+	Amount Money `json:"amount"`
+}
@@ -11,19 +11,30 @@ const (
 	RoleUser  UserRole = "user"
 )
 
+// IsStaff reports whether the role belongs to the business' staff
+func (r UserRole) IsStaff() bool {
+	return r == RoleAdmin
+}
+
 type User struct {
-	ID        int        `json:"id" db:"id"`
-	Email     string     `json:"email" db:"email" validate:"required,email"`
-	Password  string     `json:"-" db:"password" validate:"required,min=6"`
-	Role      UserRole   `json:"role" db:"role"`
-	Name      string     `json:"name" db:"name" validate:"required"`
-	Phone     string     `json:"phone" db:"phone"`
-	BirthDate *time.Time `json:"birth_date" db:"birth_date"`
-	UstBel    *float64   `json:"ust_bel" db:"ust_bel"`
-	OrtaBel   *float64   `json:"orta_bel" db:"orta_bel"`
-	AltBel    *float64   `json:"alt_bel" db:"alt_bel"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	ID         int        `json:"id" db:"id"`
+	Email      string     `json:"email" db:"email" validate:"required,email"`
+	Password   string     `json:"-" db:"password" validate:"required,min=6"`
+	Role       UserRole   `json:"role" db:"role"`
+	Name       string     `json:"name" db:"name" validate:"required"`
+	Phone      string     `json:"phone" db:"phone"`
+	BirthDate  *time.Time `json:"birth_date" db:"birth_date"`
+	UstBel     *float64   `json:"ust_bel" db:"ust_bel"`
+	OrtaBel    *float64   `json:"orta_bel" db:"orta_bel"`
+	AltBel     *float64   `json:"alt_bel" db:"alt_bel"`
+	TaxNumber  string     `json:"tax_number" db:"tax_number"` // TCKN for people, VKN for companies, printed on invoices
+	TaxOffice  string     `json:"tax_office" db:"tax_office"`
+	Address    string     `json:"address" db:"address"`
+	District   string     `json:"district" db:"district"`
+	City       string     `json:"city" db:"city"`
+	VerifiedAt *time.Time `json:"verified_at" db:"verified_at"` // nil until the email is verified
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type LoginRequest struct {
@@ -38,7 +49,145 @@ type RegisterRequest struct {
 	Phone    string `json:"phone"`
 }
 
+// AuthResponse carries a short-lived access token (Token) and the refresh
+// token of the session it belongs to
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	User         User      `json:"user"`
+	// Only set when two-factor enrollment completes during login, shown once
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// ClientInfo describes where a login comes from: the device, and the tenant
+// the issued tokens are bound to
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+	TenantID  int
+}
+
+// EmailVerified reports whether the user confirmed their email address
+func (u *User) EmailVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+// PasswordReset is a single-use reset token, only its SHA-256 hash is stored
+type PasswordReset struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// EmailVerification is a single-use email verification token, stored hashed
+type EmailVerification struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Session is a login on one device. The refresh token is stored as a SHA-256
+// hash and replaced on every refresh; the previous hash is kept to detect reuse.
+type Session struct {
+	ID                int        `json:"id" db:"id"`
+	UserID            int        `json:"user_id" db:"user_id"`
+	TokenHash         string     `json:"-" db:"token_hash"`
+	PreviousTokenHash string     `json:"-" db:"previous_token_hash"`
+	UserAgent         string     `json:"user_agent" db:"user_agent"`
+	IPAddress         string     `json:"ip_address" db:"ip_address"`
+	ExpiresAt         time.Time  `json:"expires_at" db:"expires_at"`
+	LastUsedAt        time.Time  `json:"last_used_at" db:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	Current           bool       `json:"current" db:"-"`
+}
+
+// Active reports whether the session can still be used
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+type LoginEvent string
+
+const (
+	LoginFailed    LoginEvent = "failed"
+	LoginSucceeded LoginEvent = "succeeded"
+	LoginBlocked   LoginEvent = "blocked"  // rejected by throttling before the password was checked
+	LoginLocked    LoginEvent = "locked"   // the account reached the failure threshold
+	LoginUnlocked  LoginEvent = "unlocked" // an admin cleared the account's failures
+)
+
+// LoginAttempt is a recorded login event. Email is stored lowercased and also
+// recorded for unknown accounts.
+type LoginAttempt struct {
+	ID        int        `json:"id" db:"id"`
+	Email     string     `json:"email" db:"email"`
+	UserID    *int       `json:"user_id" db:"user_id"`
+	IPAddress string     `json:"ip_address" db:"ip_address"`
+	UserAgent string     `json:"user_agent" db:"user_agent"`
+	Event     LoginEvent `json:"event" db:"event"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type LoginAttemptFilter struct {
+	Email     string
+	IPAddress string
+	Event     LoginEvent
+}
+
+// IsTaxNumber reports whether s is a valid TCKN or VKN
+func IsTaxNumber(s string) bool {
+	return IsTCKN(s) || IsVKN(s)
+}
+
+// IsTCKN checks the 11 digit Turkish identity number and its two check digits
+func IsTCKN(s string) bool {
+	if len(s) != 11 || s[0] == '0' || !isDigits(s) {
+		return false
+	}
+	d := make([]int, 11)
+	for i := range s {
+		d[i] = int(s[i] - '0')
+	}
+
+	odd := d[0] + d[2] + d[4] + d[6] + d[8]
+	even := d[1] + d[3] + d[5] + d[7]
+	if ((odd*7-even)%10+10)%10 != d[9] {
+		return false
+	}
+
+	sum := 0
+	for _, digit := range d[:10] {
+		sum += digit
+	}
+	return sum%10 == d[10]
+}
+
+// IsVKN checks the 10 digit Turkish tax number and its check digit
+func IsVKN(s string) bool {
+	if len(s) != 10 || !isDigits(s) {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		tmp := (int(s[i]-'0') + 9 - i) % 10
+		v := (tmp << (9 - i)) % 9
+		if tmp != 0 && v == 0 {
+			v = 9
+		}
+		sum += v
+	}
+	return (10-sum%10)%10 == int(s[9]-'0')
 }
@@ -0,0 +1,173 @@
+package models
+
+import "time"
+
+type WalletEntryKind string
+
+const (
+	WalletEntryGiftCard   WalletEntryKind = "gift_card"  // credit from a redeemed gift card
+	WalletEntryRefund     WalletEntryKind = "refund"     // credit from a payment refunded to the wallet
+	WalletEntryPayment    WalletEntryKind = "payment"    // debit for an appointment paid from the wallet
+	WalletEntryExpiry     WalletEntryKind = "expiry"     // debit of a credit that expired unused
+	WalletEntryAdjustment WalletEntryKind = "adjustment" // credit or debit by staff
+)
+
+// WalletEntry is a line in a customer's wallet ledger. Amount is positive for
+// credits and negative for debits, the balance is their sum. Debits spend the
+// credits expiring first; Remaining is what is left of a credit and expires at
+// ExpiresAt.
+type WalletEntry struct {
+	ID            int             `json:"id" db:"id"`
+	UserID        int             `json:"user_id" db:"user_id"`
+	Kind          WalletEntryKind `json:"kind" db:"kind"`
+	Amount        Money           `json:"amount" db:"amount"`
+	Remaining     Money           `json:"remaining" db:"remaining"` // credits only
+	Currency      string          `json:"currency" db:"currency"`
+	ExpiresAt     *time.Time      `json:"expires_at" db:"expires_at"` // credits only, nil never expires
+	PaymentID     *int            `json:"payment_id" db:"payment_id"`
+	RefundID      *int            `json:"refund_id" db:"refund_id"`
+	GiftCardID    *int            `json:"gift_card_id" db:"gift_card_id"`
+	CreditEntryID *int            `json:"credit_entry_id" db:"credit_entry_id"` // the credit an expiry ends
+	Reason        string          `json:"reason" db:"reason"`
+	ActorType     string          `json:"actor_type" db:"actor_type"`
+	ActorID       *int            `json:"actor_id" db:"actor_id"`
+	Actor         string          `json:"actor" db:"actor"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// SetCurrency sets the entry's currency on it and its amounts
+func (e *WalletEntry) SetCurrency(currency string) {
+	e.Currency = currency
+	e.Amount.Currency = currency
+	e.Remaining.Currency = currency
+}
+
+// WalletBalance is what a customer can spend in one currency
+type WalletBalance struct {
+	Currency string `json:"currency"`
+	Balance  Money  `json:"balance"`
+}
+
+// WalletAdjustmentRequest credits or, with a negative amount, debits a wallet
+type WalletAdjustmentRequest struct {
+	Amount    Money      `json:"amount" validate:"required"`
+	ExpiresAt *time.Time `json:"expires_at"` // credits only
+	Reason    string     `json:"reason" validate:"required,max=500"`
+}
+
+type GiftCardStatus string
+
+const (
+	GiftCardActive   GiftCardStatus = "active"
+	GiftCardRedeemed GiftCardStatus = "redeemed"
+	GiftCardExpired  GiftCardStatus = "expired"
+)
+
+// GiftCard is a prepaid code sold at the desk. The customer redeeming it gets
+// its amount as wallet credit that expires with the card.
+type GiftCard struct {
+	ID             int            `json:"id" db:"id"`
+	Code           string         `json:"code" db:"code"`
+	Amount         Money          `json:"amount" db:"amount"`
+	Currency       string         `json:"currency" db:"currency"`
+	PurchaserID    *int           `json:"purchaser_id" db:"purchaser_id"` // nil for walk-in buyers
+	RecipientName  string         `json:"recipient_name" db:"recipient_name"`
+	RecipientEmail string         `json:"recipient_email" db:"recipient_email"`
+	Message        string         `json:"message" db:"message"`
+	PaymentMethod  PaymentMethod  `json:"payment_method" db:"payment_method"`
+	ExpiresAt      *time.Time     `json:"expires_at" db:"expires_at"`
+	RedeemedBy     *int           `json:"redeemed_by" db:"redeemed_by"`
+	RedeemedAt     *time.Time     `json:"redeemed_at" db:"redeemed_at"`
+	Status         GiftCardStatus `json:"status" db:"-"`
+	ActorType      string         `json:"actor_type" db:"actor_type"`
+	ActorID        *int           `json:"actor_id" db:"actor_id"`
+	Actor          string         `json:"actor" db:"actor"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+}
+
+// SetStatus fills in the card's status at now
+func (g *GiftCard) SetStatus(now time.Time) {
+	switch {
+	case g.RedeemedAt != nil:
+		g.Status = GiftCardRedeemed
+	case g.ExpiresAt != nil && !now.Before(*g.ExpiresAt):
+		g.Status = GiftCardExpired
+	default:
+		g.Status = GiftCardActive
+	}
+}
+
+type CreateGiftCardRequest struct {
+	Amount         Money         `json:"amount" validate:"required,gt=0"`
+	PaymentMethod  PaymentMethod `json:"payment_method" validate:"required,oneof=credit_card cash transfer"`
+	PurchaserID    *int          `json:"purchaser_id"`
+	RecipientName  string        `json:"recipient_name" validate:"max=255"`
+	RecipientEmail string        `json:"recipient_email" validate:"omitempty,email,max=255"`
+	Message        string        `json:"message" validate:"max=500"`
+	ValidityDays   *int          `json:"validity_days" validate:"omitempty,min=0"` // defaults to the gift_card_validity_days setting, 0 never expires
+}
+
+type RedeemGiftCardRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// WalletReportFilter narrows the wallet report, zero values match everything
+type WalletReportFilter struct {
+	From *time.Time
+	To   *time.Time // exclusive
+}
+
+// WalletReport sums the wallet ledger of one currency over a period and checks
+// it against the payments, refunds and gift cards the entries came from.
+// Debits are reported as positive amounts; the closing balance is the opening
+// balance plus credits minus debits.
+type WalletReport struct {
+	Currency             string                  `json:"currency"`
+	OpeningBalance       Money                   `json:"opening_balance"`
+	GiftCardCredits      Money                   `json:"gift_card_credits"`
+	RefundCredits        Money                   `json:"refund_credits"`
+	AdjustmentCredits    Money                   `json:"adjustment_credits"`
+	PaymentDebits        Money                   `json:"payment_debits"`
+	ExpiryDebits         Money                   `json:"expiry_debits"`
+	AdjustmentDebits     Money                   `json:"adjustment_debits"`
+	ClosingBalance       Money                   `json:"closing_balance"`
+	WalletPayments       Money                   `json:"wallet_payments"`     // completed wallet payments
+	WalletRefunds        Money                   `json:"wallet_refunds"`      // refunds paid into wallets
+	GiftCardsSold        Money                   `json:"gift_cards_sold"`     // sold in the period
+	GiftCardsRedeemed    Money                   `json:"gift_cards_redeemed"` // redeemed in the period
+	GiftCardsOutstanding Money                   `json:"gift_cards_outstanding"`
+	Discrepancies        []*WalletReconciliation `json:"discrepancies"` // lines where the ledger and its source disagree
+	Reconciled           bool                    `json:"reconciled"`
+}
+
+// SetCurrency sets the report's currency on it and its amounts
+func (r *WalletReport) SetCurrency(currency string) {
+	r.Currency = currency
+	for _, amount := range []*Money{
+		&r.OpeningBalance, &r.GiftCardCredits, &r.RefundCredits, &r.AdjustmentCredits,
+		&r.PaymentDebits, &r.ExpiryDebits, &r.AdjustmentDebits, &r.ClosingBalance,
+		&r.WalletPayments, &r.WalletRefunds, &r.GiftCardsSold, &r.GiftCardsRedeemed, &r.GiftCardsOutstanding,
+	} {
+		amount.Currency = currency
+	}
+}
+
+type WalletReconciliationSource string
+
+const (
+	WalletSourcePayment  WalletReconciliationSource = "payment"
+	WalletSourceRefund   WalletReconciliationSource = "refund"
+	WalletSourceGiftCard WalletReconciliationSource = "gift_card"
+)
+
+// WalletReconciliation compares a payment, refund or gift card redemption with
+// what the ledger recorded for it. Expected is zero for ledger entries whose
+// payment was deleted or did not complete.
+type WalletReconciliation struct {
+	Source   WalletReconciliationSource `json:"source"`
+	SourceID *int                       `json:"source_id"`
+	EntryID  *int                       `json:"entry_id,omitempty"`
+	Currency string                     `json:"currency"`
+	Expected Money                      `json:"expected"`
+	Recorded Money                      `json:"recorded"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookEvent string
+type WebhookDeliveryStatus string
+
+const (
+	WebhookAppointmentCreated       WebhookEvent = "appointment.created"
+	WebhookAppointmentStatusChanged WebhookEvent = "appointment.status_changed"
+	WebhookPaymentCompleted         WebhookEvent = "payment.completed"
+	WebhookPaymentRefunded          WebhookEvent = "payment.refunded"
+	WebhookUserRegistered           WebhookEvent = "user.registered"
+	WebhookContactReceived          WebhookEvent = "contact.received"
+	// WebhookAllEvents subscribes to every event, including ones added later
+	WebhookAllEvents WebhookEvent = "*"
+
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+type WebhookSubscription struct {
+	ID          int            `json:"id" db:"id"`
+	URL         string         `json:"url" db:"url"`
+	Secret      string         `json:"secret" db:"secret"` // HMAC key, shared with the receiver
+	Events      []WebhookEvent `json:"events" db:"events"`
+	Description string         `json:"description" db:"description"`
+	Active      bool           `json:"active" db:"active"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL         string         `json:"url" validate:"required,url"`
+	Events      []WebhookEvent `json:"events" validate:"required,min=1"`
+	Description string         `json:"description"`
+	Active      *bool          `json:"active"`
+}
+
+type UpdateWebhookRequest struct {
+	URL         string         `json:"url" validate:"omitempty,url"`
+	Events      []WebhookEvent `json:"events"`
+	Description *string        `json:"description"`
+	Active      *bool          `json:"active"`
+}
+
+// WebhookPayload is the JSON body posted to subscribers. ID identifies the event
+// and is the same for every subscription and redelivery.
+type WebhookPayload struct {
+	ID        string       `json:"id"`
+	Event     WebhookEvent `json:"event"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      interface{}  `json:"data"`
+}
+
+type WebhookDelivery struct {
+	ID             int                   `json:"id" db:"id"`
+	SubscriptionID int                   `json:"subscription_id" db:"subscription_id"`
+	Event          WebhookEvent          `json:"event" db:"event"`
+	EventID        string                `json:"event_id" db:"event_id"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	MaxAttempts    int                   `json:"max_attempts" db:"max_attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError      string                `json:"last_error,omitempty" db:"last_error"`
+	ResponseStatus *int                  `json:"response_status" db:"response_status"`
+	ResponseBody   string                `json:"response_body,omitempty" db:"response_body"`
+	DeliveredAt    *time.Time            `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+}
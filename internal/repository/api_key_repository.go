@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"strings"
+)
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	GetByID(id int) (*models.APIKey, error)
+	GetByHash(keyHash string) (*models.APIKey, error)
+	List() ([]*models.APIKey, error)
+	Update(key *models.APIKey) error
+	Revoke(id int) error
+	TouchLastUsed(id int, ipAddress string) error
+}
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, created_by, expires_at, last_used_at,
+	COALESCE(last_used_ip, ''), revoked_at, created_at, updated_at`
+
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(
+		query,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		strings.Join(key.Scopes, ","),
+		key.CreatedBy,
+		key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt, &key.UpdatedAt)
+}
+
+func (r *apiKeyRepository) GetByID(id int) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	return scanAPIKey(r.db.QueryRow(query, id))
+}
+
+func (r *apiKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	return scanAPIKey(r.db.QueryRow(query, keyHash))
+}
+
+func (r *apiKeyRepository) List() ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *apiKeyRepository) Update(key *models.APIKey) error {
+	query := `
+		UPDATE api_keys
+		SET name = $1, scopes = $2, expires_at = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING updated_at`
+
+	return r.db.QueryRow(query, key.Name, strings.Join(key.Scopes, ","), key.ExpiresAt, key.ID).Scan(&key.UpdatedAt)
+}
+
+func (r *apiKeyRepository) Revoke(id int) error {
+	query := `UPDATE api_keys SET revoked_at = NOW(), updated_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchLastUsed records usage at most once a minute, so busy integrations do
+// not write on every request
+func (r *apiKeyRepository) TouchLastUsed(id int, ipAddress string) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	_, err := r.db.Exec(query, id, ipAddress)
+	return err
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes string
+	var createdBy sql.NullInt64
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &createdBy, &expiresAt,
+		&lastUsedAt, &key.LastUsedIP, &revokedAt, &key.CreatedAt, &key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = []string{}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			key.Scopes = append(key.Scopes, scope)
+		}
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		key.CreatedBy = &id
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
@@ -17,6 +17,10 @@ type AppointmentRepository interface {
 	UpdateStatus(id int, status models.AppointmentStatus) error
 	CheckConflict(specialistID int, appointmentDate, appointmentTime time.Time, excludeID *int) (bool, error)
 	UpdatePaymentStatus(appointmentID int, status models.PaymentStatus) error
+	UpdatePaymentTotals(appointmentID int, paidAmount models.Money, status models.PaymentStatus) error
+	GetBySpecialistIDSince(specialistID int, since time.Time) ([]*models.Appointment, error)
+	GetByUserIDSince(userID int, since time.Time) ([]*models.Appointment, error)
+	GetUpcoming(from, to time.Time) ([]*models.Appointment, error)
 }
 
 type appointmentRepository struct {
@@ -30,8 +34,9 @@ func NewAppointmentRepository(db *sql.DB) AppointmentRepository {
 func (r *appointmentRepository) Create(appointment *models.Appointment) error {
 	query := `
 		INSERT INTO appointments (user_id, specialist_id, service_id, appointment_date, appointment_time, 
-			status, payment_status, total_amount, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			status, payment_status, total_amount, discount_amount, promo_code, customer_package_id, currency,
+			tax_rate, net_amount, tax_amount, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12, $13, $14, $15, $16, $17, $18)
 		RETURNING id`
 
 	now := time.Now()
@@ -45,6 +50,13 @@ func (r *appointmentRepository) Create(appointment *models.Appointment) error {
 		appointment.Status,
 		appointment.PaymentStatus,
 		appointment.TotalAmount,
+		appointment.DiscountAmount,
+		appointment.PromoCode,
+		appointment.CustomerPackageID,
+		appointment.Currency,
+		appointment.TaxRate,
+		appointment.NetAmount,
+		appointment.TaxAmount,
 		appointment.Notes,
 		now,
 		now,
@@ -54,6 +66,7 @@ func (r *appointmentRepository) Create(appointment *models.Appointment) error {
 		return err
 	}
 
+	appointment.BalanceDue = appointment.TotalAmount.Sub(appointment.PaidAmount)
 	appointment.CreatedAt = now
 	appointment.UpdatedAt = now
 	return nil
@@ -62,36 +75,22 @@ func (r *appointmentRepository) Create(appointment *models.Appointment) error {
 func (r *appointmentRepository) GetByID(id int) (*models.Appointment, error) {
 	query := `
 		SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
-			status, payment_status, total_amount, notes, created_at, updated_at
+			status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+			currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
 		FROM appointments 
 		WHERE id = $1`
 
-	appointment := &models.Appointment{}
-	err := r.db.QueryRow(query, id).Scan(
-		&appointment.ID,
-		&appointment.UserID,
-		&appointment.SpecialistID,
-		&appointment.ServiceID,
-		&appointment.AppointmentDate,
-		&appointment.AppointmentTime,
-		&appointment.Status,
-		&appointment.PaymentStatus,
-		&appointment.TotalAmount,
-		&appointment.Notes,
-		&appointment.CreatedAt,
-		&appointment.UpdatedAt,
-	)
-
-	return appointment, err
+	return scanAppointment(r.db.QueryRow(query, id))
 }
 
 func (r *appointmentRepository) Update(appointment *models.Appointment) error {
 	query := `
 		UPDATE appointments 
 		SET specialist_id = $2, service_id = $3, appointment_date = $4, appointment_time = $5,
-			status = $6, payment_status = $7, total_amount = $8, notes = $9, updated_at = $10
+			status = $6, payment_status = $7, total_amount = $8, tax_rate = $9, net_amount = $10, tax_amount = $11,
+			notes = $12, sequence = sequence + 1, updated_at = $13
 		WHERE id = $1
-		RETURNING updated_at`
+		RETURNING sequence, updated_at`
 
 	appointment.UpdatedAt = time.Now()
 	err := r.db.QueryRow(
@@ -104,9 +103,12 @@ func (r *appointmentRepository) Update(appointment *models.Appointment) error {
 		appointment.Status,
 		appointment.PaymentStatus,
 		appointment.TotalAmount,
+		appointment.TaxRate,
+		appointment.NetAmount,
+		appointment.TaxAmount,
 		appointment.Notes,
 		appointment.UpdatedAt,
-	).Scan(&appointment.UpdatedAt)
+	).Scan(&appointment.Sequence, &appointment.UpdatedAt)
 
 	return err
 }
@@ -120,7 +122,8 @@ func (r *appointmentRepository) Delete(id int) error {
 func (r *appointmentRepository) GetByUserID(userID int) ([]*models.Appointment, error) {
 	query := `
 		SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
-			status, payment_status, total_amount, notes, created_at, updated_at
+			status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+			currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
 		FROM appointments 
 		WHERE user_id = $1
 		ORDER BY appointment_date DESC, appointment_time DESC`
@@ -131,30 +134,7 @@ func (r *appointmentRepository) GetByUserID(userID int) ([]*models.Appointment,
 	}
 	defer rows.Close()
 
-	var appointments []*models.Appointment
-	for rows.Next() {
-		appointment := &models.Appointment{}
-		err := rows.Scan(
-			&appointment.ID,
-			&appointment.UserID,
-			&appointment.SpecialistID,
-			&appointment.ServiceID,
-			&appointment.AppointmentDate,
-			&appointment.AppointmentTime,
-			&appointment.Status,
-			&appointment.PaymentStatus,
-			&appointment.TotalAmount,
-			&appointment.Notes,
-			&appointment.CreatedAt,
-			&appointment.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		appointments = append(appointments, appointment)
-	}
-
-	return appointments, nil
+	return r.scanAppointments(rows)
 }
 
 func (r *appointmentRepository) GetBySpecialistID(specialistID int, date *time.Time) ([]*models.Appointment, error) {
@@ -164,7 +144,8 @@ func (r *appointmentRepository) GetBySpecialistID(specialistID int, date *time.T
 	if date != nil {
 		query = `
 			SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
-				status, payment_status, total_amount, notes, created_at, updated_at
+				status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+				currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
 			FROM appointments 
 			WHERE specialist_id = $1 AND appointment_date = $2
 			ORDER BY appointment_time ASC`
@@ -172,7 +153,8 @@ func (r *appointmentRepository) GetBySpecialistID(specialistID int, date *time.T
 	} else {
 		query = `
 			SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
-				status, payment_status, total_amount, notes, created_at, updated_at
+				status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+				currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
 			FROM appointments 
 			WHERE specialist_id = $1
 			ORDER BY appointment_date DESC, appointment_time DESC`
@@ -185,30 +167,7 @@ func (r *appointmentRepository) GetBySpecialistID(specialistID int, date *time.T
 	}
 	defer rows.Close()
 
-	var appointments []*models.Appointment
-	for rows.Next() {
-		appointment := &models.Appointment{}
-		err := rows.Scan(
-			&appointment.ID,
-			&appointment.UserID,
-			&appointment.SpecialistID,
-			&appointment.ServiceID,
-			&appointment.AppointmentDate,
-			&appointment.AppointmentTime,
-			&appointment.Status,
-			&appointment.PaymentStatus,
-			&appointment.TotalAmount,
-			&appointment.Notes,
-			&appointment.CreatedAt,
-			&appointment.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		appointments = append(appointments, appointment)
-	}
-
-	return appointments, nil
+	return r.scanAppointments(rows)
 }
 
 func (r *appointmentRepository) List(limit, offset int) ([]*models.Appointment, int, error) {
@@ -223,7 +182,8 @@ func (r *appointmentRepository) List(limit, offset int) ([]*models.Appointment,
 	// Get appointments
 	query := `
 		SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
-			status, payment_status, total_amount, notes, created_at, updated_at
+			status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+			currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
 		FROM appointments 
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
@@ -234,36 +194,14 @@ func (r *appointmentRepository) List(limit, offset int) ([]*models.Appointment,
 	}
 	defer rows.Close()
 
-	var appointments []*models.Appointment
-	for rows.Next() {
-		appointment := &models.Appointment{}
-		err := rows.Scan(
-			&appointment.ID,
-			&appointment.UserID,
-			&appointment.SpecialistID,
-			&appointment.ServiceID,
-			&appointment.AppointmentDate,
-			&appointment.AppointmentTime,
-			&appointment.Status,
-			&appointment.PaymentStatus,
-			&appointment.TotalAmount,
-			&appointment.Notes,
-			&appointment.CreatedAt,
-			&appointment.UpdatedAt,
-		)
-		if err != nil {
-			return nil, 0, err
-		}
-		appointments = append(appointments, appointment)
-	}
-
-	return appointments, total, nil
+	appointments, err := r.scanAppointments(rows)
+	return appointments, total, err
 }
 
 func (r *appointmentRepository) UpdateStatus(id int, status models.AppointmentStatus) error {
 	query := `
 		UPDATE appointments 
-		SET status = $2, updated_at = CURRENT_TIMESTAMP
+		SET status = $2, sequence = sequence + 1, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1`
 
 	_, err := r.db.Exec(query, id, status)
@@ -302,3 +240,116 @@ func (r *appointmentRepository) UpdatePaymentStatus(appointmentID int, status mo
 	_, err := r.db.Exec(query, status, appointmentID)
 	return err
 }
+
+// UpdatePaymentTotals stores the amount collected so far with the payment status it results in
+func (r *appointmentRepository) UpdatePaymentTotals(appointmentID int, paidAmount models.Money, status models.PaymentStatus) error {
+	query := `UPDATE appointments SET paid_amount = $1, payment_status = $2, updated_at = NOW() WHERE id = $3`
+	_, err := r.db.Exec(query, paidAmount, status, appointmentID)
+	return err
+}
+
+func (r *appointmentRepository) GetBySpecialistIDSince(specialistID int, since time.Time) ([]*models.Appointment, error) {
+	query := `
+		SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
+			status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+			currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
+		FROM appointments 
+		WHERE specialist_id = $1 AND appointment_date >= $2
+		ORDER BY appointment_date ASC, appointment_time ASC`
+
+	rows, err := r.db.Query(query, specialistID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanAppointments(rows)
+}
+
+func (r *appointmentRepository) GetByUserIDSince(userID int, since time.Time) ([]*models.Appointment, error) {
+	query := `
+		SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
+			status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+			currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
+		FROM appointments 
+		WHERE user_id = $1 AND appointment_date >= $2
+		ORDER BY appointment_date ASC, appointment_time ASC`
+
+	rows, err := r.db.Query(query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanAppointments(rows)
+}
+
+// GetUpcoming returns the pending and confirmed appointments starting in [from, to).
+// Appointment dates and times are stored as wall time, so the bounds are compared
+// in the location they are given in.
+func (r *appointmentRepository) GetUpcoming(from, to time.Time) ([]*models.Appointment, error) {
+	query := `
+		SELECT id, user_id, specialist_id, service_id, appointment_date, appointment_time,
+			status, payment_status, total_amount, paid_amount, GREATEST(total_amount - paid_amount, 0),
+			currency, discount_amount, COALESCE(promo_code, ''), customer_package_id, tax_rate, net_amount, tax_amount, notes, sequence, created_at, updated_at
+		FROM appointments 
+		WHERE status IN ('pending', 'confirmed')
+			AND appointment_date + appointment_time >= $1::timestamp
+			AND appointment_date + appointment_time < $2::timestamp
+		ORDER BY appointment_date ASC, appointment_time ASC`
+
+	const layout = "2006-01-02 15:04:05"
+	rows, err := r.db.Query(query, from.Format(layout), to.Format(layout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanAppointments(rows)
+}
+
+func (r *appointmentRepository) scanAppointments(rows *sql.Rows) ([]*models.Appointment, error) {
+	var appointments []*models.Appointment
+	for rows.Next() {
+		appointment, err := scanAppointment(rows)
+		if err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, appointment)
+	}
+
+	return appointments, rows.Err()
+}
+
+func scanAppointment(row rowScanner) (*models.Appointment, error) {
+	appointment := &models.Appointment{}
+	err := row.Scan(
+		&appointment.ID,
+		&appointment.UserID,
+		&appointment.SpecialistID,
+		&appointment.ServiceID,
+		&appointment.AppointmentDate,
+		&appointment.AppointmentTime,
+		&appointment.Status,
+		&appointment.PaymentStatus,
+		&appointment.TotalAmount,
+		&appointment.PaidAmount,
+		&appointment.BalanceDue,
+		&appointment.Currency,
+		&appointment.DiscountAmount,
+		&appointment.PromoCode,
+		&appointment.CustomerPackageID,
+		&appointment.TaxRate,
+		&appointment.NetAmount,
+		&appointment.TaxAmount,
+		&appointment.Notes,
+		&appointment.Sequence,
+		&appointment.CreatedAt,
+		&appointment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	appointment.SetCurrency(appointment.Currency)
+	return appointment, nil
+}
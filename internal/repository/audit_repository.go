@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"encoding/json"
+)
+
+// AuditRepository only appends; the table rejects updates and deletes
+type AuditRepository interface {
+	Create(entry *models.AuditLogEntry) error
+	List(filter models.AuditLogFilter, limit, offset int) ([]*models.AuditLogEntry, int, error)
+	Each(filter models.AuditLogFilter, fn func(*models.AuditLogEntry) error) error
+}
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+const auditColumns = `id, COALESCE(tenant_id, 0), actor_type, actor_id, COALESCE(actor, ''), entity_type,
+	entity_id, action, changes, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at`
+
+func (r *auditRepository) Create(entry *models.AuditLogEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log (tenant_id, actor_type, actor_id, actor, entity_type, entity_id,
+			action, changes, ip_address, user_agent)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(
+		query,
+		entry.TenantID,
+		entry.ActorType,
+		entry.ActorID,
+		entry.Actor,
+		entry.EntityType,
+		entry.EntityID,
+		entry.Action,
+		string(changes),
+		entry.IPAddress,
+		entry.UserAgent,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// auditWhere takes the filter arguments of auditFilterArgs as $1 to $7
+const auditWhere = `
+	WHERE ($1 = '' OR actor_type = $1) AND ($2 = 0 OR actor_id = $2)
+		AND ($3 = '' OR entity_type = $3) AND ($4 = '' OR entity_id = $4) AND ($5 = '' OR action = $5)
+		AND ($6::timestamptz IS NULL OR created_at >= $6) AND ($7::timestamptz IS NULL OR created_at < $7)`
+
+func auditFilterArgs(filter models.AuditLogFilter) []interface{} {
+	return []interface{}{
+		filter.ActorType, filter.ActorID, filter.EntityType, filter.EntityID, filter.Action, filter.From, filter.To,
+	}
+}
+
+func (r *auditRepository) List(filter models.AuditLogFilter, limit, offset int) ([]*models.AuditLogEntry, int, error) {
+	args := auditFilterArgs(filter)
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_log`+auditWhere, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + auditColumns + `
+		FROM audit_log` + auditWhere + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $8 OFFSET $9`
+
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditLogEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, total, rows.Err()
+}
+
+// Each streams matching entries oldest first, for exports of any size
+func (r *auditRepository) Each(filter models.AuditLogFilter, fn func(*models.AuditLogEntry) error) error {
+	query := `SELECT ` + auditColumns + ` FROM audit_log` + auditWhere + ` ORDER BY created_at, id`
+
+	rows, err := r.db.Query(query, auditFilterArgs(filter)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func scanAuditEntry(row rowScanner) (*models.AuditLogEntry, error) {
+	entry := &models.AuditLogEntry{}
+	var actorID sql.NullInt64
+	var changes []byte
+	err := row.Scan(
+		&entry.ID, &entry.TenantID, &entry.ActorType, &actorID, &entry.Actor, &entry.EntityType,
+		&entry.EntityID, &entry.Action, &changes, &entry.IPAddress, &entry.UserAgent, &entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		entry.ActorID = &id
+	}
+	if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
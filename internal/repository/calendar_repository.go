@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type CalendarRepository interface {
+	GetFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error)
+	GetFeedTokenByHash(tokenHash string) (*models.CalendarFeedToken, error)
+	SaveFeedToken(feedToken *models.CalendarFeedToken) error
+}
+
+type calendarRepository struct {
+	db *sql.DB
+}
+
+func NewCalendarRepository(db *sql.DB) CalendarRepository {
+	return &calendarRepository{db: db}
+}
+
+func (r *calendarRepository) GetFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error) {
+	query := `
+		SELECT id, owner_type, owner_id, token_hash, created_at
+		FROM calendar_feed_tokens
+		WHERE owner_type = $1 AND owner_id = $2`
+
+	feedToken := &models.CalendarFeedToken{}
+	err := r.db.QueryRow(query, ownerType, ownerID).Scan(
+		&feedToken.ID, &feedToken.OwnerType, &feedToken.OwnerID,
+		&feedToken.TokenHash, &feedToken.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return feedToken, err
+}
+
+func (r *calendarRepository) GetFeedTokenByHash(tokenHash string) (*models.CalendarFeedToken, error) {
+	query := `
+		SELECT id, owner_type, owner_id, token_hash, created_at
+		FROM calendar_feed_tokens
+		WHERE token_hash = $1`
+
+	feedToken := &models.CalendarFeedToken{}
+	err := r.db.QueryRow(query, tokenHash).Scan(
+		&feedToken.ID, &feedToken.OwnerType, &feedToken.OwnerID,
+		&feedToken.TokenHash, &feedToken.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return feedToken, err
+}
+
+// SaveFeedToken creates the owner's token or replaces the existing one
+func (r *calendarRepository) SaveFeedToken(feedToken *models.CalendarFeedToken) error {
+	query := `
+		INSERT INTO calendar_feed_tokens (owner_type, owner_id, token_hash, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (owner_type, owner_id)
+		DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = EXCLUDED.created_at
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, feedToken.OwnerType, feedToken.OwnerID, feedToken.TokenHash).
+		Scan(&feedToken.ID, &feedToken.CreatedAt)
+}
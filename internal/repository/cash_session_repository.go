@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type CashSessionRepository interface {
+	Open(session *models.CashSession) error
+	GetByID(id int) (*models.CashSession, error)
+	GetOpen() (*models.CashSession, error)
+	List(status models.CashSessionStatus, limit, offset int) ([]*models.CashSession, int, error)
+	Close(session *models.CashSession) (bool, error)
+	Recount(session *models.CashSession) (bool, error)
+	Approve(session *models.CashSession) (bool, error)
+	Totals(session *models.CashSession) ([]*models.CashSessionLine, error)
+	GetLines(session *models.CashSession) ([]*models.CashSessionLine, error)
+}
+
+type cashSessionRepository struct {
+	db *sql.DB
+}
+
+func NewCashSessionRepository(db *sql.DB) CashSessionRepository {
+	return &cashSessionRepository{db: db}
+}
+
+const cashSessionColumns = `id, status, currency, opening_float, counted_cash, counted_credit_card, counted_transfer, notes,
+	COALESCE(opened_by, ''), opened_at, COALESCE(closed_by, ''), closed_at, COALESCE(approved_by, ''), approved_at`
+
+// Open starts the session unless another one is open, then it returns
+// sql.ErrNoRows
+func (r *cashSessionRepository) Open(session *models.CashSession) error {
+	query := `
+		INSERT INTO cash_sessions (status, currency, opening_float, notes, opened_by)
+		SELECT 'open', $1, $2, $3, NULLIF($4, '')
+		WHERE NOT EXISTS (SELECT 1 FROM cash_sessions WHERE status = 'open')
+		RETURNING id, status, opened_at`
+
+	return r.db.QueryRow(query,
+		session.Currency,
+		session.OpeningFloat,
+		session.Notes,
+		session.OpenedBy,
+	).Scan(&session.ID, &session.Status, &session.OpenedAt)
+}
+
+func (r *cashSessionRepository) GetByID(id int) (*models.CashSession, error) {
+	return scanCashSession(r.db.QueryRow(`SELECT `+cashSessionColumns+` FROM cash_sessions WHERE id = $1`, id))
+}
+
+func (r *cashSessionRepository) GetOpen() (*models.CashSession, error) {
+	return scanCashSession(r.db.QueryRow(`SELECT ` + cashSessionColumns + ` FROM cash_sessions WHERE status = 'open'`))
+}
+
+// List returns the sessions with status, all of them when it is empty
+func (r *cashSessionRepository) List(status models.CashSessionStatus, limit, offset int) ([]*models.CashSession, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM cash_sessions WHERE ($1 = '' OR status = $1)`, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + cashSessionColumns + ` FROM cash_sessions
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY opened_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(query, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.CashSession
+	for rows.Next() {
+		session, err := scanCashSession(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, total, rows.Err()
+}
+
+// Close records the counted amounts of an open session and saves its
+// Z-report lines as they stand at closing time. It reports false, changing
+// nothing, when the session is no longer open.
+func (r *cashSessionRepository) Close(session *models.CashSession) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE cash_sessions
+		SET status = 'closed', counted_cash = $2, counted_credit_card = $3, counted_transfer = $4, notes = $5,
+			closed_by = NULLIF($6, ''), closed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'open'
+		RETURNING status, closed_at`,
+		session.ID,
+		session.CountedCash,
+		session.CountedCreditCard,
+		session.CountedTransfer,
+		session.Notes,
+		session.ClosedBy,
+	).Scan(&session.Status, &session.ClosedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	lines, err := cashSessionTotals(tx, session)
+	if err != nil {
+		return false, err
+	}
+	for _, line := range lines {
+		_, err := tx.Exec(`
+			INSERT INTO cash_session_lines (cash_session_id, source, payment_method, device_id, count, sales, refunds)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			session.ID, line.Source, line.Method, line.DeviceID, line.Count, line.Sales, line.Refunds)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// Recount replaces the counted amounts of a closed session. It reports
+// false, changing nothing, when the session is not closed.
+func (r *cashSessionRepository) Recount(session *models.CashSession) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE cash_sessions
+		SET counted_cash = $2, counted_credit_card = $3, counted_transfer = $4, notes = $5, closed_by = NULLIF($6, '')
+		WHERE id = $1 AND status = 'closed'`,
+		session.ID,
+		session.CountedCash,
+		session.CountedCreditCard,
+		session.CountedTransfer,
+		session.Notes,
+		session.ClosedBy,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Approve locks a closed session. It reports false, changing nothing, when
+// the session is not closed.
+func (r *cashSessionRepository) Approve(session *models.CashSession) (bool, error) {
+	err := r.db.QueryRow(`
+		UPDATE cash_sessions SET status = 'approved', approved_by = NULLIF($2, ''), approved_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'closed'
+		RETURNING status, approved_at`,
+		session.ID, session.ApprovedBy,
+	).Scan(&session.Status, &session.ApprovedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Totals sums what was taken since the session opened, for the running
+// report of an open session
+func (r *cashSessionRepository) Totals(session *models.CashSession) ([]*models.CashSessionLine, error) {
+	return cashSessionTotals(r.db, session)
+}
+
+// cashSessionQuerier is what summing a session needs from *sql.DB or *sql.Tx
+type cashSessionQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// cashSessionTotals sums the payments, gift cards and packages sold in the
+// session's currency while it was open, and the refunds paid back in that
+// time other than to a wallet. An open session runs until now.
+func cashSessionTotals(db cashSessionQuerier, session *models.CashSession) ([]*models.CashSessionLine, error) {
+	query := `
+		WITH session_window AS (
+			SELECT opened_at AS start_at, COALESCE(closed_at, CURRENT_TIMESTAMP) AS end_at, currency
+			FROM cash_sessions WHERE id = $1
+		), taken AS (
+			SELECT 'payment' AS source, p.payment_method, p.device_id, 1 AS count, p.amount AS sales, 0 AS refunds
+			FROM payments p, session_window w
+			WHERE p.status IN ('completed', 'refunded') AND p.currency = w.currency
+				AND p.created_at >= w.start_at AND p.created_at < w.end_at
+			UNION ALL
+			SELECT 'payment', p.payment_method, p.device_id, 0, 0, r.amount
+			FROM refunds r
+			JOIN payments p ON p.id = r.payment_id
+			CROSS JOIN session_window w
+			WHERE r.status = 'completed' AND NOT r.to_wallet AND r.currency = w.currency
+				AND r.created_at >= w.start_at AND r.created_at < w.end_at
+			UNION ALL
+			SELECT 'gift_card', g.payment_method, NULL, 1, g.amount, 0
+			FROM gift_cards g, session_window w
+			WHERE g.currency = w.currency AND g.created_at >= w.start_at AND g.created_at < w.end_at
+			UNION ALL
+			SELECT 'package', cp.payment_method, NULL, 1, cp.price, 0
+			FROM customer_packages cp, session_window w
+			WHERE cp.price > 0 AND cp.currency = w.currency AND cp.created_at >= w.start_at AND cp.created_at < w.end_at
+		)
+		SELECT source, payment_method, device_id, SUM(count), SUM(sales), SUM(refunds)
+		FROM taken
+		WHERE payment_method IN ('cash', 'credit_card', 'transfer')
+		GROUP BY source, payment_method, device_id
+		ORDER BY source DESC, payment_method, device_id NULLS FIRST`
+
+	rows, err := db.Query(query, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCashSessionLines(rows, session.Currency)
+}
+
+// GetLines returns the Z-report lines saved when the session closed
+func (r *cashSessionRepository) GetLines(session *models.CashSession) ([]*models.CashSessionLine, error) {
+	query := `
+		SELECT source, payment_method, device_id, count, sales, refunds
+		FROM cash_session_lines
+		WHERE cash_session_id = $1
+		ORDER BY source DESC, payment_method, device_id NULLS FIRST`
+
+	rows, err := r.db.Query(query, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCashSessionLines(rows, session.Currency)
+}
+
+func scanCashSessionLines(rows *sql.Rows, currency string) ([]*models.CashSessionLine, error) {
+	defer rows.Close()
+
+	var lines []*models.CashSessionLine
+	for rows.Next() {
+		line := &models.CashSessionLine{}
+		if err := rows.Scan(&line.Source, &line.Method, &line.DeviceID, &line.Count, &line.Sales, &line.Refunds); err != nil {
+			return nil, err
+		}
+		line.Sales.Currency = currency
+		line.Refunds.Currency = currency
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+func scanCashSession(row rowScanner) (*models.CashSession, error) {
+	session := &models.CashSession{}
+	err := row.Scan(
+		&session.ID,
+		&session.Status,
+		&session.Currency,
+		&session.OpeningFloat,
+		&session.CountedCash,
+		&session.CountedCreditCard,
+		&session.CountedTransfer,
+		&session.Notes,
+		&session.OpenedBy,
+		&session.OpenedAt,
+		&session.ClosedBy,
+		&session.ClosedAt,
+		&session.ApprovedBy,
+		&session.ApprovedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.SetCurrency(session.Currency)
+	return session, nil
+}
@@ -25,12 +25,12 @@ func NewCategoryRepository(db *sql.DB) CategoryRepository {
 
 func (r *categoryRepository) Create(category *models.Category) error {
 	query := `
-		INSERT INTO categories (name, description, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO categories (name, description, tax_rate, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id`
 
 	now := time.Now()
-	err := r.db.QueryRow(query, category.Name, category.Description,
+	err := r.db.QueryRow(query, category.Name, category.Description, category.TaxRate,
 		category.Active, now, now).Scan(&category.ID)
 	if err != nil {
 		return err
@@ -43,12 +43,12 @@ func (r *categoryRepository) Create(category *models.Category) error {
 
 func (r *categoryRepository) GetByID(id int) (*models.Category, error) {
 	query := `
-		SELECT id, name, description, active, created_at, updated_at
+		SELECT id, name, description, tax_rate, active, created_at, updated_at
 		FROM categories WHERE id = $1`
 
 	category := &models.Category{}
 	err := r.db.QueryRow(query, id).Scan(
-		&category.ID, &category.Name, &category.Description,
+		&category.ID, &category.Name, &category.Description, &category.TaxRate,
 		&category.Active, &category.CreatedAt, &category.UpdatedAt,
 	)
 	return category, err
@@ -57,11 +57,11 @@ func (r *categoryRepository) GetByID(id int) (*models.Category, error) {
 func (r *categoryRepository) Update(category *models.Category) error {
 	query := `
 		UPDATE categories 
-		SET name = $1, description = $2, active = $3, updated_at = $4
-		WHERE id = $5`
+		SET name = $1, description = $2, tax_rate = $3, active = $4, updated_at = $5
+		WHERE id = $6`
 
 	category.UpdatedAt = time.Now()
-	_, err := r.db.Exec(query, category.Name, category.Description,
+	_, err := r.db.Exec(query, category.Name, category.Description, category.TaxRate,
 		category.Active, category.UpdatedAt, category.ID)
 	return err
 }
@@ -74,7 +74,7 @@ func (r *categoryRepository) Delete(id int) error {
 
 func (r *categoryRepository) List() ([]*models.Category, error) {
 	query := `
-		SELECT id, name, description, active, created_at, updated_at
+		SELECT id, name, description, tax_rate, active, created_at, updated_at
 		FROM categories
 		ORDER BY name ASC`
 
@@ -83,7 +83,7 @@ func (r *categoryRepository) List() ([]*models.Category, error) {
 
 func (r *categoryRepository) ListActive() ([]*models.Category, error) {
 	query := `
-		SELECT id, name, description, active, created_at, updated_at
+		SELECT id, name, description, tax_rate, active, created_at, updated_at
 		FROM categories
 		WHERE active = true
 		ORDER BY name ASC`
@@ -102,7 +102,7 @@ func (r *categoryRepository) queryCategories(query string) ([]*models.Category,
 	for rows.Next() {
 		category := &models.Category{}
 		err := rows.Scan(
-			&category.ID, &category.Name, &category.Description,
+			&category.ID, &category.Name, &category.Description, &category.TaxRate,
 			&category.Active, &category.CreatedAt, &category.UpdatedAt,
 		)
 		if err != nil {
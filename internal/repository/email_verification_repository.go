@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"time"
+)
+
+type EmailVerificationRepository interface {
+	Create(verification *models.EmailVerification) error
+	Consume(tokenHash string) (int, error)
+	InvalidateForUser(userID int) error
+	CountSince(userID int, since time.Time) (int, *time.Time, error)
+}
+
+type emailVerificationRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationRepository(db *sql.DB) EmailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+func (r *emailVerificationRepository) Create(verification *models.EmailVerification) error {
+	query := `
+		INSERT INTO email_verifications (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, verification.UserID, verification.TokenHash, verification.ExpiresAt).
+		Scan(&verification.ID, &verification.CreatedAt)
+}
+
+// Consume marks an unused, unexpired token as used and returns its user ID,
+// sql.ErrNoRows for unknown, used or expired tokens
+func (r *emailVerificationRepository) Consume(tokenHash string) (int, error) {
+	query := `
+		UPDATE email_verifications
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+
+	var userID int
+	err := r.db.QueryRow(query, tokenHash).Scan(&userID)
+	return userID, err
+}
+
+func (r *emailVerificationRepository) InvalidateForUser(userID int) error {
+	query := `UPDATE email_verifications SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
+
+// CountSince returns how many tokens were sent to the user since the given time
+// and when the last one was sent, for rate limiting resends
+func (r *emailVerificationRepository) CountSince(userID int, since time.Time) (int, *time.Time, error) {
+	query := `
+		SELECT COUNT(*), MAX(created_at)
+		FROM email_verifications
+		WHERE user_id = $1 AND created_at >= $2`
+
+	var count int
+	var last sql.NullTime
+	if err := r.db.QueryRow(query, userID, since).Scan(&count, &last); err != nil {
+		return 0, nil, err
+	}
+	if !last.Valid {
+		return count, nil, nil
+	}
+	return count, &last.Time, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"time"
+)
+
+type ExternalCalendarRepository interface {
+	Create(calendar *models.ExternalCalendar) error
+	GetByID(id int) (*models.ExternalCalendar, error)
+	ListBySpecialist(specialistID int) ([]*models.ExternalCalendar, error)
+	ListActive() ([]*models.ExternalCalendar, error)
+	Delete(id int) error
+	UpdateSyncResult(id int, syncedAt time.Time, syncError string) error
+	ReplaceBusyBlocks(calendarID int, blocks []*models.ExternalBusyBlock) error
+	GetBusyBlocks(specialistID int, from, to time.Time) ([]*models.ExternalBusyBlock, error)
+	HasBusyBlock(specialistID int, from, to time.Time) (bool, error)
+}
+
+type externalCalendarRepository struct {
+	db *sql.DB
+}
+
+func NewExternalCalendarRepository(db *sql.DB) ExternalCalendarRepository {
+	return &externalCalendarRepository{db: db}
+}
+
+const externalCalendarColumns = `
+	c.id, c.specialist_id, c.name, c.source_type, COALESCE(c.url, ''), COALESCE(c.ics_data, ''),
+	c.active, c.last_synced_at, COALESCE(c.last_error, ''), c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM external_busy_blocks b WHERE b.calendar_id = c.id)`
+
+func (r *externalCalendarRepository) Create(calendar *models.ExternalCalendar) error {
+	query := `
+		INSERT INTO external_calendars (specialist_id, name, source_type, url, ics_data, active)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(
+		query,
+		calendar.SpecialistID,
+		calendar.Name,
+		calendar.SourceType,
+		calendar.URL,
+		calendar.ICSData,
+		calendar.Active,
+	).Scan(&calendar.ID, &calendar.CreatedAt, &calendar.UpdatedAt)
+}
+
+func (r *externalCalendarRepository) GetByID(id int) (*models.ExternalCalendar, error) {
+	query := `SELECT ` + externalCalendarColumns + ` FROM external_calendars c WHERE c.id = $1`
+
+	calendar, err := scanExternalCalendar(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+func (r *externalCalendarRepository) ListBySpecialist(specialistID int) ([]*models.ExternalCalendar, error) {
+	query := `SELECT ` + externalCalendarColumns + `
+		FROM external_calendars c
+		WHERE c.specialist_id = $1
+		ORDER BY c.created_at`
+
+	return r.list(query, specialistID)
+}
+
+func (r *externalCalendarRepository) ListActive() ([]*models.ExternalCalendar, error) {
+	query := `SELECT ` + externalCalendarColumns + `
+		FROM external_calendars c
+		WHERE c.active = true
+		ORDER BY c.id`
+
+	return r.list(query)
+}
+
+func (r *externalCalendarRepository) list(query string, args ...interface{}) ([]*models.ExternalCalendar, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calendars []*models.ExternalCalendar
+	for rows.Next() {
+		calendar, err := scanExternalCalendar(rows)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, calendar)
+	}
+
+	return calendars, rows.Err()
+}
+
+// Busy blocks are removed by ON DELETE CASCADE
+func (r *externalCalendarRepository) Delete(id int) error {
+	query := `DELETE FROM external_calendars WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *externalCalendarRepository) UpdateSyncResult(id int, syncedAt time.Time, syncError string) error {
+	query := `
+		UPDATE external_calendars
+		SET last_synced_at = $2, last_error = NULLIF($3, ''), updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.db.Exec(query, id, syncedAt, syncError)
+	return err
+}
+
+// ReplaceBusyBlocks swaps all stored blocks of a calendar in one transaction
+func (r *externalCalendarRepository) ReplaceBusyBlocks(calendarID int, blocks []*models.ExternalBusyBlock) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM external_busy_blocks WHERE calendar_id = $1`, calendarID); err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO external_busy_blocks (calendar_id, specialist_id, uid, summary, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	for _, block := range blocks {
+		if _, err := tx.Exec(insertQuery, calendarID, block.SpecialistID, block.UID, block.Summary, block.StartsAt, block.EndsAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBusyBlocks returns blocks of active calendars overlapping [from, to)
+func (r *externalCalendarRepository) GetBusyBlocks(specialistID int, from, to time.Time) ([]*models.ExternalBusyBlock, error) {
+	query := `
+		SELECT b.id, b.calendar_id, b.specialist_id, b.uid, COALESCE(b.summary, ''), b.starts_at, b.ends_at
+		FROM external_busy_blocks b
+		JOIN external_calendars c ON c.id = b.calendar_id
+		WHERE b.specialist_id = $1 AND c.active = true
+		AND b.starts_at < $3 AND b.ends_at > $2
+		ORDER BY b.starts_at`
+
+	rows, err := r.db.Query(query, specialistID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []*models.ExternalBusyBlock
+	for rows.Next() {
+		block := &models.ExternalBusyBlock{}
+		err := rows.Scan(
+			&block.ID, &block.CalendarID, &block.SpecialistID, &block.UID,
+			&block.Summary, &block.StartsAt, &block.EndsAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
+}
+
+func (r *externalCalendarRepository) HasBusyBlock(specialistID int, from, to time.Time) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM external_busy_blocks b
+			JOIN external_calendars c ON c.id = b.calendar_id
+			WHERE b.specialist_id = $1 AND c.active = true
+			AND b.starts_at < $3 AND b.ends_at > $2
+		)`
+
+	var exists bool
+	err := r.db.QueryRow(query, specialistID, from, to).Scan(&exists)
+	return exists, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanExternalCalendar(row rowScanner) (*models.ExternalCalendar, error) {
+	calendar := &models.ExternalCalendar{}
+	var lastSyncedAt sql.NullTime
+	err := row.Scan(
+		&calendar.ID, &calendar.SpecialistID, &calendar.Name, &calendar.SourceType,
+		&calendar.URL, &calendar.ICSData, &calendar.Active, &lastSyncedAt,
+		&calendar.LastError, &calendar.CreatedAt, &calendar.UpdatedAt, &calendar.BlockCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if lastSyncedAt.Valid {
+		calendar.LastSyncedAt = &lastSyncedAt.Time
+	}
+	return calendar, nil
+}
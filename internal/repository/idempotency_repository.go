@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	Reserve(record *models.IdempotencyKey, expiredBefore, staleBefore time.Time) (bool, error)
+	Get(actorType string, actorID int, key string) (*models.IdempotencyKey, error)
+	Complete(record *models.IdempotencyKey) error
+	Release(record *models.IdempotencyKey) error
+}
+
+type idempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Reserve claims the key for a new request after dropping the keys that
+// expired before expiredBefore. A key whose request started before
+// staleBefore and never completed is taken over, its request is taken to have
+// died. It reports false when the key is held by another request or has a
+// stored response.
+func (r *idempotencyRepository) Reserve(record *models.IdempotencyKey, expiredBefore, staleBefore time.Time) (bool, error) {
+	if _, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < $1`, expiredBefore); err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (key, actor_type, actor_id, request_hash)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (actor_type, actor_id, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, created_at = CURRENT_TIMESTAMP
+		WHERE idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $5
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query,
+		record.Key,
+		record.ActorType,
+		record.ActorID,
+		record.RequestHash,
+		staleBefore,
+	).Scan(&record.ID, &record.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *idempotencyRepository) Get(actorType string, actorID int, key string) (*models.IdempotencyKey, error) {
+	query := `
+		SELECT id, key, actor_type, actor_id, request_hash, COALESCE(status_code, 0), COALESCE(content_type, ''),
+			response_headers, response_body, created_at, completed_at
+		FROM idempotency_keys
+		WHERE actor_type = $1 AND actor_id = $2 AND key = $3`
+
+	record := &models.IdempotencyKey{}
+	var headers []byte
+	err := r.db.QueryRow(query, actorType, actorID, key).Scan(
+		&record.ID,
+		&record.Key,
+		&record.ActorType,
+		&record.ActorID,
+		&record.RequestHash,
+		&record.StatusCode,
+		&record.ContentType,
+		&headers,
+		&record.ResponseBody,
+		&record.CreatedAt,
+		&record.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if headers != nil {
+		if err := json.Unmarshal(headers, &record.Headers); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// Complete stores the response of the request holding the key. It returns
+// sql.ErrNoRows when the key was taken over by a retry in the meantime.
+func (r *idempotencyRepository) Complete(record *models.IdempotencyKey) error {
+	headers, err := json.Marshal(record.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_headers = $5, response_body = $6, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND created_at = $2 AND status_code IS NULL
+		RETURNING completed_at`
+
+	return r.db.QueryRow(query,
+		record.ID,
+		record.CreatedAt,
+		record.StatusCode,
+		record.ContentType,
+		headers,
+		record.ResponseBody,
+	).Scan(&record.CompletedAt)
+}
+
+// Release frees the key of a request that failed, so it can be retried. A key
+// taken over by a retry is left to it.
+func (r *idempotencyRepository) Release(record *models.IdempotencyKey) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE id = $1 AND created_at = $2 AND status_code IS NULL`, record.ID, record.CreatedAt)
+	return err
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// InvoiceRepository only adds invoices; issued invoices are never changed
+type InvoiceRepository interface {
+	Create(invoice *models.Invoice, prefix string) error
+	GetByID(id int) (*models.Invoice, error)
+	GetByPaymentID(paymentID int) (*models.Invoice, error)
+	GetByRefundID(refundID int) (*models.Invoice, error)
+	GetByCustomerPackageID(customerPackageID int) (*models.Invoice, error)
+	CreditedTotal(invoiceID int) (models.Money, error)
+	ListByUserID(userID int, limit, offset int) ([]*models.Invoice, int, error)
+	List(filter models.InvoiceFilter, limit, offset int) ([]*models.Invoice, int, error)
+}
+
+type invoiceRepository struct {
+	db *sql.DB
+}
+
+func NewInvoiceRepository(db *sql.DB) InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+const invoiceColumns = `id, number, uuid, type, year, sequence, COALESCE(payment_id, 0), refund_id, original_invoice_id,
+	COALESCE(appointment_id, 0), customer_package_id, COALESCE(user_id, 0), currency, total, seller, buyer, issued_at`
+
+// Create numbers and stores the invoice with its items. The sequence row of
+// the prefix and year stays locked until the transaction ends, so concurrent
+// invoices wait for each other and a failed insert gives its number back.
+func (r *invoiceRepository) Create(invoice *models.Invoice, prefix string) error {
+	seller, err := json.Marshal(invoice.Seller)
+	if err != nil {
+		return err
+	}
+	buyer, err := json.Marshal(invoice.Buyer)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO invoice_sequences (prefix, year, last_number) VALUES ($1, $2, 1)
+		ON CONFLICT (prefix, year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number`, prefix, invoice.Year).Scan(&invoice.Sequence)
+	if err != nil {
+		return err
+	}
+	invoice.Number = fmt.Sprintf("%s%d%09d", prefix, invoice.Year, invoice.Sequence)
+
+	query := `
+		INSERT INTO invoices (number, uuid, type, year, sequence, payment_id, refund_id, original_invoice_id,
+			appointment_id, customer_package_id, user_id, currency, total, seller, buyer, issued_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7, $8, NULLIF($9, 0), $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id`
+
+	err = tx.QueryRow(query,
+		invoice.Number,
+		invoice.UUID,
+		invoice.Type,
+		invoice.Year,
+		invoice.Sequence,
+		invoice.PaymentID,
+		invoice.RefundID,
+		invoice.OriginalInvoiceID,
+		invoice.AppointmentID,
+		invoice.CustomerPackageID,
+		invoice.UserID,
+		invoice.Currency,
+		invoice.Total,
+		string(seller),
+		string(buyer),
+		invoice.IssuedAt,
+	).Scan(&invoice.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range invoice.Items {
+		item := &invoice.Items[i]
+		item.InvoiceID = invoice.ID
+		err := tx.QueryRow(`
+			INSERT INTO invoice_items (invoice_id, description, quantity, unit_price, total, tax_rate)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`,
+			item.InvoiceID, item.Description, item.Quantity, item.UnitPrice, item.Total, item.TaxRate,
+		).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *invoiceRepository) GetByID(id int) (*models.Invoice, error) {
+	return r.getWithItems(`SELECT `+invoiceColumns+` FROM invoices WHERE id = $1`, id)
+}
+
+// GetByPaymentID returns the invoice of a payment, not its credit notes
+func (r *invoiceRepository) GetByPaymentID(paymentID int) (*models.Invoice, error) {
+	return r.getWithItems(`SELECT `+invoiceColumns+` FROM invoices WHERE payment_id = $1 AND type = 'invoice'`, paymentID)
+}
+
+func (r *invoiceRepository) GetByRefundID(refundID int) (*models.Invoice, error) {
+	return r.getWithItems(`SELECT `+invoiceColumns+` FROM invoices WHERE refund_id = $1`, refundID)
+}
+
+func (r *invoiceRepository) GetByCustomerPackageID(customerPackageID int) (*models.Invoice, error) {
+	return r.getWithItems(`SELECT `+invoiceColumns+` FROM invoices WHERE customer_package_id = $1`, customerPackageID)
+}
+
+func (r *invoiceRepository) getWithItems(query string, arg interface{}) (*models.Invoice, error) {
+	invoice, err := scanInvoice(r.db.QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, invoice_id, description, quantity, unit_price, total, tax_rate
+		FROM invoice_items
+		WHERE invoice_id = $1
+		ORDER BY id`, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.InvoiceItem
+		if err := rows.Scan(&item.ID, &item.InvoiceID, &item.Description, &item.Quantity, &item.UnitPrice, &item.Total, &item.TaxRate); err != nil {
+			return nil, err
+		}
+		invoice.Items = append(invoice.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	invoice.SetCurrency(invoice.Currency)
+	return invoice, nil
+}
+
+// CreditedTotal sums the credit notes issued against an invoice
+func (r *invoiceRepository) CreditedTotal(invoiceID int) (models.Money, error) {
+	var total models.Money
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(total), 0) FROM invoices
+		WHERE original_invoice_id = $1 AND type = 'credit_note'`, invoiceID).Scan(&total)
+	return total, err
+}
+
+func (r *invoiceRepository) ListByUserID(userID int, limit, offset int) ([]*models.Invoice, int, error) {
+	return r.List(models.InvoiceFilter{UserID: userID}, limit, offset)
+}
+
+// invoiceWhere takes the filter arguments of invoiceFilterArgs as $1 to $4
+const invoiceWhere = `
+	WHERE ($1 = '' OR type = $1) AND ($2 = 0 OR user_id = $2)
+		AND ($3::timestamptz IS NULL OR issued_at >= $3) AND ($4::timestamptz IS NULL OR issued_at < $4)`
+
+func invoiceFilterArgs(filter models.InvoiceFilter) []interface{} {
+	return []interface{}{string(filter.Type), filter.UserID, filter.From, filter.To}
+}
+
+func (r *invoiceRepository) List(filter models.InvoiceFilter, limit, offset int) ([]*models.Invoice, int, error) {
+	args := invoiceFilterArgs(filter)
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM invoices`+invoiceWhere, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + invoiceColumns + `
+		FROM invoices` + invoiceWhere + `
+		ORDER BY issued_at DESC, id DESC
+		LIMIT $5 OFFSET $6`
+
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var invoices []*models.Invoice
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		invoice.SetCurrency(invoice.Currency)
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices, total, rows.Err()
+}
+
+func scanInvoice(row rowScanner) (*models.Invoice, error) {
+	invoice := &models.Invoice{}
+	var seller, buyer []byte
+	err := row.Scan(
+		&invoice.ID,
+		&invoice.Number,
+		&invoice.UUID,
+		&invoice.Type,
+		&invoice.Year,
+		&invoice.Sequence,
+		&invoice.PaymentID,
+		&invoice.RefundID,
+		&invoice.OriginalInvoiceID,
+		&invoice.AppointmentID,
+		&invoice.CustomerPackageID,
+		&invoice.UserID,
+		&invoice.Currency,
+		&invoice.Total,
+		&seller,
+		&buyer,
+		&invoice.IssuedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(seller, &invoice.Seller); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buyer, &invoice.Buyer); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
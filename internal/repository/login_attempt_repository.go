@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptRepository interface {
+	Create(attempt *models.LoginAttempt) error
+	AccountFailures(email string, since time.Time) (int, *time.Time, error)
+	IPFailures(ipAddress string, since time.Time) (int, *time.Time, error)
+	List(filter models.LoginAttemptFilter, limit, offset int) ([]*models.LoginAttempt, int, error)
+}
+
+type loginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Create(attempt *models.LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (email, user_id, ip_address, user_agent, event)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(
+		query,
+		attempt.Email,
+		attempt.UserID,
+		attempt.IPAddress,
+		attempt.UserAgent,
+		attempt.Event,
+	).Scan(&attempt.ID, &attempt.CreatedAt)
+}
+
+// AccountFailures counts failed logins for the email since the given time and
+// returns the latest one. A successful login or an admin unlock resets the count.
+func (r *loginAttemptRepository) AccountFailures(email string, since time.Time) (int, *time.Time, error) {
+	query := `
+		SELECT COUNT(*), MAX(created_at)
+		FROM login_attempts
+		WHERE email = $1 AND event = 'failed' AND created_at > $2
+			AND created_at > COALESCE((
+				SELECT MAX(created_at) FROM login_attempts
+				WHERE email = $1 AND event IN ('succeeded', 'unlocked')
+			), '-infinity')`
+
+	return r.failures(query, email, since)
+}
+
+// IPFailures counts failed logins from the address since the given time,
+// across all accounts
+func (r *loginAttemptRepository) IPFailures(ipAddress string, since time.Time) (int, *time.Time, error) {
+	query := `
+		SELECT COUNT(*), MAX(created_at)
+		FROM login_attempts
+		WHERE ip_address = $1 AND event = 'failed' AND created_at > $2`
+
+	return r.failures(query, ipAddress, since)
+}
+
+func (r *loginAttemptRepository) failures(query, key string, since time.Time) (int, *time.Time, error) {
+	var count int
+	var last sql.NullTime
+	if err := r.db.QueryRow(query, key, since).Scan(&count, &last); err != nil {
+		return 0, nil, err
+	}
+	if !last.Valid {
+		return count, nil, nil
+	}
+	return count, &last.Time, nil
+}
+
+// List filters by email, IP and event, zero values match everything
+func (r *loginAttemptRepository) List(filter models.LoginAttemptFilter, limit, offset int) ([]*models.LoginAttempt, int, error) {
+	where := `
+		WHERE ($1 = '' OR email = $1) AND ($2 = '' OR ip_address = $2) AND ($3 = '' OR event = $3)`
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM login_attempts` + where
+	if err := r.db.QueryRow(countQuery, filter.Email, filter.IPAddress, filter.Event).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, email, user_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''), event, created_at
+		FROM login_attempts` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5`
+
+	rows, err := r.db.Query(query, filter.Email, filter.IPAddress, filter.Event, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var attempts []*models.LoginAttempt
+	for rows.Next() {
+		attempt := &models.LoginAttempt{}
+		var userID sql.NullInt64
+		err := rows.Scan(
+			&attempt.ID, &attempt.Email, &userID, &attempt.IPAddress,
+			&attempt.UserAgent, &attempt.Event, &attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			attempt.UserID = &id
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, total, rows.Err()
+}
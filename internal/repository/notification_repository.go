@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"time"
+)
+
+type NotificationRepository interface {
+	GetTemplate(event models.NotificationEvent, channel models.NotificationChannelType) (*models.NotificationTemplate, error)
+	ListTemplates() ([]*models.NotificationTemplate, error)
+	SaveTemplate(template *models.NotificationTemplate) error
+	DeleteTemplate(event models.NotificationEvent, channel models.NotificationChannelType) error
+	Enqueue(item *models.NotificationOutboxItem) error
+	ClaimDue(limit int, lease time.Duration) ([]*models.NotificationOutboxItem, error)
+	MarkSent(id int) error
+	MarkFailed(id int, lastError string, nextAttemptAt *time.Time) error
+	Requeue(id int) error
+	ListOutbox(status models.NotificationStatus, limit, offset int) ([]*models.NotificationOutboxItem, int, error)
+}
+
+type notificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// GetTemplate returns nil when the tenant has not customized the template
+func (r *notificationRepository) GetTemplate(event models.NotificationEvent, channel models.NotificationChannelType) (*models.NotificationTemplate, error) {
+	query := `
+		SELECT id, event, channel, COALESCE(subject, ''), body, active, created_at, updated_at
+		FROM notification_templates
+		WHERE event = $1 AND channel = $2`
+
+	template := &models.NotificationTemplate{}
+	err := r.db.QueryRow(query, event, channel).Scan(
+		&template.ID, &template.Event, &template.Channel, &template.Subject,
+		&template.Body, &template.Active, &template.CreatedAt, &template.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (r *notificationRepository) ListTemplates() ([]*models.NotificationTemplate, error) {
+	query := `
+		SELECT id, event, channel, COALESCE(subject, ''), body, active, created_at, updated_at
+		FROM notification_templates
+		ORDER BY event, channel`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*models.NotificationTemplate
+	for rows.Next() {
+		template := &models.NotificationTemplate{}
+		err := rows.Scan(
+			&template.ID, &template.Event, &template.Channel, &template.Subject,
+			&template.Body, &template.Active, &template.CreatedAt, &template.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, rows.Err()
+}
+
+func (r *notificationRepository) SaveTemplate(template *models.NotificationTemplate) error {
+	query := `
+		INSERT INTO notification_templates (event, channel, subject, body, active)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (event, channel)
+		DO UPDATE SET subject = EXCLUDED.subject, body = EXCLUDED.body,
+			active = EXCLUDED.active, updated_at = NOW()
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(query, template.Event, template.Channel, template.Subject, template.Body, template.Active).
+		Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+}
+
+func (r *notificationRepository) DeleteTemplate(event models.NotificationEvent, channel models.NotificationChannelType) error {
+	query := `DELETE FROM notification_templates WHERE event = $1 AND channel = $2`
+	_, err := r.db.Exec(query, event, channel)
+	return err
+}
+
+func (r *notificationRepository) Enqueue(item *models.NotificationOutboxItem) error {
+	query := `
+		INSERT INTO notification_outbox (event, channel, recipient, subject, body, reference, max_attempts)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, status, attempts, next_attempt_at, created_at`
+
+	return r.db.QueryRow(
+		query,
+		item.Event,
+		item.Channel,
+		item.Recipient,
+		item.Subject,
+		item.Body,
+		item.Reference,
+		item.MaxAttempts,
+	).Scan(&item.ID, &item.Status, &item.Attempts, &item.NextAttemptAt, &item.CreatedAt)
+}
+
+// ClaimDue leases due pending messages: the attempt is counted and the next
+// attempt is pushed out by the lease, so a crashed dispatcher's messages are
+// picked up again and concurrent dispatchers never claim the same row.
+func (r *notificationRepository) ClaimDue(limit int, lease time.Duration) ([]*models.NotificationOutboxItem, error) {
+	query := `
+		UPDATE notification_outbox
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + notificationOutboxColumns
+
+	rows, err := r.db.Query(query, limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotificationOutboxItems(rows)
+}
+
+func (r *notificationRepository) MarkSent(id int) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'sent', sent_at = NOW(), last_error = NULL
+		WHERE id = $1`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// MarkFailed schedules a retry, or fails the message for good when nextAttemptAt is nil
+func (r *notificationRepository) MarkFailed(id int, lastError string, nextAttemptAt *time.Time) error {
+	if nextAttemptAt == nil {
+		query := `UPDATE notification_outbox SET status = 'failed', last_error = $2 WHERE id = $1`
+		_, err := r.db.Exec(query, id, lastError)
+		return err
+	}
+
+	query := `UPDATE notification_outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1`
+	_, err := r.db.Exec(query, id, lastError, *nextAttemptAt)
+	return err
+}
+
+// Requeue gives a message a fresh set of attempts
+func (r *notificationRepository) Requeue(id int) error {
+	query := `
+		UPDATE notification_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *notificationRepository) ListOutbox(status models.NotificationStatus, limit, offset int) ([]*models.NotificationOutboxItem, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM notification_outbox WHERE ($1 = '' OR status = $1)`
+	if err := r.db.QueryRow(countQuery, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + notificationOutboxColumns + `
+		FROM notification_outbox
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items, err := scanNotificationOutboxItems(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+const notificationOutboxColumns = `id, event, channel, recipient, COALESCE(subject, ''), body,
+	COALESCE(reference, ''), status, attempts, max_attempts, next_attempt_at,
+	COALESCE(last_error, ''), sent_at, created_at`
+
+func scanNotificationOutboxItems(rows *sql.Rows) ([]*models.NotificationOutboxItem, error) {
+	var items []*models.NotificationOutboxItem
+	for rows.Next() {
+		item := &models.NotificationOutboxItem{}
+		var sentAt sql.NullTime
+		err := rows.Scan(
+			&item.ID, &item.Event, &item.Channel, &item.Recipient, &item.Subject, &item.Body,
+			&item.Reference, &item.Status, &item.Attempts, &item.MaxAttempts, &item.NextAttemptAt,
+			&item.LastError, &sentAt, &item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if sentAt.Valid {
+			item.SentAt = &sentAt.Time
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
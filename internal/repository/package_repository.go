@@ -0,0 +1,462 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"time"
+)
+
+type PackageRepository interface {
+	Create(pkg *models.Package) error
+	GetByID(id int) (*models.Package, error)
+	Update(pkg *models.Package) error
+	Delete(id int) error
+	List(activeOnly bool) ([]*models.Package, error)
+	CountSold(packageID int) (int, error)
+
+	CreateCustomerPackage(customerPackage *models.CustomerPackage) error
+	GetCustomerPackage(id int) (*models.CustomerPackage, error)
+	ListCustomerPackages(userID int) ([]*models.CustomerPackage, error)
+	ListSessions(customerPackageID int) ([]*models.PackageSession, error)
+	ConsumeSession(customerPackageID, appointmentID int, at time.Time) (bool, error)
+	RestoreSession(appointmentID int) (bool, error)
+	Adjust(session *models.PackageSession, expiresAt *time.Time) (bool, error)
+	GetSalesByTaxRate(startDate, endDate time.Time, currency string) ([]*models.SalesByTaxRate, error)
+}
+
+type packageRepository struct {
+	db *sql.DB
+}
+
+func NewPackageRepository(db *sql.DB) PackageRepository {
+	return &packageRepository{db: db}
+}
+
+const packageColumns = `id, name, description, sessions, validity_days, price, currency, service_ids, category_ids,
+	active, created_at, updated_at`
+
+func (r *packageRepository) Create(pkg *models.Package) error {
+	serviceIDs, categoryIDs, err := marshalServiceTargets(pkg.ServiceIDs, pkg.CategoryIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO packages (name, description, sessions, validity_days, price, currency, service_ids, category_ids, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(query,
+		pkg.Name,
+		pkg.Description,
+		pkg.Sessions,
+		pkg.ValidityDays,
+		pkg.Price,
+		pkg.Currency,
+		serviceIDs,
+		categoryIDs,
+		pkg.Active,
+	).Scan(&pkg.ID, &pkg.CreatedAt, &pkg.UpdatedAt)
+}
+
+func (r *packageRepository) GetByID(id int) (*models.Package, error) {
+	return scanPackage(r.db.QueryRow(`SELECT `+packageColumns+` FROM packages WHERE id = $1`, id))
+}
+
+func (r *packageRepository) Update(pkg *models.Package) error {
+	serviceIDs, categoryIDs, err := marshalServiceTargets(pkg.ServiceIDs, pkg.CategoryIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE packages
+		SET name = $2, description = $3, sessions = $4, validity_days = $5, price = $6, currency = $7,
+			service_ids = $8, category_ids = $9, active = $10, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	return r.db.QueryRow(query,
+		pkg.ID,
+		pkg.Name,
+		pkg.Description,
+		pkg.Sessions,
+		pkg.ValidityDays,
+		pkg.Price,
+		pkg.Currency,
+		serviceIDs,
+		categoryIDs,
+		pkg.Active,
+	).Scan(&pkg.UpdatedAt)
+}
+
+func (r *packageRepository) Delete(id int) error {
+	_, err := r.db.Exec(`DELETE FROM packages WHERE id = $1`, id)
+	return err
+}
+
+func (r *packageRepository) List(activeOnly bool) ([]*models.Package, error) {
+	query := `SELECT ` + packageColumns + ` FROM packages WHERE active OR NOT $1 ORDER BY name, id`
+
+	rows, err := r.db.Query(query, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var packages []*models.Package
+	for rows.Next() {
+		pkg, err := scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+
+	return packages, rows.Err()
+}
+
+func (r *packageRepository) CountSold(packageID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM customer_packages WHERE package_id = $1`, packageID).Scan(&count)
+	return count, err
+}
+
+const customerPackageColumns = `id, package_id, user_id, name, sessions_total, sessions_used, service_ids, category_ids,
+	price, tax_rate, net_amount, tax_amount, currency, payment_method, expires_at, created_at, updated_at`
+
+func (r *packageRepository) CreateCustomerPackage(customerPackage *models.CustomerPackage) error {
+	serviceIDs, categoryIDs, err := marshalServiceTargets(customerPackage.ServiceIDs, customerPackage.CategoryIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO customer_packages (package_id, user_id, name, sessions_total, service_ids, category_ids,
+			price, tax_rate, net_amount, tax_amount, currency, payment_method, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(query,
+		customerPackage.PackageID,
+		customerPackage.UserID,
+		customerPackage.Name,
+		customerPackage.SessionsTotal,
+		serviceIDs,
+		categoryIDs,
+		customerPackage.Price,
+		customerPackage.TaxRate,
+		customerPackage.NetAmount,
+		customerPackage.TaxAmount,
+		customerPackage.Currency,
+		customerPackage.PaymentMethod,
+		customerPackage.ExpiresAt,
+	).Scan(&customerPackage.ID, &customerPackage.CreatedAt, &customerPackage.UpdatedAt)
+}
+
+func (r *packageRepository) GetCustomerPackage(id int) (*models.CustomerPackage, error) {
+	return scanCustomerPackage(r.db.QueryRow(`SELECT `+customerPackageColumns+` FROM customer_packages WHERE id = $1`, id))
+}
+
+// ListCustomerPackages returns the user's packages, those expiring first on top
+func (r *packageRepository) ListCustomerPackages(userID int) ([]*models.CustomerPackage, error) {
+	query := `
+		SELECT ` + customerPackageColumns + `
+		FROM customer_packages
+		WHERE user_id = $1
+		ORDER BY expires_at NULLS LAST, id`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customerPackages []*models.CustomerPackage
+	for rows.Next() {
+		customerPackage, err := scanCustomerPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		customerPackages = append(customerPackages, customerPackage)
+	}
+
+	return customerPackages, rows.Err()
+}
+
+func (r *packageRepository) ListSessions(customerPackageID int) ([]*models.PackageSession, error) {
+	query := `
+		SELECT id, customer_package_id, appointment_id, kind, change, reason, actor_type, actor_id,
+			COALESCE(actor, ''), created_at
+		FROM package_sessions
+		WHERE customer_package_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.Query(query, customerPackageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.PackageSession
+	for rows.Next() {
+		session := &models.PackageSession{}
+		err := rows.Scan(
+			&session.ID,
+			&session.CustomerPackageID,
+			&session.AppointmentID,
+			&session.Kind,
+			&session.Change,
+			&session.Reason,
+			&session.ActorType,
+			&session.ActorID,
+			&session.Actor,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// ConsumeSession uses a session of the package for the appointment. It
+// reports false, without using anything, when the package has no sessions
+// left or expires before at.
+func (r *packageRepository) ConsumeSession(customerPackageID, appointmentID int, at time.Time) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE customer_packages
+		SET sessions_used = sessions_used + 1, updated_at = NOW()
+		WHERE id = $1 AND (sessions_total IS NULL OR sessions_used < sessions_total)
+			AND (expires_at IS NULL OR expires_at > $2)`,
+		customerPackageID, at)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := insertPackageSession(tx, &models.PackageSession{
+		CustomerPackageID: customerPackageID,
+		AppointmentID:     &appointmentID,
+		Kind:              models.PackageSessionConsumed,
+		Change:            -1,
+		ActorType:         "system",
+	}); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// RestoreSession gives back the session a cancelled appointment used. It
+// reports false when the appointment used none or got it back already.
+func (r *packageRepository) RestoreSession(appointmentID int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var customerPackageID int
+	err = tx.QueryRow(`
+		SELECT customer_package_id FROM package_sessions
+		WHERE appointment_id = $1 AND kind = 'consumed'
+		ORDER BY id DESC LIMIT 1`, appointmentID).Scan(&customerPackageID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Lock the balance so concurrent cancellations restore the session once
+	if _, err := tx.Exec(`SELECT id FROM customer_packages WHERE id = $1 FOR UPDATE`, customerPackageID); err != nil {
+		return false, err
+	}
+
+	var used int
+	err = tx.QueryRow(`
+		SELECT COALESCE(-SUM(change), 0) FROM package_sessions
+		WHERE appointment_id = $1 AND customer_package_id = $2 AND kind IN ('consumed', 'restored')`,
+		appointmentID, customerPackageID).Scan(&used)
+	if err != nil {
+		return false, err
+	}
+	if used <= 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE customer_packages SET sessions_used = sessions_used - 1, updated_at = NOW()
+		WHERE id = $1`, customerPackageID); err != nil {
+		return false, err
+	}
+
+	if err := insertPackageSession(tx, &models.PackageSession{
+		CustomerPackageID: customerPackageID,
+		AppointmentID:     &appointmentID,
+		Kind:              models.PackageSessionRestored,
+		Change:            1,
+		ActorType:         "system",
+	}); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Adjust adds session.Change sessions to the package and moves its expiry when
+// expiresAt is set. It reports false, without changing anything, when the
+// balance would drop below the sessions already used or the package is a
+// membership, which has no session count to change.
+func (r *packageRepository) Adjust(session *models.PackageSession, expiresAt *time.Time) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE customer_packages
+		SET sessions_total = sessions_total + $2, expires_at = COALESCE($3, expires_at), updated_at = NOW()
+		WHERE id = $1 AND ($2 = 0 OR sessions_total IS NOT NULL AND sessions_total + $2 >= sessions_used)`,
+		session.CustomerPackageID, session.Change, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	session.Kind = models.PackageSessionAdjusted
+	if err := insertPackageSession(tx, session); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetSalesByTaxRate sums the packages sold in one currency per VAT rate
+func (r *packageRepository) GetSalesByTaxRate(startDate, endDate time.Time, currency string) ([]*models.SalesByTaxRate, error) {
+	query := `
+		SELECT tax_rate, COUNT(*), COALESCE(SUM(price), 0), COALESCE(SUM(tax_amount), 0)
+		FROM customer_packages
+		WHERE currency = $1 AND created_at BETWEEN $2 AND $3
+		GROUP BY tax_rate
+		ORDER BY tax_rate`
+
+	rows, err := r.db.Query(query, currency, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []*models.SalesByTaxRate
+	for rows.Next() {
+		total := &models.SalesByTaxRate{Currency: currency}
+		if err := rows.Scan(&total.TaxRate, &total.Payments, &total.Gross, &total.Tax); err != nil {
+			return nil, err
+		}
+		total.Gross.Currency = currency
+		total.Tax.Currency = currency
+		total.Net = total.Gross.Sub(total.Tax)
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
+func insertPackageSession(tx *sql.Tx, session *models.PackageSession) error {
+	query := `
+		INSERT INTO package_sessions (customer_package_id, appointment_id, kind, change, reason, actor_type, actor_id, actor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	return tx.QueryRow(query,
+		session.CustomerPackageID,
+		session.AppointmentID,
+		session.Kind,
+		session.Change,
+		session.Reason,
+		session.ActorType,
+		session.ActorID,
+		session.Actor,
+	).Scan(&session.ID, &session.CreatedAt)
+}
+
+func scanPackage(row rowScanner) (*models.Package, error) {
+	pkg := &models.Package{}
+	var serviceIDs, categoryIDs []byte
+	err := row.Scan(
+		&pkg.ID,
+		&pkg.Name,
+		&pkg.Description,
+		&pkg.Sessions,
+		&pkg.ValidityDays,
+		&pkg.Price,
+		&pkg.Currency,
+		&serviceIDs,
+		&categoryIDs,
+		&pkg.Active,
+		&pkg.CreatedAt,
+		&pkg.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalServiceTargets(serviceIDs, categoryIDs, &pkg.ServiceIDs, &pkg.CategoryIDs); err != nil {
+		return nil, err
+	}
+	pkg.Price.Currency = pkg.Currency
+	return pkg, nil
+}
+
+func scanCustomerPackage(row rowScanner) (*models.CustomerPackage, error) {
+	customerPackage := &models.CustomerPackage{}
+	var serviceIDs, categoryIDs []byte
+	err := row.Scan(
+		&customerPackage.ID,
+		&customerPackage.PackageID,
+		&customerPackage.UserID,
+		&customerPackage.Name,
+		&customerPackage.SessionsTotal,
+		&customerPackage.SessionsUsed,
+		&serviceIDs,
+		&categoryIDs,
+		&customerPackage.Price,
+		&customerPackage.TaxRate,
+		&customerPackage.NetAmount,
+		&customerPackage.TaxAmount,
+		&customerPackage.Currency,
+		&customerPackage.PaymentMethod,
+		&customerPackage.ExpiresAt,
+		&customerPackage.CreatedAt,
+		&customerPackage.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalServiceTargets(serviceIDs, categoryIDs, &customerPackage.ServiceIDs, &customerPackage.CategoryIDs); err != nil {
+		return nil, err
+	}
+	customerPackage.SetCurrency(customerPackage.Currency)
+	return customerPackage, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type PasswordResetRepository interface {
+	Create(reset *models.PasswordReset) error
+	Consume(tokenHash string) (int, error)
+	InvalidateForUser(userID int) error
+}
+
+type passwordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// Create stores the token and drops the user's old used or expired tokens
+func (r *passwordResetRepository) Create(reset *models.PasswordReset) error {
+	cleanup := `
+		DELETE FROM password_resets
+		WHERE user_id = $1 AND (used_at IS NOT NULL OR expires_at < NOW())`
+	if _, err := r.db.Exec(cleanup, reset.UserID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, reset.UserID, reset.TokenHash, reset.ExpiresAt).
+		Scan(&reset.ID, &reset.CreatedAt)
+}
+
+// Consume marks an unused, unexpired token as used and returns its user ID.
+// Returns sql.ErrNoRows for unknown, used or expired tokens; the single UPDATE
+// keeps concurrent requests from using the same token twice.
+func (r *passwordResetRepository) Consume(tokenHash string) (int, error) {
+	query := `
+		UPDATE password_resets
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+
+	var userID int
+	err := r.db.QueryRow(query, tokenHash).Scan(&userID)
+	return userID, err
+}
+
+// InvalidateForUser uses up every outstanding token of the user
+func (r *passwordResetRepository) InvalidateForUser(userID int) error {
+	query := `UPDATE password_resets SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
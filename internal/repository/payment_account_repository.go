@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type PaymentAccountRepository interface {
+	GetByProvider(provider string) (*models.PaymentProviderAccount, error)
+	Save(account *models.PaymentProviderAccount) error
+	Delete(provider string) error
+}
+
+type paymentAccountRepository struct {
+	db *sql.DB
+}
+
+func NewPaymentAccountRepository(db *sql.DB) PaymentAccountRepository {
+	return &paymentAccountRepository{db: db}
+}
+
+// GetByProvider returns the tenant's credentials for a provider, nil when
+// there are none
+func (r *paymentAccountRepository) GetByProvider(provider string) (*models.PaymentProviderAccount, error) {
+	query := `
+		SELECT provider, secret_key, webhook_secret, created_at, updated_at
+		FROM payment_provider_accounts
+		WHERE provider = $1`
+
+	account := &models.PaymentProviderAccount{}
+	err := r.db.QueryRow(query, provider).Scan(
+		&account.Provider, &account.SecretKey, &account.WebhookSecret,
+		&account.CreatedAt, &account.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Save stores or replaces the credentials for account.Provider
+func (r *paymentAccountRepository) Save(account *models.PaymentProviderAccount) error {
+	query := `
+		INSERT INTO payment_provider_accounts (provider, secret_key, webhook_secret)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider) DO UPDATE
+		SET secret_key = EXCLUDED.secret_key, webhook_secret = EXCLUDED.webhook_secret, updated_at = NOW()
+		RETURNING created_at, updated_at`
+
+	return r.db.QueryRow(query, account.Provider, account.SecretKey, account.WebhookSecret).Scan(&account.CreatedAt, &account.UpdatedAt)
+}
+
+func (r *paymentAccountRepository) Delete(provider string) error {
+	query := `DELETE FROM payment_provider_accounts WHERE provider = $1`
+
+	result, err := r.db.Exec(query, provider)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -8,13 +8,18 @@ import (
 
 type PaymentRepository interface {
 	Create(payment *models.Payment) error
+	CreateWithinBalance(payment *models.Payment) (bool, error)
+	SetProviderResult(payment *models.Payment) error
 	GetByID(id int) (*models.Payment, error)
-	GetByAppointmentID(appointmentID int) (*models.Payment, error)
+	ListByAppointmentID(appointmentID int) ([]*models.Payment, error)
+	GetByTransactionID(provider, transactionID string) (*models.Payment, error)
 	GetByUserID(userID int, limit, offset int) ([]*models.Payment, error)
 	List(limit, offset int) ([]*models.Payment, error)
 	Update(payment *models.Payment) error
+	UpdateStatus(id int, status models.PaymentStatus, failureReason string) error
 	Delete(id int) error
-	GetTotalByDateRange(startDate, endDate time.Time) (float64, error)
+	GetTotalByDateRange(startDate, endDate time.Time, currency string) (models.Money, error)
+	GetTotalsByTaxRate(startDate, endDate time.Time, currency string) ([]*models.SalesByTaxRate, error)
 	GetByStatus(status models.PaymentStatus, limit, offset int) ([]*models.Payment, error)
 }
 
@@ -26,64 +31,133 @@ func NewPaymentRepository(db *sql.DB) PaymentRepository {
 	return &paymentRepository{db: db}
 }
 
+const paymentColumns = `p.id, p.appointment_id, p.device_id, p.amount, p.currency, p.tax_rate, p.net_amount, p.tax_amount, p.payment_method,
+	COALESCE(p.transaction_id, ''), COALESCE(p.provider, ''), p.status, COALESCE(p.refunded_amount, 0), COALESCE(p.failure_reason, ''),
+	p.created_at, COALESCE(p.updated_at, p.created_at)`
+
 func (r *paymentRepository) Create(payment *models.Payment) error {
 	query := `
-		INSERT INTO payments (appointment_id, device_id, amount, payment_method, transaction_id, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW())
-		RETURNING id, created_at`
+		INSERT INTO payments (appointment_id, device_id, amount, currency, tax_rate, net_amount, tax_amount, payment_method,
+			transaction_id, provider, status, failure_reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, NULLIF($12, ''), NOW(), NOW())
+		RETURNING id, created_at, updated_at`
 
 	err := r.db.QueryRow(query,
 		payment.AppointmentID,
 		payment.DeviceID,
 		payment.Amount,
+		payment.Currency,
+		payment.TaxRate,
+		payment.NetAmount,
+		payment.TaxAmount,
 		payment.PaymentMethod,
 		payment.TransactionID,
+		payment.Provider,
 		payment.Status,
-	).Scan(&payment.ID, &payment.CreatedAt)
+		payment.FailureReason,
+	).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
 
 	return err
 }
 
-func (r *paymentRepository) GetByID(id int) (*models.Payment, error) {
-	payment := &models.Payment{}
+// CreateWithinBalance records a payment only when it fits in what is left of
+// the appointment's total after its completed and pending payments. The
+// appointment row is locked, so concurrent payments cannot both fit in the
+// same balance. It reports false, without recording anything, when the
+// payment is more than what is left.
+func (r *paymentRepository) CreateWithinBalance(payment *models.Payment) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var total models.Money
+	if err := tx.QueryRow(`SELECT total_amount FROM appointments WHERE id = $1 FOR UPDATE`, payment.AppointmentID).Scan(&total); err != nil {
+		return false, err
+	}
+
+	var held models.Money
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(amount - COALESCE(refunded_amount, 0)), 0)
+		FROM payments
+		WHERE appointment_id = $1 AND status IN ('completed', 'pending')`,
+		payment.AppointmentID).Scan(&held)
+	if err != nil {
+		return false, err
+	}
+	if held.Amount+payment.Amount.Amount > total.Amount {
+		return false, nil
+	}
+
 	query := `
-		SELECT id, appointment_id, device_id, amount, payment_method, transaction_id, status, created_at
-		FROM payments WHERE id = $1`
+		INSERT INTO payments (appointment_id, device_id, amount, currency, tax_rate, net_amount, tax_amount, payment_method,
+			transaction_id, provider, status, failure_reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, NULLIF($12, ''), NOW(), NOW())
+		RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&payment.ID,
-		&payment.AppointmentID,
-		&payment.DeviceID,
-		&payment.Amount,
-		&payment.PaymentMethod,
-		&payment.TransactionID,
-		&payment.Status,
-		&payment.CreatedAt,
-	)
+	err = tx.QueryRow(query,
+		payment.AppointmentID,
+		payment.DeviceID,
+		payment.Amount,
+		payment.Currency,
+		payment.TaxRate,
+		payment.NetAmount,
+		payment.TaxAmount,
+		payment.PaymentMethod,
+		payment.TransactionID,
+		payment.Provider,
+		payment.Status,
+		payment.FailureReason,
+	).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// SetProviderResult records the provider's reference and answer for a
+// payment that was recorded before it was sent to the provider
+func (r *paymentRepository) SetProviderResult(payment *models.Payment) error {
+	query := `
+		UPDATE payments
+		SET transaction_id = $2, status = $3, failure_reason = NULLIF($4, ''), updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	return r.db.QueryRow(query, payment.ID, payment.TransactionID, payment.Status, payment.FailureReason).
+		Scan(&payment.UpdatedAt)
+}
 
+func (r *paymentRepository) GetByID(id int) (*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments p WHERE p.id = $1`
+
+	payment, err := scanPayment(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return payment, err
 }
 
-func (r *paymentRepository) GetByAppointmentID(appointmentID int) (*models.Payment, error) {
-	payment := &models.Payment{}
-	query := `
-		SELECT id, appointment_id, device_id, amount, payment_method, transaction_id, status, created_at
-		FROM payments WHERE appointment_id = $1`
+// ListByAppointmentID returns every payment taken for an appointment, oldest first
+func (r *paymentRepository) ListByAppointmentID(appointmentID int) ([]*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments p WHERE p.appointment_id = $1 ORDER BY p.created_at, p.id`
 
-	err := r.db.QueryRow(query, appointmentID).Scan(
-		&payment.ID,
-		&payment.AppointmentID,
-		&payment.DeviceID,
-		&payment.Amount,
-		&payment.PaymentMethod,
-		&payment.TransactionID,
-		&payment.Status,
-		&payment.CreatedAt,
-	)
+	rows, err := r.db.Query(query, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPayments(rows)
+}
 
+// GetByTransactionID finds a payment by the reference its provider gave it
+func (r *paymentRepository) GetByTransactionID(provider, transactionID string) (*models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments p WHERE p.provider = $1 AND p.transaction_id = $2`
+
+	payment, err := scanPayment(r.db.QueryRow(query, provider, transactionID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -92,7 +166,7 @@ func (r *paymentRepository) GetByAppointmentID(appointmentID int) (*models.Payme
 
 func (r *paymentRepository) GetByUserID(userID int, limit, offset int) ([]*models.Payment, error) {
 	query := `
-		SELECT p.id, p.appointment_id, p.device_id, p.amount, p.payment_method, p.transaction_id, p.status, p.created_at
+		SELECT ` + paymentColumns + `
 		FROM payments p
 		JOIN appointments a ON p.appointment_id = a.id
 		WHERE a.user_id = $1
@@ -105,33 +179,14 @@ func (r *paymentRepository) GetByUserID(userID int, limit, offset int) ([]*model
 	}
 	defer rows.Close()
 
-	var payments []*models.Payment
-	for rows.Next() {
-		payment := &models.Payment{}
-		err := rows.Scan(
-			&payment.ID,
-			&payment.AppointmentID,
-			&payment.DeviceID,
-			&payment.Amount,
-			&payment.PaymentMethod,
-			&payment.TransactionID,
-			&payment.Status,
-			&payment.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		payments = append(payments, payment)
-	}
-
-	return payments, nil
+	return scanPayments(rows)
 }
 
 func (r *paymentRepository) List(limit, offset int) ([]*models.Payment, error) {
 	query := `
-		SELECT id, appointment_id, device_id, amount, payment_method, transaction_id, status, created_at
-		FROM payments
-		ORDER BY created_at DESC
+		SELECT ` + paymentColumns + `
+		FROM payments p
+		ORDER BY p.created_at DESC
 		LIMIT $1 OFFSET $2`
 
 	rows, err := r.db.Query(query, limit, offset)
@@ -140,67 +195,98 @@ func (r *paymentRepository) List(limit, offset int) ([]*models.Payment, error) {
 	}
 	defer rows.Close()
 
-	var payments []*models.Payment
-	for rows.Next() {
-		payment := &models.Payment{}
-		err := rows.Scan(
-			&payment.ID,
-			&payment.AppointmentID,
-			&payment.DeviceID,
-			&payment.Amount,
-			&payment.PaymentMethod,
-			&payment.TransactionID,
-			&payment.Status,
-			&payment.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		payments = append(payments, payment)
-	}
-
-	return payments, nil
+	return scanPayments(rows)
 }
 
 func (r *paymentRepository) Update(payment *models.Payment) error {
 	query := `
 		UPDATE payments 
-		SET amount = $1, payment_method = $2, status = $3
-		WHERE id = $4`
+		SET amount = $1, payment_method = $2, status = $3, net_amount = $4, tax_amount = $5, updated_at = NOW()
+		WHERE id = $6`
 
 	_, err := r.db.Exec(query,
 		payment.Amount,
 		payment.PaymentMethod,
 		payment.Status,
+		payment.NetAmount,
+		payment.TaxAmount,
 		payment.ID,
 	)
 
 	return err
 }
 
+// UpdateStatus records a status reported by the payment provider. A payment
+// refunded at the provider counts as refunded in full.
+func (r *paymentRepository) UpdateStatus(id int, status models.PaymentStatus, failureReason string) error {
+	query := `
+		UPDATE payments
+		SET status = $2, failure_reason = NULLIF($3, ''),
+			refunded_amount = CASE WHEN $2 = 'refunded' THEN amount ELSE refunded_amount END, updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.db.Exec(query, id, status, failureReason)
+	return err
+}
+
 func (r *paymentRepository) Delete(id int) error {
 	query := `DELETE FROM payments WHERE id = $1`
 	_, err := r.db.Exec(query, id)
 	return err
 }
 
-func (r *paymentRepository) GetTotalByDateRange(startDate, endDate time.Time) (float64, error) {
-	var total float64
+// GetTotalByDateRange sums what was kept of the completed payments in one
+// currency, partial refunds are taken off
+func (r *paymentRepository) GetTotalByDateRange(startDate, endDate time.Time, currency string) (models.Money, error) {
+	total := models.NewMoney(0, currency)
 	query := `
-		SELECT COALESCE(SUM(amount), 0) 
+		SELECT COALESCE(SUM(amount - refunded_amount), 0)
 		FROM payments 
-		WHERE status = $1 AND created_at BETWEEN $2 AND $3`
+		WHERE status = $1 AND currency = $2 AND created_at BETWEEN $3 AND $4`
 
-	err := r.db.QueryRow(query, models.PaymentCompleted, startDate, endDate).Scan(&total)
+	err := r.db.QueryRow(query, models.PaymentCompleted, currency, startDate, endDate).Scan(&total)
 	return total, err
 }
 
+// GetTotalsByTaxRate sums what was kept of the completed payments in one
+// currency per VAT rate. The tax of a partial refund is taken off the way
+// the payment's own tax was split, rounded half away from zero.
+func (r *paymentRepository) GetTotalsByTaxRate(startDate, endDate time.Time, currency string) ([]*models.SalesByTaxRate, error) {
+	query := `
+		SELECT tax_rate, COUNT(*), COALESCE(SUM(amount - refunded_amount), 0),
+			COALESCE(SUM(tax_amount - ROUND(refunded_amount * tax_rate / (100 + tax_rate), 2)), 0)
+		FROM payments
+		WHERE status = $1 AND currency = $2 AND created_at BETWEEN $3 AND $4
+		GROUP BY tax_rate
+		ORDER BY tax_rate`
+
+	rows, err := r.db.Query(query, models.PaymentCompleted, currency, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []*models.SalesByTaxRate
+	for rows.Next() {
+		total := &models.SalesByTaxRate{Currency: currency}
+		if err := rows.Scan(&total.TaxRate, &total.Payments, &total.Gross, &total.Tax); err != nil {
+			return nil, err
+		}
+		total.Gross.Currency = currency
+		total.Tax.Currency = currency
+		total.Net = total.Gross.Sub(total.Tax)
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
 func (r *paymentRepository) GetByStatus(status models.PaymentStatus, limit, offset int) ([]*models.Payment, error) {
 	query := `
-		SELECT id, appointment_id, device_id, amount, payment_method, transaction_id, status, created_at
-		FROM payments 
-		WHERE status = $1
-		ORDER BY created_at DESC
+		SELECT ` + paymentColumns + `
+		FROM payments p
+		WHERE p.status = $1
+		ORDER BY p.created_at DESC
 		LIMIT $2 OFFSET $3`
 
 	rows, err := r.db.Query(query, status, limit, offset)
@@ -209,24 +295,45 @@ func (r *paymentRepository) GetByStatus(status models.PaymentStatus, limit, offs
 	}
 	defer rows.Close()
 
+	return scanPayments(rows)
+}
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	payment := &models.Payment{}
+	err := row.Scan(
+		&payment.ID,
+		&payment.AppointmentID,
+		&payment.DeviceID,
+		&payment.Amount,
+		&payment.Currency,
+		&payment.TaxRate,
+		&payment.NetAmount,
+		&payment.TaxAmount,
+		&payment.PaymentMethod,
+		&payment.TransactionID,
+		&payment.Provider,
+		&payment.Status,
+		&payment.RefundedAmount,
+		&payment.FailureReason,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	payment.SetCurrency(payment.Currency)
+	return payment, nil
+}
+
+func scanPayments(rows *sql.Rows) ([]*models.Payment, error) {
 	var payments []*models.Payment
 	for rows.Next() {
-		payment := &models.Payment{}
-		err := rows.Scan(
-			&payment.ID,
-			&payment.AppointmentID,
-			&payment.DeviceID,
-			&payment.Amount,
-			&payment.PaymentMethod,
-			&payment.TransactionID,
-			&payment.Status,
-			&payment.CreatedAt,
-		)
+		payment, err := scanPayment(rows)
 		if err != nil {
 			return nil, err
 		}
 		payments = append(payments, payment)
 	}
 
-	return payments, nil
+	return payments, rows.Err()
 }
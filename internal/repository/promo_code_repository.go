@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"encoding/json"
+)
+
+type PromoCodeRepository interface {
+	Create(promo *models.PromoCode) error
+	GetByID(id int) (*models.PromoCode, error)
+	GetByCode(code string) (*models.PromoCode, error)
+	Update(promo *models.PromoCode) error
+	Delete(id int) error
+	List() ([]*models.PromoCode, error)
+	CountUses(promoCodeID, userID int) (int, int, error)
+	Redeem(redemption *models.PromoRedemption, maxUses, maxUsesPerUser *int) (bool, error)
+	ListRedemptions(filter models.PromoRedemptionFilter, limit, offset int) ([]*models.PromoRedemption, int, error)
+	SummarizeRedemptions(filter models.PromoRedemptionFilter) ([]*models.PromoCodeSummary, error)
+}
+
+type promoCodeRepository struct {
+	db *sql.DB
+}
+
+func NewPromoCodeRepository(db *sql.DB) PromoCodeRepository {
+	return &promoCodeRepository{db: db}
+}
+
+const promoCodeColumns = `id, code, description, discount_type, percent_off, amount_off, currency, valid_from, valid_until,
+	max_uses, max_uses_per_user, service_ids, category_ids, active, created_at, updated_at,
+	(SELECT COUNT(*) FROM promo_redemptions pr JOIN appointments a ON a.id = pr.appointment_id
+		WHERE pr.promo_code_id = promo_codes.id AND a.status <> 'cancelled')`
+
+func (r *promoCodeRepository) Create(promo *models.PromoCode) error {
+	serviceIDs, categoryIDs, err := marshalServiceTargets(promo.ServiceIDs, promo.CategoryIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO promo_codes (code, description, discount_type, percent_off, amount_off, currency, valid_from, valid_until,
+			max_uses, max_uses_per_user, service_ids, category_ids, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(query,
+		promo.Code,
+		promo.Description,
+		promo.DiscountType,
+		promo.PercentOff,
+		promo.AmountOff,
+		promo.Currency,
+		promo.ValidFrom,
+		promo.ValidUntil,
+		promo.MaxUses,
+		promo.MaxUsesPerUser,
+		serviceIDs,
+		categoryIDs,
+		promo.Active,
+	).Scan(&promo.ID, &promo.CreatedAt, &promo.UpdatedAt)
+}
+
+func (r *promoCodeRepository) GetByID(id int) (*models.PromoCode, error) {
+	return scanPromoCode(r.db.QueryRow(`SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = $1`, id))
+}
+
+func (r *promoCodeRepository) GetByCode(code string) (*models.PromoCode, error) {
+	return scanPromoCode(r.db.QueryRow(`SELECT `+promoCodeColumns+` FROM promo_codes WHERE code = $1`, code))
+}
+
+func (r *promoCodeRepository) Update(promo *models.PromoCode) error {
+	serviceIDs, categoryIDs, err := marshalServiceTargets(promo.ServiceIDs, promo.CategoryIDs)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE promo_codes
+		SET code = $2, description = $3, discount_type = $4, percent_off = $5, amount_off = $6, currency = $7,
+			valid_from = $8, valid_until = $9, max_uses = $10, max_uses_per_user = $11, service_ids = $12,
+			category_ids = $13, active = $14, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	return r.db.QueryRow(query,
+		promo.ID,
+		promo.Code,
+		promo.Description,
+		promo.DiscountType,
+		promo.PercentOff,
+		promo.AmountOff,
+		promo.Currency,
+		promo.ValidFrom,
+		promo.ValidUntil,
+		promo.MaxUses,
+		promo.MaxUsesPerUser,
+		serviceIDs,
+		categoryIDs,
+		promo.Active,
+	).Scan(&promo.UpdatedAt)
+}
+
+func (r *promoCodeRepository) Delete(id int) error {
+	_, err := r.db.Exec(`DELETE FROM promo_codes WHERE id = $1`, id)
+	return err
+}
+
+func (r *promoCodeRepository) List() ([]*models.PromoCode, error) {
+	rows, err := r.db.Query(`SELECT ` + promoCodeColumns + ` FROM promo_codes ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promos []*models.PromoCode
+	for rows.Next() {
+		promo, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, promo)
+	}
+
+	return promos, rows.Err()
+}
+
+// CountUses counts the redemptions of the code, and those of the user, whose
+// appointment is not cancelled
+func (r *promoCodeRepository) CountUses(promoCodeID, userID int) (int, int, error) {
+	return countPromoUses(r.db.QueryRow(promoUsesQuery, promoCodeID, userID))
+}
+
+const promoUsesQuery = `
+	SELECT COUNT(*), COUNT(*) FILTER (WHERE a.user_id = $2)
+	FROM promo_redemptions pr
+	JOIN appointments a ON a.id = pr.appointment_id
+	WHERE pr.promo_code_id = $1 AND a.status <> 'cancelled'`
+
+func countPromoUses(row rowScanner) (int, int, error) {
+	var total, byUser int
+	err := row.Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+// Redeem records the code on the appointment. The code's row stays locked
+// until the transaction ends, so concurrent bookings cannot exceed the usage
+// limits. It reports false, without recording anything, when a limit is
+// reached.
+func (r *promoCodeRepository) Redeem(redemption *models.PromoRedemption, maxUses, maxUsesPerUser *int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT id FROM promo_codes WHERE id = $1 FOR UPDATE`, redemption.PromoCodeID); err != nil {
+		return false, err
+	}
+
+	var userID int
+	if err := tx.QueryRow(`SELECT user_id FROM appointments WHERE id = $1`, redemption.AppointmentID).Scan(&userID); err != nil {
+		return false, err
+	}
+
+	total, byUser, err := countPromoUses(tx.QueryRow(promoUsesQuery, redemption.PromoCodeID, userID))
+	if err != nil {
+		return false, err
+	}
+	if maxUses != nil && total >= *maxUses || maxUsesPerUser != nil && byUser >= *maxUsesPerUser {
+		return false, nil
+	}
+
+	query := `
+		INSERT INTO promo_redemptions (promo_code_id, appointment_id, discount, currency)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err = tx.QueryRow(query,
+		redemption.PromoCodeID,
+		redemption.AppointmentID,
+		redemption.Discount,
+		redemption.Currency,
+	).Scan(&redemption.ID, &redemption.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	redemption.UserID = userID
+	return true, tx.Commit()
+}
+
+// promoRedemptionWhere takes the filter arguments of promoRedemptionFilterArgs as $1 to $3
+const promoRedemptionWhere = `
+	WHERE ($1 = 0 OR pr.promo_code_id = $1)
+		AND ($2::timestamptz IS NULL OR pr.created_at >= $2) AND ($3::timestamptz IS NULL OR pr.created_at < $3)`
+
+func promoRedemptionFilterArgs(filter models.PromoRedemptionFilter) []interface{} {
+	return []interface{}{filter.PromoCodeID, filter.From, filter.To}
+}
+
+const promoRedemptionFrom = `
+	FROM promo_redemptions pr
+	JOIN promo_codes pc ON pc.id = pr.promo_code_id
+	JOIN appointments a ON a.id = pr.appointment_id
+	LEFT JOIN users u ON u.id = a.user_id`
+
+func (r *promoCodeRepository) ListRedemptions(filter models.PromoRedemptionFilter, limit, offset int) ([]*models.PromoRedemption, int, error) {
+	args := promoRedemptionFilterArgs(filter)
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*)`+promoRedemptionFrom+promoRedemptionWhere, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT pr.id, pr.promo_code_id, pc.code, pr.appointment_id, a.status, a.user_id, COALESCE(u.name, ''),
+			a.service_id, pr.discount, pr.currency, pr.created_at` + promoRedemptionFrom + promoRedemptionWhere + `
+		ORDER BY pr.created_at DESC, pr.id DESC
+		LIMIT $4 OFFSET $5`
+
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var redemptions []*models.PromoRedemption
+	for rows.Next() {
+		redemption := &models.PromoRedemption{}
+		err := rows.Scan(
+			&redemption.ID,
+			&redemption.PromoCodeID,
+			&redemption.Code,
+			&redemption.AppointmentID,
+			&redemption.AppointmentStatus,
+			&redemption.UserID,
+			&redemption.UserName,
+			&redemption.ServiceID,
+			&redemption.Discount,
+			&redemption.Currency,
+			&redemption.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		redemption.Discount.Currency = redemption.Currency
+		redemptions = append(redemptions, redemption)
+	}
+
+	return redemptions, total, rows.Err()
+}
+
+func (r *promoCodeRepository) SummarizeRedemptions(filter models.PromoRedemptionFilter) ([]*models.PromoCodeSummary, error) {
+	query := `
+		SELECT pr.promo_code_id, pc.code, pr.currency, COUNT(*),
+			COUNT(*) FILTER (WHERE a.status = 'cancelled'),
+			COALESCE(SUM(pr.discount) FILTER (WHERE a.status <> 'cancelled'), 0)` + promoRedemptionFrom + promoRedemptionWhere + `
+		GROUP BY pr.promo_code_id, pc.code, pr.currency
+		ORDER BY COUNT(*) DESC, pc.code`
+
+	rows, err := r.db.Query(query, promoRedemptionFilterArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*models.PromoCodeSummary
+	for rows.Next() {
+		summary := &models.PromoCodeSummary{}
+		err := rows.Scan(
+			&summary.PromoCodeID,
+			&summary.Code,
+			&summary.Currency,
+			&summary.Redemptions,
+			&summary.Cancelled,
+			&summary.DiscountTotal,
+		)
+		if err != nil {
+			return nil, err
+		}
+		summary.DiscountTotal.Currency = summary.Currency
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+// marshalServiceTargets encodes the service and category restrictions of a
+// promo code or package for their JSONB columns
+func marshalServiceTargets(serviceIDs, categoryIDs []int) ([]byte, []byte, error) {
+	services, err := json.Marshal(nonNilIDs(serviceIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	categories, err := json.Marshal(nonNilIDs(categoryIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+	return services, categories, nil
+}
+
+func unmarshalServiceTargets(serviceIDs, categoryIDs []byte, services, categories *[]int) error {
+	if err := json.Unmarshal(serviceIDs, services); err != nil {
+		return err
+	}
+	return json.Unmarshal(categoryIDs, categories)
+}
+
+// nonNilIDs keeps empty lists as [] rather than null in JSON
+func nonNilIDs(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return ids
+}
+
+func scanPromoCode(row rowScanner) (*models.PromoCode, error) {
+	promo := &models.PromoCode{}
+	var serviceIDs, categoryIDs []byte
+	err := row.Scan(
+		&promo.ID,
+		&promo.Code,
+		&promo.Description,
+		&promo.DiscountType,
+		&promo.PercentOff,
+		&promo.AmountOff,
+		&promo.Currency,
+		&promo.ValidFrom,
+		&promo.ValidUntil,
+		&promo.MaxUses,
+		&promo.MaxUsesPerUser,
+		&serviceIDs,
+		&categoryIDs,
+		&promo.Active,
+		&promo.CreatedAt,
+		&promo.UpdatedAt,
+		&promo.Uses,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalServiceTargets(serviceIDs, categoryIDs, &promo.ServiceIDs, &promo.CategoryIDs); err != nil {
+		return nil, err
+	}
+	promo.AmountOff.Currency = promo.Currency
+	return promo, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type RefundRepository interface {
+	Create(refund *models.Refund) (bool, error)
+	Finish(refund *models.Refund) error
+	Fail(refund *models.Refund) error
+	GetByProviderReference(reference string) (*models.Refund, error)
+	ListByPaymentID(paymentID int) ([]*models.Refund, error)
+}
+
+const refundColumns = `id, payment_id, amount, currency, reason, status, COALESCE(provider_reference, ''), to_wallet,
+	actor_type, actor_id, COALESCE(actor, ''), created_at`
+
+type refundRepository struct {
+	db *sql.DB
+}
+
+func NewRefundRepository(db *sql.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+// Create records a refund and adds it to the payment's refunded amount, which
+// turns the payment refunded once all of it is returned. It reports false,
+// without recording anything, when the payment is not completed or the refund
+// is more than what is left of it.
+func (r *refundRepository) Create(refund *models.Refund) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE payments
+		SET refunded_amount = refunded_amount + $2,
+			status = CASE WHEN refunded_amount + $2 >= amount THEN 'refunded' ELSE status END,
+			updated_at = NOW()
+		WHERE id = $1 AND status = 'completed' AND refunded_amount + $2 <= amount`,
+		refund.PaymentID, refund.Amount)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	query := `
+		INSERT INTO refunds (payment_id, amount, currency, reason, status, provider_reference, to_wallet, actor_type, actor_id, actor)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	err = tx.QueryRow(query,
+		refund.PaymentID,
+		refund.Amount,
+		refund.Currency,
+		refund.Reason,
+		refund.Status,
+		refund.ProviderReference,
+		refund.ToWallet,
+		refund.ActorType,
+		refund.ActorID,
+		refund.Actor,
+	).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Finish records the provider's answer to a processing refund, or settles a
+// pending one
+func (r *refundRepository) Finish(refund *models.Refund) error {
+	query := `
+		UPDATE refunds
+		SET status = $2, provider_reference = COALESCE(NULLIF($3, ''), provider_reference)
+		WHERE id = $1 AND status IN ('processing', 'pending')`
+
+	_, err := r.db.Exec(query, refund.ID, refund.Status, refund.ProviderReference)
+	return err
+}
+
+// Fail marks a processing or pending refund failed and takes its amount off
+// the payment's refunded amount again, a payment it turned refunded is
+// completed again
+func (r *refundRepository) Fail(refund *models.Refund) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE refunds SET status = 'failed' WHERE id = $1 AND status IN ('processing', 'pending')`, refund.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE payments
+		SET refunded_amount = refunded_amount - $2,
+			status = CASE WHEN status = 'refunded' THEN 'completed' ELSE status END,
+			updated_at = NOW()
+		WHERE id = $1`,
+		refund.PaymentID, refund.Amount)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByProviderReference finds a refund by the provider's refund reference,
+// nil when there is none
+func (r *refundRepository) GetByProviderReference(reference string) (*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE provider_reference = $1`
+
+	refund, err := scanRefund(r.db.QueryRow(query, reference))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return refund, err
+}
+
+func (r *refundRepository) ListByPaymentID(paymentID int) ([]*models.Refund, error) {
+	query := `
+		SELECT ` + refundColumns + `
+		FROM refunds
+		WHERE payment_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := r.db.Query(query, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []*models.Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+func scanRefund(row rowScanner) (*models.Refund, error) {
+	refund := &models.Refund{}
+	err := row.Scan(
+		&refund.ID,
+		&refund.PaymentID,
+		&refund.Amount,
+		&refund.Currency,
+		&refund.Reason,
+		&refund.Status,
+		&refund.ProviderReference,
+		&refund.ToWallet,
+		&refund.ActorType,
+		&refund.ActorID,
+		&refund.Actor,
+		&refund.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	refund.Amount.Currency = refund.Currency
+	return refund, nil
+}
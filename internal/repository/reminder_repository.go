@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type ReminderRepository interface {
+	Record(reminder *models.AppointmentReminder) (bool, error)
+	ListByAppointment(appointmentID int) ([]*models.AppointmentReminder, error)
+}
+
+type reminderRepository struct {
+	db *sql.DB
+}
+
+func NewReminderRepository(db *sql.DB) ReminderRepository {
+	return &reminderRepository{db: db}
+}
+
+// Record stores the reminder and reports false when it was already recorded for
+// the same appointment, offset and start time
+func (r *reminderRepository) Record(reminder *models.AppointmentReminder) (bool, error) {
+	query := `
+		INSERT INTO appointment_reminders (appointment_id, offset_minutes, appointment_start, channels, sent)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (appointment_id, offset_minutes, appointment_start) DO NOTHING
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(
+		query,
+		reminder.AppointmentID,
+		reminder.OffsetMinutes,
+		reminder.AppointmentStart,
+		reminder.Channels,
+		reminder.Sent,
+	).Scan(&reminder.ID, &reminder.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *reminderRepository) ListByAppointment(appointmentID int) ([]*models.AppointmentReminder, error) {
+	query := `
+		SELECT id, appointment_id, offset_minutes, appointment_start, channels, sent, created_at
+		FROM appointment_reminders
+		WHERE appointment_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []*models.AppointmentReminder
+	for rows.Next() {
+		reminder := &models.AppointmentReminder{}
+		err := rows.Scan(
+			&reminder.ID, &reminder.AppointmentID, &reminder.OffsetMinutes,
+			&reminder.AppointmentStart, &reminder.Channels, &reminder.Sent, &reminder.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
@@ -5,27 +5,67 @@ import (
 )
 
 type Repositories struct {
-	User        UserRepository
-	Category    CategoryRepository
-	Service     ServiceRepository
-	Settings    SettingsRepository
-	Device      DeviceRepository
-	Specialist  SpecialistRepository
-	Appointment AppointmentRepository
-	Payment     PaymentRepository
-	Contact     ContactRepository
+	User              UserRepository
+	Category          CategoryRepository
+	Service           ServiceRepository
+	Settings          SettingsRepository
+	Device            DeviceRepository
+	Specialist        SpecialistRepository
+	Appointment       AppointmentRepository
+	Payment           PaymentRepository
+	Refund            RefundRepository
+	PaymentAccount    PaymentAccountRepository
+	Wallet            WalletRepository
+	CashSession       CashSessionRepository
+	Invoice           InvoiceRepository
+	PromoCode         PromoCodeRepository
+	Package           PackageRepository
+	Contact           ContactRepository
+	Calendar          CalendarRepository
+	ExternalCalendar  ExternalCalendarRepository
+	Notification      NotificationRepository
+	Reminder          ReminderRepository
+	Webhook           WebhookRepository
+	PasswordReset     PasswordResetRepository
+	EmailVerification EmailVerificationRepository
+	Session           SessionRepository
+	LoginAttempt      LoginAttemptRepository
+	TwoFactor         TwoFactorRepository
+	APIKey            APIKeyRepository
+	Audit             AuditRepository
+	Idempotency       IdempotencyRepository
 }
 
 func NewRepositories(db *sql.DB) *Repositories {
 	return &Repositories{
-		User:        NewUserRepository(db),
-		Category:    NewCategoryRepository(db),
-		Service:     NewServiceRepository(db),
-		Settings:    NewSettingsRepository(db),
-		Device:      NewDeviceRepository(db),
-		Specialist:  NewSpecialistRepository(db),
-		Appointment: NewAppointmentRepository(db),
-		Payment:     NewPaymentRepository(db),
-		Contact:     NewContactRepository(db),
+		User:              NewUserRepository(db),
+		Category:          NewCategoryRepository(db),
+		Service:           NewServiceRepository(db),
+		Settings:          NewSettingsRepository(db),
+		Device:            NewDeviceRepository(db),
+		Specialist:        NewSpecialistRepository(db),
+		Appointment:       NewAppointmentRepository(db),
+		Payment:           NewPaymentRepository(db),
+		Refund:            NewRefundRepository(db),
+		PaymentAccount:    NewPaymentAccountRepository(db),
+		Wallet:            NewWalletRepository(db),
+		CashSession:       NewCashSessionRepository(db),
+		Invoice:           NewInvoiceRepository(db),
+		PromoCode:         NewPromoCodeRepository(db),
+		Package:           NewPackageRepository(db),
+		Contact:           NewContactRepository(db),
+		Calendar:          NewCalendarRepository(db),
+		ExternalCalendar:  NewExternalCalendarRepository(db),
+		Notification:      NewNotificationRepository(db),
+		Reminder:          NewReminderRepository(db),
+		Webhook:           NewWebhookRepository(db),
+		PasswordReset:     NewPasswordResetRepository(db),
+		EmailVerification: NewEmailVerificationRepository(db),
+		Session:           NewSessionRepository(db),
+		LoginAttempt:      NewLoginAttemptRepository(db),
+		TwoFactor:         NewTwoFactorRepository(db),
+		APIKey:            NewAPIKeyRepository(db),
+		Audit:             NewAuditRepository(db),
+		Idempotency:       NewIdempotencyRepository(db),
 	}
 }
@@ -26,13 +26,13 @@ func NewServiceRepository(db *sql.DB) ServiceRepository {
 
 func (r *serviceRepository) Create(service *models.Service) error {
 	query := `
-		INSERT INTO services (category_id, name, description, price, image_url, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO services (category_id, name, description, price, tax_rate, image_url, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id`
 
 	now := time.Now()
 	err := r.db.QueryRow(query, service.CategoryID, service.Name, service.Description,
-		service.Price, service.ImageURL, service.Active, now, now).Scan(&service.ID)
+		service.Price, service.TaxRate, service.ImageURL, service.Active, now, now).Scan(&service.ID)
 	if err != nil {
 		return err
 	}
@@ -44,13 +44,13 @@ func (r *serviceRepository) Create(service *models.Service) error {
 
 func (r *serviceRepository) GetByID(id int) (*models.Service, error) {
 	query := `
-		SELECT id, category_id, name, description, price, image_url, active, created_at, updated_at
+		SELECT id, category_id, name, description, price, tax_rate, image_url, active, created_at, updated_at
 		FROM services WHERE id = $1`
 
 	service := &models.Service{}
 	err := r.db.QueryRow(query, id).Scan(
 		&service.ID, &service.CategoryID, &service.Name, &service.Description,
-		&service.Price, &service.ImageURL, &service.Active, &service.CreatedAt, &service.UpdatedAt,
+		&service.Price, &service.TaxRate, &service.ImageURL, &service.Active, &service.CreatedAt, &service.UpdatedAt,
 	)
 	return service, err
 }
@@ -58,13 +58,13 @@ func (r *serviceRepository) GetByID(id int) (*models.Service, error) {
 func (r *serviceRepository) Update(service *models.Service) error {
 	query := `
 		UPDATE services 
-		SET category_id = $1, name = $2, description = $3, price = $4, 
-			image_url = $5, active = $6, updated_at = $7
-		WHERE id = $8`
+		SET category_id = $1, name = $2, description = $3, price = $4, tax_rate = $5,
+			image_url = $6, active = $7, updated_at = $8
+		WHERE id = $9`
 
 	service.UpdatedAt = time.Now()
 	_, err := r.db.Exec(query, service.CategoryID, service.Name, service.Description,
-		service.Price, service.ImageURL, service.Active, service.UpdatedAt, service.ID)
+		service.Price, service.TaxRate, service.ImageURL, service.Active, service.UpdatedAt, service.ID)
 	return err
 }
 
@@ -76,7 +76,7 @@ func (r *serviceRepository) Delete(id int) error {
 
 func (r *serviceRepository) List() ([]*models.Service, error) {
 	query := `
-		SELECT id, category_id, name, description, price, image_url, active, created_at, updated_at
+		SELECT id, category_id, name, description, price, tax_rate, image_url, active, created_at, updated_at
 		FROM services
 		ORDER BY name ASC`
 
@@ -85,7 +85,7 @@ func (r *serviceRepository) List() ([]*models.Service, error) {
 
 func (r *serviceRepository) ListActive() ([]*models.Service, error) {
 	query := `
-		SELECT id, category_id, name, description, price, image_url, active, created_at, updated_at
+		SELECT id, category_id, name, description, price, tax_rate, image_url, active, created_at, updated_at
 		FROM services
 		WHERE active = true
 		ORDER BY name ASC`
@@ -95,7 +95,7 @@ func (r *serviceRepository) ListActive() ([]*models.Service, error) {
 
 func (r *serviceRepository) ListByCategory(categoryID int) ([]*models.Service, error) {
 	query := `
-		SELECT id, category_id, name, description, price, image_url, active, created_at, updated_at
+		SELECT id, category_id, name, description, price, tax_rate, image_url, active, created_at, updated_at
 		FROM services
 		WHERE category_id = $1 AND active = true
 		ORDER BY name ASC`
@@ -125,7 +125,7 @@ func (r *serviceRepository) scanServices(rows *sql.Rows) ([]*models.Service, err
 		service := &models.Service{}
 		err := rows.Scan(
 			&service.ID, &service.CategoryID, &service.Name, &service.Description,
-			&service.Price, &service.ImageURL, &service.Active, &service.CreatedAt, &service.UpdatedAt,
+			&service.Price, &service.TaxRate, &service.ImageURL, &service.Active, &service.CreatedAt, &service.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
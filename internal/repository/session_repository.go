@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	GetByID(id int) (*models.Session, error)
+	ListActiveByUser(userID int) ([]*models.Session, error)
+	Rotate(tokenHash, newTokenHash string, client models.ClientInfo) (*models.Session, error)
+	RevokeByPreviousToken(tokenHash string) (bool, error)
+	Revoke(id, userID int) error
+	RevokeAllForUser(userID, exceptID int) error
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+const sessionColumns = `id, user_id, token_hash, COALESCE(previous_token_hash, ''), COALESCE(user_agent, ''),
+	COALESCE(ip_address, ''), expires_at, last_used_at, revoked_at, created_at`
+
+// Create stores the session and drops the user's expired or revoked sessions
+func (r *sessionRepository) Create(session *models.Session) error {
+	cleanup := `
+		DELETE FROM sessions
+		WHERE user_id = $1 AND (revoked_at IS NOT NULL OR expires_at < NOW())`
+	if _, err := r.db.Exec(cleanup, session.UserID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO sessions (user_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, last_used_at, created_at`
+
+	return r.db.QueryRow(
+		query,
+		session.UserID,
+		session.TokenHash,
+		session.UserAgent,
+		session.IPAddress,
+		session.ExpiresAt,
+	).Scan(&session.ID, &session.LastUsedAt, &session.CreatedAt)
+}
+
+func (r *sessionRepository) GetByID(id int) (*models.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	return scanSession(r.db.QueryRow(query, id))
+}
+
+func (r *sessionRepository) ListActiveByUser(userID int) ([]*models.Session, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Rotate swaps the refresh token of an active session in a single UPDATE, so
+// the same token cannot be used twice. The session keeps the expiry it got at
+// login, refreshing does not extend it. Returns sql.ErrNoRows for unknown,
+// revoked or expired tokens.
+func (r *sessionRepository) Rotate(tokenHash, newTokenHash string, client models.ClientInfo) (*models.Session, error) {
+	query := `
+		UPDATE sessions
+		SET previous_token_hash = token_hash, token_hash = $2,
+			user_agent = $3, ip_address = $4, last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + sessionColumns
+
+	return scanSession(r.db.QueryRow(query, tokenHash, newTokenHash, client.UserAgent, client.IPAddress))
+}
+
+// RevokeByPreviousToken revokes the session an already rotated refresh token
+// belonged to, reporting whether there was one
+func (r *sessionRepository) RevokeByPreviousToken(tokenHash string) (bool, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE previous_token_hash = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *sessionRepository) Revoke(id, userID int) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every session of the user except exceptID (0 keeps none)
+func (r *sessionRepository) RevokeAllForUser(userID, exceptID int) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, userID, exceptID)
+	return err
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.TokenHash, &session.PreviousTokenHash, &session.UserAgent,
+		&session.IPAddress, &session.ExpiresAt, &session.LastUsedAt, &revokedAt, &session.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+)
+
+type TwoFactorRepository interface {
+	GetByUserID(userID int) (*models.TwoFactor, error)
+	SavePending(userID int, secret string) error
+	Enable(userID int, step int64) error
+	UseStep(userID int, step int64) (bool, error)
+	Delete(userID int) error
+	ReplaceRecoveryCodes(userID int, codeHashes []string) error
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	CountRecoveryCodes(userID int) (int, error)
+}
+
+type twoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) TwoFactorRepository {
+	return &twoFactorRepository{db: db}
+}
+
+func (r *twoFactorRepository) GetByUserID(userID int) (*models.TwoFactor, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at, updated_at
+		FROM two_factor
+		WHERE user_id = $1`
+
+	twoFactor := &models.TwoFactor{}
+	var enabledAt sql.NullTime
+	var lastUsedStep sql.NullInt64
+	err := r.db.QueryRow(query, userID).Scan(
+		&twoFactor.UserID, &twoFactor.Secret, &enabledAt, &lastUsedStep,
+		&twoFactor.CreatedAt, &twoFactor.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+	if lastUsedStep.Valid {
+		twoFactor.LastUsedStep = &lastUsedStep.Int64
+	}
+	return twoFactor, nil
+}
+
+// SavePending stores a new secret for an enrollment that is not confirmed yet.
+// An enabled secret is never replaced.
+func (r *twoFactorRepository) SavePending(userID int, secret string) error {
+	query := `
+		INSERT INTO two_factor (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = NULL, updated_at = NOW()
+		WHERE two_factor.enabled_at IS NULL`
+
+	_, err := r.db.Exec(query, userID, secret)
+	return err
+}
+
+func (r *twoFactorRepository) Enable(userID int, step int64) error {
+	query := `
+		UPDATE two_factor
+		SET enabled_at = NOW(), last_used_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND enabled_at IS NULL`
+
+	result, err := r.db.Exec(query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UseStep records the time step of an accepted code. It fails for a step that
+// is not newer than the last one, so a code cannot be replayed.
+func (r *twoFactorRepository) UseStep(userID int, step int64) (bool, error) {
+	query := `
+		UPDATE two_factor
+		SET last_used_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`
+
+	result, err := r.db.Exec(query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// Delete turns two-factor authentication off and drops the recovery codes
+func (r *twoFactorRepository) Delete(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM two_factor WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, codeHash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE two_factor_recovery_codes
+		SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM two_factor_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)`
+
+	result, err := r.db.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *twoFactorRepository) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM two_factor_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
@@ -12,6 +12,7 @@ type UserRepository interface {
 	GetByID(id int) (*models.User, error)
 	Update(user *models.User) error
 	UpdatePassword(userID int, hashedPassword string) error
+	MarkVerified(userID int) error
 	List(limit, offset int) ([]*models.User, int, error)
 	Delete(id int) error
 }
@@ -24,18 +25,36 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+const userColumns = `id, email, password, role, name, phone, birth_date,
+	ust_bel, orta_bel, alt_bel, tax_number, tax_office, address, district, city,
+	verified_at, created_at, updated_at`
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
+		&user.ID, &user.Email, &user.Password, &user.Role,
+		&user.Name, &user.Phone, &user.BirthDate,
+		&user.UstBel, &user.OrtaBel, &user.AltBel,
+		&user.TaxNumber, &user.TaxOffice, &user.Address, &user.District, &user.City,
+		&user.VerifiedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+	return user, err
+}
+
 func (r *userRepository) Create(user *models.User) error {
 	// Simple query without fixed schema - uses TenantMiddleware's search_path
 
 	query := `
-		INSERT INTO users (email, password, role, name, phone, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO users (email, password, role, name, phone, tax_number, tax_office, address, district, city,
+			verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING id`
 
 	now := time.Now()
 	var id int
 	err := r.db.QueryRow(query, user.Email, user.Password, user.Role,
-		user.Name, user.Phone, now, now).Scan(&id)
+		user.Name, user.Phone, user.TaxNumber, user.TaxOffice, user.Address, user.District, user.City,
+		user.VerifiedAt, now, now).Scan(&id)
 	if err != nil {
 		return err
 	}
@@ -48,47 +67,25 @@ func (r *userRepository) Create(user *models.User) error {
 }
 
 func (r *userRepository) GetByEmail(email string) (*models.User, error) {
-	query := `
-		SELECT id, email, password, role, name, phone, birth_date, 
-			   ust_bel, orta_bel, alt_bel, created_at, updated_at
-		FROM users WHERE email = $1`
-
-	user := &models.User{}
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Email, &user.Password, &user.Role,
-		&user.Name, &user.Phone, &user.BirthDate,
-		&user.UstBel, &user.OrtaBel, &user.AltBel,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-	return user, err
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = $1`, email))
 }
 
 func (r *userRepository) GetByID(id int) (*models.User, error) {
-	query := `
-		SELECT id, email, password, role, name, phone, birth_date,
-			   ust_bel, orta_bel, alt_bel, created_at, updated_at
-		FROM users WHERE id = $1`
-
-	user := &models.User{}
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Email, &user.Password, &user.Role,
-		&user.Name, &user.Phone, &user.BirthDate,
-		&user.UstBel, &user.OrtaBel, &user.AltBel,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
-	return user, err
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
 }
 
 func (r *userRepository) Update(user *models.User) error {
 	query := `
 		UPDATE users 
 		SET name = $1, phone = $2, birth_date = $3, ust_bel = $4, 
-			orta_bel = $5, alt_bel = $6, updated_at = $7
-		WHERE id = $8`
+			orta_bel = $5, alt_bel = $6, tax_number = $7, tax_office = $8,
+			address = $9, district = $10, city = $11, updated_at = $12
+		WHERE id = $13`
 
 	user.UpdatedAt = time.Now()
 	_, err := r.db.Exec(query, user.Name, user.Phone, user.BirthDate,
-		user.UstBel, user.OrtaBel, user.AltBel, user.UpdatedAt, user.ID)
+		user.UstBel, user.OrtaBel, user.AltBel, user.TaxNumber, user.TaxOffice,
+		user.Address, user.District, user.City, user.UpdatedAt, user.ID)
 	return err
 }
 
@@ -98,6 +95,13 @@ func (r *userRepository) UpdatePassword(userID int, hashedPassword string) error
 	return err
 }
 
+// MarkVerified sets verified_at once, later calls keep the first time
+func (r *userRepository) MarkVerified(userID int) error {
+	query := `UPDATE users SET verified_at = COALESCE(verified_at, NOW()), updated_at = $1 WHERE id = $2`
+	_, err := r.db.Exec(query, time.Now(), userID)
+	return err
+}
+
 func (r *userRepository) List(limit, offset int) ([]*models.User, int, error) {
 	// Count total
 	var total int
@@ -109,8 +113,7 @@ func (r *userRepository) List(limit, offset int) ([]*models.User, int, error) {
 
 	// Get users
 	query := `
-		SELECT id, email, password, role, name, phone, birth_date,
-			   ust_bel, orta_bel, alt_bel, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
@@ -123,13 +126,7 @@ func (r *userRepository) List(limit, offset int) ([]*models.User, int, error) {
 
 	var users []*models.User
 	for rows.Next() {
-		user := &models.User{}
-		err := rows.Scan(
-			&user.ID, &user.Email, &user.Password, &user.Role,
-			&user.Name, &user.Phone, &user.BirthDate,
-			&user.UstBel, &user.OrtaBel, &user.AltBel,
-			&user.CreatedAt, &user.UpdatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, 0, err
 		}
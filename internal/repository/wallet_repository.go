@@ -0,0 +1,520 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"time"
+)
+
+type WalletRepository interface {
+	ExpireCredits(now time.Time) error
+	Credit(entry *models.WalletEntry) error
+	Debit(entry *models.WalletEntry, now time.Time) (bool, error)
+	Balances(userID int) ([]*models.WalletBalance, error)
+	ListEntries(userID, limit, offset int) ([]*models.WalletEntry, int, error)
+
+	CreateGiftCard(card *models.GiftCard) error
+	GetGiftCard(id int) (*models.GiftCard, error)
+	ListGiftCards(limit, offset int) ([]*models.GiftCard, int, error)
+	RedeemGiftCard(code string, userID int, now time.Time) (*models.GiftCard, *models.WalletEntry, error)
+
+	SummarizeLedger(filter models.WalletReportFilter) ([]*models.WalletReport, error)
+	SummarizeGiftCards(filter models.WalletReportFilter, now time.Time) ([]*models.WalletReport, error)
+	GetGiftCardSales(startDate, endDate time.Time, currency string) (*models.SalesTotal, error)
+	Reconcile(filter models.WalletReportFilter) ([]*models.WalletReconciliation, error)
+}
+
+type walletRepository struct {
+	db *sql.DB
+}
+
+func NewWalletRepository(db *sql.DB) WalletRepository {
+	return &walletRepository{db: db}
+}
+
+// ExpireCredits ends what is left of the credits that expired by now with an
+// expiry entry dated at the credit's expiry, so reports over past periods do
+// not depend on when this runs
+func (r *walletRepository) ExpireCredits(now time.Time) error {
+	query := `
+		WITH due AS (
+			SELECT id, user_id, remaining, currency, expires_at
+			FROM wallet_entries
+			WHERE remaining > 0 AND expires_at <= $1
+			FOR UPDATE
+		), expired AS (
+			UPDATE wallet_entries w SET remaining = 0
+			FROM due
+			WHERE w.id = due.id
+			RETURNING due.id, due.user_id, due.remaining, due.currency, due.expires_at
+		)
+		INSERT INTO wallet_entries (user_id, kind, amount, remaining, currency, credit_entry_id, actor_type, created_at)
+		SELECT user_id, 'expiry', -remaining, 0, currency, id, 'system', expires_at
+		FROM expired`
+
+	_, err := r.db.Exec(query, now)
+	return err
+}
+
+// Credit adds a credit to the wallet, spendable in full until it expires
+func (r *walletRepository) Credit(entry *models.WalletEntry) error {
+	entry.Remaining = entry.Amount
+	return insertWalletEntry(r.db, entry)
+}
+
+// Debit spends -entry.Amount from the wallet, using the credits that expire
+// first. The credits stay locked until the transaction ends, so concurrent
+// debits cannot spend the same credit. It reports false, without spending
+// anything, when the balance does not cover the amount.
+func (r *walletRepository) Debit(entry *models.WalletEntry, now time.Time) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, remaining FROM wallet_entries
+		WHERE user_id = $1 AND currency = $2 AND remaining > 0 AND (expires_at IS NULL OR expires_at > $3)
+		ORDER BY expires_at NULLS LAST, id
+		FOR UPDATE`,
+		entry.UserID, entry.Currency, now)
+	if err != nil {
+		return false, err
+	}
+
+	type credit struct {
+		id        int
+		remaining models.Money
+	}
+	var credits []credit
+	var available int64
+	for rows.Next() {
+		var c credit
+		if err := rows.Scan(&c.id, &c.remaining); err != nil {
+			rows.Close()
+			return false, err
+		}
+		credits = append(credits, c)
+		available += c.remaining.Amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	left := -entry.Amount.Amount
+	if left <= 0 || available < left {
+		return false, nil
+	}
+
+	for _, c := range credits {
+		if left == 0 {
+			break
+		}
+		spent := c.remaining.Amount
+		if spent > left {
+			spent = left
+		}
+		if _, err := tx.Exec(`UPDATE wallet_entries SET remaining = remaining - $2 WHERE id = $1`,
+			c.id, models.NewMoney(spent, entry.Currency)); err != nil {
+			return false, err
+		}
+		left -= spent
+	}
+
+	entry.Remaining = models.NewMoney(0, entry.Currency)
+	if err := insertWalletEntry(tx, entry); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// walletExecutor is what inserting a ledger entry needs from *sql.DB or *sql.Tx
+type walletExecutor interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func insertWalletEntry(db walletExecutor, entry *models.WalletEntry) error {
+	query := `
+		INSERT INTO wallet_entries (user_id, kind, amount, remaining, currency, expires_at, payment_id, refund_id,
+			gift_card_id, reason, actor_type, actor_id, actor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''))
+		RETURNING id, created_at`
+
+	return db.QueryRow(query,
+		entry.UserID,
+		entry.Kind,
+		entry.Amount,
+		entry.Remaining,
+		entry.Currency,
+		entry.ExpiresAt,
+		entry.PaymentID,
+		entry.RefundID,
+		entry.GiftCardID,
+		entry.Reason,
+		entry.ActorType,
+		entry.ActorID,
+		entry.Actor,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (r *walletRepository) Balances(userID int) ([]*models.WalletBalance, error) {
+	query := `
+		SELECT currency, SUM(amount)
+		FROM wallet_entries
+		WHERE user_id = $1
+		GROUP BY currency
+		ORDER BY currency`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []*models.WalletBalance
+	for rows.Next() {
+		balance := &models.WalletBalance{}
+		if err := rows.Scan(&balance.Currency, &balance.Balance); err != nil {
+			return nil, err
+		}
+		balance.Balance.Currency = balance.Currency
+		balances = append(balances, balance)
+	}
+
+	return balances, rows.Err()
+}
+
+func (r *walletRepository) ListEntries(userID, limit, offset int) ([]*models.WalletEntry, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM wallet_entries WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, user_id, kind, amount, remaining, currency, expires_at, payment_id, refund_id, gift_card_id,
+			credit_entry_id, reason, actor_type, actor_id, COALESCE(actor, ''), created_at
+		FROM wallet_entries
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []*models.WalletEntry
+	for rows.Next() {
+		entry := &models.WalletEntry{}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Kind,
+			&entry.Amount,
+			&entry.Remaining,
+			&entry.Currency,
+			&entry.ExpiresAt,
+			&entry.PaymentID,
+			&entry.RefundID,
+			&entry.GiftCardID,
+			&entry.CreditEntryID,
+			&entry.Reason,
+			&entry.ActorType,
+			&entry.ActorID,
+			&entry.Actor,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		entry.SetCurrency(entry.Currency)
+		entries = append(entries, entry)
+	}
+
+	return entries, total, rows.Err()
+}
+
+const giftCardColumns = `id, code, amount, currency, purchaser_id, recipient_name, recipient_email, message, payment_method,
+	expires_at, redeemed_by, redeemed_at, actor_type, actor_id, COALESCE(actor, ''), created_at`
+
+func (r *walletRepository) CreateGiftCard(card *models.GiftCard) error {
+	query := `
+		INSERT INTO gift_cards (code, amount, currency, purchaser_id, recipient_name, recipient_email, message,
+			payment_method, expires_at, actor_type, actor_id, actor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''))
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query,
+		card.Code,
+		card.Amount,
+		card.Currency,
+		card.PurchaserID,
+		card.RecipientName,
+		card.RecipientEmail,
+		card.Message,
+		card.PaymentMethod,
+		card.ExpiresAt,
+		card.ActorType,
+		card.ActorID,
+		card.Actor,
+	).Scan(&card.ID, &card.CreatedAt)
+}
+
+func (r *walletRepository) GetGiftCard(id int) (*models.GiftCard, error) {
+	return scanGiftCard(r.db.QueryRow(`SELECT `+giftCardColumns+` FROM gift_cards WHERE id = $1`, id))
+}
+
+func (r *walletRepository) ListGiftCards(limit, offset int) ([]*models.GiftCard, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM gift_cards`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + giftCardColumns + ` FROM gift_cards ORDER BY created_at DESC, id DESC LIMIT $1 OFFSET $2`
+	rows, err := r.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var cards []*models.GiftCard
+	for rows.Next() {
+		card, err := scanGiftCard(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, total, rows.Err()
+}
+
+// RedeemGiftCard marks the card redeemed by the user and credits its amount
+// to their wallet until the card's expiry. A card that was already redeemed
+// or has expired is returned without an entry and left unchanged.
+func (r *walletRepository) RedeemGiftCard(code string, userID int, now time.Time) (*models.GiftCard, *models.WalletEntry, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	card, err := scanGiftCard(tx.QueryRow(`SELECT `+giftCardColumns+` FROM gift_cards WHERE code = $1 FOR UPDATE`, code))
+	if err != nil {
+		return nil, nil, err
+	}
+	card.SetStatus(now)
+	if card.Status != models.GiftCardActive {
+		return card, nil, nil
+	}
+
+	if err := tx.QueryRow(`UPDATE gift_cards SET redeemed_by = $2, redeemed_at = $3 WHERE id = $1 RETURNING redeemed_at`,
+		card.ID, userID, now).Scan(&card.RedeemedAt); err != nil {
+		return nil, nil, err
+	}
+	card.RedeemedBy = &userID
+	card.SetStatus(now)
+
+	entry := &models.WalletEntry{
+		UserID:     userID,
+		Kind:       models.WalletEntryGiftCard,
+		Amount:     card.Amount,
+		Remaining:  card.Amount,
+		ExpiresAt:  card.ExpiresAt,
+		GiftCardID: &card.ID,
+		ActorType:  "system",
+	}
+	entry.SetCurrency(card.Currency)
+	if err := insertWalletEntry(tx, entry); err != nil {
+		return nil, nil, err
+	}
+
+	return card, entry, tx.Commit()
+}
+
+func scanGiftCard(row rowScanner) (*models.GiftCard, error) {
+	card := &models.GiftCard{}
+	err := row.Scan(
+		&card.ID,
+		&card.Code,
+		&card.Amount,
+		&card.Currency,
+		&card.PurchaserID,
+		&card.RecipientName,
+		&card.RecipientEmail,
+		&card.Message,
+		&card.PaymentMethod,
+		&card.ExpiresAt,
+		&card.RedeemedBy,
+		&card.RedeemedAt,
+		&card.ActorType,
+		&card.ActorID,
+		&card.Actor,
+		&card.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	card.Amount.Currency = card.Currency
+	return card, nil
+}
+
+// walletPeriod takes the report's From and To as $1 and $2
+func walletPeriod(column string) string {
+	return `($1::timestamptz IS NULL OR ` + column + ` >= $1) AND ($2::timestamptz IS NULL OR ` + column + ` < $2)`
+}
+
+// SummarizeLedger totals the ledger of each currency, the gift card and
+// reconciliation fields are left empty
+func (r *walletRepository) SummarizeLedger(filter models.WalletReportFilter) ([]*models.WalletReport, error) {
+	inPeriod := walletPeriod("created_at")
+	query := `
+		SELECT currency,
+			COALESCE(SUM(amount) FILTER (WHERE $1::timestamptz IS NOT NULL AND created_at < $1), 0),
+			COALESCE(SUM(amount) FILTER (WHERE ` + inPeriod + ` AND kind = 'gift_card'), 0),
+			COALESCE(SUM(amount) FILTER (WHERE ` + inPeriod + ` AND kind = 'refund'), 0),
+			COALESCE(SUM(amount) FILTER (WHERE ` + inPeriod + ` AND kind = 'adjustment' AND amount > 0), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE ` + inPeriod + ` AND kind = 'payment'), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE ` + inPeriod + ` AND kind = 'expiry'), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE ` + inPeriod + ` AND kind = 'adjustment' AND amount < 0), 0),
+			COALESCE(SUM(amount) FILTER (WHERE $2::timestamptz IS NULL OR created_at < $2), 0)
+		FROM wallet_entries
+		GROUP BY currency
+		ORDER BY currency`
+
+	rows, err := r.db.Query(query, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*models.WalletReport
+	for rows.Next() {
+		report := &models.WalletReport{}
+		err := rows.Scan(
+			&report.Currency,
+			&report.OpeningBalance,
+			&report.GiftCardCredits,
+			&report.RefundCredits,
+			&report.AdjustmentCredits,
+			&report.PaymentDebits,
+			&report.ExpiryDebits,
+			&report.AdjustmentDebits,
+			&report.ClosingBalance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		report.SetCurrency(report.Currency)
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// SummarizeGiftCards totals the gift cards of each currency sold and redeemed
+// in the period, and those outstanding at its end (or now without one); only
+// the gift card fields are set
+func (r *walletRepository) SummarizeGiftCards(filter models.WalletReportFilter, now time.Time) ([]*models.WalletReport, error) {
+	query := `
+		SELECT currency,
+			COALESCE(SUM(amount) FILTER (WHERE ` + walletPeriod("created_at") + `), 0),
+			COALESCE(SUM(amount) FILTER (WHERE redeemed_at IS NOT NULL AND ` + walletPeriod("redeemed_at") + `), 0),
+			COALESCE(SUM(amount) FILTER (WHERE created_at < COALESCE($2::timestamptz, $3)
+				AND (redeemed_at IS NULL OR redeemed_at >= COALESCE($2::timestamptz, $3))
+				AND (expires_at IS NULL OR expires_at > COALESCE($2::timestamptz, $3))), 0)
+		FROM gift_cards
+		GROUP BY currency
+		ORDER BY currency`
+
+	rows, err := r.db.Query(query, filter.From, filter.To, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*models.WalletReport
+	for rows.Next() {
+		report := &models.WalletReport{}
+		if err := rows.Scan(&report.Currency, &report.GiftCardsSold, &report.GiftCardsRedeemed, &report.GiftCardsOutstanding); err != nil {
+			return nil, err
+		}
+		report.SetCurrency(report.Currency)
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// GetGiftCardSales sums the gift cards sold in one currency
+func (r *walletRepository) GetGiftCardSales(startDate, endDate time.Time, currency string) (*models.SalesTotal, error) {
+	total := &models.SalesTotal{Amount: models.NewMoney(0, currency)}
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(amount), 0)
+		FROM gift_cards
+		WHERE currency = $1 AND created_at BETWEEN $2 AND $3`
+
+	err := r.db.QueryRow(query, currency, startDate, endDate).Scan(&total.Count, &total.Amount)
+	return total, err
+}
+
+// Reconcile lines up the wallet payments, refunds to wallets and gift card
+// redemptions of the period with the ledger entries recorded for them, plus
+// payment debits whose payment is gone or did not complete
+func (r *walletRepository) Reconcile(filter models.WalletReportFilter) ([]*models.WalletReconciliation, error) {
+	query := `
+		SELECT 'payment', p.id, NULL::int, p.currency, p.amount, COALESCE(-SUM(w.amount), 0)
+		FROM payments p
+		LEFT JOIN wallet_entries w ON w.payment_id = p.id AND w.kind = 'payment'
+		WHERE p.payment_method = 'wallet' AND p.status IN ('completed', 'refunded') AND ` + walletPeriod("p.created_at") + `
+		GROUP BY p.id, p.currency, p.amount
+		UNION ALL
+		SELECT 'payment', w.payment_id, w.id, w.currency, 0, -w.amount
+		FROM wallet_entries w
+		LEFT JOIN payments p ON p.id = w.payment_id
+		WHERE w.kind = 'payment' AND (p.id IS NULL OR p.status NOT IN ('completed', 'refunded')) AND ` + walletPeriod("w.created_at") + `
+		UNION ALL
+		SELECT 'refund', rf.id, NULL::int, rf.currency, rf.amount, COALESCE(SUM(w.amount), 0)
+		FROM refunds rf
+		LEFT JOIN wallet_entries w ON w.refund_id = rf.id AND w.kind = 'refund'
+		WHERE rf.to_wallet AND ` + walletPeriod("rf.created_at") + `
+		GROUP BY rf.id, rf.currency, rf.amount
+		UNION ALL
+		SELECT 'gift_card', g.id, NULL::int, g.currency, g.amount, COALESCE(SUM(w.amount), 0)
+		FROM gift_cards g
+		LEFT JOIN wallet_entries w ON w.gift_card_id = g.id AND w.kind = 'gift_card'
+		WHERE g.redeemed_at IS NOT NULL AND ` + walletPeriod("g.redeemed_at") + `
+		GROUP BY g.id, g.currency, g.amount
+		ORDER BY 1, 2`
+
+	rows, err := r.db.Query(query, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []*models.WalletReconciliation
+	for rows.Next() {
+		line := &models.WalletReconciliation{}
+		err := rows.Scan(
+			&line.Source,
+			&line.SourceID,
+			&line.EntryID,
+			&line.Currency,
+			&line.Expected,
+			&line.Recorded,
+		)
+		if err != nil {
+			return nil, err
+		}
+		line.Expected.Currency = line.Currency
+		line.Recorded.Currency = line.Currency
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
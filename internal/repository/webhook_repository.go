@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"appointment-api/internal/models"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(subscription *models.WebhookSubscription) error
+	GetSubscription(id int) (*models.WebhookSubscription, error)
+	ListSubscriptions() ([]*models.WebhookSubscription, error)
+	ListActiveForEvent(event models.WebhookEvent) ([]*models.WebhookSubscription, error)
+	UpdateSubscription(subscription *models.WebhookSubscription) error
+	DeleteSubscription(id int) error
+	EnqueueDelivery(delivery *models.WebhookDelivery) error
+	GetDelivery(id int) (*models.WebhookDelivery, error)
+	ClaimDue(limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	MarkDelivered(id int, responseStatus int, responseBody string) error
+	MarkFailed(id int, responseStatus *int, responseBody, lastError string, nextAttemptAt *time.Time) error
+	ListDeliveries(subscriptionID int, status models.WebhookDeliveryStatus, limit, offset int) ([]*models.WebhookDelivery, int, error)
+}
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+const webhookSubscriptionColumns = `id, url, secret, events, COALESCE(description, ''), active, created_at, updated_at`
+
+func (r *webhookRepository) CreateSubscription(subscription *models.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (url, secret, events, description, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(
+		query,
+		subscription.URL,
+		subscription.Secret,
+		joinWebhookEvents(subscription.Events),
+		subscription.Description,
+		subscription.Active,
+	).Scan(&subscription.ID, &subscription.CreatedAt, &subscription.UpdatedAt)
+}
+
+func (r *webhookRepository) GetSubscription(id int) (*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	return scanWebhookSubscription(r.db.QueryRow(query, id))
+}
+
+func (r *webhookRepository) ListSubscriptions() ([]*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookSubscriptions(rows)
+}
+
+func (r *webhookRepository) ListActiveForEvent(event models.WebhookEvent) ([]*models.WebhookSubscription, error) {
+	query := `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE active = true
+			AND ($1 = ANY(string_to_array(events, ',')) OR '*' = ANY(string_to_array(events, ',')))
+		ORDER BY id`
+
+	rows, err := r.db.Query(query, event)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookSubscriptions(rows)
+}
+
+func (r *webhookRepository) UpdateSubscription(subscription *models.WebhookSubscription) error {
+	query := `
+		UPDATE webhook_subscriptions
+		SET url = $1, secret = $2, events = $3, description = $4, active = $5, updated_at = NOW()
+		WHERE id = $6
+		RETURNING updated_at`
+
+	return r.db.QueryRow(
+		query,
+		subscription.URL,
+		subscription.Secret,
+		joinWebhookEvents(subscription.Events),
+		subscription.Description,
+		subscription.Active,
+		subscription.ID,
+	).Scan(&subscription.UpdatedAt)
+}
+
+func (r *webhookRepository) DeleteSubscription(id int) error {
+	result, err := r.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *webhookRepository) EnqueueDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event, event_id, payload, max_attempts)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, attempts, next_attempt_at, created_at`
+
+	return r.db.QueryRow(
+		query,
+		delivery.SubscriptionID,
+		delivery.Event,
+		delivery.EventID,
+		string(delivery.Payload),
+		delivery.MaxAttempts,
+	).Scan(&delivery.ID, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt)
+}
+
+func (r *webhookRepository) GetDelivery(id int) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return deliveries[0], nil
+}
+
+// ClaimDue leases due pending deliveries the same way the notification outbox
+// does: the attempt is counted and the next attempt pushed out by the lease.
+func (r *webhookRepository) ClaimDue(limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	rows, err := r.db.Query(query, limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+func (r *webhookRepository) MarkDelivered(id int, responseStatus int, responseBody string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', delivered_at = NOW(), last_error = NULL,
+			response_status = $2, response_body = $3
+		WHERE id = $1`
+
+	_, err := r.db.Exec(query, id, responseStatus, responseBody)
+	return err
+}
+
+// MarkFailed schedules a retry, or fails the delivery for good when nextAttemptAt is nil
+func (r *webhookRepository) MarkFailed(id int, responseStatus *int, responseBody, lastError string, nextAttemptAt *time.Time) error {
+	if nextAttemptAt == nil {
+		query := `
+			UPDATE webhook_deliveries
+			SET status = 'failed', last_error = $2, response_status = $3, response_body = $4
+			WHERE id = $1`
+		_, err := r.db.Exec(query, id, lastError, responseStatus, responseBody)
+		return err
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET last_error = $2, response_status = $3, response_body = $4, next_attempt_at = $5
+		WHERE id = $1`
+	_, err := r.db.Exec(query, id, lastError, responseStatus, responseBody, *nextAttemptAt)
+	return err
+}
+
+// ListDeliveries filters by subscription and status, zero values match everything
+func (r *webhookRepository) ListDeliveries(subscriptionID int, status models.WebhookDeliveryStatus, limit, offset int) ([]*models.WebhookDelivery, int, error) {
+	var total int
+	countQuery := `
+		SELECT COUNT(*) FROM webhook_deliveries
+		WHERE ($1 = 0 OR subscription_id = $1) AND ($2 = '' OR status = $2)`
+	if err := r.db.QueryRow(countQuery, subscriptionID, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE ($1 = 0 OR subscription_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.Query(query, subscriptionID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries, err := scanWebhookDeliveries(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+const webhookDeliveryColumns = `id, subscription_id, event, event_id, payload, status, attempts,
+	max_attempts, next_attempt_at, COALESCE(last_error, ''), response_status,
+	COALESCE(response_body, ''), delivered_at, created_at`
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	subscription := &models.WebhookSubscription{}
+	var events string
+	err := row.Scan(
+		&subscription.ID, &subscription.URL, &subscription.Secret, &events,
+		&subscription.Description, &subscription.Active, &subscription.CreatedAt, &subscription.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Events = splitWebhookEvents(events)
+	return subscription, nil
+}
+
+func scanWebhookSubscriptions(rows *sql.Rows) ([]*models.WebhookSubscription, error) {
+	var subscriptions []*models.WebhookSubscription
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		var payload string
+		var responseStatus sql.NullInt64
+		var deliveredAt sql.NullTime
+		err := rows.Scan(
+			&delivery.ID, &delivery.SubscriptionID, &delivery.Event, &delivery.EventID, &payload,
+			&delivery.Status, &delivery.Attempts, &delivery.MaxAttempts, &delivery.NextAttemptAt,
+			&delivery.LastError, &responseStatus, &delivery.ResponseBody, &deliveredAt, &delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = []byte(payload)
+		if responseStatus.Valid {
+			status := int(responseStatus.Int64)
+			delivery.ResponseStatus = &status
+		}
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Events are stored as a comma separated list
+func joinWebhookEvents(events []models.WebhookEvent) string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = string(event)
+	}
+	return strings.Join(names, ",")
+}
+
+func splitWebhookEvents(value string) []models.WebhookEvent {
+	events := []models.WebhookEvent{}
+	for _, name := range strings.Split(value, ",") {
+		if name != "" {
+			events = append(events, models.WebhookEvent(name))
+		}
+	}
+	return events
+}
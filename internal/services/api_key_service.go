@@ -0,0 +1,200 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// API keys look like "ak_<prefix>_<secret>". The prefix is stored in clear so a
+// key can be recognised in lists and logs; only the hash of the whole key is
+// kept, so the key itself is shown once on creation.
+const (
+	apiKeyMarker      = "ak_"
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+)
+
+type APIKeyService interface {
+	List() ([]*models.APIKey, error)
+	GetByID(id int) (*models.APIKey, error)
+	Create(req *models.CreateAPIKeyRequest, createdBy int) (*models.CreatedAPIKey, error)
+	Update(id int, req *models.UpdateAPIKeyRequest) (*models.APIKey, error)
+	Revoke(id int) error
+	Authenticate(key, ipAddress string) (*models.APIKey, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+// IsAPIKey reports whether a credential has the API key format, so callers can
+// tell keys and JWTs apart without a lookup
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyMarker)
+}
+
+func (s *apiKeyService) List() ([]*models.APIKey, error) {
+	keys, err := s.apiKeyRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+	return keys, nil
+}
+
+func (s *apiKeyService) GetByID(id int) (*models.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("api key not found")
+	}
+	return key, err
+}
+
+func (s *apiKeyService) Create(req *models.CreateAPIKeyRequest, createdBy int) (*models.CreatedAPIKey, error) {
+	scopes, err := normalizeAPIKeyScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	prefix, err := generateSecureToken(apiKeyPrefixBytes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := generateSecureToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, err
+	}
+	prefix = apiKeyMarker + prefix
+	plain := prefix + "_" + secret
+
+	key := &models.APIKey{
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		KeyHash:   hashToken(plain),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if createdBy > 0 {
+		key.CreatedBy = &createdBy
+	}
+
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, err
+	}
+	return &models.CreatedAPIKey{APIKey: *key, Key: plain}, nil
+}
+
+func (s *apiKeyService) Update(id int, req *models.UpdateAPIKeyRequest) (*models.APIKey, error) {
+	key, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("api key is revoked")
+	}
+
+	if req.Name != nil {
+		key.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Scopes != nil {
+		scopes, err := normalizeAPIKeyScopes(req.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		key.Scopes = scopes
+	}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, errors.New("expiry must be in the future")
+		}
+		key.ExpiresAt = req.ExpiresAt
+	}
+
+	if err := s.apiKeyRepo.Update(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *apiKeyService) Revoke(id int) error {
+	if err := s.apiKeyRepo.Revoke(id); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("api key not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate resolves an active key and records its use
+func (s *apiKeyService) Authenticate(plain, ipAddress string) (*models.APIKey, error) {
+	if !IsAPIKey(plain) {
+		return nil, errors.New("invalid api key")
+	}
+
+	key, err := s.apiKeyRepo.GetByHash(hashToken(plain))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invalid api key")
+		}
+		return nil, err
+	}
+	if !key.Active() {
+		return nil, errors.New("invalid api key")
+	}
+
+	if len(ipAddress) > 45 {
+		ipAddress = ipAddress[:45]
+	}
+	if err := s.apiKeyRepo.TouchLastUsed(key.ID, ipAddress); err != nil {
+		log.Printf("Warning: Failed to record API key usage: %v", err)
+	}
+	return key, nil
+}
+
+// normalizeAPIKeyScopes validates "<resource>:<read|write>" scopes and drops duplicates
+func normalizeAPIKeyScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		resource, access, ok := strings.Cut(scope, ":")
+		if !ok || !isAPIKeyResource(resource) || (access != models.APIKeyScopeRead && access != models.APIKeyScopeWrite) {
+			return nil, errors.New("invalid api key scope: " + scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
+
+func isAPIKeyResource(resource string) bool {
+	for _, r := range models.APIKeyResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,14 @@
 package services
 
 import (
+	"appointment-api/internal/config"
 	"appointment-api/internal/models"
 	"appointment-api/internal/repository"
 	"errors"
+	"log"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,16 +30,34 @@ type AppointmentService interface {
 }
 
 type appointmentService struct {
-	appointmentRepo repository.AppointmentRepository
-	serviceRepo     repository.ServiceRepository
-	specialistRepo  repository.SpecialistRepository
+	appointmentRepo      repository.AppointmentRepository
+	serviceRepo          repository.ServiceRepository
+	categoryRepo         repository.CategoryRepository
+	specialistRepo       repository.SpecialistRepository
+	settingsRepo         repository.SettingsRepository
+	externalCalendarRepo repository.ExternalCalendarRepository
+	promoCodeService     PromoCodeService
+	packageService       PackageService
+	notificationService  NotificationService
+	webhookService       WebhookService
+	location             *time.Location
+	defaultCurrency      string
 }
 
-func NewAppointmentService(appointmentRepo repository.AppointmentRepository, serviceRepo repository.ServiceRepository, specialistRepo repository.SpecialistRepository) AppointmentService {
+func NewAppointmentService(appointmentRepo repository.AppointmentRepository, serviceRepo repository.ServiceRepository, categoryRepo repository.CategoryRepository, specialistRepo repository.SpecialistRepository, settingsRepo repository.SettingsRepository, externalCalendarRepo repository.ExternalCalendarRepository, promoCodeService PromoCodeService, packageService PackageService, notificationService NotificationService, webhookService WebhookService, cfg *config.Config) AppointmentService {
 	return &appointmentService{
-		appointmentRepo: appointmentRepo,
-		serviceRepo:     serviceRepo,
-		specialistRepo:  specialistRepo,
+		appointmentRepo:      appointmentRepo,
+		serviceRepo:          serviceRepo,
+		categoryRepo:         categoryRepo,
+		specialistRepo:       specialistRepo,
+		settingsRepo:         settingsRepo,
+		externalCalendarRepo: externalCalendarRepo,
+		promoCodeService:     promoCodeService,
+		packageService:       packageService,
+		notificationService:  notificationService,
+		webhookService:       webhookService,
+		location:             loadLocation(cfg.Calendar.TimeZone),
+		defaultCurrency:      cfg.Payment.Currency,
 	}
 }
 
@@ -65,6 +88,9 @@ func (s *appointmentService) CreateFromRequest(req *models.CreateAppointmentRequ
 	if hasConflict {
 		return nil, errors.New("appointment time is already booked")
 	}
+	if err := s.checkExternalBusy(req.SpecialistID, req.AppointmentDate, req.AppointmentTime); err != nil {
+		return nil, err
+	}
 
 	// Check if appointment is in the past
 	appointmentDateTime := time.Date(
@@ -80,6 +106,32 @@ func (s *appointmentService) CreateFromRequest(req *models.CreateAppointmentRequ
 		return nil, errors.New("appointment cannot be in the past")
 	}
 
+	currency := tenantCurrency(s.settingsRepo, s.defaultCurrency)
+	price := models.NewMoney(service.Price.Amount, currency)
+
+	// A package covering the service pays for the booking with one of its sessions
+	customerPackage, err := s.packageService.FindSession(userID, service, appointmentDateTime)
+	if err != nil {
+		return nil, err
+	}
+	if customerPackage != nil && req.PromoCode != "" {
+		return nil, errors.New("promo code cannot be used with a package session")
+	}
+
+	var promo *models.PromoCode
+	var discount models.Money
+	if req.PromoCode != "" {
+		promo, discount, err = s.promoCodeService.Quote(req.PromoCode, service, price, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Prices are net or gross by the tenant's prices_include_tax setting, the
+	// discount comes off the price before VAT is worked out
+	taxRate := serviceTaxRate(service, s.categoryRepo, s.settingsRepo)
+	net, tax, gross := addTax(price.Sub(discount), taxRate, pricesIncludeTax(s.settingsRepo))
+
 	// Create appointment
 	appointment := &models.Appointment{
 		UserID:          userID,
@@ -89,15 +141,53 @@ func (s *appointmentService) CreateFromRequest(req *models.CreateAppointmentRequ
 		AppointmentTime: req.AppointmentTime,
 		Status:          models.StatusPending,
 		PaymentStatus:   models.PaymentPending,
-		TotalAmount:     service.Price,
+		TotalAmount:     gross,
+		DiscountAmount:  discount,
+		TaxRate:         taxRate,
+		NetAmount:       net,
+		TaxAmount:       tax,
 		Notes:           req.Notes,
 	}
+	if promo != nil {
+		appointment.PromoCode = promo.Code
+	}
+	if customerPackage != nil {
+		appointment.TotalAmount = models.Money{}
+		appointment.NetAmount = models.Money{}
+		appointment.TaxAmount = models.Money{}
+		appointment.PaymentStatus = models.PaymentCompleted
+		appointment.CustomerPackageID = &customerPackage.ID
+	}
+	appointment.SetCurrency(currency)
 
 	err = s.appointmentRepo.Create(appointment)
 	if err != nil {
 		return nil, err
 	}
 
+	// A concurrent booking may have used up the code since it was quoted
+	if promo != nil {
+		if err := s.promoCodeService.Redeem(promo, appointment); err != nil {
+			if deleteErr := s.appointmentRepo.Delete(appointment.ID); deleteErr != nil {
+				log.Printf("Warning: failed to remove appointment %d after its promo code was rejected: %v", appointment.ID, deleteErr)
+			}
+			return nil, err
+		}
+	}
+
+	// Likewise a concurrent booking may have used the package's last session
+	if customerPackage != nil {
+		if err := s.packageService.ConsumeSession(customerPackage.ID, appointment.ID, appointmentDateTime); err != nil {
+			if deleteErr := s.appointmentRepo.Delete(appointment.ID); deleteErr != nil {
+				log.Printf("Warning: failed to remove appointment %d after its package session was rejected: %v", appointment.ID, deleteErr)
+			}
+			return nil, err
+		}
+	}
+
+	s.notify(models.NotificationAppointmentCreated, appointment.ID)
+	s.publish(models.WebhookAppointmentCreated, appointment.ID, "")
+
 	return appointment, nil
 }
 
@@ -121,6 +211,9 @@ func (s *appointmentService) Create(appointment *models.Appointment) error {
 	if hasConflict {
 		return errors.New("appointment time is already booked")
 	}
+	if err := s.checkExternalBusy(appointment.SpecialistID, appointment.AppointmentDate, appointment.AppointmentTime); err != nil {
+		return err
+	}
 
 	// Set default status if not provided
 	if appointment.Status == "" {
@@ -129,8 +222,49 @@ func (s *appointmentService) Create(appointment *models.Appointment) error {
 	if appointment.PaymentStatus == "" {
 		appointment.PaymentStatus = models.PaymentPending
 	}
+	appointment.SetCurrency(tenantCurrency(s.settingsRepo, s.defaultCurrency))
+	// Staff enter what the customer pays, so the total is taken tax inclusive
+	setAppointmentTax(appointment, s.taxRate(appointment.ServiceID))
+
+	if err := s.appointmentRepo.Create(appointment); err != nil {
+		return err
+	}
+
+	s.notify(models.NotificationAppointmentCreated, appointment.ID)
+	s.publish(models.WebhookAppointmentCreated, appointment.ID, "")
+	return nil
+}
 
-	return s.appointmentRepo.Create(appointment)
+// taxRate is the VAT rate of the service, the tenant's tax_rate for unknown services
+func (s *appointmentService) taxRate(serviceID int) float64 {
+	service, err := s.serviceRepo.GetByID(serviceID)
+	if err != nil {
+		return tenantTaxRate(s.settingsRepo)
+	}
+	return serviceTaxRate(service, s.categoryRepo, s.settingsRepo)
+}
+
+// checkExternalBusy rejects times blocked by the specialist's external calendars
+func (s *appointmentService) checkExternalBusy(specialistID int, appointmentDate, appointmentTime time.Time) error {
+	start := time.Date(
+		appointmentDate.Year(),
+		appointmentDate.Month(),
+		appointmentDate.Day(),
+		appointmentTime.Hour(),
+		appointmentTime.Minute(),
+		0, 0,
+		s.location,
+	)
+	end := start.Add(time.Duration(getAppointmentDuration(s.settingsRepo)) * time.Minute)
+
+	isBusy, err := s.externalCalendarRepo.HasBusyBlock(specialistID, start, end)
+	if err != nil {
+		return err
+	}
+	if isBusy {
+		return errors.New("specialist is not available at this time")
+	}
+	return nil
 }
 
 func (s *appointmentService) GetByID(id int) (*models.Appointment, error) {
@@ -169,9 +303,59 @@ func (s *appointmentService) Update(appointment *models.Appointment) error {
 		if hasConflict {
 			return errors.New("appointment time is already booked")
 		}
+		if err := s.checkExternalBusy(appointment.SpecialistID, appointment.AppointmentDate, appointment.AppointmentTime); err != nil {
+			return err
+		}
+	}
+
+	if appointment.ServiceID != existing.ServiceID {
+		if err := s.repriceForService(appointment, existing); err != nil {
+			return err
+		}
+	} else {
+		setAppointmentTax(appointment, existing.TaxRate)
 	}
 
-	return s.appointmentRepo.Update(appointment)
+	if err := s.appointmentRepo.Update(appointment); err != nil {
+		return err
+	}
+
+	if appointment.Status == models.StatusCancelled && existing.Status != models.StatusCancelled {
+		s.restoreSession(appointment.ID)
+	}
+	if appointment.Status != "" && existing.Status != appointment.Status {
+		s.publish(models.WebhookAppointmentStatusChanged, appointment.ID, existing.Status)
+	}
+	return nil
+}
+
+// repriceForService prices an appointment moved to another service at that
+// service's price and VAT rate, as a new booking would be. Package sessions,
+// promo discounts and payments were taken for the old service, so those
+// appointments have to be cancelled and booked again instead.
+func (s *appointmentService) repriceForService(appointment, existing *models.Appointment) error {
+	if existing.CustomerPackageID != nil || existing.PromoCode != "" ||
+		(existing.PaymentStatus != models.PaymentPending && existing.PaymentStatus != models.PaymentFailed) {
+		return errors.New("cannot change the service of a paid, discounted or package appointment, cancel it and book again")
+	}
+
+	service, err := s.serviceRepo.GetByID(appointment.ServiceID)
+	if err != nil {
+		return errors.New("service not found")
+	}
+	if !service.Active {
+		return errors.New("service is not active")
+	}
+
+	currency := existing.Currency
+	taxRate := serviceTaxRate(service, s.categoryRepo, s.settingsRepo)
+	net, tax, gross := addTax(models.NewMoney(service.Price.Amount, currency), taxRate, pricesIncludeTax(s.settingsRepo))
+	appointment.TotalAmount = gross
+	appointment.TaxRate = taxRate
+	appointment.NetAmount = net
+	appointment.TaxAmount = tax
+	appointment.SetCurrency(currency)
+	return nil
 }
 
 func (s *appointmentService) Cancel(id int, userID int) error {
@@ -197,7 +381,14 @@ func (s *appointmentService) Cancel(id int, userID int) error {
 		return errors.New("cannot cancel completed appointment")
 	}
 
-	return s.appointmentRepo.UpdateStatus(id, models.StatusCancelled)
+	if err := s.appointmentRepo.UpdateStatus(id, models.StatusCancelled); err != nil {
+		return err
+	}
+	s.restoreSession(id)
+
+	s.notify(models.NotificationAppointmentCancelled, id)
+	s.publish(models.WebhookAppointmentStatusChanged, id, appointment.Status)
+	return nil
 }
 
 func (s *appointmentService) GetByUserID(userID int) ([]*models.Appointment, error) {
@@ -228,7 +419,7 @@ func (s *appointmentService) UpdateStatus(id int, status models.AppointmentStatu
 	}
 
 	// Check if appointment exists
-	_, err := s.appointmentRepo.GetByID(id)
+	existing, err := s.appointmentRepo.GetByID(id)
 	if err != nil {
 		return errors.New("appointment not found")
 	}
@@ -244,8 +435,83 @@ func (s *appointmentService) UpdateStatus(id int, status models.AppointmentStatu
 	if !validStatuses[status] {
 		return errors.New("invalid status")
 	}
+	if status == models.StatusConfirmed && existing.Status != models.StatusConfirmed {
+		if err := checkDepositPaid(s.settingsRepo, existing); err != nil {
+			return err
+		}
+	}
 
-	return s.appointmentRepo.UpdateStatus(id, status)
+	if err := s.appointmentRepo.UpdateStatus(id, status); err != nil {
+		return err
+	}
+
+	if existing.Status != status {
+		if status == models.StatusCancelled {
+			s.restoreSession(id)
+			s.notify(models.NotificationAppointmentCancelled, id)
+		} else {
+			s.notify(models.NotificationAppointmentStatusChanged, id)
+		}
+		s.publish(models.WebhookAppointmentStatusChanged, id, existing.Status)
+	}
+	return nil
+}
+
+// depositAmount is the part of the total the tenant's deposit_percentage
+// setting requires before a booking is confirmed, zero when none is required
+func depositAmount(settingsRepo repository.SettingsRepository, appointment *models.Appointment) models.Money {
+	none := models.NewMoney(0, appointment.Currency)
+	setting, err := settingsRepo.GetByKey("deposit_percentage")
+	if err != nil {
+		return none
+	}
+	percentage, err := strconv.ParseFloat(strings.TrimSpace(setting.Value), 64)
+	if err != nil || percentage <= 0 {
+		return none
+	}
+	return appointment.TotalAmount.Percent(math.Min(percentage, 100))
+}
+
+// checkDepositPaid is the one check every way of confirming a booking goes
+// through: staff, the reminder link and the payment that covers the deposit
+func checkDepositPaid(settingsRepo repository.SettingsRepository, appointment *models.Appointment) error {
+	if appointment.PaidAmount.Amount < depositAmount(settingsRepo, appointment).Amount {
+		return errors.New("deposit has not been paid")
+	}
+	return nil
+}
+
+// restoreSession gives back the package session a cancelled appointment used.
+// Failures never fail the cancellation, staff can adjust the balance instead.
+func (s *appointmentService) restoreSession(appointmentID int) {
+	if err := s.packageService.RestoreSession(appointmentID); err != nil {
+		log.Printf("Warning: failed to restore package session of appointment %d: %v", appointmentID, err)
+	}
+}
+
+// notify queues a customer notification, failures never fail the appointment change
+func (s *appointmentService) notify(event models.NotificationEvent, appointmentID int) {
+	if err := s.notificationService.NotifyAppointment(event, appointmentID); err != nil {
+		log.Printf("Warning: failed to queue %s notification for appointment %d: %v", event, appointmentID, err)
+	}
+}
+
+// publish queues the appointment webhooks, previousStatus is set for status changes.
+// Failures never fail the appointment change.
+func (s *appointmentService) publish(event models.WebhookEvent, appointmentID int, previousStatus models.AppointmentStatus) {
+	appointment, err := s.appointmentRepo.GetByID(appointmentID)
+	if err != nil {
+		log.Printf("Warning: failed to load appointment %d for %s webhook: %v", appointmentID, event, err)
+		return
+	}
+
+	data := map[string]interface{}{"appointment": appointment}
+	if previousStatus != "" {
+		data["previous_status"] = previousStatus
+	}
+	if err := s.webhookService.Publish(event, data); err != nil {
+		log.Printf("Warning: failed to queue %s webhook for appointment %d: %v", event, appointmentID, err)
+	}
 }
 
 func (s *appointmentService) Delete(id int) error {
@@ -254,11 +520,15 @@ func (s *appointmentService) Delete(id int) error {
 	}
 
 	// Check if appointment exists
-	_, err := s.appointmentRepo.GetByID(id)
+	existing, err := s.appointmentRepo.GetByID(id)
 	if err != nil {
 		return errors.New("appointment not found")
 	}
 
+	// A session is only kept for appointments that took place
+	if existing.Status != models.StatusCompleted {
+		s.restoreSession(id)
+	}
 	return s.appointmentRepo.Delete(id)
 }
 
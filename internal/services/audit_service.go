@@ -0,0 +1,233 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Audit export formats
+const (
+	AuditExportCSV  = "csv"
+	AuditExportJSON = "json"
+)
+
+// Timestamps change on every write and would only add noise to the diff
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// Secrets are logged as changed without their value
+var auditSecretFields = map[string]bool{
+	"secret": true,
+}
+
+const auditRedacted = "[redacted]"
+
+type AuditService interface {
+	Record(entry *models.AuditLogEntry, before, after interface{}) error
+	List(filter models.AuditLogFilter, limit, offset int) ([]*models.AuditLogEntry, int, error)
+	Export(filter models.AuditLogFilter, format string, w io.Writer) error
+}
+
+type auditService struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository) AuditService {
+	return &auditService{
+		auditRepo: auditRepo,
+	}
+}
+
+// Record stores an entry with the diff between the JSON forms of before and
+// after. Either may be nil, for creates and deletes.
+func (s *auditService) Record(entry *models.AuditLogEntry, before, after interface{}) error {
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	entry.Changes = changes
+
+	if len(entry.UserAgent) > 255 {
+		entry.UserAgent = entry.UserAgent[:255]
+	}
+	if len(entry.IPAddress) > 45 {
+		entry.IPAddress = entry.IPAddress[:45]
+	}
+	return s.auditRepo.Create(entry)
+}
+
+func (s *auditService) List(filter models.AuditLogFilter, limit, offset int) ([]*models.AuditLogEntry, int, error) {
+	if err := validateAuditFilter(filter); err != nil {
+		return nil, 0, err
+	}
+
+	entries, total, err := s.auditRepo.List(filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if entries == nil {
+		entries = []*models.AuditLogEntry{}
+	}
+	return entries, total, nil
+}
+
+// Export writes all matching entries, oldest first, as CSV or a JSON array
+func (s *auditService) Export(filter models.AuditLogFilter, format string, w io.Writer) error {
+	if err := validateAuditFilter(filter); err != nil {
+		return err
+	}
+
+	switch format {
+	case AuditExportCSV:
+		return s.exportCSV(filter, w)
+	case AuditExportJSON:
+		return s.exportJSON(filter, w)
+	default:
+		return errors.New("invalid export format")
+	}
+}
+
+func (s *auditService) exportCSV(filter models.AuditLogFilter, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{
+		"id", "created_at", "tenant_id", "actor_type", "actor_id", "actor", "entity_type",
+		"entity_id", "action", "changes", "ip_address", "user_agent",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	err := s.auditRepo.Each(filter, func(entry *models.AuditLogEntry) error {
+		changes, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return err
+		}
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = strconv.Itoa(*entry.ActorID)
+		}
+		return writer.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.Itoa(entry.TenantID),
+			entry.ActorType,
+			actorID,
+			entry.Actor,
+			entry.EntityType,
+			entry.EntityID,
+			entry.Action,
+			string(changes),
+			entry.IPAddress,
+			entry.UserAgent,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *auditService) exportJSON(filter models.AuditLogFilter, w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := s.auditRepo.Each(filter, func(entry *models.AuditLogEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+func validateAuditFilter(filter models.AuditLogFilter) error {
+	if filter.ActorType != "" && filter.ActorType != models.AuditActorUser && filter.ActorType != models.AuditActorAPIKey {
+		return errors.New("invalid actor type")
+	}
+	return nil
+}
+
+// auditDiff compares the top-level JSON fields of two values and returns the
+// ones that differ
+func auditDiff(before, after interface{}) (map[string]models.AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]models.AuditChange)
+	for field, value := range beforeFields {
+		if auditIgnoredFields[field] {
+			continue
+		}
+		if afterValue, ok := afterFields[field]; !ok || !reflect.DeepEqual(value, afterValue) {
+			changes[field] = models.AuditChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok && !auditIgnoredFields[field] {
+			changes[field] = models.AuditChange{After: value}
+		}
+	}
+	return changes, nil
+}
+
+func auditFields(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// Not an object, e.g. a list of working hours
+		var whole interface{}
+		if err := json.Unmarshal(data, &whole); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"value": whole}, nil
+	}
+	for field := range auditSecretFields {
+		if value, ok := fields[field]; ok && value != "" {
+			fields[field] = auditRedacted
+		}
+	}
+	return fields, nil
+}
@@ -7,7 +7,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strconv"
+	"log"
 	"strings"
 	"time"
 
@@ -16,28 +16,65 @@ import (
 )
 
 type AuthService interface {
-	Register(req *models.RegisterRequest) (*models.AuthResponse, error)
-	Login(req *models.LoginRequest) (*models.AuthResponse, error)
-	ValidateToken(tokenString string) (*models.User, error)
-	ChangePassword(userID int, currentPassword, newPassword string) error
+	Register(req *models.RegisterRequest, client models.ClientInfo) (*models.AuthResponse, error)
+	Login(req *models.LoginRequest, client models.ClientInfo) (*models.AuthResponse, *models.TwoFactorChallenge, error)
+	AdminLogin(req *models.LoginRequest, client models.ClientInfo) (*models.AuthResponse, *models.TwoFactorChallenge, error)
+	SetupTwoFactorLogin(challengeToken, issuer string, tenantID int) (*models.TwoFactorSetup, error)
+	VerifyTwoFactorLogin(req *models.TwoFactorChallengeRequest, client models.ClientInfo) (*models.AuthResponse, error)
+	Refresh(refreshToken string, client models.ClientInfo) (*models.AuthResponse, error)
+	ValidateToken(tokenString string, tenantID int) (*models.User, *models.Session, error)
+	Logout(userID, sessionID int) error
+	LogoutAll(userID int) error
+	ListSessions(userID, currentSessionID int) ([]*models.Session, error)
+	RevokeSession(userID, sessionID int) error
+	ChangePassword(userID, sessionID int, currentPassword, newPassword string) error
 	UpdateProfile(user *models.User) (*models.User, error)
 	ForgotPassword(email string) error
 	ResetPassword(token, newPassword string) error
+	VerifyEmail(token string) (*models.User, error)
+	ResendVerification(userID int) error
 }
 
+const (
+	// A verification email can be resent once per interval, and at most
+	// emailVerificationHourlyLimit times an hour
+	emailVerificationResendInterval = time.Minute
+	emailVerificationHourlyLimit    = 5
+
+	// Time to complete the second step of a two-factor login
+	twoFactorChallengeTTL = 5 * time.Minute
+	// Appended to the configured audience for two-factor challenge tokens, so
+	// they are never accepted as access tokens
+	twoFactorChallengeAudience = "/two-factor"
+)
+
 type authService struct {
-	userRepo repository.UserRepository
-	config   *config.Config
+	userRepo            repository.UserRepository
+	passwordResetRepo   repository.PasswordResetRepository
+	verificationRepo    repository.EmailVerificationRepository
+	sessionRepo         repository.SessionRepository
+	loginAttemptRepo    repository.LoginAttemptRepository
+	twoFactorService    TwoFactorService
+	notificationService NotificationService
+	webhookService      WebhookService
+	config              *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepository, cfg *config.Config) AuthService {
+func NewAuthService(userRepo repository.UserRepository, passwordResetRepo repository.PasswordResetRepository, verificationRepo repository.EmailVerificationRepository, sessionRepo repository.SessionRepository, loginAttemptRepo repository.LoginAttemptRepository, twoFactorService TwoFactorService, notificationService NotificationService, webhookService WebhookService, cfg *config.Config) AuthService {
 	return &authService{
-		userRepo: userRepo,
-		config:   cfg,
+		userRepo:            userRepo,
+		passwordResetRepo:   passwordResetRepo,
+		verificationRepo:    verificationRepo,
+		sessionRepo:         sessionRepo,
+		loginAttemptRepo:    loginAttemptRepo,
+		twoFactorService:    twoFactorService,
+		notificationService: notificationService,
+		webhookService:      webhookService,
+		config:              cfg,
 	}
 }
 
-func (s *authService) Register(req *models.RegisterRequest) (*models.AuthResponse, error) {
+func (s *authService) Register(req *models.RegisterRequest, client models.ClientInfo) (*models.AuthResponse, error) {
 	// Check if user exists
 	existingUser, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil && err != sql.ErrNoRows {
@@ -66,75 +103,257 @@ func (s *authService) Register(req *models.RegisterRequest) (*models.AuthRespons
 		return nil, err
 	}
 
-	// Generate token
-	token, err := s.generateToken(user)
-	if err != nil {
-		return nil, err
+	s.notify(models.NotificationUserRegistered, user, nil)
+	if err := s.webhookService.Publish(models.WebhookUserRegistered, map[string]interface{}{"user": user}); err != nil {
+		log.Printf("Warning: failed to queue %s webhook for user %d: %v", models.WebhookUserRegistered, user.ID, err)
+	}
+	if err := s.sendVerification(user); err != nil {
+		log.Printf("Warning: failed to send email verification for user %d: %v", user.ID, err)
 	}
 
-	return &models.AuthResponse{
-		Token: token,
-		User:  *user,
-	}, nil
+	return s.startSession(user, client)
 }
 
-func (s *authService) Login(req *models.LoginRequest) (*models.AuthResponse, error) {
+func (s *authService) Login(req *models.LoginRequest, client models.ClientInfo) (*models.AuthResponse, *models.TwoFactorChallenge, error) {
+	return s.login(req, client, false)
+}
+
+// AdminLogin only accepts staff accounts
+func (s *authService) AdminLogin(req *models.LoginRequest, client models.ClientInfo) (*models.AuthResponse, *models.TwoFactorChallenge, error) {
+	return s.login(req, client, true)
+}
+
+// login checks the throttling state before the password, so a locked account
+// cannot be probed. Unknown emails are throttled like existing ones. Users with
+// two-factor authentication, or staff of a tenant requiring it, get a
+// challenge instead of a session.
+func (s *authService) login(req *models.LoginRequest, client models.ClientInfo, staffOnly bool) (*models.AuthResponse, *models.TwoFactorChallenge, error) {
+	email := normalizeLoginEmail(req.Email)
+	if err := s.checkLogin(email, client); err != nil {
+		return nil, nil, err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("invalid credentials")
+			s.loginFailed(email, nil, client)
+			return nil, nil, errors.New("invalid credentials")
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		s.loginFailed(email, &user.ID, client)
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	if staffOnly && !user.Role.IsStaff() {
+		return nil, nil, errors.New("user is not an admin")
+	}
+
+	enabled, err := s.twoFactorService.Enabled(user.ID)
+	if err != nil {
+		return nil, nil, err
 	}
+	if enabled || s.twoFactorService.Required(user) {
+		challenge, err := s.twoFactorChallenge(user, !enabled, client.TenantID)
+		return nil, challenge, err
+	}
+
+	s.recordLogin(email, &user.ID, client, models.LoginSucceeded)
+	response, err := s.startSession(user, client)
+	return response, nil, err
+}
 
-	// Generate token
-	token, err := s.generateToken(user)
+// SetupTwoFactorLogin starts enrollment for staff who must use two-factor
+// authentication but have not enrolled yet
+func (s *authService) SetupTwoFactorLogin(challengeToken, issuer string, tenantID int) (*models.TwoFactorSetup, error) {
+	userID, setup, err := s.parseTwoFactorChallenge(challengeToken, tenantID)
 	if err != nil {
 		return nil, err
 	}
+	if !setup {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
 
-	return &models.AuthResponse{
-		Token: token,
-		User:  *user,
-	}, nil
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invalid or expired challenge")
+		}
+		return nil, err
+	}
+
+	return s.twoFactorService.Setup(user, issuer)
 }
 
-func (s *authService) ValidateToken(tokenString string) (*models.User, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// VerifyTwoFactorLogin completes a challenged login with an authenticator or
+// recovery code. For an enrollment challenge the code confirms the new secret
+// and the response carries the recovery codes. Wrong codes count as failed logins.
+func (s *authService) VerifyTwoFactorLogin(req *models.TwoFactorChallengeRequest, client models.ClientInfo) (*models.AuthResponse, error) {
+	userID, setup, err := s.parseTwoFactorChallenge(req.ChallengeToken, client.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invalid or expired challenge")
 		}
-		return []byte(s.config.JWT.Secret), nil
-	})
+		return nil, err
+	}
 
+	email := normalizeLoginEmail(user.Email)
+	if err := s.checkLogin(email, client); err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.twoFactorService.Enabled(user.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		var userID int
-		switch v := claims["user_id"].(type) {
-		case float64:
-			userID = int(v)
-		case int:
-			userID = v
-		default:
-			return nil, errors.New("invalid user_id in token")
+	var recoveryCodes []string
+	switch {
+	case enabled:
+		err = s.twoFactorService.Verify(user.ID, req.Code)
+	case setup:
+		recoveryCodes, err = s.twoFactorService.Enable(user.ID, req.Code)
+	default:
+		return nil, errors.New("invalid or expired challenge")
+	}
+	if err != nil {
+		if err.Error() == "invalid two-factor code" {
+			s.loginFailed(email, &user.ID, client)
 		}
+		return nil, err
+	}
 
-		return s.userRepo.GetByID(userID)
+	s.recordLogin(email, &user.ID, client, models.LoginSucceeded)
+	response, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+// Refresh rotates a session's refresh token and issues a new access token.
+// A refresh token that was already rotated away has leaked or been replayed,
+// so its session is revoked.
+func (s *authService) Refresh(refreshToken string, client models.ClientInfo) (*models.AuthResponse, error) {
+	tokenHash := hashToken(strings.TrimSpace(refreshToken))
+
+	newRefreshToken, err := generateSecureToken(32)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("invalid token")
+	session, err := s.sessionRepo.Rotate(tokenHash, hashToken(newRefreshToken), sessionClient(client))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		reused, err := s.sessionRepo.RevokeByPreviousToken(tokenHash)
+		if err != nil {
+			return nil, err
+		}
+		if reused {
+			log.Printf("Warning: refresh token reused, session revoked (ip %s)", client.IPAddress)
+		}
+		return nil, errors.New("invalid refresh token")
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invalid refresh token")
+		}
+		return nil, err
+	}
+
+	return s.authResponse(user, session, newRefreshToken, client.TenantID)
 }
 
-func (s *authService) ChangePassword(userID int, currentPassword, newPassword string) error {
+// ValidateToken checks an access token and returns its user and session. The
+// token must be issued for tenantID, the session must still be active, and the
+// role claim must match the stored role so role changes apply to tokens issued
+// before them.
+func (s *authService) ValidateToken(tokenString string, tenantID int) (*models.User, *models.Session, error) {
+	claims, err := s.parseToken(tokenString, s.config.JWT.Audience, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userID, ok := intClaim(claims, "user_id")
+	if !ok {
+		return nil, nil, errors.New("invalid user_id in token")
+	}
+	sessionID, ok := intClaim(claims, "sid")
+	if !ok {
+		return nil, nil, errors.New("invalid session in token")
+	}
+
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, errors.New("session not found")
+		}
+		return nil, nil, err
+	}
+	if session.UserID != userID || !session.Active() {
+		return nil, nil, errors.New("session has been revoked")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if role, _ := claims["role"].(string); role != string(user.Role) {
+		return nil, nil, errors.New("token role is outdated")
+	}
+
+	return user, session, nil
+}
+
+func (s *authService) Logout(userID, sessionID int) error {
+	if err := s.sessionRepo.Revoke(sessionID, userID); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+func (s *authService) LogoutAll(userID int) error {
+	return s.sessionRepo.RevokeAllForUser(userID, 0)
+}
+
+func (s *authService) ListSessions(userID, currentSessionID int) ([]*models.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *authService) RevokeSession(userID, sessionID int) error {
+	if err := s.sessionRepo.Revoke(sessionID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("session not found")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *authService) ChangePassword(userID, sessionID int, currentPassword, newPassword string) error {
 	// Get user
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
@@ -152,22 +371,162 @@ func (s *authService) ChangePassword(userID int, currentPassword, newPassword st
 		return err
 	}
 
-	// Update password
-	return s.userRepo.UpdatePassword(userID, string(hashedPassword))
+	// Keep the session the password was changed from
+	return s.updatePassword(userID, string(hashedPassword), sessionID)
+}
+
+// updatePassword stores the new hash, invalidates outstanding reset tokens and
+// revokes every session except keepSessionID (0 revokes all)
+func (s *authService) updatePassword(userID int, hashedPassword string, keepSessionID int) error {
+	if err := s.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
+		return err
+	}
+	if err := s.passwordResetRepo.InvalidateForUser(userID); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeAllForUser(userID, keepSessionID)
 }
 
-func (s *authService) generateToken(user *models.User) (string, error) {
+// twoFactorChallenge signs a short-lived token naming the user whose password
+// was checked. Its audience differs from access tokens, so ValidateToken never
+// accepts it.
+func (s *authService) twoFactorChallenge(user *models.User, setupRequired bool, tenantID int) (*models.TwoFactorChallenge, error) {
+	expiresAt := time.Now().Add(twoFactorChallengeTTL)
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
+		"tenant":  tenantID,
+		"aud":     s.config.JWT.Audience + twoFactorChallengeAudience,
+		"setup":   setupRequired,
+		"iat":     time.Now().Unix(),
+		"exp":     expiresAt.Unix(),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWT.Secret))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+		SetupRequired:     setupRequired,
+	}, nil
+}
+
+func (s *authService) parseTwoFactorChallenge(challengeToken string, tenantID int) (int, bool, error) {
+	claims, err := s.parseToken(challengeToken, s.config.JWT.Audience+twoFactorChallengeAudience, tenantID)
+	if err != nil {
+		return 0, false, errors.New("invalid or expired challenge")
+	}
+
+	userID, ok := intClaim(claims, "user_id")
+	if !ok {
+		return 0, false, errors.New("invalid or expired challenge")
+	}
+	setup, _ := claims["setup"].(bool)
+	return userID, setup, nil
+}
+
+// startSession opens a session for a new login on a device
+func (s *authService) startSession(user *models.User, client models.ClientInfo) (*models.AuthResponse, error) {
+	refreshToken, err := generateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	client = sessionClient(client)
+	session := &models.Session{
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		ExpiresAt: time.Now().Add(s.config.JWT.RefreshTTL),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.authResponse(user, session, refreshToken, client.TenantID)
+}
+
+func (s *authService) authResponse(user *models.User, session *models.Session, refreshToken string, tenantID int) (*models.AuthResponse, error) {
+	expiresAt := time.Now().Add(s.config.JWT.AccessTTL)
+	token, err := s.generateToken(user, session.ID, tenantID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token:        token,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+		User:         *user,
+	}, nil
+}
+
+func (s *authService) generateToken(user *models.User, sessionID, tenantID int, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"sid":     sessionID,
+		"tenant":  tenantID,
+		"aud":     s.config.JWT.Audience,
 		"email":   user.Email,
 		"role":    user.Role,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
+		"iat":     time.Now().Unix(),
+		"exp":     expiresAt.Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.config.JWT.Secret))
 }
 
+// parseToken verifies the signature, expiry and audience of a token and that it
+// was issued for tenantID. Users and sessions live in tenant schemas, so a
+// token from another tenant would name someone else.
+func (s *authService) parseToken(tokenString, audience string, tenantID int) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(s.config.JWT.Secret), nil
+	}, jwt.WithAudience(audience), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if tokenTenant, ok := intClaim(claims, "tenant"); !ok || tokenTenant != tenantID {
+		return nil, errors.New("token was issued for another tenant")
+	}
+	return claims, nil
+}
+
+func intClaim(claims jwt.MapClaims, key string) (int, bool) {
+	switch v := claims[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
+// sessionClient trims the client details to the column sizes
+func sessionClient(client models.ClientInfo) models.ClientInfo {
+	if len(client.UserAgent) > 255 {
+		client.UserAgent = strings.ToValidUTF8(client.UserAgent[:255], "")
+	}
+	if len(client.IPAddress) > 45 {
+		client.IPAddress = client.IPAddress[:45]
+	}
+	return client
+}
+
 func (s *authService) UpdateProfile(user *models.User) (*models.User, error) {
 	// Get existing user to preserve sensitive fields
 	existing, err := s.userRepo.GetByID(user.ID)
@@ -175,6 +534,10 @@ func (s *authService) UpdateProfile(user *models.User) (*models.User, error) {
 		return nil, err
 	}
 
+	if err := validateBillingDetails(user); err != nil {
+		return nil, err
+	}
+
 	// Update only allowed fields
 	existing.Name = user.Name
 	existing.Phone = user.Phone
@@ -182,6 +545,7 @@ func (s *authService) UpdateProfile(user *models.User) (*models.User, error) {
 	existing.UstBel = user.UstBel
 	existing.OrtaBel = user.OrtaBel
 	existing.AltBel = user.AltBel
+	copyBillingDetails(existing, user)
 
 	if err := s.userRepo.Update(existing); err != nil {
 		return nil, err
@@ -190,51 +554,140 @@ func (s *authService) UpdateProfile(user *models.User) (*models.User, error) {
 	return existing, nil
 }
 
+// ForgotPassword emails a single-use reset token. Unknown emails are not an
+// error, so the response does not reveal which addresses have an account.
 func (s *authService) ForgotPassword(email string) error {
-	// Check if user exists
 	user, err := s.userRepo.GetByEmail(email)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return errors.New("user not found")
+			return nil
 		}
 		return err
 	}
 
-	// Generate reset token (in production, this should be sent via email)
-	resetToken := fmt.Sprintf("reset_%d_%d", user.ID, time.Now().Unix())
+	// A new request replaces the tokens sent before
+	if err := s.passwordResetRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
 
-	// In a real application:
-	// 1. Store the token with expiration in database
-	// 2. Send email with reset link containing the token
-	// For demo purposes, we'll just log it
-	fmt.Printf("Password reset token for %s: %s\n", email, resetToken)
+	resetToken, err := generateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := s.config.Auth.PasswordResetTTL
+	reset := &models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashToken(resetToken),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.passwordResetRepo.Create(reset); err != nil {
+		return err
+	}
+
+	// Reset tokens only go to the verified channel, the account's email
+	err = s.notificationService.NotifyWith(models.NotificationPasswordReset, userRecipient(user), map[string]interface{}{
+		"Token":     resetToken,
+		"ExpiresIn": fmt.Sprintf("%d dakika", int(ttl.Minutes())),
+	}, fmt.Sprintf("user:%d", user.ID), []models.NotificationChannelType{models.NotificationChannelEmail})
+	if err != nil {
+		log.Printf("Warning: failed to queue %s notification for user %d: %v", models.NotificationPasswordReset, user.ID, err)
+	}
 
 	return nil
 }
 
+// notify queues a user notification, failures never fail the auth flow
+func (s *authService) notify(event models.NotificationEvent, user *models.User, data map[string]interface{}) {
+	recipient := userRecipient(user)
+	if err := s.notificationService.Notify(event, recipient, data, fmt.Sprintf("user:%d", user.ID)); err != nil {
+		log.Printf("Warning: failed to queue %s notification for user %d: %v", event, user.ID, err)
+	}
+}
+
 func (s *authService) ResetPassword(token, newPassword string) error {
-	// Basic token validation (in production, validate against stored tokens)
-	if !strings.HasPrefix(token, "reset_") {
-		return errors.New("invalid reset token")
+	// Hash first, so a bcrypt failure does not burn the token
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
 	}
 
-	// Extract user ID from token (simplified for demo)
-	parts := strings.Split(token, "_")
-	if len(parts) != 3 {
-		return errors.New("invalid token format")
+	userID, err := s.passwordResetRepo.Consume(hashToken(strings.TrimSpace(token)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("invalid or expired token")
+		}
+		return err
 	}
 
-	userID, err := strconv.Atoi(parts[1])
+	return s.updatePassword(userID, string(hashedPassword), 0)
+}
+
+// VerifyEmail consumes a verification token and activates the account
+func (s *authService) VerifyEmail(token string) (*models.User, error) {
+	userID, err := s.verificationRepo.Consume(hashToken(strings.TrimSpace(token)))
 	if err != nil {
-		return errors.New("invalid token")
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
 	}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err := s.userRepo.MarkVerified(userID); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetByID(userID)
+}
+
+// ResendVerification sends a new verification token, rate limited per user
+func (s *authService) ResendVerification(userID int) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("user not found")
+		}
+		return err
+	}
+	if user.EmailVerified() {
+		return errors.New("email is already verified")
+	}
+
+	count, last, err := s.verificationRepo.CountSince(userID, time.Now().Add(-time.Hour))
 	if err != nil {
 		return err
 	}
+	if count >= emailVerificationHourlyLimit || (last != nil && time.Since(*last) < emailVerificationResendInterval) {
+		return errors.New("too many verification requests, try again later")
+	}
+
+	return s.sendVerification(user)
+}
+
+// sendVerification replaces the user's outstanding tokens with a new one and
+// emails it
+func (s *authService) sendVerification(user *models.User) error {
+	if err := s.verificationRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	verificationToken, err := generateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := s.config.Auth.EmailVerificationTTL
+	verification := &models.EmailVerification{
+		UserID:    user.ID,
+		TokenHash: hashToken(verificationToken),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.verificationRepo.Create(verification); err != nil {
+		return err
+	}
 
-	// Update password
-	return s.userRepo.UpdatePassword(userID, string(hashedPassword))
+	return s.notificationService.NotifyWith(models.NotificationEmailVerification, userRecipient(user), map[string]interface{}{
+		"Token":     verificationToken,
+		"ExpiresIn": fmt.Sprintf("%d saat", int(ttl.Hours())),
+	}, fmt.Sprintf("user:%d", user.ID), []models.NotificationChannelType{models.NotificationChannelEmail})
 }
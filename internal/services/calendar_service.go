@@ -0,0 +1,303 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feeds include past appointments for this many days so recent history stays visible
+const calendarFeedHistoryDays = 90
+
+type CalendarService interface {
+	GetFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error)
+	FindFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error)
+	RegenerateFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error)
+	GetFeed(token string, tenant *models.TenantConfig) ([]byte, error)
+	GetAppointmentICS(appointmentID int, tenant *models.TenantConfig) ([]byte, error)
+}
+
+type calendarService struct {
+	calendarRepo    repository.CalendarRepository
+	appointmentRepo repository.AppointmentRepository
+	serviceRepo     repository.ServiceRepository
+	specialistRepo  repository.SpecialistRepository
+	userRepo        repository.UserRepository
+	settingsRepo    repository.SettingsRepository
+	location        *time.Location
+}
+
+func NewCalendarService(
+	calendarRepo repository.CalendarRepository,
+	appointmentRepo repository.AppointmentRepository,
+	serviceRepo repository.ServiceRepository,
+	specialistRepo repository.SpecialistRepository,
+	userRepo repository.UserRepository,
+	settingsRepo repository.SettingsRepository,
+	cfg *config.Config,
+) CalendarService {
+	return &calendarService{
+		calendarRepo:    calendarRepo,
+		appointmentRepo: appointmentRepo,
+		serviceRepo:     serviceRepo,
+		specialistRepo:  specialistRepo,
+		userRepo:        userRepo,
+		settingsRepo:    settingsRepo,
+		location:        loadLocation(cfg.Calendar.TimeZone),
+	}
+}
+
+// GetFeedToken returns the owner's feed token, creating one on first use. An
+// existing token comes back without its plain value, which is only known when
+// the token is created.
+func (s *calendarService) GetFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error) {
+	if err := s.validateOwner(ownerType, ownerID); err != nil {
+		return nil, err
+	}
+
+	feedToken, err := s.calendarRepo.GetFeedToken(ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if feedToken != nil {
+		return feedToken, nil
+	}
+
+	return s.createFeedToken(ownerType, ownerID)
+}
+
+// FindFeedToken returns the owner's feed token without creating one, nil when
+// there is none
+func (s *calendarService) FindFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error) {
+	return s.calendarRepo.GetFeedToken(ownerType, ownerID)
+}
+
+func (s *calendarService) RegenerateFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error) {
+	if err := s.validateOwner(ownerType, ownerID); err != nil {
+		return nil, err
+	}
+
+	return s.createFeedToken(ownerType, ownerID)
+}
+
+func (s *calendarService) GetFeed(token string, tenant *models.TenantConfig) ([]byte, error) {
+	if token == "" {
+		return nil, errors.New("calendar feed not found")
+	}
+
+	feedToken, err := s.calendarRepo.GetFeedTokenByHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if feedToken == nil {
+		return nil, errors.New("calendar feed not found")
+	}
+
+	since := time.Now().AddDate(0, 0, -calendarFeedHistoryDays)
+
+	switch feedToken.OwnerType {
+	case models.CalendarOwnerSpecialist:
+		specialist, err := s.specialistRepo.GetByID(feedToken.OwnerID)
+		if err != nil {
+			return nil, errors.New("calendar feed not found")
+		}
+
+		appointments, err := s.appointmentRepo.GetBySpecialistIDSince(specialist.ID, since)
+		if err != nil {
+			return nil, err
+		}
+
+		events := s.buildEvents(appointments, tenant, models.CalendarOwnerSpecialist)
+		return buildICalendar(specialist.Name, "PUBLISH", events), nil
+
+	case models.CalendarOwnerUser:
+		user, err := s.userRepo.GetByID(feedToken.OwnerID)
+		if err != nil {
+			return nil, errors.New("calendar feed not found")
+		}
+
+		appointments, err := s.appointmentRepo.GetByUserIDSince(user.ID, since)
+		if err != nil {
+			return nil, err
+		}
+
+		events := s.buildEvents(appointments, tenant, models.CalendarOwnerUser)
+		return buildICalendar(user.Name, "PUBLISH", events), nil
+	}
+
+	return nil, errors.New("calendar feed not found")
+}
+
+func (s *calendarService) GetAppointmentICS(appointmentID int, tenant *models.TenantConfig) ([]byte, error) {
+	appointment, err := s.appointmentRepo.GetByID(appointmentID)
+	if err != nil {
+		return nil, errors.New("appointment not found")
+	}
+
+	events := s.buildEvents([]*models.Appointment{appointment}, tenant, models.CalendarOwnerUser)
+	return buildICalendar("", "PUBLISH", events), nil
+}
+
+func (s *calendarService) validateOwner(ownerType models.CalendarOwnerType, ownerID int) error {
+	if ownerID <= 0 {
+		return errors.New("invalid owner ID")
+	}
+
+	switch ownerType {
+	case models.CalendarOwnerSpecialist:
+		if _, err := s.specialistRepo.GetByID(ownerID); err != nil {
+			return errors.New("specialist not found")
+		}
+	case models.CalendarOwnerUser:
+		if _, err := s.userRepo.GetByID(ownerID); err != nil {
+			return errors.New("user not found")
+		}
+	default:
+		return errors.New("invalid calendar owner type")
+	}
+
+	return nil
+}
+
+func (s *calendarService) createFeedToken(ownerType models.CalendarOwnerType, ownerID int) (*models.CalendarFeedToken, error) {
+	token, err := generateSecureToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	feedToken := &models.CalendarFeedToken{
+		OwnerType: ownerType,
+		OwnerID:   ownerID,
+		Token:     token,
+		TokenHash: hashToken(token),
+	}
+	if err := s.calendarRepo.SaveFeedToken(feedToken); err != nil {
+		return nil, err
+	}
+
+	return feedToken, nil
+}
+
+// buildEvents converts appointments to calendar events. The summary names the
+// other party: the customer in specialist feeds, the specialist otherwise.
+// UIDs carry the tenant's schema and domain, appointment IDs repeat across
+// tenants.
+func (s *calendarService) buildEvents(appointments []*models.Appointment, tenant *models.TenantConfig, perspective models.CalendarOwnerType) []icalEvent {
+	duration := time.Duration(getAppointmentDuration(s.settingsRepo)) * time.Minute
+
+	serviceNames := make(map[int]string)
+	partyNames := make(map[int]string)
+
+	events := make([]icalEvent, 0, len(appointments))
+	for _, appointment := range appointments {
+		serviceName, ok := serviceNames[appointment.ServiceID]
+		if !ok {
+			serviceName = "Appointment"
+			if service, err := s.serviceRepo.GetByID(appointment.ServiceID); err == nil {
+				serviceName = service.Name
+			}
+			serviceNames[appointment.ServiceID] = serviceName
+		}
+
+		partyID := appointment.SpecialistID
+		if perspective == models.CalendarOwnerSpecialist {
+			partyID = appointment.UserID
+		}
+		partyName, ok := partyNames[partyID]
+		if !ok {
+			partyName = s.lookupPartyName(partyID, perspective)
+			partyNames[partyID] = partyName
+		}
+
+		summary := serviceName
+		if partyName != "" {
+			summary = serviceName + " - " + partyName
+		}
+
+		start := appointmentStartTime(appointment, s.location)
+		events = append(events, icalEvent{
+			UID:         fmt.Sprintf("appointment-%d.%s@%s", appointment.ID, tenant.Schema, tenant.Host),
+			Summary:     summary,
+			Description: appointment.Notes,
+			Status:      icalStatus(appointment.Status),
+			Sequence:    appointment.Sequence,
+			Start:       start,
+			End:         start.Add(duration),
+			Stamp:       appointment.UpdatedAt,
+		})
+	}
+
+	return events
+}
+
+func (s *calendarService) lookupPartyName(id int, perspective models.CalendarOwnerType) string {
+	if perspective == models.CalendarOwnerSpecialist {
+		user, err := s.userRepo.GetByID(id)
+		if err != nil {
+			return ""
+		}
+		return user.Name
+	}
+
+	specialist, err := s.specialistRepo.GetByID(id)
+	if err != nil {
+		return ""
+	}
+	return specialist.Name
+}
+
+func icalStatus(status models.AppointmentStatus) string {
+	switch status {
+	case models.StatusCancelled:
+		return "CANCELLED"
+	case models.StatusPending:
+		return "TENTATIVE"
+	default:
+		return "CONFIRMED"
+	}
+}
+
+// appointmentStartTime combines the stored date and time columns into a wall
+// clock time in the given location
+func appointmentStartTime(appointment *models.Appointment, loc *time.Location) time.Time {
+	return time.Date(
+		appointment.AppointmentDate.Year(),
+		appointment.AppointmentDate.Month(),
+		appointment.AppointmentDate.Day(),
+		appointment.AppointmentTime.Hour(),
+		appointment.AppointmentTime.Minute(),
+		0, 0,
+		loc,
+	)
+}
+
+// getAppointmentDuration reads the appointment_duration setting (default 60 minutes)
+func getAppointmentDuration(settingsRepo repository.SettingsRepository) int {
+	duration := 60
+	setting, err := settingsRepo.GetByKey("appointment_duration")
+	if err == nil && setting.Value != "" {
+		if parsed, parseErr := strconv.Atoi(strings.TrimSpace(setting.Value)); parseErr == nil && parsed > 0 {
+			duration = parsed
+		}
+	}
+	return duration
+}
+
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: unknown time zone %q, falling back to local time: %v", name, err)
+		return time.Local
+	}
+	return loc
+}
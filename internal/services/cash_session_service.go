@@ -0,0 +1,244 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"errors"
+)
+
+type CashSessionService interface {
+	Open(req *models.OpenCashSessionRequest, actor string) (*models.CashSession, error)
+	GetByID(id int) (*models.CashSession, error)
+	GetOpen() (*models.CashSession, error)
+	List(status models.CashSessionStatus, limit, offset int) ([]*models.CashSession, int, error)
+	Close(id int, req *models.CloseCashSessionRequest, actor string) (*models.CashSession, error)
+	Approve(id int, actor string) (*models.CashSession, error)
+	ZReport(id int) (*models.ZReport, error)
+}
+
+type cashSessionService struct {
+	cashSessionRepo repository.CashSessionRepository
+	settingsRepo    repository.SettingsRepository
+	defaultCurrency string
+}
+
+func NewCashSessionService(cashSessionRepo repository.CashSessionRepository, settingsRepo repository.SettingsRepository, cfg *config.Config) CashSessionService {
+	return &cashSessionService{
+		cashSessionRepo: cashSessionRepo,
+		settingsRepo:    settingsRepo,
+		defaultCurrency: cfg.Payment.Currency,
+	}
+}
+
+// Open starts a session in the tenant's currency, one session can be open at a time
+func (s *cashSessionService) Open(req *models.OpenCashSessionRequest, actor string) (*models.CashSession, error) {
+	session := &models.CashSession{
+		OpeningFloat: req.OpeningFloat,
+		Notes:        req.Notes,
+		OpenedBy:     actor,
+	}
+	session.SetCurrency(tenantCurrency(s.settingsRepo, s.defaultCurrency))
+
+	if err := s.cashSessionRepo.Open(session); err == sql.ErrNoRows {
+		return nil, errors.New("a cash session is already open")
+	} else if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *cashSessionService) GetByID(id int) (*models.CashSession, error) {
+	session, err := s.cashSessionRepo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("cash session not found")
+	}
+	return session, err
+}
+
+func (s *cashSessionService) GetOpen() (*models.CashSession, error) {
+	session, err := s.cashSessionRepo.GetOpen()
+	if err == sql.ErrNoRows {
+		return nil, errors.New("no cash session is open")
+	}
+	return session, err
+}
+
+func (s *cashSessionService) List(status models.CashSessionStatus, limit, offset int) ([]*models.CashSession, int, error) {
+	switch status {
+	case "", models.CashSessionOpen, models.CashSessionClosed, models.CashSessionApproved:
+	default:
+		return nil, 0, errors.New("invalid status, use open, closed or approved")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	sessions, total, err := s.cashSessionRepo.List(status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sessions == nil {
+		sessions = []*models.CashSession{}
+	}
+	return sessions, total, nil
+}
+
+// Close counts an open session and saves its Z-report. A closed session can be
+// counted again, e.g. after finding a misplaced note, until it is approved.
+func (s *cashSessionService) Close(id int, req *models.CloseCashSessionRequest, actor string) (*models.CashSession, error) {
+	session, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	session.CountedCash = &req.CountedCash
+	session.CountedCreditCard = &req.CountedCreditCard
+	session.CountedTransfer = &req.CountedTransfer
+	session.Notes = req.Notes
+	session.ClosedBy = actor
+	session.SetCurrency(session.Currency)
+
+	var ok bool
+	switch session.Status {
+	case models.CashSessionOpen:
+		ok, err = s.cashSessionRepo.Close(session)
+	case models.CashSessionClosed:
+		ok, err = s.cashSessionRepo.Recount(session)
+	default:
+		return nil, errors.New("cash session is approved and locked")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("cash session changed while closing, try again")
+	}
+	return s.GetByID(id)
+}
+
+// Approve locks a closed session and its Z-report
+func (s *cashSessionService) Approve(id int, actor string) (*models.CashSession, error) {
+	session, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch session.Status {
+	case models.CashSessionOpen:
+		return nil, errors.New("cash session must be closed before it is approved")
+	case models.CashSessionApproved:
+		return nil, errors.New("cash session is already approved")
+	}
+
+	session.ApprovedBy = actor
+	ok, err := s.cashSessionRepo.Approve(session)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("cash session changed while approving, try again")
+	}
+	return session, nil
+}
+
+// ZReport reports a closed session from the lines saved at close, and an
+// open one from what was taken so far
+func (s *cashSessionService) ZReport(id int) (*models.ZReport, error) {
+	session, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []*models.CashSessionLine
+	if session.Status == models.CashSessionOpen {
+		lines, err = s.cashSessionRepo.Totals(session)
+	} else {
+		lines, err = s.cashSessionRepo.GetLines(session)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sessionCurrency := models.NewMoney(0, session.Currency)
+	for _, line := range lines {
+		if !line.Sales.SameCurrency(sessionCurrency) || !line.Refunds.SameCurrency(sessionCurrency) {
+			return nil, models.ErrCurrencyMismatch
+		}
+	}
+	return buildZReport(session, lines), nil
+}
+
+// buildZReport sums the lines per payment method and compares them with what
+// was counted
+func buildZReport(session *models.CashSession, lines []*models.CashSessionLine) *models.ZReport {
+	if lines == nil {
+		lines = []*models.CashSessionLine{}
+	}
+	currency := session.Currency
+	report := &models.ZReport{
+		Session:       session,
+		Lines:         lines,
+		TotalSales:    models.NewMoney(0, currency),
+		TotalRefunds:  models.NewMoney(0, currency),
+		TotalExpected: models.NewMoney(0, currency),
+		Locked:        session.Status == models.CashSessionApproved,
+	}
+	counted := session.Status != models.CashSessionOpen
+	if counted {
+		totalCounted := models.NewMoney(0, currency)
+		report.TotalCounted = &totalCounted
+	}
+
+	for _, method := range models.CashSessionMethods {
+		total := &models.CashSessionMethodTotal{
+			Method:  method,
+			Sales:   models.NewMoney(0, currency),
+			Refunds: models.NewMoney(0, currency),
+		}
+		for _, line := range lines {
+			if line.Method == method {
+				total.Sales = total.Sales.Add(line.Sales)
+				total.Refunds = total.Refunds.Add(line.Refunds)
+			}
+		}
+		total.Expected = total.Sales.Sub(total.Refunds)
+		if method == models.PaymentMethodCash {
+			total.Expected = total.Expected.Add(session.OpeningFloat)
+		}
+
+		if counted {
+			amount := models.NewMoney(0, currency)
+			if c := session.Counted(method); c != nil {
+				amount = *c
+			}
+			discrepancy := amount.Sub(total.Expected)
+			total.Counted = &amount
+			total.Discrepancy = &discrepancy
+			*report.TotalCounted = report.TotalCounted.Add(amount)
+		}
+
+		report.TotalSales = report.TotalSales.Add(total.Sales)
+		report.TotalRefunds = report.TotalRefunds.Add(total.Refunds)
+		report.TotalExpected = report.TotalExpected.Add(total.Expected)
+		report.Methods = append(report.Methods, total)
+	}
+
+	if counted {
+		discrepancy := report.TotalCounted.Sub(report.TotalExpected)
+		report.TotalDiscrepancy = &discrepancy
+		report.Balanced = true
+		for _, total := range report.Methods {
+			if !total.Discrepancy.IsZero() {
+				report.Balanced = false
+			}
+		}
+	}
+	return report
+}
@@ -30,6 +30,10 @@ func (s *categoryService) Create(category *models.Category) error {
 		return errors.New("category name is required")
 	}
 
+	if err := validateTaxRate(category.TaxRate); err != nil {
+		return err
+	}
+
 	// Set default active status
 	category.Active = true
 
@@ -53,6 +57,10 @@ func (s *categoryService) Update(category *models.Category) error {
 		return errors.New("category name is required")
 	}
 
+	if err := validateTaxRate(category.TaxRate); err != nil {
+		return err
+	}
+
 	// Check if category exists
 	existing, err := s.categoryRepo.GetByID(category.ID)
 	if err != nil {
@@ -62,6 +70,7 @@ func (s *categoryService) Update(category *models.Category) error {
 	// Update fields
 	existing.Name = category.Name
 	existing.Description = category.Description
+	existing.TaxRate = category.TaxRate
 	existing.Active = category.Active
 
 	return s.categoryRepo.Update(existing)
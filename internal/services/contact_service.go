@@ -3,6 +3,7 @@ package services
 import (
 	"appointment-api/internal/models"
 	"appointment-api/internal/repository"
+	"log"
 )
 
 type ContactService interface {
@@ -15,12 +16,14 @@ type ContactService interface {
 }
 
 type contactService struct {
-	contactRepo repository.ContactRepository
+	contactRepo    repository.ContactRepository
+	webhookService WebhookService
 }
 
-func NewContactService(contactRepo repository.ContactRepository) ContactService {
+func NewContactService(contactRepo repository.ContactRepository, webhookService WebhookService) ContactService {
 	return &contactService{
-		contactRepo: contactRepo,
+		contactRepo:    contactRepo,
+		webhookService: webhookService,
 	}
 }
 
@@ -38,6 +41,10 @@ func (s *contactService) Create(request *models.ContactMessageRequest) (*models.
 		return nil, err
 	}
 
+	if err := s.webhookService.Publish(models.WebhookContactReceived, map[string]interface{}{"contact_message": message}); err != nil {
+		log.Printf("Warning: failed to queue %s webhook for contact message %d: %v", models.WebhookContactReceived, message.ID, err)
+	}
+
 	return message, nil
 }
 
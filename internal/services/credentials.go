@@ -0,0 +1,67 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var errCredentialsKeyMissing = errors.New("payment credentials key is not configured")
+
+// credentialCipher encrypts secrets tenants store, such as gateway API keys,
+// with AES-256-GCM. The key is derived from the server's configured key, so
+// any length of secret can be configured.
+type credentialCipher struct {
+	aead cipher.AEAD
+}
+
+// newCredentialCipher returns nil for an empty key, sealing and opening with a
+// nil cipher fails
+func newCredentialCipher(key string) *credentialCipher {
+	if key == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		panic(err) // a 32 byte key is always valid
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &credentialCipher{aead: aead}
+}
+
+// seal returns base64 of the random nonce followed by the ciphertext
+func (c *credentialCipher) seal(plaintext string) (string, error) {
+	if c == nil {
+		return "", errCredentialsKeyMissing
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *credentialCipher) open(sealed string) (string, error) {
+	if c == nil {
+		return "", errCredentialsKeyMissing
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", errors.New("invalid encrypted credential")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		// Wrong key, e.g. PAYMENT_CREDENTIALS_KEY changed after saving
+		return "", errors.New("invalid encrypted credential")
+	}
+	return string(plaintext), nil
+}
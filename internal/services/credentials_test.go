@@ -0,0 +1,40 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCredentialCipherRoundTrip(t *testing.T) {
+	c := newCredentialCipher("server-credentials-key")
+
+	sealed, err := c.seal("sk_test_51abc")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if strings.Contains(sealed, "sk_test_51abc") {
+		t.Fatalf("sealed credential contains the plaintext: %q", sealed)
+	}
+	if again, _ := c.seal("sk_test_51abc"); again == sealed {
+		t.Error("sealing twice gave the same ciphertext, the nonce is not random")
+	}
+
+	opened, err := c.open(sealed)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if opened != "sk_test_51abc" {
+		t.Errorf("opened %q, want sk_test_51abc", opened)
+	}
+
+	if _, err := newCredentialCipher("another-key").open(sealed); err == nil {
+		t.Error("a credential sealed with another key was opened")
+	}
+}
+
+func TestCredentialCipherWithoutKey(t *testing.T) {
+	c := newCredentialCipher("")
+	if _, err := c.seal("sk_test_51abc"); err != errCredentialsKeyMissing {
+		t.Errorf("seal without a key: got %v, want %v", err, errCredentialsKeyMissing)
+	}
+}
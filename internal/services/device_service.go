@@ -35,7 +35,7 @@ func (s *deviceService) Create(device *models.Device) error {
 		return errors.New("device name is required")
 	}
 
-	if device.Price < 0 {
+	if device.Price.Amount < 0 {
 		return errors.New("device price must be positive")
 	}
 
@@ -71,7 +71,7 @@ func (s *deviceService) Update(device *models.Device) error {
 		return errors.New("device name is required")
 	}
 
-	if device.Price < 0 {
+	if device.Price.Amount < 0 {
 		return errors.New("device price must be positive")
 	}
 
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum size of an ICS document (uploaded or fetched)
+const maxICSSize = 5 * 1024 * 1024
+
+type ExternalCalendarService interface {
+	List(specialistID int) ([]*models.ExternalCalendar, error)
+	Get(specialistID, calendarID int) (*models.ExternalCalendar, error)
+	CreateFromURL(specialistID int, req *models.CreateExternalCalendarRequest) (*models.ExternalCalendar, error)
+	CreateFromFile(specialistID int, name string, data []byte) (*models.ExternalCalendar, error)
+	Delete(specialistID, calendarID int) error
+	Sync(specialistID, calendarID int) (*models.ExternalCalendar, error)
+	SyncAll() error
+	GetBusyBlocks(specialistID int, from, to time.Time) ([]*models.ExternalBusyBlock, error)
+}
+
+type externalCalendarService struct {
+	externalCalendarRepo repository.ExternalCalendarRepository
+	specialistRepo       repository.SpecialistRepository
+	settingsRepo         repository.SettingsRepository
+	httpClient           *http.Client
+	location             *time.Location
+}
+
+func NewExternalCalendarService(
+	externalCalendarRepo repository.ExternalCalendarRepository,
+	specialistRepo repository.SpecialistRepository,
+	settingsRepo repository.SettingsRepository,
+	cfg *config.Config,
+) ExternalCalendarService {
+	return &externalCalendarService{
+		externalCalendarRepo: externalCalendarRepo,
+		specialistRepo:       specialistRepo,
+		settingsRepo:         settingsRepo,
+		httpClient:           newPublicHTTPClient(20 * time.Second),
+		location:             loadLocation(cfg.Calendar.TimeZone),
+	}
+}
+
+// NewCalendarSyncWorker periodically refreshes the busy blocks of every tenant's
+// external calendars
+func NewCalendarSyncWorker(tenantCache TenantCacheService, tenantDBs *TenantDBs, cfg *config.Config) BackgroundWorker {
+	return newTenantWorker("calendar sync", cfg.Calendar.SyncInterval, tenantCache, tenantDBs,
+		func(tenant *TenantInfo, repos *repository.Repositories) error {
+			return NewExternalCalendarService(repos.ExternalCalendar, repos.Specialist, repos.Settings, cfg).SyncAll()
+		})
+}
+
+func (s *externalCalendarService) List(specialistID int) ([]*models.ExternalCalendar, error) {
+	if err := s.validateSpecialist(specialistID); err != nil {
+		return nil, err
+	}
+
+	calendars, err := s.externalCalendarRepo.ListBySpecialist(specialistID)
+	if err != nil {
+		return nil, err
+	}
+	if calendars == nil {
+		calendars = []*models.ExternalCalendar{}
+	}
+	return calendars, nil
+}
+
+func (s *externalCalendarService) CreateFromURL(specialistID int, req *models.CreateExternalCalendarRequest) (*models.ExternalCalendar, error) {
+	if err := s.validateSpecialist(specialistID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("calendar name is required")
+	}
+
+	// Feeds are fetched from the server, local and private addresses are refused
+	parsedURL, err := validatePublicURL(req.URL, "http", "https", "webcal")
+	if err == errPrivateAddress {
+		return nil, errors.New("invalid calendar URL, private and local addresses are not allowed")
+	} else if err != nil {
+		return nil, errors.New("invalid calendar URL")
+	}
+
+	calendar := &models.ExternalCalendar{
+		SpecialistID: specialistID,
+		Name:         name,
+		SourceType:   models.ExternalCalendarSourceURL,
+		URL:          parsedURL.String(),
+		Active:       true,
+	}
+	if err := s.externalCalendarRepo.Create(calendar); err != nil {
+		return nil, err
+	}
+
+	// A failing first sync is stored on the calendar and retried by the worker
+	s.syncCalendar(calendar)
+	return s.externalCalendarRepo.GetByID(calendar.ID)
+}
+
+func (s *externalCalendarService) CreateFromFile(specialistID int, name string, data []byte) (*models.ExternalCalendar, error) {
+	if err := s.validateSpecialist(specialistID); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("calendar name is required")
+	}
+	if len(data) > maxICSSize {
+		return nil, errors.New("ICS file too large")
+	}
+
+	// Reject broken files up front instead of storing a calendar that never syncs
+	if _, err := parseICalEvents(data, s.location); err != nil {
+		return nil, err
+	}
+
+	calendar := &models.ExternalCalendar{
+		SpecialistID: specialistID,
+		Name:         name,
+		SourceType:   models.ExternalCalendarSourceFile,
+		ICSData:      string(data),
+		Active:       true,
+	}
+	if err := s.externalCalendarRepo.Create(calendar); err != nil {
+		return nil, err
+	}
+
+	s.syncCalendar(calendar)
+	return s.externalCalendarRepo.GetByID(calendar.ID)
+}
+
+func (s *externalCalendarService) Delete(specialistID, calendarID int) error {
+	if _, err := s.getCalendar(specialistID, calendarID); err != nil {
+		return err
+	}
+
+	return s.externalCalendarRepo.Delete(calendarID)
+}
+
+func (s *externalCalendarService) Sync(specialistID, calendarID int) (*models.ExternalCalendar, error) {
+	calendar, err := s.getCalendar(specialistID, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.syncCalendar(calendar)
+	return s.externalCalendarRepo.GetByID(calendar.ID)
+}
+
+// SyncAll refreshes every active calendar, failures are recorded per calendar
+func (s *externalCalendarService) SyncAll() error {
+	calendars, err := s.externalCalendarRepo.ListActive()
+	if err != nil {
+		return err
+	}
+
+	for _, calendar := range calendars {
+		s.syncCalendar(calendar)
+	}
+	return nil
+}
+
+func (s *externalCalendarService) GetBusyBlocks(specialistID int, from, to time.Time) ([]*models.ExternalBusyBlock, error) {
+	if err := s.validateSpecialist(specialistID); err != nil {
+		return nil, err
+	}
+
+	blocks, err := s.externalCalendarRepo.GetBusyBlocks(specialistID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if blocks == nil {
+		blocks = []*models.ExternalBusyBlock{}
+	}
+	return blocks, nil
+}
+
+// syncCalendar replaces the calendar's busy blocks with the events inside the
+// booking window (yesterday until max_advance_booking_days ahead). Previously
+// stored blocks are kept when the source cannot be read.
+func (s *externalCalendarService) syncCalendar(calendar *models.ExternalCalendar) {
+	now := time.Now()
+
+	syncErr := s.refreshBlocks(calendar, now)
+	errMessage := ""
+	if syncErr != nil {
+		errMessage = syncErr.Error()
+		log.Printf("Warning: failed to sync external calendar %d: %v", calendar.ID, syncErr)
+	}
+
+	if err := s.externalCalendarRepo.UpdateSyncResult(calendar.ID, now, errMessage); err != nil {
+		log.Printf("Warning: failed to store sync result of external calendar %d: %v", calendar.ID, err)
+	}
+}
+
+func (s *externalCalendarService) refreshBlocks(calendar *models.ExternalCalendar, now time.Time) error {
+	data := []byte(calendar.ICSData)
+	if calendar.SourceType == models.ExternalCalendarSourceURL {
+		fetched, err := s.fetch(calendar.URL)
+		if err != nil {
+			return err
+		}
+		data = fetched
+	}
+
+	localNow := now.In(s.location)
+	from := time.Date(localNow.Year(), localNow.Month(), localNow.Day()-1, 0, 0, 0, 0, s.location)
+	to := from.AddDate(0, 0, getMaxAdvanceBookingDays(s.settingsRepo)+2)
+
+	events, err := parseICalBusyEvents(data, s.location, from, to)
+	if err != nil {
+		return err
+	}
+
+	blocks := make([]*models.ExternalBusyBlock, 0, len(events))
+	for _, event := range events {
+		blocks = append(blocks, &models.ExternalBusyBlock{
+			CalendarID:   calendar.ID,
+			SpecialistID: calendar.SpecialistID,
+			UID:          event.UID,
+			Summary:      event.Summary,
+			StartsAt:     event.Start,
+			EndsAt:       event.End,
+		})
+	}
+
+	return s.externalCalendarRepo.ReplaceBusyBlocks(calendar.ID, blocks)
+}
+
+func (s *externalCalendarService) fetch(rawURL string) ([]byte, error) {
+	// webcal:// is an alias used by calendar apps for https://
+	if strings.HasPrefix(rawURL, "webcal://") {
+		rawURL = "https://" + strings.TrimPrefix(rawURL, "webcal://")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+	req.Header.Set("User-Agent", "Appointment-API calendar sync")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch calendar: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxICSSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar: %w", err)
+	}
+	if len(data) > maxICSSize {
+		return nil, errors.New("calendar too large")
+	}
+
+	return data, nil
+}
+
+// Get returns one of the specialist's external calendars
+func (s *externalCalendarService) Get(specialistID, calendarID int) (*models.ExternalCalendar, error) {
+	return s.getCalendar(specialistID, calendarID)
+}
+
+func (s *externalCalendarService) getCalendar(specialistID, calendarID int) (*models.ExternalCalendar, error) {
+	if err := s.validateSpecialist(specialistID); err != nil {
+		return nil, err
+	}
+
+	calendar, err := s.externalCalendarRepo.GetByID(calendarID)
+	if err != nil || calendar.SpecialistID != specialistID {
+		return nil, errors.New("external calendar not found")
+	}
+	return calendar, nil
+}
+
+func (s *externalCalendarService) validateSpecialist(specialistID int) error {
+	if specialistID <= 0 {
+		return errors.New("invalid specialist ID")
+	}
+
+	if _, err := s.specialistRepo.GetByID(specialistID); err != nil {
+		return errors.New("specialist not found")
+	}
+	return nil
+}
+
+// getMaxAdvanceBookingDays reads the max_advance_booking_days setting (default 30)
+func getMaxAdvanceBookingDays(settingsRepo repository.SettingsRepository) int {
+	days := 30
+	setting, err := settingsRepo.GetByKey("max_advance_booking_days")
+	if err == nil && setting.Value != "" {
+		if parsed, parseErr := strconv.Atoi(strings.TrimSpace(setting.Value)); parseErr == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+	return days
+}
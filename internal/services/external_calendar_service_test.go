@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testICSFeed = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Test//Feed//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:busy-1@example.com\r\n" +
+	"SUMMARY:Dentist\r\n" +
+	"DTSTART:20260601T090000Z\r\n" +
+	"DTEND:20260601T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestFetchCalendarFromFeed(t *testing.T) {
+	var accept string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		if r.URL.Path != "/calendar.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(testICSFeed))
+	}))
+	defer server.Close()
+
+	// The stand-in listens on loopback, which the production client refuses
+	s := &externalCalendarService{httpClient: server.Client(), location: time.UTC}
+
+	// webcal:// is fetched over https
+	data, err := s.fetch("webcal://" + strings.TrimPrefix(server.URL, "https://") + "/calendar.ics")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if accept != "text/calendar" {
+		t.Errorf("Accept = %q, want text/calendar", accept)
+	}
+
+	from := time.Date(2026, 5, 31, 0, 0, 0, 0, time.UTC)
+	events, err := parseICalBusyEvents(data, time.UTC, from, from.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.UID != "busy-1@example.com" || event.Summary != "Dentist" {
+		t.Errorf("event = %+v", event)
+	}
+	if want := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC); !event.Start.Equal(want) {
+		t.Errorf("start = %v, want %v", event.Start, want)
+	}
+	if want := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC); !event.End.Equal(want) {
+		t.Errorf("end = %v, want %v", event.End, want)
+	}
+}
+
+func TestFetchCalendarErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large.ics":
+			w.Write(make([]byte, maxICSSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s := &externalCalendarService{httpClient: server.Client(), location: time.UTC}
+
+	if _, err := s.fetch(server.URL + "/missing.ics"); err == nil || !strings.Contains(err.Error(), "unexpected status 404") {
+		t.Errorf("missing feed: err = %v, want unexpected status 404", err)
+	}
+	if _, err := s.fetch(server.URL + "/large.ics"); err == nil || err.Error() != "calendar too large" {
+		t.Errorf("large feed: err = %v, want calendar too large", err)
+	}
+}
+
+func TestFetchCalendarRefusesPrivateAddresses(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.Write([]byte(testICSFeed))
+	}))
+	defer server.Close()
+
+	s := &externalCalendarService{httpClient: newPublicHTTPClient(5 * time.Second), location: time.UTC}
+
+	_, err := s.fetch(server.URL + "/calendar.ics")
+	if !errors.Is(err, errPrivateAddress) {
+		t.Errorf("err = %v, want %v", err, errPrivateAddress)
+	}
+	if requested {
+		t.Error("the request reached the loopback server")
+	}
+}
+
+func TestValidateCalendarURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr error
+	}{
+		{"https://calendar.example.com/feed.ics", nil},
+		{"webcal://calendar.example.com/feed.ics", nil},
+		{"ftp://calendar.example.com/feed.ics", errors.New("invalid url scheme")},
+		{"http://localhost:8080/feed.ics", errPrivateAddress},
+		{"http://127.0.0.1/feed.ics", errPrivateAddress},
+		{"http://10.1.2.3/feed.ics", errPrivateAddress},
+		{"http://169.254.169.254/latest/meta-data/", errPrivateAddress},
+		{"http://[::1]/feed.ics", errPrivateAddress},
+		{"http://[::ffff:192.168.1.1]/feed.ics", errPrivateAddress},
+		{"http://0.0.0.0/feed.ics", errPrivateAddress},
+		{"http://0.1.2.3/feed.ics", errPrivateAddress},
+		{"http://100.100.100.200/feed.ics", errPrivateAddress},
+		{"http://198.18.0.1/feed.ics", errPrivateAddress},
+		{"http://[::ffff:100.64.0.1]/feed.ics", errPrivateAddress},
+	}
+
+	for _, test := range tests {
+		_, err := validatePublicURL(test.url, "http", "https", "webcal")
+		switch {
+		case test.wantErr == nil && err != nil:
+			t.Errorf("%s: unexpected error %v", test.url, err)
+		case test.wantErr != nil && (err == nil || err.Error() != test.wantErr.Error()):
+			t.Errorf("%s: err = %v, want %v", test.url, err, test.wantErr)
+		}
+	}
+}
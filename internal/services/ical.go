@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// icalEvent is a single VEVENT entry of an iCalendar (RFC 5545) document
+type icalEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Status      string
+	Sequence    int
+	Start       time.Time
+	End         time.Time
+	Stamp       time.Time
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// buildICalendar renders the events as a VCALENDAR document with CRLF line endings
+func buildICalendar(calendarName string, method string, events []icalEvent) []byte {
+	var b strings.Builder
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//Appointment API//Calendar//EN")
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	if method != "" {
+		writeICalLine(&b, "METHOD:"+method)
+	}
+	if calendarName != "" {
+		writeICalLine(&b, "X-WR-CALNAME:"+escapeICalText(calendarName))
+	}
+
+	for _, event := range events {
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, "UID:"+event.UID)
+		writeICalLine(&b, "DTSTAMP:"+event.Stamp.UTC().Format(icalTimeFormat))
+		writeICalLine(&b, "DTSTART:"+event.Start.UTC().Format(icalTimeFormat))
+		writeICalLine(&b, "DTEND:"+event.End.UTC().Format(icalTimeFormat))
+		writeICalLine(&b, fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+		writeICalLine(&b, "STATUS:"+event.Status)
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(event.Summary))
+		if event.Description != "" {
+			writeICalLine(&b, "DESCRIPTION:"+escapeICalText(event.Description))
+		}
+		if event.Location != "" {
+			writeICalLine(&b, "LOCATION:"+escapeICalText(event.Location))
+		}
+		if event.Status == "CANCELLED" {
+			writeICalLine(&b, "TRANSP:TRANSPARENT")
+		}
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// writeICalLine folds content lines longer than 75 octets as required by RFC 5545
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+
+	for len(line) > limit {
+		cut := limit
+		// Never split a multi-byte UTF-8 sequence
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space which counts towards the limit
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func escapeICalText(text string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return replacer.Replace(text)
+}
@@ -0,0 +1,573 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Upper bound for generated occurrences of a single recurring event (including
+// those before the sync window, which still count towards COUNT)
+const maxRecurrenceInstances = 100000
+
+// icalBusyEvent is a single (expanded) occurrence of an imported VEVENT
+type icalBusyEvent struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type icalParsedEvent struct {
+	UID          string
+	Summary      string
+	Status       string
+	Transparent  bool
+	Start        time.Time
+	AllDay       bool
+	End          time.Time
+	Duration     time.Duration
+	HasDuration  bool
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID *time.Time
+}
+
+// parseICalBusyEvents reads the VEVENTs of an ICS document and returns the busy
+// occurrences overlapping [from, to). Floating times are read in loc. Cancelled and
+// transparent (free) events are skipped. Recurrence supports FREQ=DAILY/WEEKLY/
+// MONTHLY/YEARLY with INTERVAL, COUNT, UNTIL and weekly BYDAY, plus EXDATE and
+// RECURRENCE-ID overrides; other rules only produce the first occurrence.
+func parseICalBusyEvents(data []byte, loc *time.Location, from, to time.Time) ([]icalBusyEvent, error) {
+	events, err := parseICalEvents(data, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Instances replaced by a RECURRENCE-ID override are not generated from the rule
+	overridden := make(map[string]map[int64]bool)
+	for _, event := range events {
+		if event.RecurrenceID != nil {
+			if overridden[event.UID] == nil {
+				overridden[event.UID] = make(map[int64]bool)
+			}
+			overridden[event.UID][event.RecurrenceID.Unix()] = true
+		}
+	}
+
+	var busy []icalBusyEvent
+	for _, event := range events {
+		if event.Status == "CANCELLED" || event.Transparent {
+			continue
+		}
+
+		length := eventLength(event)
+		if length <= 0 {
+			continue
+		}
+
+		starts := []time.Time{event.Start}
+		if event.RRule != "" && event.RecurrenceID == nil {
+			starts = expandRecurrence(event.Start, event.RRule, from.Add(-length), to)
+		}
+
+		for _, start := range starts {
+			if event.RecurrenceID == nil && overridden[event.UID][start.Unix()] {
+				continue
+			}
+			if containsTime(event.ExDates, start) {
+				continue
+			}
+
+			end := start.Add(length)
+			if event.AllDay {
+				// All-day events follow the calendar date, not a fixed 24h span (DST)
+				days := int(length / (24 * time.Hour))
+				if days < 1 {
+					days = 1
+				}
+				end = start.AddDate(0, 0, days)
+			}
+			if !start.Before(to) || !end.After(from) {
+				continue
+			}
+
+			busy = append(busy, icalBusyEvent{
+				UID:     event.UID,
+				Summary: event.Summary,
+				Start:   start,
+				End:     end,
+			})
+		}
+	}
+
+	return busy, nil
+}
+
+func eventLength(event *icalParsedEvent) time.Duration {
+	switch {
+	case !event.End.IsZero():
+		return event.End.Sub(event.Start)
+	case event.HasDuration:
+		return event.Duration
+	case event.AllDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, candidate := range times {
+		if candidate.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseICalEvents(data []byte, loc *time.Location) ([]*icalParsedEvent, error) {
+	lines := unfoldICalLines(string(data))
+
+	var (
+		events      []*icalParsedEvent
+		stack       []string
+		current     *icalParsedEvent
+		hasCalendar bool
+	)
+
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		prop, err := parseICalProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ICS data on line %d: %v", i+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			component := strings.ToUpper(prop.Value)
+			if component == "VCALENDAR" {
+				hasCalendar = true
+			}
+			if component == "VEVENT" && len(stack) > 0 && stack[len(stack)-1] == "VCALENDAR" {
+				current = &icalParsedEvent{}
+			}
+			stack = append(stack, component)
+			continue
+		case "END":
+			component := strings.ToUpper(prop.Value)
+			if len(stack) == 0 || stack[len(stack)-1] != component {
+				return nil, fmt.Errorf("invalid ICS data on line %d: unexpected END:%s", i+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+			if component == "VEVENT" && current != nil {
+				if current.Start.IsZero() {
+					return nil, fmt.Errorf("invalid ICS data: event %q has no DTSTART", current.UID)
+				}
+				events = append(events, current)
+				current = nil
+			}
+			continue
+		}
+
+		// Only direct VEVENT properties matter, nested VALARMs are ignored
+		if current == nil || stack[len(stack)-1] != "VEVENT" {
+			continue
+		}
+
+		if err := applyICalEventProperty(current, prop, loc); err != nil {
+			return nil, fmt.Errorf("invalid ICS data on line %d: %v", i+1, err)
+		}
+	}
+
+	if !hasCalendar {
+		return nil, errors.New("invalid ICS data: missing VCALENDAR")
+	}
+	if len(stack) != 0 {
+		return nil, errors.New("invalid ICS data: unterminated " + stack[len(stack)-1])
+	}
+
+	return events, nil
+}
+
+func applyICalEventProperty(event *icalParsedEvent, prop icalProperty, loc *time.Location) error {
+	switch prop.Name {
+	case "UID":
+		event.UID = prop.Value
+	case "SUMMARY":
+		event.Summary = unescapeICalText(prop.Value)
+	case "STATUS":
+		event.Status = strings.ToUpper(prop.Value)
+	case "TRANSP":
+		event.Transparent = strings.EqualFold(prop.Value, "TRANSPARENT")
+	case "DTSTART":
+		start, allDay, err := parseICalDateTime(prop, loc)
+		if err != nil {
+			return err
+		}
+		event.Start = start
+		event.AllDay = allDay
+	case "DTEND":
+		end, _, err := parseICalDateTime(prop, loc)
+		if err != nil {
+			return err
+		}
+		event.End = end
+	case "DURATION":
+		duration, err := parseICalDuration(prop.Value)
+		if err != nil {
+			return err
+		}
+		event.Duration = duration
+		event.HasDuration = true
+	case "RRULE":
+		event.RRule = prop.Value
+	case "EXDATE":
+		for _, value := range strings.Split(prop.Value, ",") {
+			exdate, _, err := parseICalDateTime(icalProperty{Params: prop.Params, Value: value}, loc)
+			if err != nil {
+				return err
+			}
+			event.ExDates = append(event.ExDates, exdate)
+		}
+	case "RECURRENCE-ID":
+		recurrenceID, _, err := parseICalDateTime(prop, loc)
+		if err != nil {
+			return err
+		}
+		event.RecurrenceID = &recurrenceID
+	}
+	return nil
+}
+
+// unfoldICalLines joins continuation lines (starting with space or tab)
+func unfoldICalLines(data string) []string {
+	data = strings.TrimPrefix(data, "\ufeff")
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICalProperty splits "NAME;PARAM=value;PARAM2=\"quoted\":VALUE"
+func parseICalProperty(line string) (icalProperty, error) {
+	prop := icalProperty{Params: make(map[string]string)}
+
+	inQuotes := false
+	separator := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			separator = i
+			break
+		}
+	}
+	if separator < 0 {
+		return prop, errors.New("missing ':' separator")
+	}
+
+	prop.Value = line[separator+1:]
+	parts := splitICalParams(line[:separator])
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			continue
+		}
+		prop.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return prop, nil
+}
+
+func splitICalParams(s string) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, r := range s {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ';' && !inQuotes {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseICalDateTime handles UTC, TZID and floating date-times and all-day dates
+func parseICalDateTime(prop icalProperty, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.Value)
+
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if tzLoc, err := time.LoadLocation(tzid); err == nil {
+			loc = tzLoc
+		}
+	}
+
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalTimeFormat, value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+		}
+		return t, false, nil
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t, false, nil
+}
+
+// parseICalDuration parses RFC 5545 durations such as PT1H30M, P1D or P2W
+func parseICalDuration(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(s, "+")
+
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	s = s[1:]
+
+	var total time.Duration
+	inTime := false
+	number := ""
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			number += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		number = ""
+
+		switch {
+		case r == 'W' && !inTime:
+			total += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			total += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			total += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			total += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			total += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	return sign * total, nil
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// expandRecurrence returns the occurrence start times of an RRULE within
+// [from, limit). Occurrences keep the wall clock time of DTSTART.
+func expandRecurrence(start time.Time, rrule string, from, limit time.Time) []time.Time {
+	rule := make(map[string]string)
+	for _, part := range strings.Split(rrule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if found {
+			rule[strings.ToUpper(key)] = strings.ToUpper(value)
+		}
+	}
+
+	interval := 1
+	if value, err := strconv.Atoi(rule["INTERVAL"]); err == nil && value > 0 {
+		interval = value
+	}
+
+	count := 0
+	if value, err := strconv.Atoi(rule["COUNT"]); err == nil && value > 0 {
+		count = value
+	}
+
+	if untilValue := rule["UNTIL"]; untilValue != "" {
+		until, _, err := parseICalDateTime(icalProperty{Value: untilValue}, start.Location())
+		if err == nil {
+			if len(untilValue) == 8 {
+				// Date-only UNTIL includes the whole day
+				until = until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			if until.Before(limit) {
+				limit = until.Add(time.Second)
+			}
+		}
+	}
+
+	// Rules we cannot expand faithfully only produce the first occurrence
+	for key := range rule {
+		switch key {
+		case "FREQ", "INTERVAL", "COUNT", "UNTIL", "WKST":
+		case "BYDAY":
+			if rule["FREQ"] != "WEEKLY" {
+				return []time.Time{start}
+			}
+		default:
+			return []time.Time{start}
+		}
+	}
+
+	var weekdays []time.Weekday
+	if byDay := rule["BYDAY"]; byDay != "" {
+		for _, day := range strings.Split(byDay, ",") {
+			weekday, ok := icalWeekdays[day]
+			if !ok {
+				return []time.Time{start}
+			}
+			weekdays = append(weekdays, weekday)
+		}
+	}
+
+	var occurrences []time.Time
+	generated := 0
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if !t.Before(limit) || (count > 0 && generated >= count) || generated >= maxRecurrenceInstances {
+			return false
+		}
+		generated++
+		if !t.Before(from) {
+			occurrences = append(occurrences, t)
+		}
+		return true
+	}
+
+	y, m, d := start.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	switch rule["FREQ"] {
+	case "DAILY":
+		for i := 0; emit(at(y, m, d+i*interval)); i++ {
+		}
+	case "WEEKLY":
+		if len(weekdays) == 0 {
+			for i := 0; emit(at(y, m, d+i*7*interval)); i++ {
+			}
+			break
+		}
+		// Weeks start on Monday (default WKST)
+		weekStart := d - (int(start.Weekday())+6)%7
+		for week := 0; ; week += interval {
+			keepGoing := true
+			for offset := 0; offset < 7 && keepGoing; offset++ {
+				candidate := at(y, m, weekStart+week*7+offset)
+				if containsWeekday(weekdays, candidate.Weekday()) {
+					keepGoing = emit(candidate)
+				}
+			}
+			if !keepGoing {
+				break
+			}
+		}
+	case "MONTHLY":
+		for i := 0; ; i++ {
+			candidate := at(y, m+time.Month(i*interval), d)
+			// Months without this day (e.g. the 31st) are skipped per RFC 5545
+			if candidate.Day() != d {
+				if candidate.After(limit) {
+					break
+				}
+				continue
+			}
+			if !emit(candidate) {
+				break
+			}
+		}
+	case "YEARLY":
+		for i := 0; ; i++ {
+			candidate := at(y+i*interval, m, d)
+			if candidate.Day() != d {
+				if candidate.After(limit) {
+					break
+				}
+				continue
+			}
+			if !emit(candidate) {
+				break
+			}
+		}
+	default:
+		return []time.Time{start}
+	}
+
+	return occurrences
+}
+
+func containsWeekday(weekdays []time.Weekday, weekday time.Weekday) bool {
+	for _, candidate := range weekdays {
+		if candidate == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func unescapeICalText(text string) string {
+	replacer := strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	)
+	return replacer.Replace(text)
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultIdempotencyRetentionHours = 24
+	maxIdempotencyRetentionHours     = 720
+
+	maxIdempotencyKeyLength = 255
+)
+
+// unreplayedHeaders are set again for every response
+var unreplayedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Date":              true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+}
+
+type IdempotencyService interface {
+	Begin(actorType string, actorID int, key, requestHash string) (*models.IdempotencyKey, bool, error)
+	Complete(record *models.IdempotencyKey, statusCode int, headers http.Header, body []byte) error
+	Release(record *models.IdempotencyKey) error
+}
+
+type idempotencyService struct {
+	idempotencyRepo repository.IdempotencyRepository
+	settingsRepo    repository.SettingsRepository
+	// lockTimeout is how long a key stays held by a request that never
+	// completed, e.g. because the server restarted while running it
+	lockTimeout time.Duration
+}
+
+func NewIdempotencyService(idempotencyRepo repository.IdempotencyRepository, settingsRepo repository.SettingsRepository, cfg *config.Config) IdempotencyService {
+	return &idempotencyService{
+		idempotencyRepo: idempotencyRepo,
+		settingsRepo:    settingsRepo,
+		lockTimeout:     cfg.Server.IdempotencyLockTimeout,
+	}
+}
+
+// validateIdempotencySetting checks the idempotency settings before they are saved
+func validateIdempotencySetting(key, value string) error {
+	if key == "idempotency_key_retention_hours" {
+		hours, err := strconv.Atoi(value)
+		if err != nil || hours < 1 || hours > maxIdempotencyRetentionHours {
+			return errors.New("invalid idempotency_key_retention_hours: use a whole number of hours between 1 and 720")
+		}
+	}
+	return nil
+}
+
+// retention is how long responses are kept, the tenant's
+// idempotency_key_retention_hours setting
+func (s *idempotencyService) retention() time.Duration {
+	hours := defaultIdempotencyRetentionHours
+	if setting, err := s.settingsRepo.GetByKey("idempotency_key_retention_hours"); err == nil {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(setting.Value)); err == nil && parsed >= 1 && parsed <= maxIdempotencyRetentionHours {
+			hours = parsed
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Begin claims the key for a request. A new key is returned to be completed
+// or released once the request has run. When the key already has a stored
+// response for the same request it is returned with true to be replayed.
+func (s *idempotencyService) Begin(actorType string, actorID int, key, requestHash string) (*models.IdempotencyKey, bool, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return nil, false, errors.New("invalid Idempotency-Key header, use 1 to 255 characters")
+	}
+
+	now := time.Now()
+	record := &models.IdempotencyKey{
+		Key:         key,
+		ActorType:   actorType,
+		ActorID:     actorID,
+		RequestHash: requestHash,
+	}
+	reserved, err := s.idempotencyRepo.Reserve(record, now.Add(-s.retention()), now.Add(-s.lockTimeout))
+	if err != nil {
+		return nil, false, err
+	}
+	if reserved {
+		return record, false, nil
+	}
+
+	existing, err := s.idempotencyRepo.Get(actorType, actorID, key)
+	if err == sql.ErrNoRows {
+		// released by the request holding it in the meantime
+		return nil, false, errors.New("a request with this idempotency key is in progress, try again")
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, false, errors.New("idempotency key was already used with a different request")
+	}
+	if !existing.Completed() {
+		return nil, false, errors.New("a request with this idempotency key is in progress, try again")
+	}
+	return existing, true, nil
+}
+
+// Complete stores the response for replay with its headers, except those
+// that describe the connection rather than the response
+func (s *idempotencyService) Complete(record *models.IdempotencyKey, statusCode int, headers http.Header, body []byte) error {
+	record.StatusCode = statusCode
+	record.ContentType = headers.Get("Content-Type")
+	record.Headers = http.Header{}
+	for name, values := range headers {
+		if !unreplayedHeaders[http.CanonicalHeaderKey(name)] {
+			record.Headers[name] = values
+		}
+	}
+	record.ResponseBody = body
+
+	err := s.idempotencyRepo.Complete(record)
+	if err == sql.ErrNoRows {
+		return errors.New("idempotency key was taken over by a retry after its lock expired")
+	}
+	return err
+}
+
+// Release frees the key of a request whose response is not kept
+func (s *idempotencyService) Release(record *models.IdempotencyKey) error {
+	return s.idempotencyRepo.Release(record)
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// invoiceDocument is an invoice as printed, shared by the HTML and PDF output
+type invoiceDocument struct {
+	Title  string
+	Number string
+	Date   string
+	Seller models.InvoiceParty
+	Buyer  models.InvoiceParty
+	Items  []invoiceDocumentItem
+	Total  string
+}
+
+type invoiceDocumentItem struct {
+	Description string
+	Quantity    string
+	UnitPrice   string
+	Total       string
+}
+
+func newInvoiceDocument(invoice *models.Invoice, location *time.Location) invoiceDocument {
+	doc := invoiceDocument{
+		Title:  "FATURA",
+		Number: invoice.Number,
+		Date:   invoice.IssuedAt.In(location).Format("02.01.2006"),
+		Seller: invoice.Seller,
+		Buyer:  invoice.Buyer,
+		Total:  formatAmount(invoice.Total),
+	}
+	if invoice.Type == models.InvoiceTypeCreditNote {
+		doc.Title = "İADE FATURASI"
+	}
+	for _, item := range invoice.Items {
+		doc.Items = append(doc.Items, invoiceDocumentItem{
+			Description: item.Description,
+			Quantity:    strconv.Itoa(item.Quantity),
+			UnitPrice:   formatAmount(item.UnitPrice),
+			Total:       formatAmount(item.Total),
+		})
+	}
+	return doc
+}
+
+var invoiceHTMLTemplate = template.Must(template.New("invoice").Parse(`<!DOCTYPE html>
+<html lang="tr">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 13px; color: #222; margin: 40px; }
+h1 { font-size: 22px; margin: 0; }
+.meta { text-align: right; }
+.parties { display: flex; justify-content: space-between; margin: 32px 0; }
+.party { width: 45%; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 6px 4px; border-bottom: 1px solid #ccc; text-align: left; }
+.num { text-align: right; }
+.total { font-weight: bold; }
+</style>
+</head>
+<body>
+<table>
+<tr>
+<td><h1>{{.Title}}</h1></td>
+<td class="meta">No: {{.Number}}<br>Tarih: {{.Date}}</td>
+</tr>
+</table>
+<div class="parties">
+{{define "party"}}<strong>{{.Name}}</strong>{{if .Address}}<br>{{.Address}}{{end}}{{if or .TaxOffice .TaxNumber}}<br>Vergi Dairesi: {{.TaxOffice}} / No: {{.TaxNumber}}{{end}}{{if .Email}}<br>{{.Email}}{{end}}{{if .Phone}}<br>{{.Phone}}{{end}}{{end}}
+<div class="party">Satıcı<br>{{template "party" .Seller}}</div>
+<div class="party">Alıcı<br>{{template "party" .Buyer}}</div>
+</div>
+<table>
+<tr><th>Açıklama</th><th class="num">Miktar</th><th class="num">Birim Fiyat</th><th class="num">Tutar</th></tr>
+{{range .Items}}<tr><td>{{.Description}}</td><td class="num">{{.Quantity}}</td><td class="num">{{.UnitPrice}}</td><td class="num">{{.Total}}</td></tr>
+{{end}}<tr class="total"><td colspan="3" class="num">Toplam</td><td class="num">{{.Total}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+func renderInvoiceHTML(invoice *models.Invoice, location *time.Location) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := invoiceHTMLTemplate.Execute(&buf, newInvoiceDocument(invoice, location)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderInvoicePDF lays the invoice out on one A4 page. It writes the PDF
+// itself with the standard Helvetica fonts, which every viewer has, so no
+// fonts are embedded. Text is encoded as Windows-1254 to print Turkish letters.
+func renderInvoicePDF(invoice *models.Invoice, location *time.Location) []byte {
+	doc := newInvoiceDocument(invoice, location)
+	page := &pdfPage{}
+
+	page.text(pdfBold, 18, 50, 780, doc.Title)
+	page.textRight(pdfRegular, 10, 545, 785, "No: "+doc.Number)
+	page.textRight(pdfRegular, 10, 545, 770, "Tarih: "+doc.Date)
+
+	page.party(50, 730, "Satıcı", doc.Seller)
+	page.party(320, 730, "Alıcı", doc.Buyer)
+
+	y := 600.0
+	page.text(pdfBold, 10, 50, y, "Açıklama")
+	page.textRight(pdfBold, 10, 350, y, "Miktar")
+	page.textRight(pdfBold, 10, 450, y, "Birim Fiyat")
+	page.textRight(pdfBold, 10, 545, y, "Tutar")
+	page.line(50, y-6, 545, y-6)
+
+	for _, item := range doc.Items {
+		y -= 20
+		lines := wrapText(item.Description, 48)
+		page.textRight(pdfRegular, 10, 350, y, item.Quantity)
+		page.textRight(pdfRegular, 10, 450, y, item.UnitPrice)
+		page.textRight(pdfRegular, 10, 545, y, item.Total)
+		for i, line := range lines {
+			if i > 0 {
+				y -= 13
+			}
+			page.text(pdfRegular, 10, 50, y, line)
+		}
+	}
+
+	y -= 12
+	page.line(50, y, 545, y)
+	y -= 18
+	page.textRight(pdfBold, 11, 450, y, "Toplam")
+	page.textRight(pdfBold, 11, 545, y, doc.Total)
+
+	return page.document()
+}
+
+const (
+	pdfRegular = "F1"
+	pdfBold    = "F2"
+)
+
+// pdfPage collects the content stream of a single page
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+func (p *pdfPage) text(font string, size, x, y float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// textRight draws s so that it ends at x
+func (p *pdfPage) textRight(font string, size, x, y float64, s string) {
+	p.text(font, size, x-helveticaWidth(s)*size/1000, y, s)
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+func (p *pdfPage) party(x, y float64, label string, party models.InvoiceParty) {
+	p.text(pdfBold, 10, x, y, label)
+	lines := []string{party.Name, party.Address}
+	if party.TaxOffice != "" || party.TaxNumber != "" {
+		lines = append(lines, "Vergi Dairesi: "+party.TaxOffice+" / No: "+party.TaxNumber)
+	}
+	lines = append(lines, party.Email, party.Phone)
+
+	for _, line := range lines {
+		for _, wrapped := range wrapText(line, 42) {
+			y -= 13
+			p.text(pdfRegular, 9, x, y, wrapped)
+		}
+	}
+}
+
+// document wraps the page in a complete PDF file with its cross-reference table
+func (p *pdfPage) document() []byte {
+	encoding := "<< /Type /Encoding /BaseEncoding /WinAnsiEncoding " +
+		"/Differences [208 /Gbreve 221 /Idotaccent 222 /Scedilla 240 /gbreve 253 /dotlessi 254 /scedilla] >>"
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] " +
+			"/Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding 7 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding 7 0 R >>",
+		encoding,
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfString encodes s as Windows-1254 and escapes it for a PDF string literal.
+// Characters the encoding lacks are printed as '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		var c byte
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			c = byte(r)
+		case r >= 0x20 && r < 0x7f:
+			c = byte(r)
+		case r == 'Ğ':
+			c = 0xd0
+		case r == 'İ':
+			c = 0xdd
+		case r == 'Ş':
+			c = 0xde
+		case r == 'ğ':
+			c = 0xf0
+		case r == 'ı':
+			c = 0xfd
+		case r == 'ş':
+			c = 0xfe
+		case r == '€':
+			c = 0x80
+		case r >= 0xa0 && r <= 0xff && r != 0xd0 && r != 0xdd && r != 0xde && r != 0xf0 && r != 0xfd && r != 0xfe:
+			c = byte(r)
+		default:
+			c = '?'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// helveticaWidth approximates the width of s in thousandths of the font size,
+// exact for the digits and punctuation of amounts
+func helveticaWidth(s string) float64 {
+	width := 0.0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			width += 556
+		case r == '.' || r == ',' || r == ' ' || r == ':' || r == 'ı' || r == 'i' || r == 'l':
+			width += 278
+		case r == '-' || r == 'r' || r == 't' || r == 'f':
+			width += 333
+		case r == 'm' || r == 'M' || r == 'W':
+			width += 833
+		case r >= 'A' && r <= 'Z' || r == 'İ' || r == 'Ş' || r == 'Ğ':
+			width += 667
+		default:
+			width += 556
+		}
+	}
+	return width
+}
+
+// wrapText breaks s into lines of at most width characters at spaces
+func wrapText(s string, width int) []string {
+	words := strings.Fields(s)
+	var lines []string
+	var line string
+	for _, word := range words {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type InvoiceService interface {
+	IssueForPayment(paymentID int) (*models.Invoice, error)
+	IssueCreditNote(payment *models.Payment, refund *models.Refund) (*models.Invoice, error)
+	IssueForPackage(customerPackage *models.CustomerPackage) (*models.Invoice, error)
+	GetByID(id int) (*models.Invoice, error)
+	GetByPaymentID(paymentID int) (*models.Invoice, error)
+	GetUserInvoices(userID int, limit, offset int) ([]*models.Invoice, int, error)
+	List(filter models.InvoiceFilter, limit, offset int) ([]*models.Invoice, int, error)
+	Render(invoice *models.Invoice, format string) ([]byte, string, error)
+	ExportUBL(filter models.InvoiceFilter) ([]byte, error)
+}
+
+type invoiceService struct {
+	invoiceRepo     repository.InvoiceRepository
+	paymentRepo     repository.PaymentRepository
+	appointmentRepo repository.AppointmentRepository
+	serviceRepo     repository.ServiceRepository
+	userRepo        repository.UserRepository
+	settingsRepo    repository.SettingsRepository
+	location        *time.Location
+}
+
+func NewInvoiceService(invoiceRepo repository.InvoiceRepository, paymentRepo repository.PaymentRepository, appointmentRepo repository.AppointmentRepository, serviceRepo repository.ServiceRepository, userRepo repository.UserRepository, settingsRepo repository.SettingsRepository, cfg *config.Config) InvoiceService {
+	return &invoiceService{
+		invoiceRepo:     invoiceRepo,
+		paymentRepo:     paymentRepo,
+		appointmentRepo: appointmentRepo,
+		serviceRepo:     serviceRepo,
+		userRepo:        userRepo,
+		settingsRepo:    settingsRepo,
+		location:        loadLocation(cfg.Calendar.TimeZone),
+	}
+}
+
+// Default series prefixes, tenants can change them in settings
+const (
+	defaultInvoicePrefix    = "INV"
+	defaultCreditNotePrefix = "CRN"
+)
+
+var invoicePrefixPattern = regexp.MustCompile(`^[A-Z0-9]{3}$`)
+
+// validateInvoiceSetting checks the invoice settings before they are saved
+func validateInvoiceSetting(key, value string) error {
+	switch key {
+	case "invoice_prefix", "credit_note_prefix":
+		if !invoicePrefixPattern.MatchString(value) {
+			return fmt.Errorf("invalid %s: must be 3 uppercase letters or digits", key)
+		}
+	case "invoice_seller_tax_number":
+		if value != "" && !models.IsTaxNumber(value) {
+			return errors.New("invalid invoice_seller_tax_number: must be a valid VKN or TCKN")
+		}
+	case "einvoice_profile":
+		if value != EInvoiceProfileEArsiv && value != EInvoiceProfileBasic && value != EInvoiceProfileTrade {
+			return fmt.Errorf("invalid einvoice_profile: use %s, %s or %s", EInvoiceProfileEArsiv, EInvoiceProfileBasic, EInvoiceProfileTrade)
+		}
+	case "tax_rate":
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 100 {
+			return errors.New("invalid tax_rate: use a number between 0 and 100")
+		}
+	}
+	return nil
+}
+
+// IssueForPayment invoices a completed payment. A payment has one invoice,
+// issuing it again returns the existing one.
+func (s *invoiceService) IssueForPayment(paymentID int) (*models.Invoice, error) {
+	payment, err := s.paymentRepo.GetByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, errors.New("payment not found")
+	}
+	if payment.Status != models.PaymentCompleted {
+		return nil, errors.New("only completed payments can be invoiced")
+	}
+	return s.invoiceFor(payment)
+}
+
+// invoiceFor returns the payment's invoice, issuing it when there is none yet
+func (s *invoiceService) invoiceFor(payment *models.Payment) (*models.Invoice, error) {
+	if existing, err := s.invoiceRepo.GetByPaymentID(payment.ID); err != nil || existing != nil {
+		return existing, err
+	}
+
+	appointment, err := s.appointmentRepo.GetByID(payment.AppointmentID)
+	if err != nil {
+		return nil, errors.New("appointment not found")
+	}
+
+	description := fmt.Sprintf("Randevu #%d", appointment.ID)
+	if service, err := s.serviceRepo.GetByID(appointment.ServiceID); err == nil {
+		description = service.Name
+	}
+	description += " - " + appointmentStartTime(appointment, s.location).Format("02.01.2006 15:04")
+
+	invoice := &models.Invoice{
+		Type:          models.InvoiceTypeInvoice,
+		PaymentID:     payment.ID,
+		AppointmentID: appointment.ID,
+		UserID:        appointment.UserID,
+		Total:         payment.Amount,
+		Seller:        s.seller(),
+		Buyer:         s.buyer(appointment.UserID),
+		Items: []models.InvoiceItem{
+			{Description: description, Quantity: 1, UnitPrice: payment.Amount, Total: payment.Amount, TaxRate: payment.TaxRate},
+		},
+	}
+	invoice.SetCurrency(payment.Currency)
+
+	if err := s.create(invoice, s.prefix("invoice_prefix", defaultInvoicePrefix)); err != nil {
+		// A concurrent request may have invoiced the payment first
+		if existing, getErr := s.invoiceRepo.GetByPaymentID(payment.ID); getErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// IssueForPackage invoices a package sale, the sessions are paid for up
+// front. A sale has one invoice, issuing it again returns the existing one.
+func (s *invoiceService) IssueForPackage(customerPackage *models.CustomerPackage) (*models.Invoice, error) {
+	if existing, err := s.invoiceRepo.GetByCustomerPackageID(customerPackage.ID); err != nil || existing != nil {
+		return existing, err
+	}
+
+	customerPackageID := customerPackage.ID
+	invoice := &models.Invoice{
+		Type:              models.InvoiceTypeInvoice,
+		CustomerPackageID: &customerPackageID,
+		UserID:            customerPackage.UserID,
+		Total:             customerPackage.Price,
+		Seller:            s.seller(),
+		Buyer:             s.buyer(customerPackage.UserID),
+		Items: []models.InvoiceItem{
+			{Description: "Paket: " + customerPackage.Name, Quantity: 1, UnitPrice: customerPackage.Price, Total: customerPackage.Price, TaxRate: customerPackage.TaxRate},
+		},
+	}
+	invoice.SetCurrency(customerPackage.Currency)
+
+	if err := s.create(invoice, s.prefix("invoice_prefix", defaultInvoicePrefix)); err != nil {
+		if existing, getErr := s.invoiceRepo.GetByCustomerPackageID(customerPackage.ID); getErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// IssueCreditNote credits a refund against the payment's invoice, issuing the
+// invoice first when the payment was never invoiced. Without a refund record,
+// e.g. when staff mark a payment refunded, what is left on the invoice is
+// credited. A refund is credited once.
+func (s *invoiceService) IssueCreditNote(payment *models.Payment, refund *models.Refund) (*models.Invoice, error) {
+	if refund != nil {
+		if existing, err := s.invoiceRepo.GetByRefundID(refund.ID); err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	original, err := s.invoiceFor(payment)
+	if err != nil {
+		return nil, err
+	}
+
+	credited, err := s.invoiceRepo.CreditedTotal(original.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !credited.SameCurrency(original.Total) || refund != nil && !refund.Amount.SameCurrency(original.Total) {
+		return nil, models.ErrCurrencyMismatch
+	}
+	amount := original.Total.Sub(credited)
+	var refundID *int
+	if refund != nil {
+		amount = refund.Amount
+		refundID = &refund.ID
+	}
+	if amount.Amount <= 0 || credited.Add(amount).Amount > original.Total.Amount {
+		return nil, errors.New("invoice is already credited")
+	}
+
+	// The refund is taxed like what it refunds
+	taxRate := payment.TaxRate
+	if len(original.Items) > 0 {
+		taxRate = original.Items[0].TaxRate
+	}
+
+	originalID := original.ID
+	creditNote := &models.Invoice{
+		Type:              models.InvoiceTypeCreditNote,
+		PaymentID:         payment.ID,
+		RefundID:          refundID,
+		OriginalInvoiceID: &originalID,
+		AppointmentID:     original.AppointmentID,
+		UserID:            original.UserID,
+		Total:             amount,
+		Seller:            s.seller(),
+		Buyer:             original.Buyer,
+		Items: []models.InvoiceItem{
+			{Description: "İade: " + original.Number, Quantity: 1, UnitPrice: amount, Total: amount, TaxRate: taxRate},
+		},
+	}
+	creditNote.SetCurrency(original.Currency)
+
+	if err := s.create(creditNote, s.prefix("credit_note_prefix", defaultCreditNotePrefix)); err != nil {
+		return nil, err
+	}
+	return creditNote, nil
+}
+
+// create numbers the invoice in its issue year in the tenant's time zone
+func (s *invoiceService) create(invoice *models.Invoice, prefix string) error {
+	id, err := newUUID()
+	if err != nil {
+		return err
+	}
+	invoice.UUID = id
+
+	now := time.Now().In(s.location)
+	invoice.IssuedAt = now
+	invoice.Year = now.Year()
+	return s.invoiceRepo.Create(invoice, prefix)
+}
+
+// buyer is the customer as printed on the invoice, empty when the user is gone
+func (s *invoiceService) buyer(userID int) models.InvoiceParty {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return models.InvoiceParty{}
+	}
+	return models.InvoiceParty{
+		Name:      user.Name,
+		Address:   user.Address,
+		District:  user.District,
+		City:      user.City,
+		TaxOffice: user.TaxOffice,
+		TaxNumber: user.TaxNumber,
+		Email:     user.Email,
+		Phone:     user.Phone,
+	}
+}
+
+// seller reads the tenant's invoice_seller_* settings
+func (s *invoiceService) seller() models.InvoiceParty {
+	return models.InvoiceParty{
+		Name:      s.setting("invoice_seller_name"),
+		Address:   s.setting("invoice_seller_address"),
+		District:  s.setting("invoice_seller_district"),
+		City:      s.setting("invoice_seller_city"),
+		Country:   s.setting("invoice_seller_country"),
+		TaxOffice: s.setting("invoice_seller_tax_office"),
+		TaxNumber: s.setting("invoice_seller_tax_number"),
+		Email:     s.setting("invoice_seller_email"),
+		Phone:     s.setting("invoice_seller_phone"),
+	}
+}
+
+func (s *invoiceService) prefix(key, fallback string) string {
+	if value := s.setting(key); invoicePrefixPattern.MatchString(value) {
+		return value
+	}
+	return fallback
+}
+
+func (s *invoiceService) setting(key string) string {
+	setting, err := s.settingsRepo.GetByKey(key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(setting.Value)
+}
+
+// GetByPaymentID returns the payment's invoice, nil when it has none yet
+func (s *invoiceService) GetByPaymentID(paymentID int) (*models.Invoice, error) {
+	return s.invoiceRepo.GetByPaymentID(paymentID)
+}
+
+func (s *invoiceService) GetByID(id int) (*models.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if invoice == nil {
+		return nil, errors.New("invoice not found")
+	}
+	return invoice, nil
+}
+
+func (s *invoiceService) GetUserInvoices(userID int, limit, offset int) ([]*models.Invoice, int, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.invoiceRepo.ListByUserID(userID, limit, offset)
+}
+
+func (s *invoiceService) List(filter models.InvoiceFilter, limit, offset int) ([]*models.Invoice, int, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.invoiceRepo.List(filter, limit, offset)
+}
+
+// Invoice download formats
+const (
+	InvoiceFormatPDF  = "pdf"
+	InvoiceFormatHTML = "html"
+	InvoiceFormatUBL  = "xml" // UBL-TR e-invoice
+)
+
+// Render returns the invoice document and its content type
+func (s *invoiceService) Render(invoice *models.Invoice, format string) ([]byte, string, error) {
+	switch format {
+	case "", InvoiceFormatPDF:
+		return renderInvoicePDF(invoice, s.location), "application/pdf", nil
+	case InvoiceFormatHTML:
+		body, err := renderInvoiceHTML(invoice, s.location)
+		if err != nil {
+			return nil, "", err
+		}
+		return body, "text/html; charset=utf-8", nil
+	case InvoiceFormatUBL:
+		body, err := s.renderUBL(invoice)
+		if err != nil {
+			return nil, "", err
+		}
+		return body, "application/xml; charset=utf-8", nil
+	default:
+		return nil, "", errors.New("unsupported invoice format")
+	}
+}
+
+// renderUBL writes the invoice as a UBL-TR document in the tenant's einvoice_profile
+func (s *invoiceService) renderUBL(invoice *models.Invoice) ([]byte, error) {
+	var original *models.Invoice
+	if invoice.OriginalInvoiceID != nil {
+		var err error
+		if original, err = s.invoiceRepo.GetByID(*invoice.OriginalInvoiceID); err != nil {
+			return nil, err
+		}
+	}
+
+	profile := s.setting("einvoice_profile")
+	if profile == "" {
+		profile = EInvoiceProfileEArsiv
+	}
+	return renderInvoiceUBL(invoice, original, profile, s.location)
+}
+
+// ExportUBL zips the UBL-TR documents of the filtered invoices, one file per
+// invoice named after its number. Nothing is exported when one of them is invalid.
+func (s *invoiceService) ExportUBL(filter models.InvoiceFilter) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	// Invoices issued during the export shift the pages, seen skips repeats
+	seen := map[int]bool{}
+	const pageSize = 100
+	for offset := 0; ; offset += pageSize {
+		page, _, err := s.invoiceRepo.List(filter, pageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, listed := range page {
+			if seen[listed.ID] {
+				continue
+			}
+			seen[listed.ID] = true
+
+			// Lists leave out the items
+			invoice, err := s.GetByID(listed.ID)
+			if err != nil {
+				return nil, err
+			}
+			body, err := s.renderUBL(invoice)
+			if err != nil {
+				return nil, err
+			}
+			file, err := archive.Create(invoice.Number + ".xml")
+			if err != nil {
+				return nil, err
+			}
+			if _, err := file.Write(body); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UBL-TR profiles a tenant can export its invoices with
+const (
+	EInvoiceProfileEArsiv = "EARSIVFATURA" // customers outside the e-Fatura system
+	EInvoiceProfileBasic  = "TEMELFATURA"
+	EInvoiceProfileTrade  = "TICARIFATURA"
+)
+
+// ublAnonymousTCKN identifies final consumers who gave no TCKN on e-Arşiv invoices
+const ublAnonymousTCKN = "11111111111"
+
+// ublInvoice is the UBL-TR 1.2 Invoice document. Fields are in the order of
+// the UBL 2.1 schema sequences, which the XML has to follow.
+type ublInvoice struct {
+	XMLName              xml.Name             `xml:"Invoice"`
+	Xmlns                string               `xml:"xmlns,attr"`
+	XmlnsCac             string               `xml:"xmlns:cac,attr"`
+	XmlnsCbc             string               `xml:"xmlns:cbc,attr"`
+	XmlnsExt             string               `xml:"xmlns:ext,attr"`
+	Extension            ublExtensionContent  `xml:"ext:UBLExtensions>ext:UBLExtension>ext:ExtensionContent"`
+	UBLVersionID         string               `xml:"cbc:UBLVersionID"`
+	CustomizationID      string               `xml:"cbc:CustomizationID"`
+	ProfileID            string               `xml:"cbc:ProfileID"`
+	ID                   string               `xml:"cbc:ID"`
+	CopyIndicator        bool                 `xml:"cbc:CopyIndicator"`
+	UUID                 string               `xml:"cbc:UUID"`
+	IssueDate            string               `xml:"cbc:IssueDate"`
+	IssueTime            string               `xml:"cbc:IssueTime"`
+	InvoiceTypeCode      string               `xml:"cbc:InvoiceTypeCode"`
+	DocumentCurrencyCode string               `xml:"cbc:DocumentCurrencyCode"`
+	LineCountNumeric     int                  `xml:"cbc:LineCountNumeric"`
+	BillingReference     *ublBillingReference `xml:"cac:BillingReference"`
+	Signature            ublSignature         `xml:"cac:Signature"`
+	Supplier             ublParty             `xml:"cac:AccountingSupplierParty>cac:Party"`
+	Customer             ublParty             `xml:"cac:AccountingCustomerParty>cac:Party"`
+	TaxTotal             ublTaxTotal          `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal   ublMonetaryTotal     `xml:"cac:LegalMonetaryTotal"`
+	Lines                []ublInvoiceLine     `xml:"cac:InvoiceLine"`
+}
+
+// ublExtensionContent is left empty for the integrator's XAdES signature
+type ublExtensionContent struct{}
+
+type ublBillingReference struct {
+	ID               string `xml:"cac:InvoiceDocumentReference>cbc:ID"`
+	IssueDate        string `xml:"cac:InvoiceDocumentReference>cbc:IssueDate"`
+	DocumentTypeCode string `xml:"cac:InvoiceDocumentReference>cbc:DocumentTypeCode"`
+}
+
+type ublSignature struct {
+	ID            ublID      `xml:"cbc:ID"`
+	SignatoryID   ublID      `xml:"cac:SignatoryParty>cac:PartyIdentification>cbc:ID"`
+	SignatoryAddr ublAddress `xml:"cac:SignatoryParty>cac:PostalAddress"`
+	URI           string     `xml:"cac:DigitalSignatureAttachment>cac:ExternalReference>cbc:URI"`
+}
+
+type ublID struct {
+	SchemeID string `xml:"schemeID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ublParty struct {
+	Identification ublID       `xml:"cac:PartyIdentification>cbc:ID"`
+	Name           *ublName    `xml:"cac:PartyName"`
+	Address        ublAddress  `xml:"cac:PostalAddress"`
+	TaxScheme      *ublName    `xml:"cac:PartyTaxScheme>cac:TaxScheme"` // the tax office
+	Contact        *ublContact `xml:"cac:Contact"`
+	Person         *ublPerson  `xml:"cac:Person"`
+}
+
+type ublName struct {
+	Name string `xml:"cbc:Name"`
+}
+
+type ublAddress struct {
+	StreetName          string `xml:"cbc:StreetName,omitempty"`
+	CitySubdivisionName string `xml:"cbc:CitySubdivisionName"`
+	CityName            string `xml:"cbc:CityName"`
+	Country             string `xml:"cac:Country>cbc:Name"`
+}
+
+type ublContact struct {
+	Telephone      string `xml:"cbc:Telephone,omitempty"`
+	ElectronicMail string `xml:"cbc:ElectronicMail,omitempty"`
+}
+
+type ublPerson struct {
+	FirstName  string `xml:"cbc:FirstName"`
+	FamilyName string `xml:"cbc:FamilyName"`
+}
+
+type ublAmount struct {
+	CurrencyID string `xml:"currencyID,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount ublAmount        `xml:"cbc:TaxAmount"`
+	Subtotals []ublTaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type ublTaxSubtotal struct {
+	TaxableAmount ublAmount `xml:"cbc:TaxableAmount"`
+	TaxAmount     ublAmount `xml:"cbc:TaxAmount"`
+	Percent       string    `xml:"cbc:Percent"`
+	SchemeName    string    `xml:"cac:TaxCategory>cac:TaxScheme>cbc:Name"`
+	TaxTypeCode   string    `xml:"cac:TaxCategory>cac:TaxScheme>cbc:TaxTypeCode"`
+}
+
+type ublMonetaryTotal struct {
+	LineExtensionAmount ublAmount `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount  ublAmount `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount  ublAmount `xml:"cbc:TaxInclusiveAmount"`
+	PayableAmount       ublAmount `xml:"cbc:PayableAmount"`
+}
+
+type ublInvoiceLine struct {
+	ID                  int         `xml:"cbc:ID"`
+	InvoicedQuantity    ublQuantity `xml:"cbc:InvoicedQuantity"`
+	LineExtensionAmount ublAmount   `xml:"cbc:LineExtensionAmount"`
+	TaxTotal            ublTaxTotal `xml:"cac:TaxTotal"`
+	ItemName            string      `xml:"cac:Item>cbc:Name"`
+	PriceAmount         ublAmount   `xml:"cac:Price>cbc:PriceAmount"`
+}
+
+type ublQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    int    `xml:",chardata"`
+}
+
+// buildUBLInvoice maps an invoice to UBL-TR. Prices include VAT, so each line
+// is split into net and tax by its rate. original is the credited invoice of
+// a credit note, which is exported as an IADE invoice referencing it.
+func buildUBLInvoice(invoice, original *models.Invoice, profile string, location *time.Location) *ublInvoice {
+	issued := invoice.IssuedAt.In(location)
+	currency := invoice.Currency
+	amount := func(m models.Money) ublAmount {
+		return ublAmount{CurrencyID: currency, Value: m.Decimal()}
+	}
+
+	doc := &ublInvoice{
+		Xmlns:                "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
+		XmlnsCac:             "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		XmlnsCbc:             "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
+		XmlnsExt:             "urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2",
+		UBLVersionID:         "2.1",
+		CustomizationID:      "TR1.2",
+		ProfileID:            profile,
+		ID:                   invoice.Number,
+		UUID:                 invoice.UUID,
+		IssueDate:            issued.Format("2006-01-02"),
+		IssueTime:            issued.Format("15:04:05"),
+		InvoiceTypeCode:      "SATIS",
+		DocumentCurrencyCode: currency,
+		LineCountNumeric:     len(invoice.Items),
+	}
+	if invoice.Type == models.InvoiceTypeCreditNote {
+		doc.InvoiceTypeCode = "IADE"
+		// IADE invoices are not allowed on the commercial profile
+		if profile == EInvoiceProfileTrade {
+			doc.ProfileID = EInvoiceProfileBasic
+		}
+		if original != nil {
+			doc.BillingReference = &ublBillingReference{
+				ID:               original.Number,
+				IssueDate:        original.IssuedAt.In(location).Format("2006-01-02"),
+				DocumentTypeCode: "IADE",
+			}
+		}
+	}
+
+	seller := invoice.Seller
+	sellerAddress := ublPartyAddress(seller, models.InvoiceParty{})
+	doc.Supplier = ublParty{
+		Identification: ublID{SchemeID: ublScheme(seller.TaxNumber), Value: seller.TaxNumber},
+		Name:           &ublName{Name: seller.Name},
+		Address:        sellerAddress,
+		TaxScheme:      ublTaxOffice(seller.TaxOffice),
+		Contact:        ublPartyContact(seller),
+	}
+	if ublScheme(seller.TaxNumber) == "TCKN" {
+		// Sole proprietors are identified by their TCKN and named as a person
+		doc.Supplier.Person = ublPersonName(seller.Name)
+	}
+	doc.Signature = ublSignature{
+		ID:            ublID{SchemeID: "VKN_TCKN", Value: seller.TaxNumber},
+		SignatoryID:   ublID{SchemeID: ublScheme(seller.TaxNumber), Value: seller.TaxNumber},
+		SignatoryAddr: sellerAddress,
+		URI:           "#Signature_" + invoice.Number,
+	}
+
+	// Walk-in customers without an address are billed at the clinic, as for retail sales
+	buyer := invoice.Buyer
+	buyerNumber := buyer.TaxNumber
+	if buyerNumber == "" && profile == EInvoiceProfileEArsiv {
+		buyerNumber = ublAnonymousTCKN
+	}
+	doc.Customer = ublParty{
+		Identification: ublID{SchemeID: ublScheme(buyerNumber), Value: buyerNumber},
+		Address:        ublPartyAddress(buyer, seller),
+		TaxScheme:      ublTaxOffice(buyer.TaxOffice),
+		Contact:        ublPartyContact(buyer),
+	}
+	if ublScheme(buyerNumber) == "VKN" {
+		doc.Customer.Name = &ublName{Name: buyer.Name}
+	} else {
+		doc.Customer.Person = ublPersonName(buyer.Name)
+	}
+
+	type taxGroup struct {
+		net, tax models.Money
+	}
+	groups := map[float64]*taxGroup{}
+	var rates []float64
+	net := models.NewMoney(0, currency)
+	tax := models.NewMoney(0, currency)
+
+	for i, item := range invoice.Items {
+		lineNet, lineTax := splitTax(item.Total, item.TaxRate)
+		unitNet, _ := splitTax(item.UnitPrice, item.TaxRate)
+		subtotal := ublTaxSubtotal{
+			TaxableAmount: amount(lineNet),
+			TaxAmount:     amount(lineTax),
+			Percent:       formatRate(item.TaxRate),
+			SchemeName:    "KDV",
+			TaxTypeCode:   "0015",
+		}
+		doc.Lines = append(doc.Lines, ublInvoiceLine{
+			ID:                  i + 1,
+			InvoicedQuantity:    ublQuantity{UnitCode: "C62", Value: item.Quantity},
+			LineExtensionAmount: amount(lineNet),
+			TaxTotal:            ublTaxTotal{TaxAmount: amount(lineTax), Subtotals: []ublTaxSubtotal{subtotal}},
+			ItemName:            item.Description,
+			PriceAmount:         amount(unitNet),
+		})
+
+		group, ok := groups[item.TaxRate]
+		if !ok {
+			group = &taxGroup{}
+			groups[item.TaxRate] = group
+			rates = append(rates, item.TaxRate)
+		}
+		group.net = group.net.Add(lineNet)
+		group.tax = group.tax.Add(lineTax)
+		net = net.Add(lineNet)
+		tax = tax.Add(lineTax)
+	}
+
+	doc.TaxTotal.TaxAmount = amount(tax)
+	for _, rate := range rates {
+		doc.TaxTotal.Subtotals = append(doc.TaxTotal.Subtotals, ublTaxSubtotal{
+			TaxableAmount: amount(groups[rate].net),
+			TaxAmount:     amount(groups[rate].tax),
+			Percent:       formatRate(rate),
+			SchemeName:    "KDV",
+			TaxTypeCode:   "0015",
+		})
+	}
+	doc.LegalMonetaryTotal = ublMonetaryTotal{
+		LineExtensionAmount: amount(net),
+		TaxExclusiveAmount:  amount(net),
+		TaxInclusiveAmount:  amount(invoice.Total),
+		PayableAmount:       amount(invoice.Total),
+	}
+	return doc
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// ublScheme names the identifier scheme of a TCKN or VKN
+func ublScheme(number string) string {
+	if len(number) == 10 {
+		return "VKN"
+	}
+	return "TCKN"
+}
+
+// ublPartyAddress is the party's address, or fallback's when it has no city
+func ublPartyAddress(party, fallback models.InvoiceParty) ublAddress {
+	if party.City == "" {
+		party.Address, party.District, party.City, party.Country = fallback.Address, fallback.District, fallback.City, fallback.Country
+	}
+	country := party.Country
+	if country == "" {
+		country = "Türkiye"
+	}
+	return ublAddress{StreetName: party.Address, CitySubdivisionName: party.District, CityName: party.City, Country: country}
+}
+
+func ublTaxOffice(office string) *ublName {
+	if office == "" {
+		return nil
+	}
+	return &ublName{Name: office}
+}
+
+func ublPartyContact(party models.InvoiceParty) *ublContact {
+	if party.Phone == "" && party.Email == "" {
+		return nil
+	}
+	return &ublContact{Telephone: party.Phone, ElectronicMail: party.Email}
+}
+
+// ublPersonName splits a full name into first and family name at the last space
+func ublPersonName(name string) *ublPerson {
+	fields := strings.Fields(name)
+	if len(fields) < 2 {
+		return &ublPerson{FirstName: name, FamilyName: name}
+	}
+	return &ublPerson{FirstName: strings.Join(fields[:len(fields)-1], " "), FamilyName: fields[len(fields)-1]}
+}
+
+var (
+	ublNumberPattern = regexp.MustCompile(`^[A-Z0-9]{3}20[0-9]{2}[0-9]{9}$`)
+	ublUUIDPattern   = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// validateUBLInvoice checks the rules of the UBL-TR 1.2 schema and schematron
+// the exported documents depend on, so a tenant with incomplete settings gets
+// a clear error instead of a rejected filing
+func validateUBLInvoice(doc *ublInvoice) error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(doc.ProfileID == EInvoiceProfileEArsiv || doc.ProfileID == EInvoiceProfileBasic || doc.ProfileID == EInvoiceProfileTrade,
+		"unknown profile "+doc.ProfileID)
+	check(ublNumberPattern.MatchString(doc.ID), "number must be 3 letters, the year and 9 digits")
+	check(ublUUIDPattern.MatchString(doc.UUID), "uuid is missing")
+	check(models.IsCurrency(doc.DocumentCurrencyCode), "unknown currency")
+	check(doc.InvoiceTypeCode != "IADE" || doc.BillingReference != nil, "credit note has no original invoice")
+	check(doc.InvoiceTypeCode != "IADE" || doc.ProfileID != EInvoiceProfileTrade, "credit notes cannot use the "+doc.ProfileID+" profile")
+	check(doc.LineCountNumeric > 0 && doc.LineCountNumeric == len(doc.Lines), "line count does not match the lines")
+
+	checkParty := func(role string, party ublParty) {
+		number := party.Identification.Value
+		check(models.IsTaxNumber(number) || role == "buyer" && number == ublAnonymousTCKN, role+" tax number (VKN/TCKN) is missing or invalid")
+		check(party.Name != nil && party.Name.Name != "" || party.Person != nil && party.Person.FirstName != "", role+" name is missing")
+		check(party.Address.CitySubdivisionName != "", role+" district is missing")
+		check(party.Address.CityName != "", role+" city is missing")
+	}
+	checkParty("seller", doc.Supplier)
+	check(doc.Supplier.TaxScheme != nil, "seller tax office is missing")
+	checkParty("buyer", doc.Customer)
+	check(doc.ProfileID == EInvoiceProfileEArsiv || doc.Customer.Identification.Value != ublAnonymousTCKN,
+		"buyer tax number is required for "+doc.ProfileID)
+
+	// The totals have to add up exactly, in minor units
+	sum := func(values ...string) int64 {
+		var total int64
+		for _, value := range values {
+			m, err := models.ParseMoney(value, "")
+			check(err == nil, "invalid amount "+value)
+			total += m.Amount
+		}
+		return total
+	}
+	var lineNet, lineTax []string
+	for _, line := range doc.Lines {
+		lineNet = append(lineNet, line.LineExtensionAmount.Value)
+		lineTax = append(lineTax, line.TaxTotal.TaxAmount.Value)
+	}
+	totals := doc.LegalMonetaryTotal
+	check(sum(lineNet...) == sum(totals.LineExtensionAmount.Value), "line amounts do not add up to the total")
+	check(sum(lineTax...) == sum(doc.TaxTotal.TaxAmount.Value), "line taxes do not add up to the tax total")
+	check(sum(totals.TaxExclusiveAmount.Value, doc.TaxTotal.TaxAmount.Value) == sum(totals.TaxInclusiveAmount.Value),
+		"net and tax do not add up to the gross total")
+	check(sum(totals.PayableAmount.Value) > 0, "payable amount must be positive")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid e-invoice %s: %s", doc.ID, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// renderInvoiceUBL validates and writes the UBL-TR XML document
+func renderInvoiceUBL(invoice, original *models.Invoice, profile string, location *time.Location) ([]byte, error) {
+	doc := buildUBLInvoice(invoice, original, profile, location)
+	if err := validateUBLInvoice(doc); err != nil {
+		return nil, err
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,530 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The GİB UBL-TR 1.2 package (XSD and schematron) is not vendored, so these
+// tests check the rendered XML against the parts of it the export relies on:
+// the UBL 2.1 element sequences and cardinalities of the elements written,
+// and the GİB schematron rules for the header, parties, taxes and totals.
+
+const (
+	ublNamespaceInvoice = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	ublNamespaceCAC     = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	ublNamespaceCBC     = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+	ublNamespaceEXT     = "urn:oasis:names:specification:ubl:schema:xsd:CommonExtensionComponents-2"
+)
+
+// ublElement is one element of a rendered document, with namespaces resolved
+type ublElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Text     string       `xml:",chardata"`
+	Children []ublElement `xml:",any"`
+}
+
+func (e ublElement) attr(name string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// all returns the descendants at path, e.g. "cac:TaxTotal/cbc:TaxAmount"
+func (e ublElement) all(path string) []ublElement {
+	current := []ublElement{e}
+	for _, step := range strings.Split(path, "/") {
+		var next []ublElement
+		for _, element := range current {
+			for _, child := range element.Children {
+				if ublQualifiedName(child.XMLName) == step {
+					next = append(next, child)
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func (e ublElement) text(path string) string {
+	if found := e.all(path); len(found) > 0 {
+		return strings.TrimSpace(found[0].Text)
+	}
+	return ""
+}
+
+func ublQualifiedName(name xml.Name) string {
+	switch name.Space {
+	case ublNamespaceCAC:
+		return "cac:" + name.Local
+	case ublNamespaceCBC:
+		return "cbc:" + name.Local
+	case ublNamespaceEXT:
+		return "ext:" + name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// ublSequence is an xs:sequence of the UBL 2.1 schema, limited to the
+// elements the export writes. max is 0 for unbounded elements.
+type ublSequence []struct {
+	name     string
+	min, max int
+}
+
+var ublSequences = map[string]ublSequence{
+	"Invoice": {
+		{"ext:UBLExtensions", 0, 1},
+		{"cbc:UBLVersionID", 1, 1},
+		{"cbc:CustomizationID", 1, 1},
+		{"cbc:ProfileID", 1, 1},
+		{"cbc:ID", 1, 1},
+		{"cbc:CopyIndicator", 1, 1},
+		{"cbc:UUID", 1, 1},
+		{"cbc:IssueDate", 1, 1},
+		{"cbc:IssueTime", 0, 1},
+		{"cbc:InvoiceTypeCode", 1, 1},
+		{"cbc:DocumentCurrencyCode", 1, 1},
+		{"cbc:LineCountNumeric", 1, 1},
+		{"cac:BillingReference", 0, 0},
+		{"cac:Signature", 1, 0},
+		{"cac:AccountingSupplierParty", 1, 1},
+		{"cac:AccountingCustomerParty", 1, 1},
+		{"cac:TaxTotal", 1, 0},
+		{"cac:LegalMonetaryTotal", 1, 1},
+		{"cac:InvoiceLine", 1, 0},
+	},
+	"cac:Signature": {
+		{"cbc:ID", 1, 1},
+		{"cac:SignatoryParty", 0, 1},
+		{"cac:DigitalSignatureAttachment", 0, 1},
+	},
+	"cac:Party": {
+		{"cac:PartyIdentification", 1, 0},
+		{"cac:PartyName", 0, 1},
+		{"cac:PostalAddress", 1, 1},
+		{"cac:PartyTaxScheme", 0, 1},
+		{"cac:Contact", 0, 1},
+		{"cac:Person", 0, 1},
+	},
+	"cac:PostalAddress": {
+		{"cbc:StreetName", 0, 1},
+		{"cbc:CitySubdivisionName", 1, 1},
+		{"cbc:CityName", 1, 1},
+		{"cac:Country", 1, 1},
+	},
+	"cac:Contact": {
+		{"cbc:Telephone", 0, 1},
+		{"cbc:ElectronicMail", 0, 1},
+	},
+	"cac:Person": {
+		{"cbc:FirstName", 1, 1},
+		{"cbc:FamilyName", 1, 1},
+	},
+	"cac:TaxTotal": {
+		{"cbc:TaxAmount", 1, 1},
+		{"cac:TaxSubtotal", 1, 0},
+	},
+	"cac:TaxSubtotal": {
+		{"cbc:TaxableAmount", 0, 1},
+		{"cbc:TaxAmount", 1, 1},
+		{"cbc:Percent", 0, 1},
+		{"cac:TaxCategory", 1, 1},
+	},
+	"cac:TaxScheme": {
+		{"cbc:Name", 0, 1},
+		{"cbc:TaxTypeCode", 0, 1},
+	},
+	"cac:LegalMonetaryTotal": {
+		{"cbc:LineExtensionAmount", 1, 1},
+		{"cbc:TaxExclusiveAmount", 1, 1},
+		{"cbc:TaxInclusiveAmount", 1, 1},
+		{"cbc:PayableAmount", 1, 1},
+	},
+	"cac:InvoiceLine": {
+		{"cbc:ID", 1, 1},
+		{"cbc:InvoicedQuantity", 1, 1},
+		{"cbc:LineExtensionAmount", 1, 1},
+		{"cac:TaxTotal", 0, 1},
+		{"cac:Item", 1, 1},
+		{"cac:Price", 1, 1},
+	},
+}
+
+// checkUBLSequences walks the document and checks every element with a known
+// sequence for order, unknown children and cardinality
+func checkUBLSequences(t *testing.T, path string, element ublElement) {
+	t.Helper()
+	name := ublQualifiedName(element.XMLName)
+	if path == "" {
+		name = element.XMLName.Local
+	}
+	path += "/" + name
+
+	if sequence, ok := ublSequences[name]; ok {
+		counts := map[string]int{}
+		last := -1
+		for _, child := range element.Children {
+			childName := ublQualifiedName(child.XMLName)
+			position := -1
+			for i, item := range sequence {
+				if item.name == childName {
+					position = i
+				}
+			}
+			switch {
+			case position < 0:
+				t.Errorf("%s: unexpected element %s", path, childName)
+			case position < last:
+				t.Errorf("%s: %s is out of sequence", path, childName)
+			default:
+				last = position
+			}
+			counts[childName]++
+		}
+		for _, item := range sequence {
+			if counts[item.name] < item.min {
+				t.Errorf("%s: %s is required", path, item.name)
+			}
+			if item.max > 0 && counts[item.name] > item.max {
+				t.Errorf("%s: %s occurs %d times, at most %d allowed", path, item.name, counts[item.name], item.max)
+			}
+		}
+	}
+
+	for _, child := range element.Children {
+		checkUBLSequences(t, path, child)
+	}
+}
+
+var ublDatePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+
+// checkUBLRules checks the GİB schematron rules the export has to meet
+func checkUBLRules(t *testing.T, doc ublElement) {
+	t.Helper()
+	if doc.XMLName.Space != ublNamespaceInvoice || doc.XMLName.Local != "Invoice" {
+		t.Fatalf("root element is %s %s", doc.XMLName.Space, doc.XMLName.Local)
+	}
+
+	if got := doc.text("cbc:UBLVersionID"); got != "2.1" {
+		t.Errorf("UBLVersionID = %q, want 2.1", got)
+	}
+	if got := doc.text("cbc:CustomizationID"); got != "TR1.2" {
+		t.Errorf("CustomizationID = %q, want TR1.2", got)
+	}
+	if got := doc.text("cbc:CopyIndicator"); got != "false" {
+		t.Errorf("CopyIndicator = %q, want false", got)
+	}
+	if id := doc.text("cbc:ID"); !ublNumberPattern.MatchString(id) {
+		t.Errorf("ID %q does not match the GİB invoice number pattern", id)
+	}
+	if uuid := doc.text("cbc:UUID"); !ublUUIDPattern.MatchString(uuid) {
+		t.Errorf("UUID %q is not an ETTN", uuid)
+	}
+	if date := doc.text("cbc:IssueDate"); !ublDatePattern.MatchString(date) {
+		t.Errorf("IssueDate %q is not a date", date)
+	}
+
+	profile, typeCode := doc.text("cbc:ProfileID"), doc.text("cbc:InvoiceTypeCode")
+	switch profile {
+	case EInvoiceProfileEArsiv, EInvoiceProfileBasic, EInvoiceProfileTrade:
+	default:
+		t.Errorf("unknown ProfileID %q", profile)
+	}
+	switch typeCode {
+	case "SATIS":
+	case "IADE":
+		if profile == EInvoiceProfileTrade {
+			t.Errorf("IADE invoices are not allowed on %s", profile)
+		}
+		if doc.text("cac:BillingReference/cac:InvoiceDocumentReference/cbc:ID") == "" ||
+			doc.text("cac:BillingReference/cac:InvoiceDocumentReference/cbc:IssueDate") == "" {
+			t.Error("IADE invoice does not reference the returned invoice")
+		}
+	default:
+		t.Errorf("unexpected InvoiceTypeCode %q", typeCode)
+	}
+
+	if got := doc.all("cac:Signature/cbc:ID"); len(got) == 0 || got[0].attr("schemeID") != "VKN_TCKN" {
+		t.Error("Signature ID needs schemeID VKN_TCKN")
+	}
+
+	for _, role := range []string{"cac:AccountingSupplierParty", "cac:AccountingCustomerParty"} {
+		for _, party := range doc.all(role + "/cac:Party") {
+			ids := party.all("cac:PartyIdentification/cbc:ID")
+			if len(ids) == 0 {
+				t.Errorf("%s has no identification", role)
+				continue
+			}
+			number := strings.TrimSpace(ids[0].Text)
+			switch ids[0].attr("schemeID") {
+			case "VKN":
+				if len(number) != 10 {
+					t.Errorf("%s VKN %q is not 10 digits", role, number)
+				}
+				if party.text("cac:PartyName/cbc:Name") == "" {
+					t.Errorf("%s with a VKN needs PartyName", role)
+				}
+			case "TCKN":
+				if len(number) != 11 {
+					t.Errorf("%s TCKN %q is not 11 digits", role, number)
+				}
+				if party.text("cac:Person/cbc:FirstName") == "" || party.text("cac:Person/cbc:FamilyName") == "" {
+					t.Errorf("%s with a TCKN needs Person with FirstName and FamilyName", role)
+				}
+			default:
+				t.Errorf("%s identification has schemeID %q, want VKN or TCKN", role, ids[0].attr("schemeID"))
+			}
+			if party.text("cac:PostalAddress/cbc:CitySubdivisionName") == "" || party.text("cac:PostalAddress/cbc:CityName") == "" ||
+				party.text("cac:PostalAddress/cac:Country/cbc:Name") == "" {
+				t.Errorf("%s address needs district, city and country", role)
+			}
+		}
+	}
+	if doc.text("cac:AccountingSupplierParty/cac:Party/cac:PartyTaxScheme/cac:TaxScheme/cbc:Name") == "" {
+		t.Error("supplier tax office is missing")
+	}
+
+	currency := doc.text("cbc:DocumentCurrencyCode")
+	var checkAmounts func(element ublElement)
+	checkAmounts = func(element ublElement) {
+		if strings.HasSuffix(element.XMLName.Local, "Amount") && element.attr("currencyID") != currency {
+			t.Errorf("%s has currencyID %q, want %q", element.XMLName.Local, element.attr("currencyID"), currency)
+		}
+		for _, child := range element.Children {
+			checkAmounts(child)
+		}
+	}
+	checkAmounts(doc)
+
+	amount := func(element ublElement, path string) int64 {
+		value := element.text(path)
+		m, err := models.ParseMoney(value, currency)
+		if err != nil {
+			t.Errorf("%s: invalid amount %q", path, value)
+		}
+		return m.Amount
+	}
+
+	lines := doc.all("cac:InvoiceLine")
+	if got := doc.text("cbc:LineCountNumeric"); got != strconv.Itoa(len(lines)) {
+		t.Errorf("LineCountNumeric = %s, document has %d lines", got, len(lines))
+	}
+
+	var lineNet, lineTax int64
+	for _, line := range lines {
+		lineNet += amount(line, "cbc:LineExtensionAmount")
+		lineTax += amount(line, "cac:TaxTotal/cbc:TaxAmount")
+		checkTaxTotal(t, line.all("cac:TaxTotal")[0], amount)
+	}
+	for _, taxTotal := range doc.all("cac:TaxTotal") {
+		checkTaxTotal(t, taxTotal, amount)
+	}
+
+	totals := doc.all("cac:LegalMonetaryTotal")[0]
+	if got := amount(totals, "cbc:LineExtensionAmount"); got != lineNet {
+		t.Errorf("LineExtensionAmount = %d, lines add up to %d", got, lineNet)
+	}
+	if got := amount(doc, "cac:TaxTotal/cbc:TaxAmount"); got != lineTax {
+		t.Errorf("TaxAmount = %d, line taxes add up to %d", got, lineTax)
+	}
+	if net, gross := amount(totals, "cbc:TaxExclusiveAmount"), amount(totals, "cbc:TaxInclusiveAmount"); net+lineTax != gross {
+		t.Errorf("TaxExclusiveAmount %d + TaxAmount %d != TaxInclusiveAmount %d", net, lineTax, gross)
+	}
+	if payable := amount(totals, "cbc:PayableAmount"); payable <= 0 || payable != amount(totals, "cbc:TaxInclusiveAmount") {
+		t.Errorf("PayableAmount = %d, want the tax inclusive amount", payable)
+	}
+}
+
+// checkTaxTotal checks that the subtotals add up and are KDV (tax type 0015)
+func checkTaxTotal(t *testing.T, taxTotal ublElement, amount func(ublElement, string) int64) {
+	t.Helper()
+	var sum int64
+	for _, subtotal := range taxTotal.all("cac:TaxSubtotal") {
+		sum += amount(subtotal, "cbc:TaxAmount")
+		if subtotal.text("cac:TaxCategory/cac:TaxScheme/cbc:TaxTypeCode") != "0015" || subtotal.text("cac:TaxCategory/cac:TaxScheme/cbc:Name") != "KDV" {
+			t.Error("tax subtotal is not KDV with tax type code 0015")
+		}
+	}
+	if got := amount(taxTotal, "cbc:TaxAmount"); got != sum {
+		t.Errorf("TaxAmount = %d, subtotals add up to %d", got, sum)
+	}
+}
+
+func renderTestUBL(t *testing.T, invoice, original *models.Invoice, profile string) ublElement {
+	t.Helper()
+	data, err := renderInvoiceUBL(invoice, original, profile, time.UTC)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Error("document has no XML declaration")
+	}
+
+	var doc ublElement
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("rendered XML does not parse: %v\n%s", err, data)
+	}
+	checkUBLSequences(t, "", doc)
+	checkUBLRules(t, doc)
+	return doc
+}
+
+func testUBLInvoice() *models.Invoice {
+	invoice := &models.Invoice{
+		Number: "INV2026000000042",
+		UUID:   "3f2b8c1e-6d4a-4b7e-9c2f-1a5d8e7b6c40",
+		Type:   models.InvoiceTypeInvoice,
+		Total:  models.NewMoney(165000, ""),
+		Seller: models.InvoiceParty{
+			Name:      "Güzellik Merkezi Ltd. Şti.",
+			Address:   "Bağdat Cad. No: 1",
+			District:  "Kadıköy",
+			City:      "İstanbul",
+			TaxOffice: "Kadıköy",
+			TaxNumber: "1234567890",
+			Email:     "info@example.com",
+		},
+		Buyer: models.InvoiceParty{
+			Name:      "Örnek Danışmanlık A.Ş.",
+			Address:   "Atatürk Bulvarı No: 5",
+			District:  "Çankaya",
+			City:      "Ankara",
+			TaxOffice: "Çankaya",
+			TaxNumber: "9876543217",
+		},
+		Items: []models.InvoiceItem{
+			{Description: "Cilt bakımı", Quantity: 1, UnitPrice: models.NewMoney(120000, ""), Total: models.NewMoney(120000, ""), TaxRate: 20},
+			{Description: "Bakım kremi", Quantity: 3, UnitPrice: models.NewMoney(15000, ""), Total: models.NewMoney(45000, ""), TaxRate: 10},
+		},
+		IssuedAt: time.Date(2026, 3, 14, 10, 30, 0, 0, time.UTC),
+	}
+	invoice.SetCurrency("TRY")
+	return invoice
+}
+
+func TestRenderInvoiceUBL(t *testing.T) {
+	doc := renderTestUBL(t, testUBLInvoice(), nil, EInvoiceProfileBasic)
+
+	if got := doc.text("cbc:InvoiceTypeCode"); got != "SATIS" {
+		t.Errorf("InvoiceTypeCode = %q, want SATIS", got)
+	}
+	if len(doc.all("cac:BillingReference")) != 0 {
+		t.Error("invoice references another invoice")
+	}
+	if got := doc.all("cac:TaxTotal/cac:TaxSubtotal"); len(got) != 2 {
+		t.Errorf("got %d tax subtotals, want one per rate", len(got))
+	}
+
+	totals := doc.all("cac:LegalMonetaryTotal")[0]
+	// 1200.00 at 20% and 450.00 at 10%, prices include VAT
+	if got := totals.text("cbc:TaxExclusiveAmount"); got != "1409.09" {
+		t.Errorf("TaxExclusiveAmount = %s, want 1409.09", got)
+	}
+	if got := doc.text("cac:TaxTotal/cbc:TaxAmount"); got != "240.91" {
+		t.Errorf("TaxAmount = %s, want 240.91", got)
+	}
+	if got := totals.text("cbc:PayableAmount"); got != "1650.00" {
+		t.Errorf("PayableAmount = %s, want 1650.00", got)
+	}
+}
+
+func TestRenderCreditNoteUBL(t *testing.T) {
+	original := testUBLInvoice()
+	original.Buyer = models.InvoiceParty{Name: "Ayşe Nur Yılmaz", TaxNumber: "10000000146"}
+
+	refundID := 7
+	note := testUBLInvoice()
+	note.Number = "IAD2026000000003"
+	note.UUID = "9a1c4e2b-7f3d-4c8a-b6e5-2d0f1b3a4c59"
+	note.Type = models.InvoiceTypeCreditNote
+	note.RefundID = &refundID
+	note.Buyer = original.Buyer
+	note.Items = note.Items[:1]
+	note.Total = note.Items[0].Total
+	note.IssuedAt = time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC)
+
+	doc := renderTestUBL(t, note, original, EInvoiceProfileEArsiv)
+
+	if got := doc.text("cbc:InvoiceTypeCode"); got != "IADE" {
+		t.Errorf("InvoiceTypeCode = %q, want IADE", got)
+	}
+	if got := doc.text("cac:BillingReference/cac:InvoiceDocumentReference/cbc:ID"); got != original.Number {
+		t.Errorf("BillingReference ID = %q, want %q", got, original.Number)
+	}
+	if got := doc.text("cac:BillingReference/cac:InvoiceDocumentReference/cbc:IssueDate"); got != "2026-03-14" {
+		t.Errorf("BillingReference IssueDate = %q, want 2026-03-14", got)
+	}
+
+	// The buyer without an address is billed at the clinic's
+	buyer := doc.all("cac:AccountingCustomerParty/cac:Party")[0]
+	if got := buyer.text("cac:Person/cbc:FamilyName"); got != "Yılmaz" {
+		t.Errorf("buyer FamilyName = %q, want Yılmaz", got)
+	}
+	if got := buyer.text("cac:PostalAddress/cbc:CityName"); got != "İstanbul" {
+		t.Errorf("buyer CityName = %q, want the seller's city", got)
+	}
+}
+
+func TestRenderCreditNoteUBLOnTradeProfile(t *testing.T) {
+	original := testUBLInvoice()
+	note := testUBLInvoice()
+	note.Number = "IAD2026000000004"
+	note.Type = models.InvoiceTypeCreditNote
+
+	doc := renderTestUBL(t, note, original, EInvoiceProfileTrade)
+	if got := doc.text("cbc:ProfileID"); got != EInvoiceProfileBasic {
+		t.Errorf("ProfileID = %q, want %s", got, EInvoiceProfileBasic)
+	}
+}
+
+func TestRenderInvoiceUBLSoleProprietor(t *testing.T) {
+	invoice := testUBLInvoice()
+	invoice.Seller.Name = "Zeynep Kaya"
+	invoice.Seller.TaxNumber = "12345678950"
+
+	doc := renderTestUBL(t, invoice, nil, EInvoiceProfileBasic)
+	seller := doc.all("cac:AccountingSupplierParty/cac:Party")[0]
+	if got := seller.all("cac:PartyIdentification/cbc:ID")[0].attr("schemeID"); got != "TCKN" {
+		t.Errorf("seller schemeID = %q, want TCKN", got)
+	}
+}
+
+func TestRenderInvoiceUBLRejectsIncompleteInvoices(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(invoice *models.Invoice)
+		profile string
+		problem string
+	}{
+		{"bad number", func(invoice *models.Invoice) { invoice.Number = "INV-42" }, EInvoiceProfileBasic, "number must be"},
+		{"no seller tax office", func(invoice *models.Invoice) { invoice.Seller.TaxOffice = "" }, EInvoiceProfileBasic, "seller tax office is missing"},
+		{"anonymous buyer", func(invoice *models.Invoice) { invoice.Buyer.TaxNumber = "" }, EInvoiceProfileBasic, "buyer tax number (VKN/TCKN) is missing"},
+		{"credit note without original", func(invoice *models.Invoice) { invoice.Type = models.InvoiceTypeCreditNote }, EInvoiceProfileBasic, "credit note has no original invoice"},
+		{"totals do not add up", func(invoice *models.Invoice) { invoice.Total = models.NewMoney(100, "TRY") }, EInvoiceProfileBasic, "net and tax do not add up"},
+	}
+
+	for _, test := range tests {
+		invoice := testUBLInvoice()
+		test.change(invoice)
+		_, err := renderInvoiceUBL(invoice, nil, test.profile, time.UTC)
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.problem)
+		}
+	}
+
+	// e-Arşiv invoices may go to final consumers without a TCKN
+	invoice := testUBLInvoice()
+	invoice.Buyer.TaxNumber = ""
+	renderTestUBL(t, invoice, nil, EInvoiceProfileEArsiv)
+}
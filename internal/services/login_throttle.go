@@ -0,0 +1,121 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"log"
+	"strings"
+	"time"
+)
+
+// Upper bound of the progressive delay between failed logins
+const loginMaxDelay = time.Minute
+
+// LoginThrottledError rejects a login before the password is checked, either
+// because of the progressive delay or a lockout. RetryAfter tells the client
+// when to try again.
+type LoginThrottledError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "account is temporarily locked"
+	}
+	return "too many login attempts, try again later"
+}
+
+// loginDelay is the wait before the next attempt after the given number of
+// consecutive failures: 1s after the first, doubling up to loginMaxDelay
+func loginDelay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if failures > 7 {
+		return loginMaxDelay
+	}
+	delay := time.Second << (failures - 1)
+	if delay > loginMaxDelay {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+// checkLoginThrottle applies the per-account delay and lockout and the per-IP
+// block. Failures older than the lockout period are not counted.
+func (s *authService) checkLoginThrottle(email, ipAddress string) error {
+	now := time.Now()
+	lockout := s.config.Auth.LoginLockout
+	since := now.Add(-lockout)
+
+	failures, last, err := s.loginAttemptRepo.AccountFailures(email, since)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		if failures >= s.config.Auth.LoginMaxAttempts {
+			return &LoginThrottledError{Locked: true, RetryAfter: last.Add(lockout).Sub(now)}
+		}
+		if wait := last.Add(loginDelay(failures)).Sub(now); wait > 0 {
+			return &LoginThrottledError{RetryAfter: wait}
+		}
+	}
+
+	if ipAddress == "" {
+		return nil
+	}
+	ipFailures, ipLast, err := s.loginAttemptRepo.IPFailures(ipAddress, since)
+	if err != nil {
+		return err
+	}
+	if ipLast != nil && ipFailures >= s.config.Auth.LoginIPMaxAttempts {
+		return &LoginThrottledError{RetryAfter: ipLast.Add(lockout).Sub(now)}
+	}
+
+	return nil
+}
+
+// checkLogin runs checkLoginThrottle and records the attempts it blocks
+func (s *authService) checkLogin(email string, client models.ClientInfo) error {
+	err := s.checkLoginThrottle(email, client.IPAddress)
+	if _, throttled := err.(*LoginThrottledError); throttled {
+		s.recordLogin(email, nil, client, models.LoginBlocked)
+	}
+	return err
+}
+
+// loginFailed records a failed login and locks the account once it reaches
+// the threshold
+func (s *authService) loginFailed(email string, userID *int, client models.ClientInfo) {
+	s.recordLogin(email, userID, client, models.LoginFailed)
+
+	failures, _, err := s.loginAttemptRepo.AccountFailures(email, time.Now().Add(-s.config.Auth.LoginLockout))
+	if err != nil {
+		log.Printf("Warning: failed to count failed logins for %s: %v", email, err)
+		return
+	}
+	if failures == s.config.Auth.LoginMaxAttempts {
+		log.Printf("Warning: account %s locked after %d failed logins (ip %s)", email, failures, client.IPAddress)
+		s.recordLogin(email, userID, client, models.LoginLocked)
+	}
+}
+
+// recordLogin stores a login event, failures never fail the login itself
+func (s *authService) recordLogin(email string, userID *int, client models.ClientInfo, event models.LoginEvent) {
+	client = sessionClient(client)
+	attempt := &models.LoginAttempt{
+		Email:     email,
+		UserID:    userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Event:     event,
+	}
+	if err := s.loginAttemptRepo.Create(attempt); err != nil {
+		log.Printf("Warning: failed to record %s login for %s: %v", event, email, err)
+	}
+}
+
+// normalizeLoginEmail is the key failed logins are counted under
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
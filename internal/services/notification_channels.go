@@ -0,0 +1,193 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotificationChannel delivers a rendered message (email via SMTP, SMS via a
+// provider API, or the development log)
+type NotificationChannel interface {
+	Send(message *models.NotificationMessage) error
+}
+
+// NewNotificationChannels builds the channel per message type from the config
+func NewNotificationChannels(cfg config.NotificationConfig) map[models.NotificationChannelType]NotificationChannel {
+	logChannel := NewLogNotificationChannel(cfg.LogFile)
+
+	channels := map[models.NotificationChannelType]NotificationChannel{
+		models.NotificationChannelEmail: logChannel,
+		models.NotificationChannelSMS:   logChannel,
+	}
+
+	switch cfg.EmailDriver {
+	case "smtp":
+		channels[models.NotificationChannelEmail] = NewSMTPNotificationChannel(cfg.SMTP)
+	case "log":
+	default:
+		log.Printf("Warning: unknown notification email driver %q, using log", cfg.EmailDriver)
+	}
+
+	switch cfg.SMSDriver {
+	case "http":
+		channels[models.NotificationChannelSMS] = NewHTTPSMSNotificationChannel(cfg.SMS)
+	case "log":
+	default:
+		log.Printf("Warning: unknown notification SMS driver %q, using log", cfg.SMSDriver)
+	}
+
+	return channels
+}
+
+// SMTP
+
+type smtpNotificationChannel struct {
+	cfg config.SMTPConfig
+}
+
+func NewSMTPNotificationChannel(cfg config.SMTPConfig) NotificationChannel {
+	return &smtpNotificationChannel{cfg: cfg}
+}
+
+// Send uses STARTTLS when the server offers it. Authentication is skipped when no
+// username is configured, which is what local SMTP stand-ins (MailHog, smtp4dev) expect.
+func (c *smtpNotificationChannel) Send(message *models.NotificationMessage) error {
+	addr := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+
+	var auth smtp.Auth
+	if c.cfg.Username != "" {
+		auth = smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)
+	}
+
+	data, err := buildEmail(c.cfg.From, message.Recipient, message.Subject, message.Body)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(addr, auth, c.cfg.From, []string{message.Recipient}, data)
+}
+
+func buildEmail(from, to, subject, body string) ([]byte, error) {
+	for _, value := range []string{from, to, subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid email header value %q", value)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	b.WriteString("\r\n")
+
+	writer := quotedprintable.NewWriter(&b)
+	if _, err := writer.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// SMS provider adapter
+
+type httpSMSNotificationChannel struct {
+	cfg        config.SMSConfig
+	httpClient *http.Client
+}
+
+// NewHTTPSMSNotificationChannel posts {"from","to","message"} as JSON to the
+// provider URL with a bearer API key. Providers with a different API get their
+// own NotificationChannel implementation.
+func NewHTTPSMSNotificationChannel(cfg config.SMSConfig) NotificationChannel {
+	return &httpSMSNotificationChannel{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (c *httpSMSNotificationChannel) Send(message *models.NotificationMessage) error {
+	payload, err := json.Marshal(map[string]string{
+		"from":    c.cfg.Sender,
+		"to":      message.Recipient,
+		"message": message.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.cfg.APIURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sms provider returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Development log / file
+
+type logNotificationChannel struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewLogNotificationChannel(path string) NotificationChannel {
+	return &logNotificationChannel{path: path}
+}
+
+func (c *logNotificationChannel) Send(message *models.NotificationMessage) error {
+	entry := fmt.Sprintf("[%s] %s to %s\nSubject: %s\n%s\n---\n",
+		time.Now().Format(time.RFC3339), message.Channel, message.Recipient, message.Subject, message.Body)
+
+	if c.path == "" {
+		log.Printf("📨 Notification %s", entry)
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
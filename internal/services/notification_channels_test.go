@@ -0,0 +1,202 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"bufio"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is an in-process SMTP server that keeps what it receives, like
+// the MailHog the README points local development at
+type smtpStandIn struct {
+	listener net.Listener
+	messages chan smtpStandInMessage
+}
+
+type smtpStandInMessage struct {
+	from string
+	to   []string
+	data []byte
+}
+
+func startSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &smtpStandIn{listener: listener, messages: make(chan smtpStandInMessage, 1)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *smtpStandIn) config() config.SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return config.SMTPConfig{Host: addr.IP.String(), Port: addr.Port, From: "randevu@example.com"}
+}
+
+// serve speaks just enough SMTP for net/smtp, without STARTTLS or AUTH
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP stand-in")
+
+	var message smtpStandInMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250-localhost")
+			text.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			message = smtpStandInMessage{from: smtpAddress(arg)}
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			message.to = append(message.to, smtpAddress(arg))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			message.data = data
+			s.messages <- message
+			text.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// smtpAddress takes the address out of "FROM:<a@b> BODY=8BITMIME" or "TO:<a@b>"
+func smtpAddress(arg string) string {
+	_, address, _ := strings.Cut(arg, "<")
+	address, _, _ = strings.Cut(address, ">")
+	return address
+}
+
+func (s *smtpStandIn) receive(t *testing.T) smtpStandInMessage {
+	t.Helper()
+	select {
+	case message := <-s.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("the SMTP stand-in received no message")
+		return smtpStandInMessage{}
+	}
+}
+
+func TestSMTPNotificationChannelSend(t *testing.T) {
+	server := startSMTPStandIn(t)
+
+	channels := NewNotificationChannels(config.NotificationConfig{EmailDriver: "smtp", SMSDriver: "log", SMTP: server.config()})
+	err := channels[models.NotificationChannelEmail].Send(&models.NotificationMessage{
+		Channel:   models.NotificationChannelEmail,
+		Recipient: "ayse@example.com",
+		Subject:   "Randevunuz onaylandı",
+		Body:      "Merhaba Ayşe,\nrandevunuz 12.06.2026 14:30 için onaylandı.",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	received := server.receive(t)
+	if received.from != "randevu@example.com" {
+		t.Errorf("envelope sender %q, want randevu@example.com", received.from)
+	}
+	if len(received.to) != 1 || received.to[0] != "ayse@example.com" {
+		t.Errorf("envelope recipients %v, want [ayse@example.com]", received.to)
+	}
+
+	message, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(received.data))))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if from := message.Header.Get("From"); from != "randevu@example.com" {
+		t.Errorf("From %q, want randevu@example.com", from)
+	}
+	if to := message.Header.Get("To"); to != "ayse@example.com" {
+		t.Errorf("To %q, want ayse@example.com", to)
+	}
+	if _, err := message.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if contentType := message.Header.Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type %q", contentType)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+	if subject != "Randevunuz onaylandı" {
+		t.Errorf("subject %q, want %q", subject, "Randevunuz onaylandı")
+	}
+
+	if encoding := message.Header.Get("Content-Transfer-Encoding"); encoding != "quoted-printable" {
+		t.Fatalf("Content-Transfer-Encoding %q, want quoted-printable", encoding)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(message.Body))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	// The stand-in reads the data as text lines, so line ends come out as \n
+	want := "Merhaba Ayşe,\nrandevunuz 12.06.2026 14:30 için onaylandı.\n"
+	if string(body) != want {
+		t.Errorf("body %q, want %q", body, want)
+	}
+}
+
+func TestSMTPNotificationChannelRejectsHeaderInjection(t *testing.T) {
+	server := startSMTPStandIn(t)
+
+	channel := NewSMTPNotificationChannel(server.config())
+	err := channel.Send(&models.NotificationMessage{
+		Channel:   models.NotificationChannelEmail,
+		Recipient: "ayse@example.com",
+		Subject:   "Randevu\r\nBcc: everyone@example.com",
+		Body:      "Merhaba",
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid email header value") {
+		t.Fatalf("got %v, want an invalid email header value error", err)
+	}
+
+	select {
+	case message := <-server.messages:
+		t.Fatalf("a message was sent: %q", message.data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,503 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// Messages claimed per dispatch batch
+	notificationBatchSize = 50
+	// A claimed message is retried after this long if the dispatcher dies mid-send
+	notificationClaimLease = 5 * time.Minute
+	// Retry delays double per attempt up to this cap
+	notificationMaxBackoff = time.Hour
+)
+
+var notificationEvents = []models.NotificationEvent{
+	models.NotificationUserRegistered,
+	models.NotificationPasswordReset,
+	models.NotificationEmailVerification,
+	models.NotificationAppointmentCreated,
+	models.NotificationAppointmentStatusChanged,
+	models.NotificationAppointmentCancelled,
+	models.NotificationAppointmentReminder,
+	models.NotificationPaymentCompleted,
+	models.NotificationPaymentRefunded,
+}
+
+var notificationChannelTypes = []models.NotificationChannelType{
+	models.NotificationChannelEmail,
+	models.NotificationChannelSMS,
+}
+
+type NotificationService interface {
+	Notify(event models.NotificationEvent, recipient models.NotificationRecipient, data map[string]interface{}, reference string) error
+	NotifyWith(event models.NotificationEvent, recipient models.NotificationRecipient, data map[string]interface{}, reference string, channels []models.NotificationChannelType) error
+	NotifyAppointment(event models.NotificationEvent, appointmentID int) error
+	NotifyAppointmentWith(event models.NotificationEvent, appointmentID int, data map[string]interface{}, channels []models.NotificationChannelType) error
+	NotifyPayment(event models.NotificationEvent, payment *models.Payment) error
+	Dispatch() (int, error)
+	ListTemplates() ([]*models.NotificationTemplate, error)
+	UpdateTemplate(event models.NotificationEvent, channel models.NotificationChannelType, req *models.UpdateNotificationTemplateRequest) (*models.NotificationTemplate, error)
+	ResetTemplate(event models.NotificationEvent, channel models.NotificationChannelType) (*models.NotificationTemplate, error)
+	ListOutbox(status models.NotificationStatus, limit, offset int) ([]*models.NotificationOutboxItem, int, error)
+	RetryOutboxItem(id int) error
+}
+
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+	userRepo         repository.UserRepository
+	appointmentRepo  repository.AppointmentRepository
+	serviceRepo      repository.ServiceRepository
+	specialistRepo   repository.SpecialistRepository
+	channels         map[models.NotificationChannelType]NotificationChannel
+	maxAttempts      int
+	location         *time.Location
+}
+
+func NewNotificationService(
+	notificationRepo repository.NotificationRepository,
+	userRepo repository.UserRepository,
+	appointmentRepo repository.AppointmentRepository,
+	serviceRepo repository.ServiceRepository,
+	specialistRepo repository.SpecialistRepository,
+	channels map[models.NotificationChannelType]NotificationChannel,
+	cfg *config.Config,
+) NotificationService {
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		appointmentRepo:  appointmentRepo,
+		serviceRepo:      serviceRepo,
+		specialistRepo:   specialistRepo,
+		channels:         channels,
+		maxAttempts:      cfg.Notification.MaxAttempts,
+		location:         loadLocation(cfg.Calendar.TimeZone),
+	}
+}
+
+// NewNotificationDispatchWorker sends the due outbox messages of every tenant
+func NewNotificationDispatchWorker(tenantCache TenantCacheService, tenantDBs *TenantDBs, channels map[models.NotificationChannelType]NotificationChannel, cfg *config.Config) BackgroundWorker {
+	return newTenantWorker("notification dispatch", cfg.Notification.DispatchInterval, tenantCache, tenantDBs,
+		func(tenant *TenantInfo, repos *repository.Repositories) error {
+			_, err := newTenantServices(repos, channels, cfg).Notification.Dispatch()
+			return err
+		})
+}
+
+// Notify renders the event's templates and queues one message per channel the
+// recipient can be reached on. Inactive templates disable the channel.
+func (s *notificationService) Notify(event models.NotificationEvent, recipient models.NotificationRecipient, data map[string]interface{}, reference string) error {
+	return s.NotifyWith(event, recipient, data, reference, notificationChannelTypes)
+}
+
+// NotifyWith is Notify limited to the given channels
+func (s *notificationService) NotifyWith(event models.NotificationEvent, recipient models.NotificationRecipient, data map[string]interface{}, reference string, channels []models.NotificationChannelType) error {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	if _, exists := data["Name"]; !exists {
+		data["Name"] = recipient.Name
+	}
+
+	var errs []string
+	for _, channel := range channels {
+		address := recipient.Email
+		if channel == models.NotificationChannelSMS {
+			address = recipient.Phone
+		}
+		if strings.TrimSpace(address) == "" {
+			continue
+		}
+
+		tmpl, err := s.getTemplate(event, channel)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if tmpl == nil || !tmpl.Active {
+			continue
+		}
+
+		subject, body, err := renderNotificationTemplate(tmpl, data)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s/%s: %v", event, channel, err))
+			continue
+		}
+
+		item := &models.NotificationOutboxItem{
+			Event:       event,
+			Channel:     channel,
+			Recipient:   strings.TrimSpace(address),
+			Subject:     subject,
+			Body:        body,
+			Reference:   reference,
+			MaxAttempts: s.maxAttempts,
+		}
+		if err := s.notificationRepo.Enqueue(item); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to queue %s notification: %s", event, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// NotifyAppointment notifies the appointment's customer
+func (s *notificationService) NotifyAppointment(event models.NotificationEvent, appointmentID int) error {
+	return s.NotifyAppointmentWith(event, appointmentID, nil, notificationChannelTypes)
+}
+
+// NotifyAppointmentWith adds extra template data and limits the channels used
+func (s *notificationService) NotifyAppointmentWith(event models.NotificationEvent, appointmentID int, extra map[string]interface{}, channels []models.NotificationChannelType) error {
+	appointment, err := s.appointmentRepo.GetByID(appointmentID)
+	if err != nil {
+		return errors.New("appointment not found")
+	}
+
+	user, err := s.userRepo.GetByID(appointment.UserID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	data := s.appointmentData(appointment)
+	for key, value := range extra {
+		data[key] = value
+	}
+	return s.NotifyWith(event, userRecipient(user), data, fmt.Sprintf("appointment:%d", appointment.ID), channels)
+}
+
+func (s *notificationService) NotifyPayment(event models.NotificationEvent, payment *models.Payment) error {
+	appointment, err := s.appointmentRepo.GetByID(payment.AppointmentID)
+	if err != nil {
+		return errors.New("appointment not found")
+	}
+
+	user, err := s.userRepo.GetByID(appointment.UserID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	data := s.appointmentData(appointment)
+	data["Amount"] = formatAmount(payment.Amount)
+	data["PaymentMethod"] = string(payment.PaymentMethod)
+	data["TransactionID"] = payment.TransactionID
+
+	return s.Notify(event, userRecipient(user), data, fmt.Sprintf("payment:%d", payment.ID))
+}
+
+// Dispatch sends due outbox messages until none are left and returns how many were sent
+func (s *notificationService) Dispatch() (int, error) {
+	sent := 0
+	for {
+		items, err := s.notificationRepo.ClaimDue(notificationBatchSize, notificationClaimLease)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, item := range items {
+			if s.deliver(item) {
+				sent++
+			}
+		}
+
+		if len(items) < notificationBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+func (s *notificationService) deliver(item *models.NotificationOutboxItem) bool {
+	channel, exists := s.channels[item.Channel]
+
+	var sendErr error
+	if !exists {
+		sendErr = fmt.Errorf("no channel configured for %s", item.Channel)
+	} else {
+		sendErr = channel.Send(&models.NotificationMessage{
+			Channel:   item.Channel,
+			Recipient: item.Recipient,
+			Subject:   item.Subject,
+			Body:      item.Body,
+		})
+	}
+
+	if sendErr == nil {
+		if err := s.notificationRepo.MarkSent(item.ID); err != nil {
+			log.Printf("Warning: failed to mark notification %d as sent: %v", item.ID, err)
+		}
+		return true
+	}
+
+	// Attempts were already counted when the message was claimed
+	var nextAttemptAt *time.Time
+	if item.Attempts < item.MaxAttempts {
+		next := time.Now().Add(notificationBackoff(item.Attempts))
+		nextAttemptAt = &next
+	}
+
+	log.Printf("Warning: notification %d (%s to %s) failed, attempt %d/%d: %v",
+		item.ID, item.Channel, item.Recipient, item.Attempts, item.MaxAttempts, sendErr)
+	if err := s.notificationRepo.MarkFailed(item.ID, sendErr.Error(), nextAttemptAt); err != nil {
+		log.Printf("Warning: failed to record notification %d failure: %v", item.ID, err)
+	}
+	return false
+}
+
+// notificationBackoff returns 1m, 2m, 4m, ... capped at an hour
+func notificationBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 7 {
+		return notificationMaxBackoff
+	}
+
+	delay := time.Minute << (attempts - 1)
+	if delay > notificationMaxBackoff {
+		return notificationMaxBackoff
+	}
+	return delay
+}
+
+// ListTemplates returns the effective template for every event and channel
+func (s *notificationService) ListTemplates() ([]*models.NotificationTemplate, error) {
+	customized, err := s.notificationRepo.ListTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]*models.NotificationTemplate)
+	for _, tmpl := range customized {
+		overrides[string(tmpl.Event)+"/"+string(tmpl.Channel)] = tmpl
+	}
+
+	templates := []*models.NotificationTemplate{}
+	for _, event := range notificationEvents {
+		for _, channel := range notificationChannelTypes {
+			if tmpl, exists := overrides[string(event)+"/"+string(channel)]; exists {
+				templates = append(templates, tmpl)
+				continue
+			}
+			if tmpl := defaultNotificationTemplate(event, channel); tmpl != nil {
+				templates = append(templates, tmpl)
+			}
+		}
+	}
+
+	return templates, nil
+}
+
+func (s *notificationService) UpdateTemplate(event models.NotificationEvent, channel models.NotificationChannelType, req *models.UpdateNotificationTemplateRequest) (*models.NotificationTemplate, error) {
+	if err := validateNotificationKey(event, channel); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, errors.New("template body is required")
+	}
+	if channel == models.NotificationChannelEmail && strings.TrimSpace(req.Subject) == "" {
+		return nil, errors.New("email templates require a subject")
+	}
+
+	tmpl := &models.NotificationTemplate{
+		Event:   event,
+		Channel: channel,
+		Subject: req.Subject,
+		Body:    req.Body,
+		Active:  true,
+	}
+	if req.Active != nil {
+		tmpl.Active = *req.Active
+	}
+
+	// Render with sample data so broken templates are rejected on save
+	if _, _, err := renderNotificationTemplate(tmpl, sampleNotificationData()); err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+
+	if err := s.notificationRepo.SaveTemplate(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// ResetTemplate removes the tenant's version and returns the built-in default
+func (s *notificationService) ResetTemplate(event models.NotificationEvent, channel models.NotificationChannelType) (*models.NotificationTemplate, error) {
+	if err := validateNotificationKey(event, channel); err != nil {
+		return nil, err
+	}
+
+	if err := s.notificationRepo.DeleteTemplate(event, channel); err != nil {
+		return nil, err
+	}
+	return defaultNotificationTemplate(event, channel), nil
+}
+
+func (s *notificationService) ListOutbox(status models.NotificationStatus, limit, offset int) ([]*models.NotificationOutboxItem, int, error) {
+	switch status {
+	case "", models.NotificationPending, models.NotificationSent, models.NotificationFailed:
+	default:
+		return nil, 0, errors.New("invalid status")
+	}
+
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	items, total, err := s.notificationRepo.ListOutbox(status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if items == nil {
+		items = []*models.NotificationOutboxItem{}
+	}
+	return items, total, nil
+}
+
+func (s *notificationService) RetryOutboxItem(id int) error {
+	err := s.notificationRepo.Requeue(id)
+	if err == sql.ErrNoRows {
+		return errors.New("notification not found")
+	}
+	return err
+}
+
+func (s *notificationService) getTemplate(event models.NotificationEvent, channel models.NotificationChannelType) (*models.NotificationTemplate, error) {
+	tmpl, err := s.notificationRepo.GetTemplate(event, channel)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl != nil {
+		return tmpl, nil
+	}
+	return defaultNotificationTemplate(event, channel), nil
+}
+
+func (s *notificationService) appointmentData(appointment *models.Appointment) map[string]interface{} {
+	serviceName := ""
+	if service, err := s.serviceRepo.GetByID(appointment.ServiceID); err == nil {
+		serviceName = service.Name
+	}
+
+	specialistName := ""
+	if specialist, err := s.specialistRepo.GetByID(appointment.SpecialistID); err == nil {
+		specialistName = specialist.Name
+	}
+
+	start := appointmentStartTime(appointment, s.location)
+	return map[string]interface{}{
+		"AppointmentID": appointment.ID,
+		"Service":       serviceName,
+		"Specialist":    specialistName,
+		"Date":          start.Format("02.01.2006"),
+		"Time":          start.Format("15:04"),
+		"Status":        string(appointment.Status),
+		"StatusText":    appointmentStatusTexts[appointment.Status],
+		"Amount":        formatAmount(appointment.TotalAmount),
+		"Notes":         appointment.Notes,
+	}
+}
+
+func userRecipient(user *models.User) models.NotificationRecipient {
+	return models.NotificationRecipient{
+		Name:  user.Name,
+		Email: user.Email,
+		Phone: user.Phone,
+	}
+}
+
+func validateNotificationKey(event models.NotificationEvent, channel models.NotificationChannelType) error {
+	validEvent := false
+	for _, candidate := range notificationEvents {
+		if candidate == event {
+			validEvent = true
+			break
+		}
+	}
+	if !validEvent {
+		return errors.New("invalid notification event")
+	}
+
+	if channel != models.NotificationChannelEmail && channel != models.NotificationChannelSMS {
+		return errors.New("invalid notification channel")
+	}
+	return nil
+}
+
+func renderNotificationTemplate(tmpl *models.NotificationTemplate, data map[string]interface{}) (string, string, error) {
+	subject, err := renderTemplateString("subject", tmpl.Subject, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	body, err := renderTemplateString("body", tmpl.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Subjects end up in a mail header
+	subject = strings.Join(strings.Fields(subject), " ")
+	return subject, body, nil
+}
+
+func renderTemplateString(name, text string, data map[string]interface{}) (string, error) {
+	parsed, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := parsed.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func sampleNotificationData() map[string]interface{} {
+	return map[string]interface{}{
+		"Name":          "Ayşe Yılmaz",
+		"AppointmentID": 1,
+		"Service":       "Danışmanlık",
+		"Specialist":    "Dr. Ahmet Yılmaz",
+		"Date":          "15.02.2024",
+		"Time":          "10:30",
+		"Status":        string(models.StatusConfirmed),
+		"StatusText":    appointmentStatusTexts[models.StatusConfirmed],
+		"Amount":        formatAmount(models.NewMoney(15000, models.DefaultCurrency)),
+		"Notes":         "",
+		"PaymentMethod": string(models.PaymentMethodCreditCard),
+		"TransactionID": "demo_1",
+		"Token":         "reset-token",
+		"ExpiresIn":     "60 dakika",
+		"TimeUntil":     "24 saat",
+		"ConfirmURL":    "https://example.com/api/appointment-links/1/confirm",
+		"CancelURL":     "https://example.com/api/appointment-links/1/cancel",
+	}
+}
+
+// formatAmount writes an amount for customers, e.g. "150.00 TL" or "40.00 EUR"
+func formatAmount(amount models.Money) string {
+	currency := amount.Currency
+	if currency == "" || currency == "TRY" {
+		currency = "TL"
+	}
+	return amount.Decimal() + " " + currency
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+)
+
+// defaultNotificationTemplates are used until a tenant saves its own version.
+// Templates are Go text/template strings, see notificationData for the fields.
+var defaultNotificationTemplates = []models.NotificationTemplate{
+	{
+		Event:   models.NotificationUserRegistered,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Hoş geldiniz",
+		Body: `Merhaba {{.Name}},
+
+Hesabınız oluşturuldu. Artık online randevu alabilirsiniz.`,
+	},
+	{
+		Event:   models.NotificationPasswordReset,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Şifre sıfırlama",
+		Body: `Merhaba {{.Name}},
+
+Şifrenizi sıfırlamak için aşağıdaki kodu kullanın:
+
+{{.Token}}
+
+Kod {{.ExpiresIn}} geçerlidir ve yalnızca bir kez kullanılabilir.
+Bu isteği siz yapmadıysanız bu e-postayı dikkate almayın.`,
+	},
+	{
+		Event:   models.NotificationEmailVerification,
+		Channel: models.NotificationChannelEmail,
+		Subject: "E-posta adresinizi doğrulayın",
+		Body: `Merhaba {{.Name}},
+
+Hesabınızı etkinleştirmek için aşağıdaki doğrulama kodunu kullanın:
+
+{{.Token}}
+
+Kod {{.ExpiresIn}} geçerlidir ve yalnızca bir kez kullanılabilir.
+Bu hesabı siz oluşturmadıysanız bu e-postayı dikkate almayın.`,
+	},
+	{
+		Event:   models.NotificationAppointmentCreated,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Randevu talebiniz alındı",
+		Body: `Merhaba {{.Name}},
+
+{{.Date}} {{.Time}} tarihli {{.Service}} randevunuz ({{.Specialist}}) oluşturuldu.
+Durum: {{.StatusText}}
+Tutar: {{.Amount}}`,
+	},
+	{
+		Event:   models.NotificationAppointmentCreated,
+		Channel: models.NotificationChannelSMS,
+		Body:    `{{.Date}} {{.Time}} {{.Service}} randevunuz oluşturuldu ({{.Specialist}}).`,
+	},
+	{
+		Event:   models.NotificationAppointmentStatusChanged,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Randevu durumunuz güncellendi",
+		Body: `Merhaba {{.Name}},
+
+{{.Date}} {{.Time}} tarihli {{.Service}} randevunuzun durumu: {{.StatusText}}`,
+	},
+	{
+		Event:   models.NotificationAppointmentStatusChanged,
+		Channel: models.NotificationChannelSMS,
+		Body:    `{{.Date}} {{.Time}} {{.Service}} randevunuz: {{.StatusText}}`,
+	},
+	{
+		Event:   models.NotificationAppointmentCancelled,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Randevunuz iptal edildi",
+		Body: `Merhaba {{.Name}},
+
+{{.Date}} {{.Time}} tarihli {{.Service}} randevunuz ({{.Specialist}}) iptal edildi.`,
+	},
+	{
+		Event:   models.NotificationAppointmentCancelled,
+		Channel: models.NotificationChannelSMS,
+		Body:    `{{.Date}} {{.Time}} {{.Service}} randevunuz iptal edildi.`,
+	},
+	{
+		Event:   models.NotificationAppointmentReminder,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Randevu hatırlatması",
+		Body: `Merhaba {{.Name}},
+
+{{.TimeUntil}} sonra {{.Date}} {{.Time}} tarihinde {{.Service}} randevunuz ({{.Specialist}}) var.
+{{if .ConfirmURL}}
+Onaylamak için: {{.ConfirmURL}}{{end}}
+{{if .CancelURL}}İptal etmek için: {{.CancelURL}}{{end}}`,
+	},
+	{
+		Event:   models.NotificationAppointmentReminder,
+		Channel: models.NotificationChannelSMS,
+		Body:    `Hatırlatma: {{.Date}} {{.Time}} {{.Service}} randevunuz var.{{if .ConfirmURL}} Onay: {{.ConfirmURL}}{{end}}{{if .CancelURL}} İptal: {{.CancelURL}}{{end}}`,
+	},
+	{
+		Event:   models.NotificationPaymentCompleted,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Ödemeniz alındı",
+		Body: `Merhaba {{.Name}},
+
+{{.Date}} {{.Time}} tarihli {{.Service}} randevunuz için {{.Amount}} tutarındaki ödemeniz alındı.
+İşlem no: {{.TransactionID}}`,
+	},
+	{
+		Event:   models.NotificationPaymentRefunded,
+		Channel: models.NotificationChannelEmail,
+		Subject: "Ödemeniz iade edildi",
+		Body: `Merhaba {{.Name}},
+
+{{.Date}} {{.Time}} tarihli {{.Service}} randevunuz için {{.Amount}} tutarındaki ödemeniz iade edildi.`,
+	},
+}
+
+func defaultNotificationTemplate(event models.NotificationEvent, channel models.NotificationChannelType) *models.NotificationTemplate {
+	for _, template := range defaultNotificationTemplates {
+		if template.Event == event && template.Channel == channel {
+			copied := template
+			copied.Active = true
+			copied.IsDefault = true
+			return &copied
+		}
+	}
+	return nil
+}
+
+var appointmentStatusTexts = map[models.AppointmentStatus]string{
+	models.StatusPending:   "Beklemede",
+	models.StatusConfirmed: "Onaylandı",
+	models.StatusCompleted: "Tamamlandı",
+	models.StatusCancelled: "İptal edildi",
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress is returned for outbound requests to addresses inside the
+// server's network
+var errPrivateAddress = errors.New("address is not publicly reachable")
+
+// newPublicHTTPClient returns a client for URLs entered by tenants, such as
+// webhooks and calendar feeds. It only connects to public addresses: the
+// check runs on the resolved IP when dialing, so a hostname that resolves to
+// a private address, and a redirect to one, are refused too. Proxies from the
+// environment are not used, they would hide the destination from the check.
+func newPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   rejectPrivateAddress,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// rejectPrivateAddress is a net.Dialer Control hook refusing connections to
+// loopback, private, link-local (including cloud metadata), carrier-grade NAT,
+// reserved and unspecified addresses
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(ip) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, ip)
+	}
+	return nil
+}
+
+// nonPublicPrefixes are the special-purpose ranges netip has no predicate for:
+// "this network", carrier-grade NAT (used inside some cloud networks),
+// IETF protocol assignments, benchmarking and the reserved block
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePublicURL checks that a tenant-entered URL uses one of schemes and
+// does not name a local host or a private IP. Hostnames are checked again
+// after DNS resolution when the request is made.
+func validatePublicURL(rawURL string, schemes ...string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Hostname() == "" || parsed.User != nil {
+		return nil, errors.New("invalid url")
+	}
+
+	validScheme := false
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			validScheme = true
+		}
+	}
+	if !validScheme {
+		return nil, errors.New("invalid url scheme")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") ||
+		strings.HasSuffix(host, ".internal") {
+		return nil, errPrivateAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddress(ip) {
+		return nil, errPrivateAddress
+	}
+	return parsed, nil
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+type PackageService interface {
+	List(activeOnly bool) ([]*models.Package, error)
+	GetByID(id int) (*models.Package, error)
+	Create(req *models.PackageRequest) (*models.Package, error)
+	Update(id int, req *models.PackageRequest) (*models.Package, error)
+	Delete(id int) error
+	Sell(userID int, req *models.SellPackageRequest) (*models.CustomerPackage, error)
+	GetUserPackages(userID int) ([]*models.CustomerPackage, error)
+	GetCustomerPackage(id int) (*models.CustomerPackage, error)
+	Adjust(customerPackageID int, req *models.AdjustPackageRequest, actorType string, actorID *int, actor string) (*models.CustomerPackage, error)
+	FindSession(userID int, service *models.Service, at time.Time) (*models.CustomerPackage, error)
+	ConsumeSession(customerPackageID, appointmentID int, at time.Time) error
+	RestoreSession(appointmentID int) error
+	GetSalesByTaxRate(startDate, endDate time.Time) ([]*models.SalesByTaxRate, error)
+}
+
+type packageService struct {
+	packageRepo     repository.PackageRepository
+	userRepo        repository.UserRepository
+	serviceRepo     repository.ServiceRepository
+	categoryRepo    repository.CategoryRepository
+	settingsRepo    repository.SettingsRepository
+	invoiceService  InvoiceService
+	defaultCurrency string
+}
+
+func NewPackageService(packageRepo repository.PackageRepository, userRepo repository.UserRepository, serviceRepo repository.ServiceRepository, categoryRepo repository.CategoryRepository, settingsRepo repository.SettingsRepository, invoiceService InvoiceService, cfg *config.Config) PackageService {
+	return &packageService{
+		packageRepo:     packageRepo,
+		userRepo:        userRepo,
+		serviceRepo:     serviceRepo,
+		categoryRepo:    categoryRepo,
+		settingsRepo:    settingsRepo,
+		invoiceService:  invoiceService,
+		defaultCurrency: cfg.Payment.Currency,
+	}
+}
+
+func (s *packageService) List(activeOnly bool) ([]*models.Package, error) {
+	packages, err := s.packageRepo.List(activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	if packages == nil {
+		packages = []*models.Package{}
+	}
+	return packages, nil
+}
+
+func (s *packageService) GetByID(id int) (*models.Package, error) {
+	pkg, err := s.packageRepo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("package not found")
+	}
+	return pkg, err
+}
+
+func (s *packageService) Create(req *models.PackageRequest) (*models.Package, error) {
+	pkg := &models.Package{Active: true}
+	if err := s.apply(pkg, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.packageRepo.Create(pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Update changes the package for future sales, packages already sold keep
+// the terms they were bought with
+func (s *packageService) Update(id int, req *models.PackageRequest) (*models.Package, error) {
+	pkg, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(pkg, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.packageRepo.Update(pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Delete removes a package that was never sold; sold packages can be
+// deactivated instead
+func (s *packageService) Delete(id int) error {
+	if _, err := s.GetByID(id); err != nil {
+		return err
+	}
+
+	sold, err := s.packageRepo.CountSold(id)
+	if err != nil {
+		return err
+	}
+	if sold > 0 {
+		return errors.New("package has been sold, deactivate it instead")
+	}
+
+	return s.packageRepo.Delete(id)
+}
+
+// apply validates the request and copies it onto pkg
+func (s *packageService) apply(pkg *models.Package, req *models.PackageRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("package name is required")
+	}
+	if req.Sessions == nil && req.ValidityDays == 0 {
+		return errors.New("invalid validity_days, memberships must expire")
+	}
+	if err := validateServiceTargets(s.serviceRepo, s.categoryRepo, req.ServiceIDs, req.CategoryIDs); err != nil {
+		return err
+	}
+
+	pkg.Name = name
+	pkg.Description = strings.TrimSpace(req.Description)
+	pkg.Sessions = req.Sessions
+	pkg.ValidityDays = req.ValidityDays
+	pkg.Currency = tenantCurrency(s.settingsRepo, s.defaultCurrency)
+	pkg.Price = models.NewMoney(req.Price.Amount, pkg.Currency)
+	pkg.ServiceIDs = req.ServiceIDs
+	pkg.CategoryIDs = req.CategoryIDs
+	if req.Active != nil {
+		pkg.Active = *req.Active
+	}
+	return nil
+}
+
+// Sell records a package bought by the user at the desk. The package's terms
+// are copied and the validity starts now.
+func (s *packageService) Sell(userID int, req *models.SellPackageRequest) (*models.CustomerPackage, error) {
+	pkg, err := s.GetByID(req.PackageID)
+	if err != nil {
+		return nil, err
+	}
+	if !pkg.Active {
+		return nil, errors.New("package is not active")
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	customerPackage := &models.CustomerPackage{
+		PackageID:     pkg.ID,
+		UserID:        userID,
+		Name:          pkg.Name,
+		SessionsTotal: pkg.Sessions,
+		ServiceIDs:    pkg.ServiceIDs,
+		CategoryIDs:   pkg.CategoryIDs,
+		Price:         pkg.Price,
+		TaxRate:       tenantTaxRate(s.settingsRepo),
+		Currency:      pkg.Currency,
+		PaymentMethod: req.PaymentMethod,
+	}
+	customerPackage.NetAmount, customerPackage.TaxAmount = splitTax(customerPackage.Price, customerPackage.TaxRate)
+	if pkg.ValidityDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, pkg.ValidityDays)
+		customerPackage.ExpiresAt = &expiresAt
+	}
+
+	if err := s.packageRepo.CreateCustomerPackage(customerPackage); err != nil {
+		return nil, err
+	}
+	customerPackage.SetBalance(time.Now())
+
+	// The sale is paid at the desk, it has no payment to invoice later
+	if _, err := s.invoiceService.IssueForPackage(customerPackage); err != nil {
+		log.Printf("Warning: failed to invoice customer package %d: %v", customerPackage.ID, err)
+	}
+	return customerPackage, nil
+}
+
+// GetSalesByTaxRate totals the packages sold in the tenant's currency per VAT
+// rate
+func (s *packageService) GetSalesByTaxRate(startDate, endDate time.Time) ([]*models.SalesByTaxRate, error) {
+	totals, err := s.packageRepo.GetSalesByTaxRate(startDate, endDate, tenantCurrency(s.settingsRepo, s.defaultCurrency))
+	if err != nil {
+		return nil, err
+	}
+	if totals == nil {
+		totals = []*models.SalesByTaxRate{}
+	}
+	return totals, nil
+}
+
+func (s *packageService) GetUserPackages(userID int) ([]*models.CustomerPackage, error) {
+	customerPackages, err := s.packageRepo.ListCustomerPackages(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, customerPackage := range customerPackages {
+		customerPackage.SetBalance(now)
+	}
+	if customerPackages == nil {
+		customerPackages = []*models.CustomerPackage{}
+	}
+	return customerPackages, nil
+}
+
+// GetCustomerPackage returns the package with its session history
+func (s *packageService) GetCustomerPackage(id int) (*models.CustomerPackage, error) {
+	customerPackage, err := s.packageRepo.GetCustomerPackage(id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("customer package not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.packageRepo.ListSessions(id)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []*models.PackageSession{}
+	}
+	customerPackage.History = history
+	customerPackage.SetBalance(time.Now())
+	return customerPackage, nil
+}
+
+// Adjust changes the balance or expiry of a customer package, e.g. to make up
+// for a missed session, and records who did it
+func (s *packageService) Adjust(customerPackageID int, req *models.AdjustPackageRequest, actorType string, actorID *int, actor string) (*models.CustomerPackage, error) {
+	customerPackage, err := s.packageRepo.GetCustomerPackage(customerPackageID)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("customer package not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Sessions == 0 && req.ExpiresAt == nil {
+		return nil, errors.New("nothing to adjust, set sessions or expires_at")
+	}
+	if req.Sessions != 0 && customerPackage.SessionsTotal == nil {
+		return nil, errors.New("memberships have no sessions to adjust")
+	}
+
+	adjusted, err := s.packageRepo.Adjust(&models.PackageSession{
+		CustomerPackageID: customerPackageID,
+		Change:            req.Sessions,
+		Reason:            strings.TrimSpace(req.Reason),
+		ActorType:         actorType,
+		ActorID:           actorID,
+		Actor:             actor,
+	}, req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !adjusted {
+		return nil, errors.New("invalid sessions, the balance cannot drop below the sessions used")
+	}
+
+	return s.GetCustomerPackage(customerPackageID)
+}
+
+// FindSession returns the user's package that covers the service at the
+// given time and has sessions left, preferring the one expiring first. It
+// returns nil when no package covers the booking.
+func (s *packageService) FindSession(userID int, service *models.Service, at time.Time) (*models.CustomerPackage, error) {
+	customerPackages, err := s.packageRepo.ListCustomerPackages(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listed by expiry, memberships without one last
+	for _, customerPackage := range customerPackages {
+		customerPackage.SetBalance(at)
+		if customerPackage.Status != models.CustomerPackageActive {
+			continue
+		}
+		if coversService(customerPackage.ServiceIDs, customerPackage.CategoryIDs, service) {
+			return customerPackage, nil
+		}
+	}
+	return nil, nil
+}
+
+// ConsumeSession uses a session for the appointment, failing when a
+// concurrent booking used up the package since it was found
+func (s *packageService) ConsumeSession(customerPackageID, appointmentID int, at time.Time) error {
+	consumed, err := s.packageRepo.ConsumeSession(customerPackageID, appointmentID, at)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return errors.New("package has no sessions left")
+	}
+	return nil
+}
+
+// RestoreSession gives back the session used by a cancelled appointment, if any
+func (s *packageService) RestoreSession(appointmentID int) error {
+	_, err := s.packageRepo.RestoreSession(appointmentID)
+	return err
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+)
+
+// PaymentAccountService keeps each tenant's payment gateway credentials and
+// builds the tenant's provider adapters from them
+type PaymentAccountService interface {
+	Get(provider string) (*models.PaymentProviderAccountInfo, error)
+	Save(provider string, req *models.PaymentProviderAccountRequest) (*models.PaymentProviderAccountInfo, error)
+	Delete(provider string) error
+	// Provider returns the adapter for a provider with the current tenant's
+	// credentials
+	Provider(name string) (PaymentProvider, error)
+}
+
+type paymentAccountService struct {
+	accountRepo repository.PaymentAccountRepository
+	cipher      *credentialCipher
+	cfg         config.PaymentConfig
+	fake        PaymentProvider
+}
+
+func NewPaymentAccountService(accountRepo repository.PaymentAccountRepository, cfg *config.Config) PaymentAccountService {
+	s := &paymentAccountService{
+		accountRepo: accountRepo,
+		cipher:      newCredentialCipher(cfg.Payment.CredentialsKey),
+		cfg:         cfg.Payment,
+	}
+	// The fake provider has no credentials and is only offered when the
+	// server enables it
+	if cfg.Payment.FakeProvider {
+		s.fake = NewFakePaymentProvider(cfg.Payment.FakeWebhookSecret)
+	}
+	return s
+}
+
+func (s *paymentAccountService) Get(provider string) (*models.PaymentProviderAccountInfo, error) {
+	if provider != PaymentProviderStripe {
+		return nil, errors.New("unknown payment provider")
+	}
+
+	account, err := s.accountRepo.GetByProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	info := &models.PaymentProviderAccountInfo{Provider: provider}
+	if account == nil {
+		return info, nil
+	}
+
+	info.Configured = true
+	info.UpdatedAt = &account.UpdatedAt
+	if secretKey, err := s.cipher.open(account.SecretKey); err == nil {
+		info.SecretKeyHint = secretHint(secretKey)
+	}
+	return info, nil
+}
+
+// Save encrypts and stores the credentials, replacing any stored before
+func (s *paymentAccountService) Save(provider string, req *models.PaymentProviderAccountRequest) (*models.PaymentProviderAccountInfo, error) {
+	if provider != PaymentProviderStripe {
+		return nil, errors.New("unknown payment provider")
+	}
+
+	secretKey, webhookSecret := strings.TrimSpace(req.SecretKey), strings.TrimSpace(req.WebhookSecret)
+	if !strings.HasPrefix(secretKey, "sk_") && !strings.HasPrefix(secretKey, "rk_") {
+		return nil, errors.New("invalid secret_key, use a Stripe secret or restricted key")
+	}
+	if !strings.HasPrefix(webhookSecret, "whsec_") {
+		return nil, errors.New("invalid webhook_secret, use the signing secret of the webhook endpoint")
+	}
+
+	account := &models.PaymentProviderAccount{Provider: provider}
+	var err error
+	if account.SecretKey, err = s.cipher.seal(secretKey); err != nil {
+		return nil, err
+	}
+	if account.WebhookSecret, err = s.cipher.seal(webhookSecret); err != nil {
+		return nil, err
+	}
+	if err := s.accountRepo.Save(account); err != nil {
+		return nil, err
+	}
+
+	return &models.PaymentProviderAccountInfo{
+		Provider:      provider,
+		Configured:    true,
+		SecretKeyHint: secretHint(secretKey),
+		UpdatedAt:     &account.UpdatedAt,
+	}, nil
+}
+
+func (s *paymentAccountService) Delete(provider string) error {
+	if provider != PaymentProviderStripe {
+		return errors.New("unknown payment provider")
+	}
+	if err := s.accountRepo.Delete(provider); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("payment provider is not configured")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *paymentAccountService) Provider(name string) (PaymentProvider, error) {
+	switch name {
+	case PaymentProviderFake:
+		if s.fake == nil {
+			return nil, errors.New("unknown payment provider")
+		}
+		return s.fake, nil
+	case PaymentProviderStripe:
+		account, err := s.accountRepo.GetByProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return nil, errors.New("payment provider is not configured")
+		}
+
+		secretKey, err := s.cipher.open(account.SecretKey)
+		if err == nil {
+			var webhookSecret string
+			if webhookSecret, err = s.cipher.open(account.WebhookSecret); err == nil {
+				return NewStripePaymentProvider(s.cfg.Stripe, secretKey, webhookSecret, s.cfg.Timeout), nil
+			}
+		}
+		log.Printf("Warning: stored %s credentials cannot be decrypted: %v", name, err)
+		return nil, errors.New("payment provider is not configured")
+	default:
+		return nil, errors.New("unknown payment provider")
+	}
+}
+
+// secretHint shows the last characters of a key so admins can tell which one
+// is stored
+func secretHint(secret string) string {
+	if len(secret) <= 8 {
+		return "…"
+	}
+	return "…" + secret[len(secret)-4:]
+}
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Payment providers, selected per tenant with the payment_provider setting
+const (
+	PaymentProviderFake   = "fake"
+	PaymentProviderStripe = "stripe"
+)
+
+// PaymentProviderStatus is the gateway-neutral state of a charge
+type PaymentProviderStatus string
+
+const (
+	// Waiting for the customer (e.g. 3-D Secure) or the gateway, a webhook follows
+	ProviderStatusPending PaymentProviderStatus = "pending"
+	// Funds are reserved and still have to be captured
+	ProviderStatusAuthorized PaymentProviderStatus = "authorized"
+	ProviderStatusCaptured   PaymentProviderStatus = "captured"
+	ProviderStatusFailed     PaymentProviderStatus = "failed"
+	ProviderStatusRefunded   PaymentProviderStatus = "refunded"
+)
+
+// PaymentCharge is a card payment to authorize
+type PaymentCharge struct {
+	Amount      models.Money
+	CardToken   string // Tokenised card from the gateway's client library
+	Description string
+	Reference   string // Our reference, stored as gateway metadata
+}
+
+// PaymentResult is the state of a charge or refund at the gateway. A declined
+// card is a result with ProviderStatusFailed, errors are for calls that failed.
+// Refund results from webhooks are marked, their Reference is the refund's.
+type PaymentResult struct {
+	Reference     string
+	Status        PaymentProviderStatus
+	FailureReason string
+	Refund        bool
+}
+
+// PaymentProvider is a card payment gateway. Amounts are in the currency of
+// the charge.
+type PaymentProvider interface {
+	Authorize(charge *PaymentCharge) (*PaymentResult, error)
+	Capture(reference string, amount models.Money) (*PaymentResult, error)
+	Refund(reference string, amount models.Money, reason string) (*PaymentResult, error)
+	Status(reference string) (*PaymentResult, error)
+	RefundStatus(reference string) (*PaymentResult, error)
+	// VerifyWebhook checks the signature of a gateway webhook and returns the
+	// charge or refund it reports on, or nil for other events
+	VerifyWebhook(payload []byte, header http.Header) (*PaymentResult, error)
+}
+
+var errInvalidPaymentWebhook = errors.New("invalid payment webhook signature")
+
+// validatePaymentSetting rejects payment providers that do not exist,
+// deposit percentages outside 0-100 and unsupported currencies
+func validatePaymentSetting(key, value string) error {
+	switch key {
+	case "currency":
+		if !models.IsCurrency(value) {
+			return fmt.Errorf("invalid currency value, use one of %s", strings.Join(models.Currencies, ", "))
+		}
+	case "payment_provider":
+		switch strings.TrimSpace(value) {
+		case PaymentProviderFake, PaymentProviderStripe:
+		default:
+			return errors.New("invalid payment_provider value, use fake or stripe")
+		}
+	case "deposit_percentage":
+		percentage, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || percentage < 0 || percentage > 100 {
+			return errors.New("invalid deposit_percentage value, use a number between 0 and 100")
+		}
+	}
+	return nil
+}
+
+// tenantCurrency is the tenant's currency setting, fallback when it is not set
+func tenantCurrency(settingsRepo repository.SettingsRepository, fallback string) string {
+	if setting, err := settingsRepo.GetByKey("currency"); err == nil && models.IsCurrency(setting.Value) {
+		return setting.Value
+	}
+	return fallback
+}
+
+// Fake provider for development and tests
+
+type fakePaymentProvider struct {
+	webhookSecret string
+
+	mu      sync.Mutex
+	charges map[string]*fakeCharge
+	refunds map[string]PaymentProviderStatus
+}
+
+type fakeCharge struct {
+	status   PaymentProviderStatus
+	amount   int64 // minor units
+	captured int64
+	refunded int64
+}
+
+// NewFakePaymentProvider never moves money. The card token decides the outcome:
+// "tok_declined" is declined, "tok_pending" waits for a webhook and any other
+// token is authorized. Refunds with the reason "pending" wait for a webhook.
+// Webhooks are {"reference","status","failure_reason","refund"} JSON signed
+// with an HMAC-SHA256 hex digest in X-Fake-Signature.
+func NewFakePaymentProvider(webhookSecret string) PaymentProvider {
+	return &fakePaymentProvider{
+		webhookSecret: webhookSecret,
+		charges:       make(map[string]*fakeCharge),
+		refunds:       make(map[string]PaymentProviderStatus),
+	}
+}
+
+func (p *fakePaymentProvider) Authorize(charge *PaymentCharge) (*PaymentResult, error) {
+	token, err := generateSecureToken(8)
+	if err != nil {
+		return nil, err
+	}
+	result := &PaymentResult{Reference: "fake_" + token, Status: ProviderStatusAuthorized}
+
+	switch charge.CardToken {
+	case "tok_declined":
+		result.Status = ProviderStatusFailed
+		result.FailureReason = "card declined"
+	case "tok_pending":
+		result.Status = ProviderStatusPending
+	}
+
+	p.mu.Lock()
+	p.charges[result.Reference] = &fakeCharge{status: result.Status, amount: charge.Amount.Amount}
+	p.mu.Unlock()
+	return result, nil
+}
+
+func (p *fakePaymentProvider) Capture(reference string, amount models.Money) (*PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[reference]
+	if !ok {
+		return nil, errors.New("payment not found at provider")
+	}
+	if charge.status != ProviderStatusAuthorized {
+		return nil, fmt.Errorf("cannot capture a %s payment", charge.status)
+	}
+	if amount.Amount > charge.amount {
+		return nil, errors.New("capture exceeds the authorized amount")
+	}
+
+	charge.status = ProviderStatusCaptured
+	charge.captured = amount.Amount
+	return &PaymentResult{Reference: reference, Status: ProviderStatusCaptured}, nil
+}
+
+func (p *fakePaymentProvider) Refund(reference string, amount models.Money, reason string) (*PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Charges from before a restart are unknown, refunds of those succeed
+	charge, ok := p.charges[reference]
+	if ok {
+		if charge.status != ProviderStatusCaptured && charge.status != ProviderStatusRefunded {
+			return nil, fmt.Errorf("cannot refund a %s payment", charge.status)
+		}
+		if charge.refunded+amount.Amount > charge.captured {
+			return nil, errors.New("refund exceeds the captured amount")
+		}
+		charge.refunded += amount.Amount
+		if charge.refunded == charge.captured {
+			charge.status = ProviderStatusRefunded
+		}
+	}
+
+	token, err := generateSecureToken(8)
+	if err != nil {
+		return nil, err
+	}
+	result := &PaymentResult{Reference: "fake_re_" + token, Status: ProviderStatusRefunded}
+	if reason == "pending" {
+		result.Status = ProviderStatusPending
+	}
+	p.refunds[result.Reference] = result.Status
+	return result, nil
+}
+
+func (p *fakePaymentProvider) RefundStatus(reference string) (*PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status, ok := p.refunds[reference]
+	if !ok {
+		return nil, errors.New("refund not found at provider")
+	}
+	return &PaymentResult{Reference: reference, Status: status, Refund: true}, nil
+}
+
+func (p *fakePaymentProvider) Status(reference string) (*PaymentResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[reference]
+	if !ok {
+		return nil, errors.New("payment not found at provider")
+	}
+	return &PaymentResult{Reference: reference, Status: charge.status}, nil
+}
+
+func (p *fakePaymentProvider) VerifyWebhook(payload []byte, header http.Header) (*PaymentResult, error) {
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Fake-Signature"))) {
+		return nil, errInvalidPaymentWebhook
+	}
+
+	var event struct {
+		Reference     string                `json:"reference"`
+		Status        PaymentProviderStatus `json:"status"`
+		FailureReason string                `json:"failure_reason"`
+		Refund        bool                  `json:"refund"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil || event.Reference == "" {
+		return nil, errors.New("invalid payment webhook payload")
+	}
+
+	p.mu.Lock()
+	if event.Refund {
+		if _, ok := p.refunds[event.Reference]; ok {
+			p.refunds[event.Reference] = event.Status
+		}
+	} else if charge, ok := p.charges[event.Reference]; ok {
+		charge.status = event.Status
+	}
+	p.mu.Unlock()
+
+	return &PaymentResult{Reference: event.Reference, Status: event.Status, FailureReason: event.FailureReason, Refund: event.Refund}, nil
+}
+
+// Stripe adapter (PaymentIntents API)
+
+// Stripe signatures older than this are rejected to stop replays
+const stripeWebhookTolerance = 5 * time.Minute
+
+type stripePaymentProvider struct {
+	cfg           config.StripeConfig
+	secretKey     string
+	webhookSecret string // Signing secret of the tenant's webhook endpoint
+	httpClient    *http.Client
+}
+
+// NewStripePaymentProvider authorizes with manual-capture PaymentIntents, so
+// the card is charged only when the payment is captured. The keys are the
+// tenant's own.
+func NewStripePaymentProvider(cfg config.StripeConfig, secretKey, webhookSecret string, timeout time.Duration) PaymentProvider {
+	return &stripePaymentProvider{
+		cfg:           cfg,
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		httpClient:    &http.Client{Timeout: timeout},
+	}
+}
+
+type stripePaymentIntent struct {
+	ID               string `json:"id"`
+	Object           string `json:"object"`
+	Status           string `json:"status"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason"`
+}
+
+type stripeError struct {
+	Error struct {
+		Type          string               `json:"type"`
+		Message       string               `json:"message"`
+		PaymentIntent *stripePaymentIntent `json:"payment_intent"`
+	} `json:"error"`
+}
+
+func (p *stripePaymentProvider) Authorize(charge *PaymentCharge) (*PaymentResult, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(charge.Amount.Amount, 10))
+	form.Set("currency", strings.ToLower(charge.Amount.Currency))
+	form.Set("payment_method", charge.CardToken)
+	form.Set("payment_method_types[]", "card")
+	form.Set("capture_method", "manual")
+	form.Set("confirm", "true")
+	form.Set("description", charge.Description)
+	form.Set("metadata[reference]", charge.Reference)
+
+	var intent stripePaymentIntent
+	if err := p.call(http.MethodPost, "/v1/payment_intents", form, &intent); err != nil {
+		var declined *stripeDeclinedError
+		if errors.As(err, &declined) {
+			return declined.result(), nil
+		}
+		return nil, err
+	}
+	return stripeIntentResult(&intent), nil
+}
+
+func (p *stripePaymentProvider) Capture(reference string, amount models.Money) (*PaymentResult, error) {
+	form := url.Values{}
+	form.Set("amount_to_capture", strconv.FormatInt(amount.Amount, 10))
+
+	var intent stripePaymentIntent
+	if err := p.call(http.MethodPost, "/v1/payment_intents/"+url.PathEscape(reference)+"/capture", form, &intent); err != nil {
+		return nil, err
+	}
+	return stripeIntentResult(&intent), nil
+}
+
+func (p *stripePaymentProvider) Refund(reference string, amount models.Money, reason string) (*PaymentResult, error) {
+	form := url.Values{}
+	form.Set("payment_intent", reference)
+	form.Set("amount", strconv.FormatInt(amount.Amount, 10))
+	if reason != "" {
+		form.Set("metadata[reason]", reason)
+	}
+
+	var refund stripeRefund
+	if err := p.call(http.MethodPost, "/v1/refunds", form, &refund); err != nil {
+		return nil, err
+	}
+	return stripeRefundResult(&refund), nil
+}
+
+func (p *stripePaymentProvider) RefundStatus(reference string) (*PaymentResult, error) {
+	var refund stripeRefund
+	if err := p.call(http.MethodGet, "/v1/refunds/"+url.PathEscape(reference), nil, &refund); err != nil {
+		return nil, err
+	}
+	return stripeRefundResult(&refund), nil
+}
+
+func (p *stripePaymentProvider) Status(reference string) (*PaymentResult, error) {
+	var intent stripePaymentIntent
+	if err := p.call(http.MethodGet, "/v1/payment_intents/"+url.PathEscape(reference), nil, &intent); err != nil {
+		return nil, err
+	}
+	return stripeIntentResult(&intent), nil
+}
+
+// VerifyWebhook checks the Stripe-Signature header ("t=<unix>,v1=<hex>") over
+// "<t>.<payload>" with the endpoint's signing secret
+func (p *stripePaymentProvider) VerifyWebhook(payload []byte, header http.Header) (*PaymentResult, error) {
+	if p.webhookSecret == "" {
+		return nil, errors.New("payment provider is not configured")
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return nil, errInvalidPaymentWebhook
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return nil, errInvalidPaymentWebhook
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	valid := false
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, errInvalidPaymentWebhook
+	}
+
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.New("invalid payment webhook payload")
+	}
+
+	switch {
+	case strings.HasPrefix(event.Type, "payment_intent."):
+		var intent stripePaymentIntent
+		if err := json.Unmarshal(event.Data.Object, &intent); err != nil {
+			return nil, errors.New("invalid payment webhook payload")
+		}
+		return stripeIntentResult(&intent), nil
+	case event.Type == "charge.refunded":
+		var charge struct {
+			PaymentIntent string `json:"payment_intent"`
+			Refunded      bool   `json:"refunded"`
+		}
+		if err := json.Unmarshal(event.Data.Object, &charge); err != nil {
+			return nil, errors.New("invalid payment webhook payload")
+		}
+		// Partial refunds are recorded when they are made through the API
+		if !charge.Refunded || charge.PaymentIntent == "" {
+			return nil, nil
+		}
+		return &PaymentResult{Reference: charge.PaymentIntent, Status: ProviderStatusRefunded}, nil
+	case strings.HasPrefix(event.Type, "refund.") || event.Type == "charge.refund.updated":
+		var refund stripeRefund
+		if err := json.Unmarshal(event.Data.Object, &refund); err != nil || refund.ID == "" {
+			return nil, errors.New("invalid payment webhook payload")
+		}
+		return stripeRefundResult(&refund), nil
+	default:
+		return nil, nil
+	}
+}
+
+// stripeDeclinedError is a card error: the charge exists but was declined
+type stripeDeclinedError struct {
+	message string
+	intent  *stripePaymentIntent
+}
+
+func (e *stripeDeclinedError) Error() string {
+	return "card declined: " + e.message
+}
+
+func (e *stripeDeclinedError) result() *PaymentResult {
+	result := &PaymentResult{Status: ProviderStatusFailed, FailureReason: e.message}
+	if e.intent != nil {
+		result.Reference = e.intent.ID
+	}
+	return result
+}
+
+func (p *stripePaymentProvider) call(method, path string, form url.Values, out interface{}) error {
+	if p.secretKey == "" {
+		return errors.New("payment provider is not configured")
+	}
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(p.cfg.APIURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr stripeError
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Type == "card_error" {
+			return &stripeDeclinedError{message: apiErr.Error.Message, intent: apiErr.Error.PaymentIntent}
+		}
+		return fmt.Errorf("stripe returned status %d: %s", resp.StatusCode, strings.TrimSpace(apiErr.Error.Message))
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+func stripeRefundResult(refund *stripeRefund) *PaymentResult {
+	result := &PaymentResult{Reference: refund.ID, Status: ProviderStatusRefunded, Refund: true}
+	switch refund.Status {
+	case "pending", "requires_action":
+		result.Status = ProviderStatusPending
+	case "failed", "canceled":
+		result.Status = ProviderStatusFailed
+		result.FailureReason = refund.FailureReason
+	}
+	return result
+}
+
+func stripeIntentResult(intent *stripePaymentIntent) *PaymentResult {
+	result := &PaymentResult{Reference: intent.ID}
+	switch intent.Status {
+	case "requires_capture":
+		result.Status = ProviderStatusAuthorized
+	case "succeeded":
+		result.Status = ProviderStatusCaptured
+	case "requires_payment_method", "canceled":
+		result.Status = ProviderStatusFailed
+		if intent.LastPaymentError != nil {
+			result.FailureReason = intent.LastPaymentError.Message
+		} else if intent.Status == "canceled" {
+			result.FailureReason = "payment canceled"
+		}
+	default:
+		// processing, requires_action, requires_confirmation
+		result.Status = ProviderStatusPending
+	}
+	return result
+}
@@ -1,59 +1,112 @@
 package services
 
 import (
+	"appointment-api/internal/config"
 	"appointment-api/internal/models"
 	"appointment-api/internal/repository"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type PaymentService interface {
 	Create(payment *models.Payment) error
 	GetByID(id int) (*models.Payment, error)
-	GetByAppointmentID(appointmentID int) (*models.Payment, error)
+	GetByAppointmentID(appointmentID int) ([]*models.Payment, error)
 	GetUserPayments(userID int, limit, offset int) ([]*models.Payment, error)
 	List(limit, offset int) ([]*models.Payment, error)
 	Update(payment *models.Payment) error
 	Delete(id int) error
-	ProcessPayment(appointmentID int, paymentMethod models.PaymentMethod, deviceID *int) (*models.Payment, error)
-	RefundPayment(paymentID int, reason string) error
-	GetTotalRevenue(startDate, endDate time.Time) (float64, error)
+	ProcessPayment(appointmentID int, paymentMethod models.PaymentMethod, amount models.Money, cardToken string, deviceID *int) (*models.Payment, error)
+	HandleProviderWebhook(provider string, payload []byte, header http.Header) error
+	SyncStatus(paymentID int) (*models.Payment, error)
+	Refund(paymentID int, refund *models.Refund) error
+	GetRefunds(paymentID int) ([]*models.Refund, error)
+	Currency() string
+	GetTotalRevenue(startDate, endDate time.Time) (models.Money, error)
+	GetRevenueByTaxRate(startDate, endDate time.Time) ([]*models.SalesByTaxRate, error)
 	GetPaymentsByStatus(status models.PaymentStatus, limit, offset int) ([]*models.Payment, error)
-	GetMonthlyRevenue() (float64, error)
-	GetYearlyRevenue() (float64, error)
-	GetPreviousMonthlyRevenue() (float64, error)
-	GetPreviousYearlyRevenue() (float64, error)
+	GetMonthlyRevenue() (models.Money, error)
+	GetYearlyRevenue() (models.Money, error)
+	GetPreviousMonthlyRevenue() (models.Money, error)
+	GetPreviousYearlyRevenue() (models.Money, error)
 }
 
 type paymentService struct {
-	paymentRepo     repository.PaymentRepository
-	appointmentRepo repository.AppointmentRepository
+	paymentRepo         repository.PaymentRepository
+	refundRepo          repository.RefundRepository
+	appointmentRepo     repository.AppointmentRepository
+	settingsRepo        repository.SettingsRepository
+	paymentAccounts     PaymentAccountService
+	appointmentService  AppointmentService
+	invoiceService      InvoiceService
+	walletService       WalletService
+	notificationService NotificationService
+	webhookService      WebhookService
+	defaultCurrency     string
 }
 
-func NewPaymentService(paymentRepo repository.PaymentRepository, appointmentRepo repository.AppointmentRepository) PaymentService {
+func NewPaymentService(paymentRepo repository.PaymentRepository, refundRepo repository.RefundRepository, appointmentRepo repository.AppointmentRepository, settingsRepo repository.SettingsRepository, paymentAccounts PaymentAccountService, appointmentService AppointmentService, invoiceService InvoiceService, walletService WalletService, notificationService NotificationService, webhookService WebhookService, cfg *config.Config) PaymentService {
 	return &paymentService{
-		paymentRepo:     paymentRepo,
-		appointmentRepo: appointmentRepo,
+		paymentRepo:         paymentRepo,
+		refundRepo:          refundRepo,
+		appointmentRepo:     appointmentRepo,
+		settingsRepo:        settingsRepo,
+		paymentAccounts:     paymentAccounts,
+		appointmentService:  appointmentService,
+		invoiceService:      invoiceService,
+		walletService:       walletService,
+		notificationService: notificationService,
+		webhookService:      webhookService,
+		defaultCurrency:     cfg.Payment.Currency,
 	}
 }
 
+// Create records a payment taken at the desk. An appointment can be paid in
+// several parts, e.g. a deposit online and the rest split between cash and card.
 func (s *paymentService) Create(payment *models.Payment) error {
-	// Create the payment first
-	err := s.paymentRepo.Create(payment)
+	appointment, err := s.appointmentRepo.GetByID(payment.AppointmentID)
 	if err != nil {
-		return err
+		return fmt.Errorf("appointment not found")
+	}
+	if payment.Status == "" {
+		payment.Status = models.PaymentPending
+	}
+	payment.SetCurrency(appointment.Currency)
+	setPaymentTax(payment, appointment.TaxRate)
+	if payment.PaymentMethod == models.PaymentMethodWallet {
+		if err := checkPaymentAmount(payment.Amount, appointment.BalanceDue); err != nil {
+			return err
+		}
+		return s.payFromWallet(appointment, payment)
 	}
 
-	// If payment is completed, update appointment payment status
-	if payment.Status == models.PaymentCompleted {
-		err = s.appointmentRepo.UpdatePaymentStatus(payment.AppointmentID, models.PaymentCompleted)
-		if err != nil {
-			// Log error but don't fail the payment creation
-			fmt.Printf("Warning: failed to update appointment payment status: %v\n", err)
+	if payment.Status == models.PaymentFailed {
+		// a failed payment holds nothing of the balance
+		if err := s.paymentRepo.Create(payment); err != nil {
+			return err
+		}
+	} else {
+		if err := checkPaymentAmount(payment.Amount, appointment.BalanceDue); err != nil {
+			return err
+		}
+		if err := s.createWithinBalance(payment); err != nil {
+			return err
 		}
 	}
 
+	// If payment is completed, update the appointment's paid amount
+	if payment.Status == models.PaymentCompleted {
+		s.refreshAppointment(payment.AppointmentID)
+		s.issueInvoice(payment)
+		s.notify(models.NotificationPaymentCompleted, payment)
+		s.publish(models.WebhookPaymentCompleted, payment)
+	}
+
 	return nil
 }
 
@@ -68,8 +121,8 @@ func (s *paymentService) GetByID(id int) (*models.Payment, error) {
 	return payment, nil
 }
 
-func (s *paymentService) GetByAppointmentID(appointmentID int) (*models.Payment, error) {
-	return s.paymentRepo.GetByAppointmentID(appointmentID)
+func (s *paymentService) GetByAppointmentID(appointmentID int) ([]*models.Payment, error) {
+	return s.paymentRepo.ListByAppointmentID(appointmentID)
 }
 
 func (s *paymentService) GetUserPayments(userID int, limit, offset int) ([]*models.Payment, error) {
@@ -102,19 +155,42 @@ func (s *paymentService) Update(payment *models.Payment) error {
 		return fmt.Errorf("payment not found")
 	}
 
+	// The wallet ledger has to match wallet payments, so they only change by refunds
+	if existing.PaymentMethod == models.PaymentMethodWallet || payment.PaymentMethod == models.PaymentMethodWallet {
+		if existing.PaymentMethod != payment.PaymentMethod || existing.Amount.Amount != payment.Amount.Amount || existing.Status != payment.Status {
+			return errors.New("wallet payments cannot be changed, refund them instead")
+		}
+	}
+
+	// Money taken stays as invoiced; returning it has to go through a refund so
+	// the refund, the provider and the credit note agree
+	if payment.Status == models.PaymentRefunded && existing.Status != models.PaymentRefunded {
+		return errors.New("payments cannot be set to refunded, use POST /admin/payments/:id/refunds")
+	}
+	if existing.Status == models.PaymentCompleted || existing.Status == models.PaymentRefunded {
+		if existing.Amount.Amount != payment.Amount.Amount || existing.Status != payment.Status {
+			return errors.New("completed payments cannot be changed, use POST /admin/payments/:id/refunds")
+		}
+	}
+
+	setPaymentTax(payment, existing.TaxRate)
+
 	// Update payment
 	err = s.paymentRepo.Update(payment)
 	if err != nil {
 		return err
 	}
 
-	// If status changed, update appointment payment status too
-	if existing.Status != payment.Status {
-		err = s.appointmentRepo.UpdatePaymentStatus(payment.AppointmentID, payment.Status)
-		if err != nil {
-			// Log error but don't fail the payment update
-			fmt.Printf("Warning: failed to update appointment payment status: %v\n", err)
-		}
+	// The amount or status may change what is paid on the appointment
+	payment.AppointmentID = existing.AppointmentID
+	if existing.Status != payment.Status || existing.Amount != payment.Amount {
+		s.refreshAppointment(payment.AppointmentID)
+	}
+
+	if existing.Status != payment.Status && payment.Status == models.PaymentCompleted {
+		s.issueInvoice(payment)
+		s.notify(models.NotificationPaymentCompleted, payment)
+		s.publish(models.WebhookPaymentCompleted, payment)
 	}
 
 	return nil
@@ -141,89 +217,672 @@ func (s *paymentService) Delete(id int) error {
 		return err
 	}
 
-	// Update appointment payment status to pending after payment deletion
-	err = s.appointmentRepo.UpdatePaymentStatus(existing.AppointmentID, models.PaymentPending)
-	if err != nil {
-		// Log error but don't fail the payment deletion since it's already deleted
-		fmt.Printf("Warning: failed to update appointment payment status after payment deletion: %v\n", err)
-	}
+	// The remaining payments decide the appointment's payment status
+	s.refreshAppointment(existing.AppointmentID)
 
 	return nil
 }
 
-func (s *paymentService) ProcessPayment(appointmentID int, paymentMethod models.PaymentMethod, deviceID *int) (*models.Payment, error) {
+// ProcessPayment charges card payments through the tenant's payment provider.
+// Wallet payments are taken from the customer's wallet at once. Cash and
+// transfers are collected at the desk, so they stay pending until staff
+// complete them. A declined card is returned as a failed payment, and a
+// pending one is settled later by the provider's webhook.
+//
+// A zero amount pays what is due next: the rest of the deposit while it is not
+// covered, otherwise the whole balance. Pending payments hold their part of
+// the balance until they complete or fail, so a retry or a second device
+// cannot pay the same part twice.
+func (s *paymentService) ProcessPayment(appointmentID int, paymentMethod models.PaymentMethod, amount models.Money, cardToken string, deviceID *int) (*models.Payment, error) {
 	// Get appointment to verify and get amount
 	appointment, err := s.appointmentRepo.GetByID(appointmentID)
 	if err != nil {
 		return nil, fmt.Errorf("appointment not found")
 	}
 
-	// Check if payment already exists
-	existingPayment, err := s.paymentRepo.GetByAppointmentID(appointmentID)
+	if appointment.BalanceDue.Amount <= 0 {
+		return nil, fmt.Errorf("appointment already paid")
+	}
+	pending, err := s.pendingAmount(appointment)
 	if err != nil {
 		return nil, err
 	}
-	if existingPayment != nil && existingPayment.Status == models.PaymentCompleted {
-		return nil, fmt.Errorf("appointment already paid")
+	outstanding := appointment.BalanceDue.Sub(pending)
+	if outstanding.Amount <= 0 {
+		return nil, errors.New("balance due is covered by pending payments")
+	}
+	if amount.IsZero() {
+		amount = outstanding
+		held := appointment.PaidAmount.Add(pending)
+		if deposit := s.depositAmount(appointment); held.Amount < deposit.Amount {
+			amount = deposit.Sub(held)
+		}
+	}
+	if err := checkPaymentAmount(amount, outstanding); err != nil {
+		return nil, err
 	}
 
-	// Generate transaction ID
-	transactionID := "demo_" + strconv.Itoa(appointmentID) + "_" + strconv.FormatInt(time.Now().Unix(), 10)
-
-	// Create payment
 	payment := &models.Payment{
 		AppointmentID: appointmentID,
 		DeviceID:      deviceID,
-		Amount:        appointment.TotalAmount,
+		Amount:        amount,
 		PaymentMethod: paymentMethod,
-		TransactionID: transactionID,
-		Status:        models.PaymentCompleted, // Demo: always successful
+		Status:        models.PaymentPending,
+	}
+	payment.SetCurrency(appointment.Currency)
+	setPaymentTax(payment, appointment.TaxRate)
+
+	if paymentMethod == models.PaymentMethodWallet {
+		if err := s.payFromWallet(appointment, payment); err != nil {
+			return nil, err
+		}
+		return payment, nil
+	}
+
+	if paymentMethod != models.PaymentMethodCreditCard {
+		if err := s.createWithinBalance(payment); err != nil {
+			return nil, err
+		}
+		return payment, nil
+	}
+
+	if cardToken == "" {
+		return nil, errors.New("card token is required")
 	}
 
-	// Save payment
-	err = s.paymentRepo.Create(payment)
+	providerName, provider, err := s.tenantProvider()
 	if err != nil {
 		return nil, err
 	}
 
-	// Update appointment payment status
-	err = s.appointmentRepo.UpdatePaymentStatus(appointmentID, models.PaymentCompleted)
+	// The payment holds its part of the balance before the card is charged
+	payment.Provider = providerName
+	if err := s.createWithinBalance(payment); err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Authorize(&PaymentCharge{
+		Amount:      payment.Amount,
+		CardToken:   cardToken,
+		Description: "Appointment #" + strconv.Itoa(appointmentID),
+		Reference:   "appointment_" + strconv.Itoa(appointmentID),
+	})
 	if err != nil {
+		log.Printf("Warning: payment provider %s failed to authorize appointment %d: %v", providerName, appointmentID, err)
+		if updateErr := s.paymentRepo.UpdateStatus(payment.ID, models.PaymentFailed, "payment provider error"); updateErr != nil {
+			log.Printf("Warning: failed to mark payment %d failed: %v", payment.ID, updateErr)
+		}
+		return nil, errors.New("payment provider error")
+	}
+
+	if result.Status == ProviderStatusAuthorized {
+		// A failed capture leaves the payment pending, a sync or webhook settles it
+		if captured, err := provider.Capture(result.Reference, payment.Amount); err != nil {
+			log.Printf("Warning: payment provider %s failed to capture %s: %v", providerName, result.Reference, err)
+		} else {
+			result.Status = captured.Status
+		}
+	}
+
+	payment.TransactionID = result.Reference
+	payment.Status = providerPaymentStatus(result.Status)
+	payment.FailureReason = result.FailureReason
+
+	if err := s.paymentRepo.SetProviderResult(payment); err != nil {
 		return nil, err
 	}
 
+	if payment.Status != models.PaymentPending {
+		s.statusChanged(payment)
+	}
 	return payment, nil
 }
 
-func (s *paymentService) RefundPayment(paymentID int, reason string) error {
-	// Get payment
-	payment, err := s.paymentRepo.GetByID(paymentID)
+// payFromWallet debits the payment from the wallet of the appointment's
+// customer. The payment is recorded first so the ledger entry can refer to
+// it, and removed again when the balance does not cover it.
+func (s *paymentService) payFromWallet(appointment *models.Appointment, payment *models.Payment) error {
+	payment.Status = models.PaymentPending
+	if err := s.createWithinBalance(payment); err != nil {
+		return err
+	}
+
+	if err := s.walletService.Pay(appointment.UserID, payment); err != nil {
+		if deleteErr := s.paymentRepo.Delete(payment.ID); deleteErr != nil {
+			log.Printf("Warning: failed to remove unpaid wallet payment %d: %v", payment.ID, deleteErr)
+		}
+		return err
+	}
+
+	if err := s.paymentRepo.UpdateStatus(payment.ID, models.PaymentCompleted, ""); err != nil {
+		return err
+	}
+	payment.Status = models.PaymentCompleted
+
+	s.statusChanged(payment)
+	return nil
+}
+
+// HandleProviderWebhook applies a signed status update from a payment provider.
+// Updates for payments this tenant does not know are ignored.
+func (s *paymentService) HandleProviderWebhook(providerName string, payload []byte, header http.Header) error {
+	provider, err := s.paymentAccounts.Provider(providerName)
+	if err != nil {
+		return err
+	}
+
+	result, err := provider.VerifyWebhook(payload, header)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+
+	if result.Refund {
+		refund, err := s.refundRepo.GetByProviderReference(result.Reference)
+		if err != nil {
+			return err
+		}
+		if refund == nil {
+			return nil
+		}
+		if payment, err := s.paymentRepo.GetByID(refund.PaymentID); err != nil || payment == nil || payment.Provider != providerName {
+			return err
+		}
+		return s.applyRefundResult(refund, result)
+	}
+
+	payment, err := s.paymentRepo.GetByTransactionID(providerName, result.Reference)
 	if err != nil {
 		return err
 	}
 	if payment == nil {
-		return fmt.Errorf("payment not found")
+		return nil
+	}
+
+	return s.applyProviderResult(payment, provider, result)
+}
+
+// SyncStatus looks the payment and its pending refunds up at the provider, for
+// missed webhooks
+func (s *paymentService) SyncStatus(paymentID int) (*models.Payment, error) {
+	payment, err := s.GetByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Provider == "" || payment.TransactionID == "" {
+		return nil, errors.New("payment was not made through a payment provider")
+	}
+	provider, err := s.paymentAccounts.Provider(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Status(payment.TransactionID)
+	if err != nil {
+		log.Printf("Warning: payment provider %s status lookup for %s failed: %v", payment.Provider, payment.TransactionID, err)
+		return nil, errors.New("payment provider error")
+	}
+
+	if err := s.applyProviderResult(payment, provider, result); err != nil {
+		return nil, err
+	}
+	if err := s.syncRefunds(payment, provider); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// syncRefunds settles the payment's pending refunds from the provider, for
+// missed refund webhooks
+func (s *paymentService) syncRefunds(payment *models.Payment, provider PaymentProvider) error {
+	refunds, err := s.refundRepo.ListByPaymentID(payment.ID)
+	if err != nil {
+		return err
+	}
+	for _, refund := range refunds {
+		if refund.Status != models.RefundPending || refund.ProviderReference == "" {
+			continue
+		}
+		result, err := provider.RefundStatus(refund.ProviderReference)
+		if err != nil {
+			log.Printf("Warning: payment provider %s refund lookup for %s failed: %v", payment.Provider, refund.ProviderReference, err)
+			return errors.New("payment provider error")
+		}
+		if err := s.applyRefundResult(refund, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyProviderResult moves a payment forward to the provider's status. An
+// authorized charge is captured first. Stale or out of order updates, like a
+// failure reported after completion, are ignored.
+func (s *paymentService) applyProviderResult(payment *models.Payment, provider PaymentProvider, result *PaymentResult) error {
+	if result.Status == ProviderStatusAuthorized && payment.Status == models.PaymentPending {
+		captured, err := provider.Capture(payment.TransactionID, payment.Amount)
+		if err != nil {
+			log.Printf("Warning: payment provider %s failed to capture %s: %v", payment.Provider, payment.TransactionID, err)
+			return errors.New("payment provider error")
+		}
+		result = captured
+	}
+
+	status := providerPaymentStatus(result.Status)
+	if !paymentTransitionAllowed(payment.Status, status) {
+		return nil
+	}
+
+	if err := s.paymentRepo.UpdateStatus(payment.ID, status, result.FailureReason); err != nil {
+		return err
+	}
+	payment.Status = status
+	payment.FailureReason = result.FailureReason
+
+	s.statusChanged(payment)
+	return nil
+}
+
+// statusChanged updates the appointment's paid amount and tells the customer
+// and webhook subscribers about the new payment status
+func (s *paymentService) statusChanged(payment *models.Payment) {
+	s.refreshAppointment(payment.AppointmentID)
+
+	switch payment.Status {
+	case models.PaymentCompleted:
+		s.issueInvoice(payment)
+		s.notify(models.NotificationPaymentCompleted, payment)
+		s.publish(models.WebhookPaymentCompleted, payment)
+	case models.PaymentRefunded:
+		s.issueCreditNote(payment, nil)
+		s.notify(models.NotificationPaymentRefunded, payment)
+		s.publish(models.WebhookPaymentRefunded, payment)
+	}
+}
+
+// refreshAppointment recomputes what is paid on an appointment from its
+// payments. Once the tenant's deposit is covered a pending booking is
+// confirmed. Failures are logged, they never fail the payment.
+func (s *paymentService) refreshAppointment(appointmentID int) {
+	appointment, err := s.appointmentRepo.GetByID(appointmentID)
+	if err != nil {
+		log.Printf("Warning: failed to load appointment %d for payment totals: %v", appointmentID, err)
+		return
+	}
+	payments, err := s.paymentRepo.ListByAppointmentID(appointmentID)
+	if err != nil {
+		log.Printf("Warning: failed to list payments of appointment %d: %v", appointmentID, err)
+		return
+	}
+
+	paid := models.NewMoney(0, appointment.Currency)
+	refunded := models.NewMoney(0, appointment.Currency)
+	for _, payment := range payments {
+		if !payment.Amount.SameCurrency(paid) {
+			log.Printf("Warning: payment %d of appointment %d is in %s, not %s", payment.ID, appointmentID, payment.Currency, appointment.Currency)
+			continue
+		}
+		switch payment.Status {
+		case models.PaymentCompleted:
+			paid = paid.Add(payment.Amount.Sub(payment.RefundedAmount))
+			refunded = refunded.Add(payment.RefundedAmount)
+		case models.PaymentRefunded:
+			refunded = refunded.Add(payment.Amount)
+		}
+	}
+
+	status := appointmentPaymentStatus(appointment.TotalAmount, paid, refunded, payments)
+	if err := s.appointmentRepo.UpdatePaymentTotals(appointmentID, paid, status); err != nil {
+		log.Printf("Warning: failed to update appointment payment status: %v", err)
+		return
+	}
+
+	deposit := s.depositAmount(appointment)
+	if appointment.Status == models.StatusPending && deposit.Amount > 0 && paid.Amount >= deposit.Amount {
+		if err := s.appointmentService.UpdateStatus(appointmentID, models.StatusConfirmed); err != nil {
+			log.Printf("Warning: failed to confirm appointment %d after deposit: %v", appointmentID, err)
+		}
+	}
+}
+
+func (s *paymentService) depositAmount(appointment *models.Appointment) models.Money {
+	return depositAmount(s.settingsRepo, appointment)
+}
+
+// appointmentPaymentStatus sums up an appointment's payments, oldest first, in
+// one status. paid is what is kept after refunds.
+func appointmentPaymentStatus(total, paid, refunded models.Money, payments []*models.Payment) models.PaymentStatus {
+	switch {
+	case refunded.Amount > 0 && paid.Amount > 0:
+		return models.PaymentPartiallyRefunded
+	case refunded.Amount > 0:
+		return models.PaymentRefunded
+	case paid.Amount > 0 && paid.Amount >= total.Amount:
+		return models.PaymentCompleted
+	case paid.Amount > 0:
+		return models.PaymentPartiallyPaid
+	}
+
+	if len(payments) > 0 && payments[len(payments)-1].Status == models.PaymentFailed {
+		return models.PaymentFailed
+	}
+	return models.PaymentPending
+}
+
+// checkPaymentAmount rejects amounts that are not positive or more than is due
+// createWithinBalance records a payment that must fit in the appointment's
+// balance left after its completed and pending payments
+func (s *paymentService) createWithinBalance(payment *models.Payment) error {
+	created, err := s.paymentRepo.CreateWithinBalance(payment)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return errors.New("amount exceeds balance due")
+	}
+	return nil
+}
+
+// pendingAmount is what the appointment's pending payments hold of its balance
+func (s *paymentService) pendingAmount(appointment *models.Appointment) (models.Money, error) {
+	pending := models.NewMoney(0, appointment.Currency)
+	payments, err := s.paymentRepo.ListByAppointmentID(appointment.ID)
+	if err != nil {
+		return pending, err
+	}
+	for _, payment := range payments {
+		if payment.Status == models.PaymentPending && payment.Amount.SameCurrency(pending) {
+			pending = pending.Add(payment.Amount)
+		}
+	}
+	return pending, nil
+}
+
+func checkPaymentAmount(amount, balanceDue models.Money) error {
+	if amount.Amount <= 0 {
+		return errors.New("invalid payment amount")
+	}
+	if amount.Amount > balanceDue.Amount {
+		return errors.New("amount exceeds balance due")
+	}
+	return nil
+}
+
+// Currency is the tenant's currency setting, new appointments are priced in it
+func (s *paymentService) Currency() string {
+	return tenantCurrency(s.settingsRepo, s.defaultCurrency)
+}
+
+// tenantProvider returns the provider chosen in the tenant's payment_provider
+// setting, tenants without one take no card payments
+func (s *paymentService) tenantProvider() (string, PaymentProvider, error) {
+	var name string
+	if setting, err := s.settingsRepo.GetByKey("payment_provider"); err == nil {
+		name = strings.TrimSpace(setting.Value)
+	}
+
+	provider, err := s.paymentAccounts.Provider(name)
+	if err != nil {
+		if err.Error() == "unknown payment provider" {
+			return "", nil, errors.New("payment provider is not configured")
+		}
+		return "", nil, err
+	}
+	return name, provider, nil
+}
+
+func providerPaymentStatus(status PaymentProviderStatus) models.PaymentStatus {
+	switch status {
+	case ProviderStatusCaptured:
+		return models.PaymentCompleted
+	case ProviderStatusFailed:
+		return models.PaymentFailed
+	case ProviderStatusRefunded:
+		return models.PaymentRefunded
+	default:
+		return models.PaymentPending
 	}
+}
+
+// paymentTransitionAllowed only lets provider updates move a payment forward. A
+// failed payment can still complete when the customer retries the same charge.
+func paymentTransitionAllowed(from, to models.PaymentStatus) bool {
+	switch from {
+	case models.PaymentPending:
+		return to == models.PaymentCompleted || to == models.PaymentFailed
+	case models.PaymentFailed:
+		return to == models.PaymentCompleted
+	case models.PaymentCompleted:
+		return to == models.PaymentRefunded
+	default:
+		return false
+	}
+}
 
-	// Check if payment can be refunded
+// Refund returns part or all of a completed payment, refund.Amount defaults to
+// what is left to refund. Money taken through a provider goes back the same
+// way; cash and transfers are handed back at the desk. With refund.ToWallet,
+// and always for wallet payments, the refund is credited to the customer's
+// wallet instead. The caller sets the reason and actor.
+func (s *paymentService) Refund(paymentID int, refund *models.Refund) error {
+	payment, err := s.GetByID(paymentID)
+	if err != nil {
+		return err
+	}
 	if payment.Status != models.PaymentCompleted {
-		return fmt.Errorf("only completed payments can be refunded")
+		return errors.New("only completed payments can be refunded")
 	}
 
-	// Update payment status to refunded
-	payment.Status = models.PaymentRefunded
+	refundable := payment.Amount.Sub(payment.RefundedAmount)
+	if refund.Amount.IsZero() {
+		refund.Amount = refundable
+	}
+	if refund.Amount.Amount <= 0 {
+		return errors.New("invalid refund amount")
+	}
+	if refund.Amount.Amount > refundable.Amount {
+		return errors.New("refund exceeds refundable amount")
+	}
 
-	err = s.paymentRepo.Update(payment)
+	refund.PaymentID = payment.ID
+	refund.Currency = payment.Currency
+	refund.Amount.Currency = payment.Currency
+	refund.Status = models.RefundCompleted
+	refund.ToWallet = refund.ToWallet || payment.PaymentMethod == models.PaymentMethodWallet
+	viaProvider := payment.Provider != "" && payment.TransactionID != "" && !refund.ToWallet
+	var provider PaymentProvider
+	if viaProvider {
+		// Checked before anything is reserved, so a tenant that removed its
+		// credentials gets an error instead of a stuck refund
+		if provider, err = s.paymentAccounts.Provider(payment.Provider); err != nil {
+			return err
+		}
+	}
+	if viaProvider || refund.ToWallet {
+		// The amount is reserved before the provider or the wallet is
+		// credited, so concurrent refunds can't together return more than
+		// was paid
+		refund.Status = models.RefundProcessing
+	}
+
+	recorded, err := s.refundRepo.Create(refund)
 	if err != nil {
 		return err
 	}
+	if !recorded {
+		// Only a concurrent refund of the same payment gets here
+		return errors.New("refund exceeds refundable amount")
+	}
+
+	if viaProvider {
+		result, err := provider.Refund(payment.TransactionID, refund.Amount, refund.Reason)
+		if err != nil || result.Status == ProviderStatusFailed {
+			s.failRefund(refund)
+			if err != nil {
+				log.Printf("Warning: payment provider %s failed to refund %s: %v", payment.Provider, payment.TransactionID, err)
+				return errors.New("payment provider error")
+			}
+			return fmt.Errorf("refund failed: %s", result.FailureReason)
+		}
+
+		refund.Status = models.RefundCompleted
+		if result.Status == ProviderStatusPending {
+			// settled by the provider's refund webhook or a status sync
+			refund.Status = models.RefundPending
+		}
+		refund.ProviderReference = result.Reference
+		if err := s.refundRepo.Finish(refund); err != nil {
+			// The money is on its way back, the refund stays reserved
+			log.Printf("Warning: provider refund %s of payment %d could not be recorded: %v", refund.ProviderReference, payment.ID, err)
+		}
+	}
+
+	if refund.ToWallet {
+		if err := s.creditRefund(payment, refund); err != nil {
+			s.failRefund(refund)
+			return err
+		}
+		refund.Status = models.RefundCompleted
+		if err := s.refundRepo.Finish(refund); err != nil {
+			// The wallet is credited, the refund stays reserved
+			log.Printf("Warning: wallet refund %d of payment %d could not be recorded: %v", refund.ID, payment.ID, err)
+		}
+	}
+
+	// What is kept after the refund decides the appointment's payment status,
+	// a pending refund holds its amount until it settles
+	s.refreshAppointment(payment.AppointmentID)
+	if refund.Status == models.RefundCompleted {
+		s.refundCompleted(payment.ID, refund)
+	}
+	return nil
+}
+
+// refundCompleted issues the credit note of a refund whose money is back
+// and tells the customer and webhook subscribers
+func (s *paymentService) refundCompleted(paymentID int, refund *models.Refund) {
+	payment, err := s.GetByID(paymentID)
+	if err != nil {
+		log.Printf("Warning: failed to load payment %d of refund %d: %v", paymentID, refund.ID, err)
+		return
+	}
+
+	s.issueCreditNote(payment, refund)
+
+	// The customer is told the refunded amount, not the payment's
+	refunded := *payment
+	refunded.Amount = refund.Amount
+	s.notify(models.NotificationPaymentRefunded, &refunded)
+	if err := s.webhookService.Publish(models.WebhookPaymentRefunded, map[string]interface{}{"payment": payment, "refund": refund}); err != nil {
+		log.Printf("Warning: failed to queue %s webhook for payment %d: %v", models.WebhookPaymentRefunded, payment.ID, err)
+	}
+}
+
+// applyRefundResult settles a pending refund with the provider's answer. A
+// failed refund releases its amount, so it can be refunded again. Refunds
+// that are already settled are left alone.
+func (s *paymentService) applyRefundResult(refund *models.Refund, result *PaymentResult) error {
+	if refund.Status != models.RefundPending && refund.Status != models.RefundProcessing {
+		return nil
+	}
+
+	switch result.Status {
+	case ProviderStatusRefunded:
+		refund.Status = models.RefundCompleted
+		if err := s.refundRepo.Finish(refund); err != nil {
+			return err
+		}
+		s.refundCompleted(refund.PaymentID, refund)
+	case ProviderStatusFailed:
+		log.Printf("Warning: refund %d of payment %d failed at the provider: %s", refund.ID, refund.PaymentID, result.FailureReason)
+		refund.Status = models.RefundFailed
+		if err := s.refundRepo.Fail(refund); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	if payment, err := s.GetByID(refund.PaymentID); err == nil {
+		s.refreshAppointment(payment.AppointmentID)
+	}
+	return nil
+}
+
+// failRefund releases the amount reserved for a refund the provider refused
+func (s *paymentService) failRefund(refund *models.Refund) {
+	refund.Status = models.RefundFailed
+	if err := s.refundRepo.Fail(refund); err != nil {
+		log.Printf("Warning: failed to release refund %d of payment %d: %v", refund.ID, refund.PaymentID, err)
+	}
+}
+
+func (s *paymentService) GetRefunds(paymentID int) ([]*models.Refund, error) {
+	if _, err := s.GetByID(paymentID); err != nil {
+		return nil, err
+	}
+	return s.refundRepo.ListByPaymentID(paymentID)
+}
+
+// creditRefund credits a refund to the wallet of the appointment's customer
+func (s *paymentService) creditRefund(payment *models.Payment, refund *models.Refund) error {
+	appointment, err := s.appointmentRepo.GetByID(payment.AppointmentID)
+	if err != nil {
+		return fmt.Errorf("appointment not found")
+	}
+	if err := s.walletService.CreditRefund(appointment.UserID, refund); err != nil {
+		log.Printf("Warning: failed to credit refund %d to wallet of user %d: %v", refund.ID, appointment.UserID, err)
+		return errors.New("failed to credit refund to wallet")
+	}
+	return nil
+}
+
+// issueInvoice invoices a completed payment, failures never fail the payment
+// and staff can issue the invoice later
+func (s *paymentService) issueInvoice(payment *models.Payment) {
+	if _, err := s.invoiceService.IssueForPayment(payment.ID); err != nil {
+		log.Printf("Warning: failed to issue invoice for payment %d: %v", payment.ID, err)
+	}
+}
+
+// issueCreditNote credits a refund, failures never fail the refund
+func (s *paymentService) issueCreditNote(payment *models.Payment, refund *models.Refund) {
+	if _, err := s.invoiceService.IssueCreditNote(payment, refund); err != nil {
+		log.Printf("Warning: failed to issue credit note for payment %d: %v", payment.ID, err)
+	}
+}
+
+// notify queues a customer notification, failures never fail the payment
+func (s *paymentService) notify(event models.NotificationEvent, payment *models.Payment) {
+	if err := s.notificationService.NotifyPayment(event, payment); err != nil {
+		log.Printf("Warning: failed to queue %s notification for payment %d: %v", event, payment.ID, err)
+	}
+}
+
+// publish queues the payment webhooks, failures never fail the payment
+func (s *paymentService) publish(event models.WebhookEvent, payment *models.Payment) {
+	if err := s.webhookService.Publish(event, map[string]interface{}{"payment": payment}); err != nil {
+		log.Printf("Warning: failed to queue %s webhook for payment %d: %v", event, payment.ID, err)
+	}
+}
 
-	// Update appointment payment status
-	return s.appointmentRepo.UpdatePaymentStatus(payment.AppointmentID, models.PaymentRefunded)
+func (s *paymentService) GetTotalRevenue(startDate, endDate time.Time) (models.Money, error) {
+	return s.paymentRepo.GetTotalByDateRange(startDate, endDate, s.Currency())
 }
 
-func (s *paymentService) GetTotalRevenue(startDate, endDate time.Time) (float64, error) {
-	return s.paymentRepo.GetTotalByDateRange(startDate, endDate)
+// GetRevenueByTaxRate splits the revenue of GetTotalRevenue by VAT rate
+func (s *paymentService) GetRevenueByTaxRate(startDate, endDate time.Time) ([]*models.SalesByTaxRate, error) {
+	totals, err := s.paymentRepo.GetTotalsByTaxRate(startDate, endDate, s.Currency())
+	if err != nil {
+		return nil, err
+	}
+	if totals == nil {
+		totals = []*models.SalesByTaxRate{}
+	}
+	return totals, nil
 }
 
 func (s *paymentService) GetPaymentsByStatus(status models.PaymentStatus, limit, offset int) ([]*models.Payment, error) {
@@ -236,23 +895,23 @@ func (s *paymentService) GetPaymentsByStatus(status models.PaymentStatus, limit,
 	return s.paymentRepo.GetByStatus(status, limit, offset)
 }
 
-func (s *paymentService) GetMonthlyRevenue() (float64, error) {
+func (s *paymentService) GetMonthlyRevenue() (models.Money, error) {
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 	endOfMonth := startOfMonth.AddDate(0, 1, 0).Add(-time.Second)
 
-	return s.paymentRepo.GetTotalByDateRange(startOfMonth, endOfMonth)
+	return s.paymentRepo.GetTotalByDateRange(startOfMonth, endOfMonth, s.Currency())
 }
 
-func (s *paymentService) GetYearlyRevenue() (float64, error) {
+func (s *paymentService) GetYearlyRevenue() (models.Money, error) {
 	now := time.Now()
 	startOfYear := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
 	endOfYear := time.Date(now.Year()+1, 1, 1, 0, 0, 0, 0, now.Location()).Add(-time.Second)
 
-	return s.paymentRepo.GetTotalByDateRange(startOfYear, endOfYear)
+	return s.paymentRepo.GetTotalByDateRange(startOfYear, endOfYear, s.Currency())
 }
 
-func (s *paymentService) GetPreviousMonthlyRevenue() (float64, error) {
+func (s *paymentService) GetPreviousMonthlyRevenue() (models.Money, error) {
 	now := time.Now()
 	startOfPrevMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
 	endOfPrevMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Add(-time.Second)
@@ -263,13 +922,13 @@ func (s *paymentService) GetPreviousMonthlyRevenue() (float64, error) {
 		endOfPrevMonth = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()).Add(-time.Second)
 	}
 
-	return s.paymentRepo.GetTotalByDateRange(startOfPrevMonth, endOfPrevMonth)
+	return s.paymentRepo.GetTotalByDateRange(startOfPrevMonth, endOfPrevMonth, s.Currency())
 }
 
-func (s *paymentService) GetPreviousYearlyRevenue() (float64, error) {
+func (s *paymentService) GetPreviousYearlyRevenue() (models.Money, error) {
 	now := time.Now()
 	startOfPrevYear := time.Date(now.Year()-1, 1, 1, 0, 0, 0, 0, now.Location())
 	endOfPrevYear := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()).Add(-time.Second)
 
-	return s.paymentRepo.GetTotalByDateRange(startOfPrevYear, endOfPrevYear)
+	return s.paymentRepo.GetTotalByDateRange(startOfPrevYear, endOfPrevYear, s.Currency())
 }
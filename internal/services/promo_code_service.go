@@ -0,0 +1,268 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+type PromoCodeService interface {
+	List() ([]*models.PromoCode, error)
+	GetByID(id int) (*models.PromoCode, error)
+	Create(req *models.PromoCodeRequest) (*models.PromoCode, error)
+	Update(id int, req *models.PromoCodeRequest) (*models.PromoCode, error)
+	Delete(id int) error
+	Quote(code string, service *models.Service, price models.Money, userID int) (*models.PromoCode, models.Money, error)
+	Redeem(promo *models.PromoCode, appointment *models.Appointment) error
+	ListRedemptions(filter models.PromoRedemptionFilter, limit, offset int) ([]*models.PromoRedemption, int, error)
+	SummarizeRedemptions(filter models.PromoRedemptionFilter) ([]*models.PromoCodeSummary, error)
+}
+
+type promoCodeService struct {
+	promoCodeRepo   repository.PromoCodeRepository
+	serviceRepo     repository.ServiceRepository
+	categoryRepo    repository.CategoryRepository
+	settingsRepo    repository.SettingsRepository
+	defaultCurrency string
+}
+
+func NewPromoCodeService(promoCodeRepo repository.PromoCodeRepository, serviceRepo repository.ServiceRepository, categoryRepo repository.CategoryRepository, settingsRepo repository.SettingsRepository, cfg *config.Config) PromoCodeService {
+	return &promoCodeService{
+		promoCodeRepo:   promoCodeRepo,
+		serviceRepo:     serviceRepo,
+		categoryRepo:    categoryRepo,
+		settingsRepo:    settingsRepo,
+		defaultCurrency: cfg.Payment.Currency,
+	}
+}
+
+func (s *promoCodeService) List() ([]*models.PromoCode, error) {
+	promos, err := s.promoCodeRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	if promos == nil {
+		promos = []*models.PromoCode{}
+	}
+	return promos, nil
+}
+
+func (s *promoCodeService) GetByID(id int) (*models.PromoCode, error) {
+	promo, err := s.promoCodeRepo.GetByID(id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("promo code not found")
+	}
+	return promo, err
+}
+
+func (s *promoCodeService) Create(req *models.PromoCodeRequest) (*models.PromoCode, error) {
+	promo := &models.PromoCode{Active: true}
+	if err := s.apply(promo, req); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.promoCodeRepo.GetByCode(promo.Code); err == nil {
+		return nil, errors.New("promo code already exists")
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err := s.promoCodeRepo.Create(promo); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// Update replaces the code's settings. Lowering a limit below the current
+// uses only stops further redemptions.
+func (s *promoCodeService) Update(id int, req *models.PromoCodeRequest) (*models.PromoCode, error) {
+	promo, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(promo, req); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.promoCodeRepo.GetByCode(promo.Code); err == nil && existing.ID != id {
+		return nil, errors.New("promo code already exists")
+	} else if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err := s.promoCodeRepo.Update(promo); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// Delete removes a code that was never redeemed; redeemed codes stay for the
+// report and can be deactivated instead
+func (s *promoCodeService) Delete(id int) error {
+	if _, err := s.GetByID(id); err != nil {
+		return err
+	}
+
+	_, redeemed, err := s.promoCodeRepo.ListRedemptions(models.PromoRedemptionFilter{PromoCodeID: id}, 1, 0)
+	if err != nil {
+		return err
+	}
+	if redeemed > 0 {
+		return errors.New("promo code has been redeemed, deactivate it instead")
+	}
+
+	return s.promoCodeRepo.Delete(id)
+}
+
+// apply validates the request and copies it onto promo
+func (s *promoCodeService) apply(promo *models.PromoCode, req *models.PromoCodeRequest) error {
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if !promoCodePattern.MatchString(code) {
+		return errors.New("invalid promo code format, use 3-32 letters, digits, - or _")
+	}
+
+	switch req.DiscountType {
+	case models.DiscountPercentage:
+		if req.PercentOff <= 0 || req.PercentOff > 100 {
+			return errors.New("invalid percent_off, must be more than 0 and at most 100")
+		}
+		promo.PercentOff = req.PercentOff
+		promo.AmountOff = models.Money{}
+		promo.Currency = ""
+	case models.DiscountFixed:
+		if req.AmountOff.Amount <= 0 {
+			return errors.New("invalid amount_off, must be more than 0")
+		}
+		promo.PercentOff = 0
+		promo.Currency = tenantCurrency(s.settingsRepo, s.defaultCurrency)
+		promo.AmountOff = models.NewMoney(req.AmountOff.Amount, promo.Currency)
+	default:
+		return errors.New("invalid discount_type")
+	}
+
+	if req.ValidFrom != nil && req.ValidUntil != nil && !req.ValidUntil.After(*req.ValidFrom) {
+		return errors.New("invalid validity window, valid_until must be after valid_from")
+	}
+
+	if err := validateServiceTargets(s.serviceRepo, s.categoryRepo, req.ServiceIDs, req.CategoryIDs); err != nil {
+		return err
+	}
+
+	promo.Code = code
+	promo.Description = strings.TrimSpace(req.Description)
+	promo.DiscountType = req.DiscountType
+	promo.ValidFrom = req.ValidFrom
+	promo.ValidUntil = req.ValidUntil
+	promo.MaxUses = req.MaxUses
+	promo.MaxUsesPerUser = req.MaxUsesPerUser
+	promo.ServiceIDs = req.ServiceIDs
+	promo.CategoryIDs = req.CategoryIDs
+	if req.Active != nil {
+		promo.Active = *req.Active
+	}
+	return nil
+}
+
+// Quote checks that the user can book the service with the code and returns
+// the discount on price, at most the price itself. Limits are checked again
+// when the code is redeemed.
+func (s *promoCodeService) Quote(code string, service *models.Service, price models.Money, userID int) (*models.PromoCode, models.Money, error) {
+	promo, err := s.promoCodeRepo.GetByCode(strings.ToUpper(strings.TrimSpace(code)))
+	if err == sql.ErrNoRows || err == nil && !promo.Active {
+		return nil, models.Money{}, errors.New("invalid promo code")
+	}
+	if err != nil {
+		return nil, models.Money{}, err
+	}
+
+	now := time.Now()
+	if promo.ValidFrom != nil && now.Before(*promo.ValidFrom) {
+		return nil, models.Money{}, errors.New("promo code is not valid yet")
+	}
+	if promo.ValidUntil != nil && !now.Before(*promo.ValidUntil) {
+		return nil, models.Money{}, errors.New("promo code has expired")
+	}
+	if !coversService(promo.ServiceIDs, promo.CategoryIDs, service) {
+		return nil, models.Money{}, errors.New("promo code does not apply to this service")
+	}
+	if promo.DiscountType == models.DiscountFixed && promo.Currency != price.Currency {
+		return nil, models.Money{}, errors.New("promo code does not apply to this currency")
+	}
+
+	total, byUser, err := s.promoCodeRepo.CountUses(promo.ID, userID)
+	if err != nil {
+		return nil, models.Money{}, err
+	}
+	if promo.MaxUses != nil && total >= *promo.MaxUses {
+		return nil, models.Money{}, errors.New("promo code usage limit reached")
+	}
+	if promo.MaxUsesPerUser != nil && byUser >= *promo.MaxUsesPerUser {
+		return nil, models.Money{}, errors.New("promo code usage limit per customer reached")
+	}
+
+	discount := price.Percent(promo.PercentOff)
+	if promo.DiscountType == models.DiscountFixed {
+		discount = promo.AmountOff
+	}
+	if discount.Amount > price.Amount {
+		discount = price
+	}
+	return promo, discount, nil
+}
+
+// Redeem records the code on a booked appointment, failing when a concurrent
+// booking used up the code since it was quoted
+func (s *promoCodeService) Redeem(promo *models.PromoCode, appointment *models.Appointment) error {
+	redeemed, err := s.promoCodeRepo.Redeem(&models.PromoRedemption{
+		PromoCodeID:   promo.ID,
+		AppointmentID: appointment.ID,
+		Discount:      appointment.DiscountAmount,
+		Currency:      appointment.Currency,
+	}, promo.MaxUses, promo.MaxUsesPerUser)
+	if err != nil {
+		return err
+	}
+	if !redeemed {
+		return errors.New("promo code usage limit reached")
+	}
+	return nil
+}
+
+func (s *promoCodeService) ListRedemptions(filter models.PromoRedemptionFilter, limit, offset int) ([]*models.PromoRedemption, int, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	redemptions, total, err := s.promoCodeRepo.ListRedemptions(filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if redemptions == nil {
+		redemptions = []*models.PromoRedemption{}
+	}
+	return redemptions, total, nil
+}
+
+func (s *promoCodeService) SummarizeRedemptions(filter models.PromoRedemptionFilter) ([]*models.PromoCodeSummary, error) {
+	summaries, err := s.promoCodeRepo.SummarizeRedemptions(filter)
+	if err != nil {
+		return nil, err
+	}
+	if summaries == nil {
+		summaries = []*models.PromoCodeSummary{}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions a customer can take from a reminder link
+const (
+	ReminderActionConfirm = "confirm"
+	ReminderActionCancel  = "cancel"
+)
+
+var (
+	defaultReminderOffsets  = []time.Duration{24 * time.Hour, 2 * time.Hour}
+	defaultReminderChannels = []models.NotificationChannelType{models.NotificationChannelEmail, models.NotificationChannelSMS}
+)
+
+type ReminderService interface {
+	SendDue(tenant *TenantInfo) (int, error)
+	ListByAppointment(appointmentID int) ([]*models.AppointmentReminder, error)
+	GetLinkAppointment(tenantSchema string, appointmentID int, action, expires, signature string) (*models.Appointment, error)
+	HandleLink(tenantSchema string, appointmentID int, action, expires, signature string) (*models.Appointment, error)
+}
+
+type reminderService struct {
+	appointmentRepo     repository.AppointmentRepository
+	reminderRepo        repository.ReminderRepository
+	settingsRepo        repository.SettingsRepository
+	appointmentService  AppointmentService
+	notificationService NotificationService
+	config              *config.Config
+	location            *time.Location
+}
+
+func NewReminderService(
+	appointmentRepo repository.AppointmentRepository,
+	reminderRepo repository.ReminderRepository,
+	settingsRepo repository.SettingsRepository,
+	appointmentService AppointmentService,
+	notificationService NotificationService,
+	cfg *config.Config,
+) ReminderService {
+	return &reminderService{
+		appointmentRepo:     appointmentRepo,
+		reminderRepo:        reminderRepo,
+		settingsRepo:        settingsRepo,
+		appointmentService:  appointmentService,
+		notificationService: notificationService,
+		config:              cfg,
+		location:            loadLocation(cfg.Calendar.TimeZone),
+	}
+}
+
+// NewReminderWorker queues the due appointment reminders of every tenant
+func NewReminderWorker(tenantCache TenantCacheService, tenantDBs *TenantDBs, channels map[models.NotificationChannelType]NotificationChannel, cfg *config.Config) BackgroundWorker {
+	return newTenantWorker("appointment reminder", cfg.Reminder.Interval, tenantCache, tenantDBs,
+		func(tenant *TenantInfo, repos *repository.Repositories) error {
+			_, err := newTenantServices(repos, channels, cfg).Reminder.SendDue(tenant)
+			return err
+		})
+}
+
+// SendDue queues reminders for the upcoming pending and confirmed appointments and
+// returns how many were queued. Every passed offset is recorded once per start
+// time, but only the closest one is sent, so an appointment booked (or a worker
+// restarted) after the 24h mark gets a single reminder instead of several.
+func (s *reminderService) SendDue(tenant *TenantInfo) (int, error) {
+	if !s.remindersEnabled() {
+		return 0, nil
+	}
+
+	offsets := s.reminderOffsets()
+	channels := s.reminderChannels()
+	if len(offsets) == 0 || len(channels) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now().In(s.location)
+	appointments, err := s.appointmentRepo.GetUpcoming(now, now.Add(offsets[len(offsets)-1]))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, appointment := range appointments {
+		start := appointmentStartTime(appointment, s.location)
+
+		// offsets are sorted ascending, the first passed one is the closest
+		var due []time.Duration
+		for _, offset := range offsets {
+			if !start.Add(-offset).After(now) {
+				due = append(due, offset)
+			}
+		}
+		if len(due) == 0 {
+			continue
+		}
+
+		var send bool
+		for i, offset := range due {
+			inserted, err := s.reminderRepo.Record(&models.AppointmentReminder{
+				AppointmentID:    appointment.ID,
+				OffsetMinutes:    int(offset / time.Minute),
+				AppointmentStart: start,
+				Channels:         joinChannelTypes(channels),
+				Sent:             i == 0,
+			})
+			if err != nil {
+				return sent, err
+			}
+			if i == 0 {
+				send = inserted
+			}
+		}
+		if !send {
+			continue
+		}
+
+		data := map[string]interface{}{
+			"TimeUntil": formatTimeUntil(start.Sub(now)),
+		}
+		if tenant != nil && tenant.Domain != "" {
+			data["ConfirmURL"] = s.linkURL(tenant, appointment.ID, ReminderActionConfirm, start)
+			data["CancelURL"] = s.linkURL(tenant, appointment.ID, ReminderActionCancel, start)
+		}
+
+		if err := s.notificationService.NotifyAppointmentWith(models.NotificationAppointmentReminder, appointment.ID, data, channels); err != nil {
+			log.Printf("Warning: failed to queue reminder for appointment %d: %v", appointment.ID, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (s *reminderService) ListByAppointment(appointmentID int) ([]*models.AppointmentReminder, error) {
+	if appointmentID <= 0 {
+		return nil, errors.New("invalid appointment ID")
+	}
+
+	if _, err := s.appointmentRepo.GetByID(appointmentID); err != nil {
+		return nil, errors.New("appointment not found")
+	}
+
+	return s.reminderRepo.ListByAppointment(appointmentID)
+}
+
+// GetLinkAppointment verifies a reminder link without acting on it
+func (s *reminderService) GetLinkAppointment(tenantSchema string, appointmentID int, action, expires, signature string) (*models.Appointment, error) {
+	if err := s.verifyLink(tenantSchema, appointmentID, action, expires, signature); err != nil {
+		return nil, err
+	}
+
+	appointment, err := s.appointmentRepo.GetByID(appointmentID)
+	if err != nil {
+		return nil, errors.New("appointment not found")
+	}
+	return appointment, nil
+}
+
+// HandleLink confirms or cancels the appointment of a verified reminder link.
+// Repeating an action that already happened is not an error.
+func (s *reminderService) HandleLink(tenantSchema string, appointmentID int, action, expires, signature string) (*models.Appointment, error) {
+	appointment, err := s.GetLinkAppointment(tenantSchema, appointmentID, action, expires, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	var status models.AppointmentStatus
+	switch action {
+	case ReminderActionConfirm:
+		if appointment.Status == models.StatusConfirmed {
+			return appointment, nil
+		}
+		if appointment.Status != models.StatusPending {
+			return nil, errors.New("appointment can no longer be changed")
+		}
+		status = models.StatusConfirmed
+	case ReminderActionCancel:
+		if appointment.Status == models.StatusCancelled {
+			return appointment, nil
+		}
+		if appointment.Status == models.StatusCompleted {
+			return nil, errors.New("appointment can no longer be changed")
+		}
+		status = models.StatusCancelled
+	}
+
+	if err := s.appointmentService.UpdateStatus(appointmentID, status); err != nil {
+		return nil, err
+	}
+	appointment.Status = status
+	return appointment, nil
+}
+
+// Links are valid until the appointment starts and are bound to the tenant
+// schema, so a link cannot be replayed against another tenant's appointment IDs.
+func (s *reminderService) linkURL(tenant *TenantInfo, appointmentID int, action string, start time.Time) string {
+	expires := strconv.FormatInt(start.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.linkSignature(tenant.Schema, appointmentID, action, expires))
+
+	link := url.URL{
+		Scheme:   s.config.Reminder.LinkScheme,
+		Host:     tenant.Domain,
+		Path:     fmt.Sprintf("/api/appointment-links/%d/%s", appointmentID, action),
+		RawQuery: query.Encode(),
+	}
+	return link.String()
+}
+
+func (s *reminderService) linkSignature(tenantSchema string, appointmentID int, action, expires string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.Reminder.LinkSecret))
+	fmt.Fprintf(mac, "%s:%d:%s:%s", tenantSchema, appointmentID, action, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *reminderService) verifyLink(tenantSchema string, appointmentID int, action, expires, signature string) error {
+	if action != ReminderActionConfirm && action != ReminderActionCancel {
+		return errors.New("invalid link action")
+	}
+
+	expected := s.linkSignature(tenantSchema, appointmentID, action, expires)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return errors.New("invalid link signature")
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("invalid link signature")
+	}
+	if time.Now().Unix() >= expiresAt {
+		return errors.New("link has expired")
+	}
+	return nil
+}
+
+// remindersEnabled reads the reminders_enabled setting (default true)
+func (s *reminderService) remindersEnabled() bool {
+	setting, err := s.settingsRepo.GetByKey("reminders_enabled")
+	if err != nil || setting.Value == "" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(strings.TrimSpace(setting.Value))
+	if err != nil {
+		return true
+	}
+	return enabled
+}
+
+// reminderOffsets reads the reminder_offsets setting, sorted ascending
+func (s *reminderService) reminderOffsets() []time.Duration {
+	setting, err := s.settingsRepo.GetByKey("reminder_offsets")
+	if err != nil || setting.Value == "" {
+		return defaultReminderOffsets
+	}
+
+	offsets, err := parseReminderOffsets(setting.Value)
+	if err != nil {
+		log.Printf("Warning: invalid reminder_offsets setting %q, using defaults: %v", setting.Value, err)
+		return defaultReminderOffsets
+	}
+	return offsets
+}
+
+// reminderChannels reads the reminder_channels setting
+func (s *reminderService) reminderChannels() []models.NotificationChannelType {
+	setting, err := s.settingsRepo.GetByKey("reminder_channels")
+	if err != nil || setting.Value == "" {
+		return defaultReminderChannels
+	}
+
+	channels, err := parseReminderChannels(setting.Value)
+	if err != nil {
+		log.Printf("Warning: invalid reminder_channels setting %q, using defaults: %v", setting.Value, err)
+		return defaultReminderChannels
+	}
+	return channels
+}
+
+// parseReminderOffsets parses "24h,2h,30m" style lists. Go durations are
+// accepted plus a "d" suffix for days; the result is sorted and deduplicated.
+func parseReminderOffsets(value string) ([]time.Duration, error) {
+	seen := make(map[time.Duration]bool)
+	var offsets []time.Duration
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var offset time.Duration
+		if days, ok := strings.CutSuffix(part, "d"); ok {
+			n, err := strconv.Atoi(days)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reminder offset %q", part)
+			}
+			offset = time.Duration(n) * 24 * time.Hour
+		} else {
+			parsed, err := time.ParseDuration(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reminder offset %q", part)
+			}
+			offset = parsed
+		}
+
+		if offset < time.Minute {
+			return nil, fmt.Errorf("invalid reminder offset %q", part)
+		}
+		if !seen[offset] {
+			seen[offset] = true
+			offsets = append(offsets, offset)
+		}
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets, nil
+}
+
+func parseReminderChannels(value string) ([]models.NotificationChannelType, error) {
+	var channels []models.NotificationChannelType
+	for _, part := range strings.Split(value, ",") {
+		channel := models.NotificationChannelType(strings.ToLower(strings.TrimSpace(part)))
+		if channel == "" {
+			continue
+		}
+		if channel != models.NotificationChannelEmail && channel != models.NotificationChannelSMS {
+			return nil, fmt.Errorf("invalid reminder channel %q", part)
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+// validateReminderSetting rejects reminder settings the worker could not use
+func validateReminderSetting(key, value string) error {
+	switch key {
+	case "reminders_enabled":
+		if _, err := strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+			return errors.New("invalid reminders_enabled value, use true or false")
+		}
+	case "reminder_offsets":
+		_, err := parseReminderOffsets(value)
+		return err
+	case "reminder_channels":
+		_, err := parseReminderChannels(value)
+		return err
+	}
+	return nil
+}
+
+func joinChannelTypes(channels []models.NotificationChannelType) string {
+	names := make([]string, len(channels))
+	for i, channel := range channels {
+		names[i] = string(channel)
+	}
+	return strings.Join(names, ",")
+}
+
+// formatTimeUntil renders the time left until the appointment, e.g. "1 gün", "2 saat"
+func formatTimeUntil(d time.Duration) string {
+	if d >= time.Hour {
+		hours := int(d.Round(time.Hour).Hours())
+		if hours%24 == 0 {
+			return fmt.Sprintf("%d gün", hours/24)
+		}
+		return fmt.Sprintf("%d saat", hours)
+	}
+
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+	return fmt.Sprintf("%d dakika", minutes)
+}
@@ -4,6 +4,7 @@ import (
 	"appointment-api/internal/models"
 	"appointment-api/internal/repository"
 	"errors"
+	"fmt"
 )
 
 type ServiceService interface {
@@ -33,10 +34,14 @@ func (s *serviceService) Create(service *models.Service) error {
 		return errors.New("service name is required")
 	}
 
-	if service.Price < 0 {
+	if service.Price.Amount < 0 {
 		return errors.New("service price must be positive")
 	}
 
+	if err := validateTaxRate(service.TaxRate); err != nil {
+		return err
+	}
+
 	// Validate category if provided
 	if service.CategoryID != nil {
 		_, err := s.categoryRepo.GetByID(*service.CategoryID)
@@ -68,10 +73,14 @@ func (s *serviceService) Update(service *models.Service) error {
 		return errors.New("service name is required")
 	}
 
-	if service.Price < 0 {
+	if service.Price.Amount < 0 {
 		return errors.New("service price must be positive")
 	}
 
+	if err := validateTaxRate(service.TaxRate); err != nil {
+		return err
+	}
+
 	// Check if service exists
 	existing, err := s.serviceRepo.GetByID(service.ID)
 	if err != nil {
@@ -91,6 +100,7 @@ func (s *serviceService) Update(service *models.Service) error {
 	existing.Name = service.Name
 	existing.Description = service.Description
 	existing.Price = service.Price
+	existing.TaxRate = service.TaxRate
 	existing.ImageURL = service.ImageURL
 	existing.Active = service.Active
 
@@ -132,3 +142,40 @@ func (s *serviceService) ListByCategory(categoryID int) ([]*models.Service, erro
 
 	return s.serviceRepo.ListByCategory(categoryID)
 }
+
+// validateServiceTargets checks the services and categories a promo code or
+// package is restricted to
+func validateServiceTargets(serviceRepo repository.ServiceRepository, categoryRepo repository.CategoryRepository, serviceIDs, categoryIDs []int) error {
+	for _, id := range serviceIDs {
+		if _, err := serviceRepo.GetByID(id); err != nil {
+			return fmt.Errorf("invalid service_ids, service %d not found", id)
+		}
+	}
+	for _, id := range categoryIDs {
+		if _, err := categoryRepo.GetByID(id); err != nil {
+			return fmt.Errorf("invalid category_ids, category %d not found", id)
+		}
+	}
+	return nil
+}
+
+// coversService reports whether service is in serviceIDs or one of
+// categoryIDs; empty lists cover every service
+func coversService(serviceIDs, categoryIDs []int, service *models.Service) bool {
+	if len(serviceIDs) == 0 && len(categoryIDs) == 0 {
+		return true
+	}
+	for _, id := range serviceIDs {
+		if id == service.ID {
+			return true
+		}
+	}
+	if service.CategoryID != nil {
+		for _, id := range categoryIDs {
+			if id == *service.CategoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
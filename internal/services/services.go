@@ -2,6 +2,7 @@ package services
 
 import (
 	"appointment-api/internal/config"
+	"appointment-api/internal/models"
 	"appointment-api/internal/repository"
 	"database/sql"
 	"log"
@@ -9,19 +10,38 @@ import (
 )
 
 type Services struct {
-	Auth        AuthService
-	Tenant      TenantService
-	TenantCache TenantCacheService
-	Category    CategoryService
-	Service     ServiceService
-	Device      DeviceService
-	Settings    SettingsService
-	User        UserService
-	Specialist  SpecialistService
-	Appointment AppointmentService
-	Payment     PaymentService
-	Contact     ContactService
-	Upload      UploadService
+	Auth             AuthService
+	Tenant           TenantService
+	TenantCache      TenantCacheService
+	Category         CategoryService
+	Service          ServiceService
+	Device           DeviceService
+	Settings         SettingsService
+	User             UserService
+	Specialist       SpecialistService
+	Appointment      AppointmentService
+	Payment          PaymentService
+	PaymentAccount   PaymentAccountService
+	Invoice          InvoiceService
+	PromoCode        PromoCodeService
+	Package          PackageService
+	Wallet           WalletService
+	CashSession      CashSessionService
+	Idempotency      IdempotencyService
+	Contact          ContactService
+	Upload           UploadService
+	Calendar         CalendarService
+	ExternalCalendar ExternalCalendarService
+	Notification     NotificationService
+	Reminder         ReminderService
+	Webhook          WebhookService
+	TwoFactor        TwoFactorService
+	APIKey           APIKeyService
+	Audit            AuditService
+
+	// Background jobs started by StartWorkers
+	Workers   []BackgroundWorker
+	tenantDBs *TenantDBs
 }
 
 func NewServices(repos *repository.Repositories, cfg *config.Config, mainDB *sql.DB) *Services {
@@ -38,19 +58,79 @@ func NewServices(repos *repository.Repositories, cfg *config.Config, mainDB *sql
 		// Continue without upload service - will be nil
 	}
 
+	tenantDBs := NewTenantDBs(cfg.Database)
+
+	// Notification channels are shared by request handlers and the dispatcher
+	notificationChannels := NewNotificationChannels(cfg.Notification)
+
+	svc := newTenantServices(repos, notificationChannels, cfg)
+	svc.Auth = NewAuthService(globalUserRepo, repos.PasswordReset, repos.EmailVerification, repos.Session, repos.LoginAttempt, svc.TwoFactor, svc.Notification, svc.Webhook, cfg)
+	svc.Tenant = NewTenantService(mainDB)
+	svc.TenantCache = tenantCache
+	svc.Upload = uploadService
+	svc.Workers = []BackgroundWorker{
+		NewCalendarSyncWorker(tenantCache, tenantDBs, cfg),
+		NewNotificationDispatchWorker(tenantCache, tenantDBs, notificationChannels, cfg),
+		NewReminderWorker(tenantCache, tenantDBs, notificationChannels, cfg),
+		NewWebhookDispatchWorker(tenantCache, tenantDBs, cfg),
+	}
+	svc.tenantDBs = tenantDBs
+	return svc
+}
+
+// newTenantServices wires the services that work on one tenant database.
+// NewServices builds them for the request handlers and background workers for
+// each tenant they visit, so both run the same service graph.
+func newTenantServices(repos *repository.Repositories, channels map[models.NotificationChannelType]NotificationChannel, cfg *config.Config) *Services {
+	notificationService := NewNotificationService(repos.Notification, repos.User, repos.Appointment, repos.Service, repos.Specialist, channels, cfg)
+	webhookService := NewWebhookService(repos.Webhook, cfg)
+	twoFactorService := NewTwoFactorService(repos.TwoFactor, repos.Settings)
+	invoiceService := NewInvoiceService(repos.Invoice, repos.Payment, repos.Appointment, repos.Service, repos.User, repos.Settings, cfg)
+	promoCodeService := NewPromoCodeService(repos.PromoCode, repos.Service, repos.Category, repos.Settings, cfg)
+	packageService := NewPackageService(repos.Package, repos.User, repos.Service, repos.Category, repos.Settings, invoiceService, cfg)
+	walletService := NewWalletService(repos.Wallet, repos.User, repos.Settings, cfg)
+	paymentAccountService := NewPaymentAccountService(repos.PaymentAccount, cfg)
+	appointmentService := NewAppointmentService(repos.Appointment, repos.Service, repos.Category, repos.Specialist, repos.Settings, repos.ExternalCalendar, promoCodeService, packageService, notificationService, webhookService, cfg)
+
 	return &Services{
-		Auth:        NewAuthService(globalUserRepo, cfg),
-		Tenant:      NewTenantService(mainDB),
-		TenantCache: tenantCache,
-		Category:    NewCategoryService(repos.Category),
-		Service:     NewServiceService(repos.Service, repos.Category),
-		Device:      NewDeviceService(repos.Device),
-		Settings:    NewSettingsService(repos.Settings, repos.Service),
-		User:        NewUserService(repos.User),
-		Specialist:  NewSpecialistService(repos.Specialist, repos.Appointment, repos.Settings),
-		Appointment: NewAppointmentService(repos.Appointment, repos.Service, repos.Specialist),
-		Payment:     NewPaymentService(repos.Payment, repos.Appointment),
-		Contact:     NewContactService(repos.Contact),
-		Upload:      uploadService,
+		Category:         NewCategoryService(repos.Category),
+		Service:          NewServiceService(repos.Service, repos.Category),
+		Device:           NewDeviceService(repos.Device),
+		Settings:         NewSettingsService(repos.Settings, repos.Service),
+		User:             NewUserService(repos.User, repos.Session, repos.LoginAttempt),
+		Specialist:       NewSpecialistService(repos.Specialist, repos.Appointment, repos.Settings, repos.ExternalCalendar, cfg),
+		Appointment:      appointmentService,
+		PaymentAccount:   paymentAccountService,
+		Payment:          NewPaymentService(repos.Payment, repos.Refund, repos.Appointment, repos.Settings, paymentAccountService, appointmentService, invoiceService, walletService, notificationService, webhookService, cfg),
+		Invoice:          invoiceService,
+		PromoCode:        promoCodeService,
+		Package:          packageService,
+		Wallet:           walletService,
+		CashSession:      NewCashSessionService(repos.CashSession, repos.Settings, cfg),
+		Idempotency:      NewIdempotencyService(repos.Idempotency, repos.Settings, cfg),
+		Contact:          NewContactService(repos.Contact, webhookService),
+		Calendar:         NewCalendarService(repos.Calendar, repos.Appointment, repos.Service, repos.Specialist, repos.User, repos.Settings, cfg),
+		ExternalCalendar: NewExternalCalendarService(repos.ExternalCalendar, repos.Specialist, repos.Settings, cfg),
+		Notification:     notificationService,
+		Webhook:          webhookService,
+		TwoFactor:        twoFactorService,
+		APIKey:           NewAPIKeyService(repos.APIKey),
+		Audit:            NewAuditService(repos.Audit),
+		Reminder:         NewReminderService(repos.Appointment, repos.Reminder, repos.Settings, appointmentService, notificationService, cfg),
+	}
+}
+
+// StartWorkers starts the background jobs, the tenant cache must be running
+func (s *Services) StartWorkers() {
+	for _, worker := range s.Workers {
+		worker.Start()
+	}
+}
+
+// StopWorkers stops the background jobs and closes their tenant connections
+func (s *Services) StopWorkers() {
+	for _, worker := range s.Workers {
+		worker.Stop()
 	}
+	s.tenantDBs.Close()
 }
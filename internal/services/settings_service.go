@@ -49,6 +49,25 @@ func (s *settingsService) Update(setting *models.Setting) error {
 		return errors.New("setting not found")
 	}
 
+	if err := validateReminderSetting(setting.Key, setting.Value); err != nil {
+		return err
+	}
+	if err := validatePaymentSetting(setting.Key, setting.Value); err != nil {
+		return err
+	}
+	if err := validateInvoiceSetting(setting.Key, setting.Value); err != nil {
+		return err
+	}
+	if err := validateWalletSetting(setting.Key, setting.Value); err != nil {
+		return err
+	}
+	if err := validateTaxSetting(setting.Key, setting.Value); err != nil {
+		return err
+	}
+	if err := validateIdempotencySetting(setting.Key, setting.Value); err != nil {
+		return err
+	}
+
 	return s.settingsRepo.UpdateByKey(setting.Key, setting.Value, setting.Description)
 }
 
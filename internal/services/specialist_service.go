@@ -1,10 +1,12 @@
 package services
 
 import (
+	"appointment-api/internal/config"
 	"appointment-api/internal/models"
 	"appointment-api/internal/repository"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 	"time"
@@ -23,16 +25,20 @@ type SpecialistService interface {
 }
 
 type specialistService struct {
-	specialistRepo  repository.SpecialistRepository
-	appointmentRepo repository.AppointmentRepository
-	settingsRepo    repository.SettingsRepository
+	specialistRepo       repository.SpecialistRepository
+	appointmentRepo      repository.AppointmentRepository
+	settingsRepo         repository.SettingsRepository
+	externalCalendarRepo repository.ExternalCalendarRepository
+	location             *time.Location
 }
 
-func NewSpecialistService(specialistRepo repository.SpecialistRepository, appointmentRepo repository.AppointmentRepository, settingsRepo repository.SettingsRepository) SpecialistService {
+func NewSpecialistService(specialistRepo repository.SpecialistRepository, appointmentRepo repository.AppointmentRepository, settingsRepo repository.SettingsRepository, externalCalendarRepo repository.ExternalCalendarRepository, cfg *config.Config) SpecialistService {
 	return &specialistService{
-		specialistRepo:  specialistRepo,
-		appointmentRepo: appointmentRepo,
-		settingsRepo:    settingsRepo,
+		specialistRepo:       specialistRepo,
+		appointmentRepo:      appointmentRepo,
+		settingsRepo:         settingsRepo,
+		externalCalendarRepo: externalCalendarRepo,
+		location:             loadLocation(cfg.Calendar.TimeZone),
 	}
 }
 
@@ -324,7 +330,42 @@ func (s *specialistService) GetAvailableSlots(specialistID int, date string) ([]
 		}
 	}
 
-	return availableSlots, nil
+	return s.removeExternalBusySlots(specialistID, parsedDate, availableSlots, appointmentDuration)
+}
+
+// removeExternalBusySlots drops slots overlapping busy blocks imported from the
+// specialist's external calendars
+func (s *specialistService) removeExternalBusySlots(specialistID int, date time.Time, slots []string, duration int) ([]string, error) {
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, s.location)
+	blocks, err := s.externalCalendarRepo.GetBusyBlocks(specialistID, dayStart, dayStart.AddDate(0, 0, 1))
+	if err != nil {
+		// Without the blocks a busy specialist would look free
+		log.Printf("Warning: failed to get external busy blocks of specialist %d: %v", specialistID, err)
+		return nil, errors.New("failed to get external busy blocks")
+	}
+	if len(blocks) == 0 {
+		return slots, nil
+	}
+
+	freeSlots := []string{}
+	for _, slot := range slots {
+		slotTime, _ := time.Parse("15:04", slot)
+		slotStart := time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(), slotTime.Hour(), slotTime.Minute(), 0, 0, s.location)
+		slotEnd := slotStart.Add(time.Duration(duration) * time.Minute)
+
+		isBusy := false
+		for _, block := range blocks {
+			if block.StartsAt.Before(slotEnd) && block.EndsAt.After(slotStart) {
+				isBusy = true
+				break
+			}
+		}
+		if !isBusy {
+			freeSlots = append(freeSlots, slot)
+		}
+	}
+
+	return freeSlots, nil
 }
 
 // Helper method to get appointments by specialist and date
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// validateTaxRate accepts VAT rates between 0 and 100 percent, nil inherits one
+func validateTaxRate(rate *float64) error {
+	if rate != nil && (*rate < 0 || *rate > 100) {
+		return errors.New("invalid tax_rate: use a number between 0 and 100")
+	}
+	return nil
+}
+
+// validateTaxSetting checks the pricing settings before they are saved, the
+// tax_rate setting is checked with the invoice settings
+func validateTaxSetting(key, value string) error {
+	if key == "prices_include_tax" {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("invalid prices_include_tax: use true or false")
+		}
+	}
+	return nil
+}
+
+// tenantTaxRate is the tenant's tax_rate setting, the VAT of services and
+// categories without a rate of their own
+func tenantTaxRate(settingsRepo repository.SettingsRepository) float64 {
+	setting, err := settingsRepo.GetByKey("tax_rate")
+	if err != nil {
+		return 0
+	}
+	rate, err := strconv.ParseFloat(strings.TrimSpace(setting.Value), 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// pricesIncludeTax reports whether service prices include VAT, the tenant's
+// prices_include_tax setting. Without it prices are taken as tax inclusive,
+// as they were before the setting existed.
+func pricesIncludeTax(settingsRepo repository.SettingsRepository) bool {
+	setting, err := settingsRepo.GetByKey("prices_include_tax")
+	if err != nil {
+		return true
+	}
+	inclusive, err := strconv.ParseBool(strings.TrimSpace(setting.Value))
+	if err != nil {
+		return true
+	}
+	return inclusive
+}
+
+// serviceTaxRate is the VAT rate of the service, else of its category, else
+// the tenant's tax_rate setting
+func serviceTaxRate(service *models.Service, categoryRepo repository.CategoryRepository, settingsRepo repository.SettingsRepository) float64 {
+	if service.TaxRate != nil {
+		return *service.TaxRate
+	}
+	if service.CategoryID != nil {
+		if category, err := categoryRepo.GetByID(*service.CategoryID); err == nil && category.TaxRate != nil {
+			return *category.TaxRate
+		}
+	}
+	return tenantTaxRate(settingsRepo)
+}
+
+// splitTax splits a tax-inclusive amount into net and tax, the tax is
+// rounded half away from zero and the net takes the rest
+func splitTax(gross models.Money, rate float64) (models.Money, models.Money) {
+	tax := models.NewMoney(int64(math.Round(float64(gross.Amount)*rate/(100+rate))), gross.Currency)
+	return gross.Sub(tax), tax
+}
+
+// addTax returns the net, tax and gross of a price at rate. Tax inclusive
+// prices are the gross, tax exclusive ones the net.
+func addTax(price models.Money, rate float64, inclusive bool) (models.Money, models.Money, models.Money) {
+	if inclusive {
+		net, tax := splitTax(price, rate)
+		return net, tax, price
+	}
+	tax := price.Percent(rate)
+	return price, tax, price.Add(tax)
+}
+
+// setAppointmentTax splits the appointment's total at rate
+func setAppointmentTax(appointment *models.Appointment, rate float64) {
+	appointment.TaxRate = rate
+	appointment.NetAmount, appointment.TaxAmount = splitTax(appointment.TotalAmount, rate)
+}
+
+// setPaymentTax splits the payment at the VAT rate of its appointment
+func setPaymentTax(payment *models.Payment, rate float64) {
+	payment.TaxRate = rate
+	payment.NetAmount, payment.TaxAmount = splitTax(payment.Amount, rate)
+}
@@ -32,6 +32,8 @@ type TenantCacheService interface {
 	Start() error
 	Stop()
 	GetTenantByDomain(domain string) (*TenantInfo, error)
+	GetTenantByID(id int) (*TenantInfo, error)
+	GetAllTenants() []*TenantInfo
 	RefreshCache() error
 	GetCacheStats() (int, []string)
 }
@@ -100,6 +102,44 @@ func (tc *TenantCache) GetTenantByDomain(domain string) (*TenantInfo, error) {
 	return tenant, nil
 }
 
+// GetTenantByID id'ye göre tenant bilgisi döner, domain'den bağımsız gelen
+// istekler için (ör. ödeme sağlayıcı webhook'ları)
+func (tc *TenantCache) GetTenantByID(id int) (*TenantInfo, error) {
+	tc.mu.RLock()
+	for _, tenant := range tc.tenants {
+		if tenant.ID == id {
+			tc.mu.RUnlock()
+			return tenant, nil
+		}
+	}
+	tc.mu.RUnlock()
+
+	// Cache'te yoksa DB'den çek
+	tenant, err := tc.fetchTenant("id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if tenant == nil {
+		return nil, fmt.Errorf("tenant not found: %d", id)
+	}
+
+	tc.addToCache(tenant)
+	return tenant, nil
+}
+
+// GetAllTenants cache'teki tüm tenantları döner (background job'lar için)
+func (tc *TenantCache) GetAllTenants() []*TenantInfo {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	tenants := make([]*TenantInfo, 0, len(tc.tenants))
+	for _, tenant := range tc.tenants {
+		tenants = append(tenants, tenant)
+	}
+	return tenants
+}
+
 // RefreshCache tüm cache'i yeniler
 func (tc *TenantCache) RefreshCache() error {
 	log.Println("🔄 Refreshing tenant cache...")
@@ -173,13 +213,18 @@ func (tc *TenantCache) getCacheSize() int {
 
 // fetchTenantFromDB DB'den tek bir tenant çeker
 func (tc *TenantCache) fetchTenantFromDB(domain string) (*TenantInfo, error) {
+	return tc.fetchTenant("domain = $1", domain)
+}
+
+// fetchTenant condition'a uyan active tenant'ı çeker, yoksa nil döner
+func (tc *TenantCache) fetchTenant(condition string, arg interface{}) (*TenantInfo, error) {
 	query := `
 		SELECT id, name, domain, schema_name 
 		FROM public.tenants 
-		WHERE domain = $1 AND active = true`
+		WHERE ` + condition + ` AND active = true`
 
 	var tenant TenantInfo
-	err := tc.db.QueryRow(query, domain).Scan(
+	err := tc.db.QueryRow(query, arg).Scan(
 		&tenant.ID,
 		&tenant.Name,
 		&tenant.Domain,
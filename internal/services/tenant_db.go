@@ -0,0 +1,64 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"net/url"
+	"sync"
+)
+
+// TenantDBs keeps a small connection pool per tenant schema for background jobs.
+// The search_path is fixed on the connection, so the regular repositories can be
+// used outside of a request (where TenantMiddleware would normally set it).
+type TenantDBs struct {
+	mu    sync.Mutex
+	cfg   config.DatabaseConfig
+	pools map[string]*sql.DB
+}
+
+func NewTenantDBs(cfg config.DatabaseConfig) *TenantDBs {
+	return &TenantDBs{
+		cfg:   cfg,
+		pools: make(map[string]*sql.DB),
+	}
+}
+
+// Repositories returns repositories bound to the given tenant schema
+func (t *TenantDBs) Repositories(schema string) (*repository.Repositories, error) {
+	db, err := t.get(schema)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewRepositories(db), nil
+}
+
+func (t *TenantDBs) get(schema string) (*sql.DB, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if db, exists := t.pools[schema]; exists {
+		return db, nil
+	}
+
+	db, err := sql.Open("postgres", t.cfg.ConnectionString("search_path="+url.QueryEscape(schema+",public")))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(2)
+	db.SetMaxIdleConns(1)
+
+	t.pools[schema] = db
+	return db, nil
+}
+
+// Close closes every tenant pool
+func (t *TenantDBs) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for schema, db := range t.pools {
+		db.Close()
+		delete(t.pools, schema)
+	}
+}
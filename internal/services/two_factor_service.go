@@ -0,0 +1,229 @@
+package services
+
+import (
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of recovery codes issued at a time
+const twoFactorRecoveryCodeCount = 10
+
+type TwoFactorService interface {
+	Status(user *models.User) (*models.TwoFactorStatus, error)
+	Enabled(userID int) (bool, error)
+	Required(user *models.User) bool
+	Setup(user *models.User, issuer string) (*models.TwoFactorSetup, error)
+	Enable(userID int, code string) ([]string, error)
+	Disable(user *models.User, code string) error
+	RegenerateRecoveryCodes(userID int, code string) ([]string, error)
+	Verify(userID int, code string) error
+	Reset(userID int) error
+}
+
+type twoFactorService struct {
+	twoFactorRepo repository.TwoFactorRepository
+	settingsRepo  repository.SettingsRepository
+}
+
+func NewTwoFactorService(twoFactorRepo repository.TwoFactorRepository, settingsRepo repository.SettingsRepository) TwoFactorService {
+	return &twoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		settingsRepo:  settingsRepo,
+	}
+}
+
+func (s *twoFactorService) Status(user *models.User) (*models.TwoFactorStatus, error) {
+	status := &models.TwoFactorStatus{Required: s.Required(user)}
+
+	twoFactor, err := s.get(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !twoFactor.Enabled() {
+		return status, nil
+	}
+
+	remaining, err := s.twoFactorRepo.CountRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled = true
+	status.EnabledAt = twoFactor.EnabledAt
+	status.RecoveryCodesRemaining = remaining
+	return status, nil
+}
+
+func (s *twoFactorService) Enabled(userID int) (bool, error) {
+	twoFactor, err := s.get(userID)
+	if err != nil {
+		return false, err
+	}
+	return twoFactor.Enabled(), nil
+}
+
+// Required reads the require_staff_two_factor setting (default false) for staff users
+func (s *twoFactorService) Required(user *models.User) bool {
+	if !user.Role.IsStaff() {
+		return false
+	}
+
+	setting, err := s.settingsRepo.GetByKey("require_staff_two_factor")
+	if err != nil || setting.Value == "" {
+		return false
+	}
+	required, err := strconv.ParseBool(strings.TrimSpace(setting.Value))
+	if err != nil {
+		return false
+	}
+	return required
+}
+
+// Setup starts enrollment with a new secret. Calling it again before the
+// first code is confirmed replaces the pending secret.
+func (s *twoFactorService) Setup(user *models.User, issuer string) (*models.TwoFactorSetup, error) {
+	enabled, err := s.Enabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.SavePending(user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable confirms enrollment with a code from the authenticator app and
+// returns the recovery codes, which are only shown this once
+func (s *twoFactorService) Enable(userID int, code string) ([]string, error) {
+	twoFactor, err := s.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil {
+		return nil, errors.New("two-factor setup has not been started")
+	}
+	if twoFactor.Enabled() {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	step, ok := verifyTOTP(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid two-factor code")
+	}
+	if err := s.twoFactorRepo.Enable(userID, step); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("two-factor authentication is already enabled")
+		}
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(userID)
+}
+
+func (s *twoFactorService) Disable(user *models.User, code string) error {
+	if s.Required(user) {
+		return errors.New("two-factor authentication is required for staff")
+	}
+	if err := s.Verify(user.ID, code); err != nil {
+		return err
+	}
+	return s.twoFactorRepo.Delete(user.ID)
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(userID int, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// Verify accepts a current authenticator code or an unused recovery code.
+// Each code works only once.
+func (s *twoFactorService) Verify(userID int, code string) error {
+	twoFactor, err := s.get(userID)
+	if err != nil {
+		return err
+	}
+	if !twoFactor.Enabled() {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	if step, ok := verifyTOTP(twoFactor.Secret, code, time.Now()); ok {
+		used, err := s.twoFactorRepo.UseStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errors.New("invalid two-factor code")
+		}
+		return nil
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.New("invalid two-factor code")
+	}
+	return nil
+}
+
+// Reset turns two-factor authentication off without a code, for admins helping
+// a user who lost their authenticator and recovery codes
+func (s *twoFactorService) Reset(userID int) error {
+	return s.twoFactorRepo.Delete(userID)
+}
+
+// get returns nil without error for users that never started enrollment
+func (s *twoFactorService) get(userID int) (*models.TwoFactor, error) {
+	twoFactor, err := s.twoFactorRepo.GetByUserID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return twoFactor, nil
+}
+
+// newRecoveryCodes replaces the user's recovery codes, formatted xxxxx-xxxxx
+func (s *twoFactorService) newRecoveryCodes(userID int) ([]string, error) {
+	codes := make([]string, twoFactorRecoveryCodeCount)
+	hashes := make([]string, twoFactorRecoveryCodeCount)
+	for i := range codes {
+		token, err := generateSecureToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = token[:5] + "-" + token[5:]
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -4,6 +4,7 @@ import (
 	"appointment-api/internal/models"
 	"appointment-api/internal/repository"
 	"errors"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -16,17 +17,24 @@ type UserService interface {
 	Update(user *models.User) error
 	Delete(id int) error
 	UpdateRole(userID int, role models.UserRole) error
+	RevokeSessions(userID int) error
+	UnlockAccount(userID int, client models.ClientInfo) error
+	ListLoginAttempts(filter models.LoginAttemptFilter, limit, offset int) ([]*models.LoginAttempt, int, error)
 	GetTotalCount() (int, error)
 	GetNewMonthlyCount() (int, error)
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo         repository.UserRepository
+	sessionRepo      repository.SessionRepository
+	loginAttemptRepo repository.LoginAttemptRepository
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
+func NewUserService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, loginAttemptRepo repository.LoginAttemptRepository) UserService {
 	return &userService{
-		userRepo: userRepo,
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		loginAttemptRepo: loginAttemptRepo,
 	}
 }
 
@@ -83,7 +91,17 @@ func (s *userService) Create(user *models.User) error {
 		return errors.New("invalid role")
 	}
 
+	if err := validateBillingDetails(user); err != nil {
+		return err
+	}
+
 	user.Password = string(hashedPassword)
+
+	// Accounts created by an admin do not go through email verification
+	if user.VerifiedAt == nil {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
 	return s.userRepo.Create(user)
 }
 
@@ -119,6 +137,10 @@ func (s *userService) Update(user *models.User) error {
 		return errors.New("invalid role")
 	}
 
+	if err := validateBillingDetails(user); err != nil {
+		return err
+	}
+
 	// Update fields but keep password unchanged if not provided
 	existing.Email = user.Email
 	existing.Name = user.Name
@@ -127,6 +149,7 @@ func (s *userService) Update(user *models.User) error {
 	existing.UstBel = user.UstBel
 	existing.OrtaBel = user.OrtaBel
 	existing.AltBel = user.AltBel
+	copyBillingDetails(existing, user)
 
 	if user.Role != "" {
 		existing.Role = user.Role
@@ -193,3 +216,67 @@ func (s *userService) GetNewMonthlyCount() (int, error) {
 	}
 	return count, nil
 }
+
+// RevokeSessions logs the user out on every device
+func (s *userService) RevokeSessions(userID int) error {
+	if userID <= 0 {
+		return errors.New("invalid user ID")
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return errors.New("user not found")
+	}
+
+	return s.sessionRepo.RevokeAllForUser(userID, 0)
+}
+
+// UnlockAccount clears the failed logins of a locked account. Blocks on the
+// attacker's IP address stay in place.
+func (s *userService) UnlockAccount(userID int, client models.ClientInfo) error {
+	if userID <= 0 {
+		return errors.New("invalid user ID")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	client = sessionClient(client)
+	return s.loginAttemptRepo.Create(&models.LoginAttempt{
+		Email:     normalizeLoginEmail(user.Email),
+		UserID:    &user.ID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Event:     models.LoginUnlocked,
+	})
+}
+
+func (s *userService) ListLoginAttempts(filter models.LoginAttemptFilter, limit, offset int) ([]*models.LoginAttempt, int, error) {
+	switch filter.Event {
+	case "", models.LoginFailed, models.LoginSucceeded, models.LoginBlocked, models.LoginLocked, models.LoginUnlocked:
+	default:
+		return nil, 0, errors.New("invalid login event")
+	}
+
+	filter.Email = normalizeLoginEmail(filter.Email)
+	return s.loginAttemptRepo.List(filter, limit, offset)
+}
+
+// validateBillingDetails checks the TCKN/VKN customers are invoiced with
+func validateBillingDetails(user *models.User) error {
+	user.TaxNumber = strings.TrimSpace(user.TaxNumber)
+	if user.TaxNumber != "" && !models.IsTaxNumber(user.TaxNumber) {
+		return errors.New("invalid tax number")
+	}
+	return nil
+}
+
+// copyBillingDetails copies the invoice details of a profile update
+func copyBillingDetails(dst, src *models.User) {
+	dst.TaxNumber = src.TaxNumber
+	dst.TaxOffice = strings.TrimSpace(src.TaxOffice)
+	dst.Address = strings.TrimSpace(src.Address)
+	dst.District = strings.TrimSpace(src.District)
+	dst.City = strings.TrimSpace(src.City)
+}
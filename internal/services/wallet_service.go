@@ -0,0 +1,419 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// giftCardAlphabet leaves out letters and digits that are easy to mix up
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const defaultGiftCardValidityDays = 365
+
+type WalletService interface {
+	GetBalances(userID int) ([]*models.WalletBalance, error)
+	ListEntries(userID, limit, offset int) ([]*models.WalletEntry, int, error)
+	Adjust(userID int, req *models.WalletAdjustmentRequest, actorType string, actorID *int, actor string) (*models.WalletEntry, error)
+	Pay(userID int, payment *models.Payment) error
+	CreditRefund(userID int, refund *models.Refund) error
+	SellGiftCard(req *models.CreateGiftCardRequest, actorType string, actorID *int, actor string) (*models.GiftCard, error)
+	GetGiftCard(id int) (*models.GiftCard, error)
+	ListGiftCards(limit, offset int) ([]*models.GiftCard, int, error)
+	RedeemGiftCard(userID int, code string) (*models.WalletEntry, error)
+	Report(filter models.WalletReportFilter) ([]*models.WalletReport, error)
+	GetGiftCardSales(startDate, endDate time.Time) (*models.SalesTotal, error)
+}
+
+type walletService struct {
+	walletRepo      repository.WalletRepository
+	userRepo        repository.UserRepository
+	settingsRepo    repository.SettingsRepository
+	defaultCurrency string
+}
+
+func NewWalletService(walletRepo repository.WalletRepository, userRepo repository.UserRepository, settingsRepo repository.SettingsRepository, cfg *config.Config) WalletService {
+	return &walletService{
+		walletRepo:      walletRepo,
+		userRepo:        userRepo,
+		settingsRepo:    settingsRepo,
+		defaultCurrency: cfg.Payment.Currency,
+	}
+}
+
+// validateWalletSetting checks the wallet settings before they are saved
+func validateWalletSetting(key, value string) error {
+	if key == "gift_card_validity_days" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return errors.New("invalid gift_card_validity_days: use a whole number of days, 0 never expires")
+		}
+	}
+	return nil
+}
+
+// expire ends the credits that expired before a balance is read or spent
+func (s *walletService) expire() error {
+	return s.walletRepo.ExpireCredits(time.Now())
+}
+
+func (s *walletService) GetBalances(userID int) ([]*models.WalletBalance, error) {
+	if err := s.expire(); err != nil {
+		return nil, err
+	}
+
+	balances, err := s.walletRepo.Balances(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(balances) == 0 {
+		currency := tenantCurrency(s.settingsRepo, s.defaultCurrency)
+		balances = []*models.WalletBalance{{Currency: currency, Balance: models.NewMoney(0, currency)}}
+	}
+	return balances, nil
+}
+
+func (s *walletService) ListEntries(userID, limit, offset int) ([]*models.WalletEntry, int, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if err := s.expire(); err != nil {
+		return nil, 0, err
+	}
+
+	entries, total, err := s.walletRepo.ListEntries(userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if entries == nil {
+		entries = []*models.WalletEntry{}
+	}
+	return entries, total, nil
+}
+
+// Adjust credits the wallet in the tenant's currency or, with a negative
+// amount, debits it, e.g. for goodwill credit or to correct a mistake
+func (s *walletService) Adjust(userID int, req *models.WalletAdjustmentRequest, actorType string, actorID *int, actor string) (*models.WalletEntry, error) {
+	if _, err := s.userRepo.GetByID(userID); err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if req.Amount.Amount == 0 {
+		return nil, errors.New("invalid amount, must not be 0")
+	}
+	if req.ExpiresAt != nil && (req.Amount.Amount < 0 || !req.ExpiresAt.After(time.Now())) {
+		return nil, errors.New("invalid expires_at, only credits can expire and not in the past")
+	}
+
+	entry := &models.WalletEntry{
+		UserID:    userID,
+		Kind:      models.WalletEntryAdjustment,
+		Amount:    req.Amount,
+		ExpiresAt: req.ExpiresAt,
+		Reason:    strings.TrimSpace(req.Reason),
+		ActorType: actorType,
+		ActorID:   actorID,
+		Actor:     actor,
+	}
+	entry.SetCurrency(tenantCurrency(s.settingsRepo, s.defaultCurrency))
+
+	if entry.Amount.Amount > 0 {
+		if err := s.walletRepo.Credit(entry); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+
+	if err := s.debit(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Pay debits a wallet payment from the wallet of the appointment's customer
+func (s *walletService) Pay(userID int, payment *models.Payment) error {
+	entry := &models.WalletEntry{
+		UserID:    userID,
+		Kind:      models.WalletEntryPayment,
+		Amount:    models.NewMoney(-payment.Amount.Amount, payment.Currency),
+		PaymentID: &payment.ID,
+		ActorType: "system",
+	}
+	entry.SetCurrency(payment.Currency)
+	return s.debit(entry)
+}
+
+func (s *walletService) debit(entry *models.WalletEntry) error {
+	if err := s.expire(); err != nil {
+		return err
+	}
+
+	debited, err := s.walletRepo.Debit(entry, time.Now())
+	if err != nil {
+		return err
+	}
+	if !debited {
+		return errors.New("insufficient wallet balance")
+	}
+	return nil
+}
+
+// CreditRefund keeps a refund as credit in the customer's wallet. Refund
+// credits do not expire.
+func (s *walletService) CreditRefund(userID int, refund *models.Refund) error {
+	entry := &models.WalletEntry{
+		UserID:    userID,
+		Kind:      models.WalletEntryRefund,
+		Amount:    refund.Amount,
+		RefundID:  &refund.ID,
+		Reason:    refund.Reason,
+		ActorType: refund.ActorType,
+		ActorID:   refund.ActorID,
+		Actor:     refund.Actor,
+	}
+	entry.SetCurrency(refund.Currency)
+	return s.walletRepo.Credit(entry)
+}
+
+// SellGiftCard records a gift card bought at the desk and returns it with
+// its code. It expires after validity_days or the tenant's
+// gift_card_validity_days setting.
+func (s *walletService) SellGiftCard(req *models.CreateGiftCardRequest, actorType string, actorID *int, actor string) (*models.GiftCard, error) {
+	if req.PurchaserID != nil {
+		if _, err := s.userRepo.GetByID(*req.PurchaserID); err == sql.ErrNoRows {
+			return nil, errors.New("invalid purchaser_id, user not found")
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	code, err := newGiftCardCode()
+	if err != nil {
+		return nil, err
+	}
+
+	card := &models.GiftCard{
+		Code:           code,
+		Currency:       tenantCurrency(s.settingsRepo, s.defaultCurrency),
+		PurchaserID:    req.PurchaserID,
+		RecipientName:  strings.TrimSpace(req.RecipientName),
+		RecipientEmail: strings.TrimSpace(req.RecipientEmail),
+		Message:        strings.TrimSpace(req.Message),
+		PaymentMethod:  req.PaymentMethod,
+		ActorType:      actorType,
+		ActorID:        actorID,
+		Actor:          actor,
+	}
+	card.Amount = models.NewMoney(req.Amount.Amount, card.Currency)
+
+	validityDays := s.giftCardValidityDays()
+	if req.ValidityDays != nil {
+		validityDays = *req.ValidityDays
+	}
+	if validityDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, validityDays)
+		card.ExpiresAt = &expiresAt
+	}
+
+	if err := s.walletRepo.CreateGiftCard(card); err != nil {
+		return nil, err
+	}
+	card.SetStatus(time.Now())
+	return card, nil
+}
+
+func (s *walletService) giftCardValidityDays() int {
+	setting, err := s.settingsRepo.GetByKey("gift_card_validity_days")
+	if err != nil {
+		return defaultGiftCardValidityDays
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(setting.Value))
+	if err != nil || days < 0 {
+		return defaultGiftCardValidityDays
+	}
+	return days
+}
+
+func (s *walletService) GetGiftCard(id int) (*models.GiftCard, error) {
+	card, err := s.walletRepo.GetGiftCard(id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("gift card not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	card.SetStatus(time.Now())
+	return card, nil
+}
+
+func (s *walletService) ListGiftCards(limit, offset int) ([]*models.GiftCard, int, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	cards, total, err := s.walletRepo.ListGiftCards(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	for _, card := range cards {
+		card.SetStatus(now)
+	}
+	if cards == nil {
+		cards = []*models.GiftCard{}
+	}
+	return cards, total, nil
+}
+
+// RedeemGiftCard credits the card's amount to the user's wallet. Codes are
+// matched case-insensitively, with or without the dashes.
+func (s *walletService) RedeemGiftCard(userID int, code string) (*models.WalletEntry, error) {
+	card, entry, err := s.walletRepo.RedeemGiftCard(normalizeGiftCardCode(code), userID, time.Now())
+	if err == sql.ErrNoRows {
+		return nil, errors.New("invalid gift card code")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		if card.Status == models.GiftCardExpired {
+			return nil, errors.New("gift card has expired")
+		}
+		return nil, errors.New("gift card has already been redeemed")
+	}
+	return entry, nil
+}
+
+// Report sums the wallet ledger per currency over the period and reconciles
+// it with the payments, refunds and gift cards behind it
+func (s *walletService) Report(filter models.WalletReportFilter) ([]*models.WalletReport, error) {
+	now := time.Now()
+	if err := s.walletRepo.ExpireCredits(now); err != nil {
+		return nil, err
+	}
+
+	ledger, err := s.walletRepo.SummarizeLedger(filter)
+	if err != nil {
+		return nil, err
+	}
+	giftCards, err := s.walletRepo.SummarizeGiftCards(filter, now)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.walletRepo.Reconcile(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	byCurrency := map[string]*models.WalletReport{}
+	var reports []*models.WalletReport
+	report := func(currency string) *models.WalletReport {
+		if r, ok := byCurrency[currency]; ok {
+			return r
+		}
+		r := &models.WalletReport{Discrepancies: []*models.WalletReconciliation{}}
+		r.SetCurrency(currency)
+		byCurrency[currency] = r
+		reports = append(reports, r)
+		return r
+	}
+
+	for _, l := range ledger {
+		r := report(l.Currency)
+		discrepancies := r.Discrepancies
+		*r = *l
+		r.Discrepancies = discrepancies
+	}
+	for _, g := range giftCards {
+		r := report(g.Currency)
+		r.GiftCardsSold = g.GiftCardsSold
+		r.GiftCardsRedeemed = g.GiftCardsRedeemed
+		r.GiftCardsOutstanding = g.GiftCardsOutstanding
+	}
+	for _, line := range lines {
+		r := report(line.Currency)
+		switch line.Source {
+		case models.WalletSourcePayment:
+			r.WalletPayments = r.WalletPayments.Add(line.Expected)
+		case models.WalletSourceRefund:
+			r.WalletRefunds = r.WalletRefunds.Add(line.Expected)
+		}
+		if line.Expected.Amount != line.Recorded.Amount {
+			r.Discrepancies = append(r.Discrepancies, line)
+		}
+	}
+
+	for _, r := range reports {
+		r.Reconciled = len(r.Discrepancies) == 0
+	}
+	if reports == nil {
+		reports = []*models.WalletReport{}
+	}
+	return reports, nil
+}
+
+// newGiftCardCode returns a random code such as "K7QX-M2RD-9TWH-PB4E"
+func newGiftCardCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range buf {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(giftCardAlphabet[int(b)%len(giftCardAlphabet)])
+	}
+	return code.String(), nil
+}
+
+// normalizeGiftCardCode turns what a customer typed into the stored form
+func normalizeGiftCardCode(code string) string {
+	var chars []rune
+	for _, r := range strings.ToUpper(code) {
+		if r == '-' || r == ' ' {
+			continue
+		}
+		chars = append(chars, r)
+	}
+
+	var normalized strings.Builder
+	for i, r := range chars {
+		if i > 0 && i%4 == 0 {
+			normalized.WriteByte('-')
+		}
+		normalized.WriteRune(r)
+	}
+	return normalized.String()
+}
+
+// GetGiftCardSales totals the gift cards sold in the tenant's currency. This
+// is money taken, not revenue: the VAT is invoiced when the balance pays for
+// an appointment.
+func (s *walletService) GetGiftCardSales(startDate, endDate time.Time) (*models.SalesTotal, error) {
+	return s.walletRepo.GetGiftCardSales(startDate, endDate, tenantCurrency(s.settingsRepo, s.defaultCurrency))
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"appointment-api/internal/config"
+	"appointment-api/internal/models"
+	"appointment-api/internal/repository"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Deliveries claimed per dispatch batch
+	webhookBatchSize = 50
+	// A claimed delivery is retried after this long if the dispatcher dies mid-request
+	webhookClaimLease = 5 * time.Minute
+	// Retry delays double per attempt up to this cap
+	webhookMaxBackoff = 6 * time.Hour
+	// Stored part of the receiver's response
+	webhookMaxResponseBody = 1024
+)
+
+var webhookEvents = []models.WebhookEvent{
+	models.WebhookAppointmentCreated,
+	models.WebhookAppointmentStatusChanged,
+	models.WebhookPaymentCompleted,
+	models.WebhookPaymentRefunded,
+	models.WebhookUserRegistered,
+	models.WebhookContactReceived,
+}
+
+type WebhookService interface {
+	Publish(event models.WebhookEvent, data interface{}) error
+	Dispatch() (int, error)
+	List() ([]*models.WebhookSubscription, error)
+	GetByID(id int) (*models.WebhookSubscription, error)
+	Create(req *models.CreateWebhookRequest) (*models.WebhookSubscription, error)
+	Update(id int, req *models.UpdateWebhookRequest) (*models.WebhookSubscription, error)
+	Delete(id int) error
+	RotateSecret(id int) (*models.WebhookSubscription, error)
+	ListDeliveries(subscriptionID int, status models.WebhookDeliveryStatus, limit, offset int) ([]*models.WebhookDelivery, int, error)
+	Redeliver(deliveryID int) (*models.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	httpClient  *http.Client
+	maxAttempts int
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, cfg *config.Config) WebhookService {
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		httpClient:  newWebhookHTTPClient(cfg.Webhook.Timeout),
+		maxAttempts: cfg.Webhook.MaxAttempts,
+	}
+}
+
+// NewWebhookDispatchWorker delivers the due webhooks of every tenant
+func NewWebhookDispatchWorker(tenantCache TenantCacheService, tenantDBs *TenantDBs, cfg *config.Config) BackgroundWorker {
+	return newTenantWorker("webhook dispatch", cfg.Webhook.DispatchInterval, tenantCache, tenantDBs,
+		func(tenant *TenantInfo, repos *repository.Repositories) error {
+			_, err := NewWebhookService(repos.Webhook, cfg).Dispatch()
+			return err
+		})
+}
+
+// Publish queues one delivery per active subscription of the event. The payload
+// is built once, so every subscriber receives the same event ID and body.
+func (s *webhookService) Publish(event models.WebhookEvent, data interface{}) error {
+	subscriptions, err := s.webhookRepo.ListActiveForEvent(event)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	eventID, err := generateSecureToken(12)
+	if err != nil {
+		return err
+	}
+	eventID = "evt_" + eventID
+
+	payload, err := json.Marshal(models.WebhookPayload{
+		ID:        eventID,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		delivery := &models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			Event:          event,
+			EventID:        eventID,
+			Payload:        payload,
+			MaxAttempts:    s.maxAttempts,
+		}
+		if err := s.webhookRepo.EnqueueDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dispatch sends due deliveries until none are left and returns how many succeeded
+func (s *webhookService) Dispatch() (int, error) {
+	subscriptions := make(map[int]*models.WebhookSubscription)
+
+	delivered := 0
+	for {
+		deliveries, err := s.webhookRepo.ClaimDue(webhookBatchSize, webhookClaimLease)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, delivery := range deliveries {
+			subscription, exists := subscriptions[delivery.SubscriptionID]
+			if !exists {
+				subscription, err = s.webhookRepo.GetSubscription(delivery.SubscriptionID)
+				if err != nil && err != sql.ErrNoRows {
+					return delivered, err
+				}
+				subscriptions[delivery.SubscriptionID] = subscription
+			}
+
+			if s.deliver(subscription, delivery) {
+				delivered++
+			}
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+func (s *webhookService) deliver(subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) bool {
+	if subscription == nil || !subscription.Active {
+		// Deliveries of a disabled subscription are not retried
+		if err := s.webhookRepo.MarkFailed(delivery.ID, nil, "", "subscription is disabled", nil); err != nil {
+			log.Printf("Warning: failed to record webhook delivery %d failure: %v", delivery.ID, err)
+		}
+		return false
+	}
+
+	responseStatus, responseBody, sendErr := s.send(subscription, delivery)
+	if sendErr == nil {
+		if err := s.webhookRepo.MarkDelivered(delivery.ID, responseStatus, responseBody); err != nil {
+			log.Printf("Warning: failed to mark webhook delivery %d as delivered: %v", delivery.ID, err)
+		}
+		return true
+	}
+
+	var status *int
+	if responseStatus != 0 {
+		status = &responseStatus
+	}
+
+	// Attempts were already counted when the delivery was claimed
+	var nextAttemptAt *time.Time
+	if delivery.Attempts < delivery.MaxAttempts {
+		next := time.Now().Add(webhookBackoff(delivery.Attempts))
+		nextAttemptAt = &next
+	}
+
+	log.Printf("Warning: webhook delivery %d (%s to %s) failed, attempt %d/%d: %v",
+		delivery.ID, delivery.Event, subscription.URL, delivery.Attempts, delivery.MaxAttempts, sendErr)
+	if err := s.webhookRepo.MarkFailed(delivery.ID, status, responseBody, sendErr.Error(), nextAttemptAt); err != nil {
+		log.Printf("Warning: failed to record webhook delivery %d failure: %v", delivery.ID, err)
+	}
+	return false
+}
+
+// send posts the payload and treats any 2xx answer as delivered. The signature
+// header is "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">" so receivers
+// can reject replays of old requests.
+func (s *webhookService) send(subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "appointment-api-webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", string(delivery.Event))
+	req.Header.Set("X-Webhook-ID", delivery.EventID)
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Webhook-Signature", "t="+timestamp+",v1="+signWebhookPayload(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseBody))
+	responseBody := sanitizeResponseBody(body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, responseBody, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, responseBody, nil
+}
+
+func signWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sanitizeResponseBody keeps the stored response valid UTF-8 text
+func sanitizeResponseBody(body []byte) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", "")
+}
+
+// webhookBackoff returns 1m, 2m, 4m, ... capped at six hours
+func webhookBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 10 {
+		return webhookMaxBackoff
+	}
+
+	delay := time.Minute << (attempts - 1)
+	if delay > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return delay
+}
+
+func (s *webhookService) List() ([]*models.WebhookSubscription, error) {
+	subscriptions, err := s.webhookRepo.ListSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	if subscriptions == nil {
+		subscriptions = []*models.WebhookSubscription{}
+	}
+	return subscriptions, nil
+}
+
+func (s *webhookService) GetByID(id int) (*models.WebhookSubscription, error) {
+	subscription, err := s.webhookRepo.GetSubscription(id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("webhook not found")
+	}
+	return subscription, err
+}
+
+func (s *webhookService) Create(req *models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := &models.WebhookSubscription{
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      events,
+		Description: req.Description,
+		Active:      true,
+	}
+	if req.Active != nil {
+		subscription.Active = *req.Active
+	}
+
+	if err := s.webhookRepo.CreateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) Update(id int, req *models.UpdateWebhookRequest) (*models.WebhookSubscription, error) {
+	subscription, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != "" {
+		if err := validateWebhookURL(req.URL); err != nil {
+			return nil, err
+		}
+		subscription.URL = req.URL
+	}
+	if req.Events != nil {
+		events, err := normalizeWebhookEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		subscription.Events = events
+	}
+	if req.Description != nil {
+		subscription.Description = *req.Description
+	}
+	if req.Active != nil {
+		subscription.Active = *req.Active
+	}
+
+	if err := s.webhookRepo.UpdateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) Delete(id int) error {
+	err := s.webhookRepo.DeleteSubscription(id)
+	if err == sql.ErrNoRows {
+		return errors.New("webhook not found")
+	}
+	return err
+}
+
+// RotateSecret replaces the signing secret, queued deliveries are signed with
+// the new one when they are sent
+func (s *webhookService) RotateSecret(id int) (*models.WebhookSubscription, error) {
+	subscription, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = secret
+
+	if err := s.webhookRepo.UpdateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) ListDeliveries(subscriptionID int, status models.WebhookDeliveryStatus, limit, offset int) ([]*models.WebhookDelivery, int, error) {
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		return nil, 0, errors.New("invalid status")
+	}
+
+	if subscriptionID != 0 {
+		if _, err := s.GetByID(subscriptionID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, total, err := s.webhookRepo.ListDeliveries(subscriptionID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+	return deliveries, total, nil
+}
+
+// Redeliver queues a new delivery with the original payload and event ID, the
+// original delivery stays in the log unchanged
+func (s *webhookService) Redeliver(deliveryID int) (*models.WebhookDelivery, error) {
+	original, err := s.webhookRepo.GetDelivery(deliveryID)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("webhook delivery not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.GetByID(original.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if !subscription.Active {
+		return nil, errors.New("webhook is disabled")
+	}
+
+	delivery := &models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		Event:          original.Event,
+		EventID:        original.EventID,
+		Payload:        original.Payload,
+		MaxAttempts:    s.maxAttempts,
+	}
+	if err := s.webhookRepo.EnqueueDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// newWebhookHTTPClient only reaches public addresses, the receiver's response
+// is kept in the delivery log where the tenant can read it
+func newWebhookHTTPClient(timeout time.Duration) *http.Client {
+	client := newPublicHTTPClient(timeout)
+	// A redirected POST would silently turn into a GET, report it instead
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client
+}
+
+func validateWebhookURL(rawURL string) error {
+	if _, err := validatePublicURL(rawURL, "http", "https"); err == errPrivateAddress {
+		return errors.New("invalid webhook url, private and local addresses are not allowed")
+	} else if err != nil {
+		return errors.New("invalid webhook url, use an http or https address")
+	}
+	return nil
+}
+
+// normalizeWebhookEvents validates and deduplicates the subscribed events
+func normalizeWebhookEvents(events []models.WebhookEvent) ([]models.WebhookEvent, error) {
+	if len(events) == 0 {
+		return nil, errors.New("at least one webhook event is required")
+	}
+
+	valid := map[models.WebhookEvent]bool{models.WebhookAllEvents: true}
+	for _, event := range webhookEvents {
+		valid[event] = true
+	}
+
+	seen := make(map[models.WebhookEvent]bool)
+	var normalized []models.WebhookEvent
+	for _, event := range events {
+		event = models.WebhookEvent(strings.TrimSpace(string(event)))
+		if !valid[event] {
+			return nil, fmt.Errorf("invalid webhook event %q", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	return normalized, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	token, err := generateSecureToken(24)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"appointment-api/internal/repository"
+	"log"
+	"time"
+)
+
+// BackgroundWorker is a periodic job started and stopped together with the server
+type BackgroundWorker interface {
+	Start()
+	Stop()
+}
+
+// tenantJob runs once per active tenant on every tick
+type tenantJob func(tenant *TenantInfo, repos *repository.Repositories) error
+
+type tenantWorker struct {
+	name        string
+	interval    time.Duration
+	tenantCache TenantCacheService
+	tenantDBs   *TenantDBs
+	job         tenantJob
+	stopCh      chan struct{}
+	doneCh      chan struct{}
+}
+
+func newTenantWorker(name string, interval time.Duration, tenantCache TenantCacheService, tenantDBs *TenantDBs, job tenantJob) BackgroundWorker {
+	return &tenantWorker{
+		name:        name,
+		interval:    interval,
+		tenantCache: tenantCache,
+		tenantDBs:   tenantDBs,
+		job:         job,
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+func (w *tenantWorker) Start() {
+	log.Printf("Starting %s worker, interval: %v", w.name, w.interval)
+	go w.loop()
+}
+
+// Stop waits for a running pass to finish
+func (w *tenantWorker) Stop() {
+	close(w.stopCh)
+	<-w.doneCh
+	log.Printf("Stopped %s worker", w.name)
+}
+
+func (w *tenantWorker) loop() {
+	defer close(w.doneCh)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.runOnce()
+	for {
+		select {
+		case <-ticker.C:
+			w.runOnce()
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+func (w *tenantWorker) runOnce() {
+	for _, tenant := range w.tenantCache.GetAllTenants() {
+		select {
+		case <-w.stopCh:
+			return
+		default:
+		}
+
+		repos, err := w.tenantDBs.Repositories(tenant.Schema)
+		if err != nil {
+			log.Printf("%s worker: failed to open tenant %s: %v", w.name, tenant.Domain, err)
+			continue
+		}
+
+		if err := w.job(tenant, repos); err != nil {
+			log.Printf("%s worker: tenant %s failed: %v", w.name, tenant.Domain, err)
+		}
+	}
+}
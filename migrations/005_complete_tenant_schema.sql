@@ -17,6 +17,12 @@ CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.users (
     ust_bel DECIMAL(5,2),
     orta_bel DECIMAL(5,2),
     alt_bel DECIMAL(5,2),
+    tax_number VARCHAR(11) NOT NULL DEFAULT '',
+    tax_office VARCHAR(100) NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    district VARCHAR(100) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL DEFAULT '',
+    verified_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -36,6 +42,7 @@ CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.categories (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
     description TEXT,
+    tax_rate DECIMAL(5,2),
     active BOOLEAN DEFAULT true,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
@@ -48,6 +55,7 @@ CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.services (
     name VARCHAR(255) NOT NULL,
     description TEXT,
     price DECIMAL(10,2) NOT NULL,
+    tax_rate DECIMAL(5,2),
     image_url VARCHAR(500),
     active BOOLEAN DEFAULT true,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -97,9 +105,18 @@ CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.appointments (
     appointment_date DATE NOT NULL,
     appointment_time TIME NOT NULL,
     status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'completed', 'cancelled')),
-    payment_status VARCHAR(20) DEFAULT 'pending' CHECK (payment_status IN ('pending', 'completed', 'failed', 'refunded')),
+    payment_status VARCHAR(20) DEFAULT 'pending' CHECK (payment_status IN ('pending', 'partially_paid', 'completed', 'failed', 'refunded', 'partially_refunded')),
     total_amount DECIMAL(10,2) NOT NULL,
+    paid_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    promo_code VARCHAR(32),
+    customer_package_id INTEGER,
+    currency VARCHAR(3) NOT NULL DEFAULT 'TRY',
+    tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0,
+    net_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
     notes TEXT,
+    sequence INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(specialist_id, appointment_date, appointment_time)
@@ -122,10 +139,18 @@ CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.payments (
     appointment_id INTEGER REFERENCES {SCHEMA_NAME}.appointments(id) ON DELETE CASCADE,
     device_id INTEGER REFERENCES {SCHEMA_NAME}.devices(id) ON DELETE SET NULL,
     amount DECIMAL(10,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'TRY',
+    tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0,
+    net_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
     payment_method VARCHAR(50),
     transaction_id VARCHAR(255),
+    provider VARCHAR(50),
     status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed', 'refunded')),
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    refunded_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Reports table
@@ -141,6 +166,467 @@ CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.reports (
     expires_at TIMESTAMP
 );
 
+-- Calendar feed tokens (private ICS subscription URLs)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.calendar_feed_tokens (
+    id SERIAL PRIMARY KEY,
+    owner_type VARCHAR(20) NOT NULL CHECK (owner_type IN ('specialist', 'user')),
+    owner_id INTEGER NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(owner_type, owner_id)
+);
+
+-- External calendars (ICS sources blocking specialist availability)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.external_calendars (
+    id SERIAL PRIMARY KEY,
+    specialist_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.specialists(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    source_type VARCHAR(10) NOT NULL CHECK (source_type IN ('url', 'file')),
+    url TEXT,
+    ics_data TEXT,
+    active BOOLEAN DEFAULT true,
+    last_synced_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Busy times imported from external calendars
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.external_busy_blocks (
+    id SERIAL PRIMARY KEY,
+    calendar_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.external_calendars(id) ON DELETE CASCADE,
+    specialist_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.specialists(id) ON DELETE CASCADE,
+    uid VARCHAR(500),
+    summary TEXT,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Tenant specific notification templates (built-in defaults are used otherwise)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.notification_templates (
+    id SERIAL PRIMARY KEY,
+    event VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'sms')),
+    subject VARCHAR(255),
+    body TEXT NOT NULL,
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(event, channel)
+);
+
+-- Outgoing notifications, delivered with retry by the dispatcher
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.notification_outbox (
+    id SERIAL PRIMARY KEY,
+    event VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'sms')),
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255),
+    body TEXT NOT NULL,
+    reference VARCHAR(100),
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 5,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Reminders already sent, one row per appointment start time and offset
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.appointment_reminders (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.appointments(id) ON DELETE CASCADE,
+    offset_minutes INTEGER NOT NULL,
+    appointment_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    channels VARCHAR(50) NOT NULL DEFAULT '',
+    sent BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(appointment_id, offset_minutes, appointment_start)
+);
+
+-- Outgoing webhook subscriptions (events is a comma separated list, * for all)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    events TEXT NOT NULL,
+    description TEXT,
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Webhook delivery log and outbox, retried with backoff by the dispatcher
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.webhook_subscriptions(id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 8,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    response_status INTEGER,
+    response_body TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Password reset tokens (SHA-256 hashes, single use)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.password_resets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Email verification tokens (SHA-256 hashes, single use)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.email_verifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login sessions, one per device (refresh token SHA-256 hashes, rotated on use)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    previous_token_hash VARCHAR(64),
+    user_agent VARCHAR(255),
+    ip_address VARCHAR(45),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login events (failed, succeeded, blocked, locked, unlocked) for throttling and audit
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.login_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    user_id INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
+    event VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- TOTP two-factor authentication (enabled once the first code is confirmed)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.two_factor (
+    user_id INTEGER PRIMARY KEY REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Two-factor recovery codes (SHA-256 hashes, single use)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.two_factor_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- API keys for server-to-server integrations (SHA-256 hashes, prefix for identification)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) UNIQUE NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes TEXT NOT NULL,
+    created_by INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Admin audit log, append-only: updates and deletes are rejected by a trigger
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INTEGER,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(100) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION {SCHEMA_NAME}.audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON {SCHEMA_NAME}.audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON {SCHEMA_NAME}.audit_log
+    FOR EACH ROW EXECUTE FUNCTION {SCHEMA_NAME}.audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON {SCHEMA_NAME}.audit_log;
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON {SCHEMA_NAME}.audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION {SCHEMA_NAME}.audit_log_append_only();
+
+-- Refunds, a payment can be refunded in several parts up to its amount
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.refunds (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.payments(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'TRY',
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'completed' CHECK (status IN ('processing', 'pending', 'completed', 'failed')),
+    provider_reference VARCHAR(255),
+    to_wallet BOOLEAN NOT NULL DEFAULT false,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Gap-free invoice numbers, one counter per series prefix and year
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.invoice_sequences (
+    prefix VARCHAR(3) NOT NULL,
+    year INTEGER NOT NULL,
+    last_number INTEGER NOT NULL,
+    PRIMARY KEY (prefix, year)
+);
+
+-- Invoices and credit notes, never changed once issued. They keep their
+-- copied details when the payment or customer is deleted.
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.invoices (
+    id SERIAL PRIMARY KEY,
+    number VARCHAR(16) UNIQUE NOT NULL,
+    uuid UUID UNIQUE NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('invoice', 'credit_note')),
+    year INTEGER NOT NULL,
+    sequence INTEGER NOT NULL,
+    payment_id INTEGER REFERENCES {SCHEMA_NAME}.payments(id) ON DELETE SET NULL,
+    refund_id INTEGER UNIQUE REFERENCES {SCHEMA_NAME}.refunds(id) ON DELETE SET NULL,
+    original_invoice_id INTEGER REFERENCES {SCHEMA_NAME}.invoices(id),
+    appointment_id INTEGER,
+    customer_package_id INTEGER UNIQUE,
+    user_id INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    currency VARCHAR(3) NOT NULL,
+    total DECIMAL(10,2) NOT NULL CHECK (total >= 0),
+    seller JSONB NOT NULL,
+    buyer JSONB NOT NULL,
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.invoice_items (
+    id SERIAL PRIMARY KEY,
+    invoice_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.invoices(id) ON DELETE CASCADE,
+    description TEXT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    unit_price DECIMAL(10,2) NOT NULL,
+    total DECIMAL(10,2) NOT NULL,
+    tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0
+);
+
+-- Promo codes customers enter when booking (service_ids and category_ids are
+-- JSON arrays, empty for every service)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(32) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percentage', 'fixed')),
+    percent_off DECIMAL(5,2) NOT NULL DEFAULT 0,
+    amount_off DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    valid_from TIMESTAMP WITH TIME ZONE,
+    valid_until TIMESTAMP WITH TIME ZONE,
+    max_uses INTEGER,
+    max_uses_per_user INTEGER,
+    service_ids JSONB NOT NULL DEFAULT '[]',
+    category_ids JSONB NOT NULL DEFAULT '[]',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Promo codes applied to appointments, one per appointment
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.promo_redemptions (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.promo_codes(id),
+    appointment_id INTEGER UNIQUE NOT NULL REFERENCES {SCHEMA_NAME}.appointments(id) ON DELETE CASCADE,
+    discount DECIMAL(10,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Prepaid packages sold to customers, a package without sessions is a
+-- membership (service_ids and category_ids are JSON arrays, empty for every service)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.packages (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    sessions INTEGER CHECK (sessions > 0),
+    validity_days INTEGER NOT NULL DEFAULT 0 CHECK (validity_days >= 0),
+    price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    service_ids JSONB NOT NULL DEFAULT '[]',
+    category_ids JSONB NOT NULL DEFAULT '[]',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Packages bought by customers with their session balance, the package's
+-- terms are copied at purchase
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.customer_packages (
+    id SERIAL PRIMARY KEY,
+    package_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.packages(id),
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    sessions_total INTEGER,
+    sessions_used INTEGER NOT NULL DEFAULT 0 CHECK (sessions_used >= 0),
+    service_ids JSONB NOT NULL DEFAULT '[]',
+    category_ids JSONB NOT NULL DEFAULT '[]',
+    price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0,
+    net_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    payment_method VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (sessions_total IS NULL OR sessions_used <= sessions_total)
+);
+
+-- Session history of customer packages: sessions used by bookings, given
+-- back on cancellation and adjusted by staff
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.package_sessions (
+    id SERIAL PRIMARY KEY,
+    customer_package_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.customer_packages(id) ON DELETE CASCADE,
+    appointment_id INTEGER REFERENCES {SCHEMA_NAME}.appointments(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('consumed', 'restored', 'adjusted')),
+    change INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Gift cards sold at the desk, redeemed into the wallet of a customer
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.gift_cards (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    purchaser_id INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    recipient_name VARCHAR(255) NOT NULL DEFAULT '',
+    recipient_email VARCHAR(255) NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    payment_method VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    redeemed_by INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    redeemed_at TIMESTAMP WITH TIME ZONE,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Customer wallet ledger: credits are positive, debits negative and the
+-- balance is their sum. remaining is what is left of a credit to spend.
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.wallet_entries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('gift_card', 'refund', 'payment', 'expiry', 'adjustment')),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0),
+    remaining DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+    currency VARCHAR(3) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    payment_id INTEGER REFERENCES {SCHEMA_NAME}.payments(id) ON DELETE SET NULL,
+    refund_id INTEGER REFERENCES {SCHEMA_NAME}.refunds(id) ON DELETE SET NULL,
+    gift_card_id INTEGER REFERENCES {SCHEMA_NAME}.gift_cards(id),
+    credit_entry_id INTEGER REFERENCES {SCHEMA_NAME}.wallet_entries(id),
+    reason TEXT NOT NULL DEFAULT '',
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Cash drawer sessions of the front desk, one open at a time
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.cash_sessions (
+    id SERIAL PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed', 'approved')),
+    currency VARCHAR(3) NOT NULL,
+    opening_float DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (opening_float >= 0),
+    counted_cash DECIMAL(10,2),
+    counted_credit_card DECIMAL(10,2),
+    counted_transfer DECIMAL(10,2),
+    notes TEXT NOT NULL DEFAULT '',
+    opened_by VARCHAR(255),
+    opened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_by VARCHAR(255),
+    closed_at TIMESTAMP WITH TIME ZONE,
+    approved_by VARCHAR(255),
+    approved_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Z-report lines of a session, saved when it closes
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.cash_session_lines (
+    id SERIAL PRIMARY KEY,
+    cash_session_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.cash_sessions(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL CHECK (source IN ('payment', 'gift_card', 'package')),
+    payment_method VARCHAR(50) NOT NULL,
+    device_id INTEGER REFERENCES {SCHEMA_NAME}.devices(id) ON DELETE SET NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    sales DECIMAL(10,2) NOT NULL DEFAULT 0,
+    refunds DECIMAL(10,2) NOT NULL DEFAULT 0
+);
+
+-- First responses to requests sent with an Idempotency-Key header, replayed
+-- to retries; status_code is NULL while the first request runs
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.idempotency_keys (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(255) NOT NULL,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response_headers JSONB,
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(actor_type, actor_id, key)
+);
+
+-- Payment gateway credentials of the tenant, encrypted with the server's
+-- PAYMENT_CREDENTIALS_KEY
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.payment_provider_accounts (
+    provider VARCHAR(50) PRIMARY KEY,
+    secret_key TEXT NOT NULL,
+    webhook_secret TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 -- ============================================================
 -- INDEXES FOR PERFORMANCE
 -- ============================================================
@@ -160,6 +646,48 @@ CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_specialists_active ON {SCHEMA_NAME}
 CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_devices_active ON {SCHEMA_NAME}.devices(active);
 CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_payments_appointment ON {SCHEMA_NAME}.payments(appointment_id);
 CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_payments_device ON {SCHEMA_NAME}.payments(device_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_external_calendars_specialist ON {SCHEMA_NAME}.external_calendars(specialist_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_external_busy_blocks_specialist_time ON {SCHEMA_NAME}.external_busy_blocks(specialist_id, starts_at, ends_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_external_busy_blocks_calendar ON {SCHEMA_NAME}.external_busy_blocks(calendar_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_notification_outbox_due ON {SCHEMA_NAME}.notification_outbox(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_notification_outbox_created ON {SCHEMA_NAME}.notification_outbox(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_webhook_deliveries_due ON {SCHEMA_NAME}.webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_webhook_deliveries_subscription ON {SCHEMA_NAME}.webhook_deliveries(subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_password_resets_user ON {SCHEMA_NAME}.password_resets(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_email_verifications_user ON {SCHEMA_NAME}.email_verifications(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_sessions_user ON {SCHEMA_NAME}.sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_sessions_previous_token ON {SCHEMA_NAME}.sessions(previous_token_hash);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_login_attempts_email ON {SCHEMA_NAME}.login_attempts(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_login_attempts_ip ON {SCHEMA_NAME}.login_attempts(ip_address, created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_two_factor_recovery_codes_user ON {SCHEMA_NAME}.two_factor_recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_audit_log_created_at ON {SCHEMA_NAME}.audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_audit_log_entity ON {SCHEMA_NAME}.audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_audit_log_actor ON {SCHEMA_NAME}.audit_log(actor_type, actor_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_payments_provider_transaction ON {SCHEMA_NAME}.payments(provider, transaction_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_refunds_payment ON {SCHEMA_NAME}.refunds(payment_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_refunds_provider_reference ON {SCHEMA_NAME}.refunds(provider_reference);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoices_payment ON {SCHEMA_NAME}.invoices(payment_id) WHERE type = 'invoice';
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoices_user ON {SCHEMA_NAME}.invoices(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoices_issued_at ON {SCHEMA_NAME}.invoices(issued_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoice_items_invoice ON {SCHEMA_NAME}.invoice_items(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_promo_redemptions_promo_code_id ON {SCHEMA_NAME}.promo_redemptions(promo_code_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_promo_redemptions_created_at ON {SCHEMA_NAME}.promo_redemptions(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_customer_packages_user_id ON {SCHEMA_NAME}.customer_packages(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_customer_packages_package_id ON {SCHEMA_NAME}.customer_packages(package_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_package_sessions_customer_package_id ON {SCHEMA_NAME}.package_sessions(customer_package_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_package_sessions_appointment_id ON {SCHEMA_NAME}.package_sessions(appointment_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_gift_cards_created_at ON {SCHEMA_NAME}.gift_cards(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_gift_cards_redeemed_at ON {SCHEMA_NAME}.gift_cards(redeemed_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_user_id ON {SCHEMA_NAME}.wallet_entries(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_payment_id ON {SCHEMA_NAME}.wallet_entries(payment_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_refund_id ON {SCHEMA_NAME}.wallet_entries(refund_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_gift_card_id ON {SCHEMA_NAME}.wallet_entries(gift_card_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_created_at ON {SCHEMA_NAME}.wallet_entries(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_expires_at ON {SCHEMA_NAME}.wallet_entries(expires_at) WHERE remaining > 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_cash_sessions_open ON {SCHEMA_NAME}.cash_sessions(status) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_cash_sessions_opened_at ON {SCHEMA_NAME}.cash_sessions(opened_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_cash_session_lines_session ON {SCHEMA_NAME}.cash_session_lines(cash_session_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_idempotency_keys_created_at ON {SCHEMA_NAME}.idempotency_keys(created_at);
 
 -- ============================================================
 -- DEFAULT DATA
@@ -170,7 +698,31 @@ INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
 ('appointment_duration', '60', 'Appointment duration in minutes for available slots calculation'),
 ('working_hours_start', '09:00', 'Default working hours start time'),
 ('working_hours_end', '17:00', 'Default working hours end time'),
-('max_advance_booking_days', '30', 'Maximum days in advance for booking');
+('max_advance_booking_days', '30', 'Maximum days in advance for booking'),
+('reminders_enabled', 'true', 'Send appointment reminders'),
+('reminder_offsets', '24h,2h', 'Comma separated reminder times before the appointment (e.g. 1d, 24h, 2h, 30m)'),
+('reminder_channels', 'email,sms', 'Comma separated reminder channels (email, sms)'),
+('require_email_verification', 'true', 'Only users with a verified email can book appointments'),
+('require_staff_two_factor', 'false', 'Staff accounts must use two-factor authentication to log in'),
+('payment_provider', '', 'Payment gateway for card payments: stripe, or fake (test) where the server enables it'),
+('deposit_percentage', '0', 'Percentage of the total to pay before a booking is confirmed, 0 disables deposits'),
+('currency', 'TRY', 'ISO 4217 currency new appointments are priced and charged in'),
+('invoice_prefix', 'INV', 'Invoice number prefix, 3 uppercase letters or digits'),
+('credit_note_prefix', 'CRN', 'Credit note number prefix, 3 uppercase letters or digits'),
+('invoice_seller_name', '', 'Company name printed on invoices'),
+('invoice_seller_address', '', 'Company address printed on invoices'),
+('invoice_seller_tax_office', '', 'Tax office printed on invoices'),
+('invoice_seller_tax_number', '', 'Tax number printed on invoices'),
+('invoice_seller_email', '', 'Contact e-mail printed on invoices'),
+('invoice_seller_phone', '', 'Contact phone printed on invoices'),
+('invoice_seller_district', '', 'District (ilçe) of the company address'),
+('invoice_seller_city', '', 'City (il) of the company address'),
+('invoice_seller_country', 'Türkiye', 'Country of the company address'),
+('einvoice_profile', 'EARSIVFATURA', 'UBL-TR profile of exported invoices: EARSIVFATURA, TEMELFATURA or TICARIFATURA'),
+('tax_rate', '20', 'Default VAT (KDV) rate in percent for services and categories without their own rate'),
+('prices_include_tax', 'true', 'Whether service prices include VAT (true) or VAT is added on top (false)'),
+('gift_card_validity_days', '365', 'Days a gift card and its wallet credit stay valid, 0 never expires'),
+('idempotency_key_retention_hours', '24', 'Hours the response to a request with an Idempotency-Key is kept for retries, 1 to 720');
 
 -- Sample categories
 INSERT INTO {SCHEMA_NAME}.categories (name, description) VALUES 
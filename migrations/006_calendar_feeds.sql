@@ -0,0 +1,12 @@
+-- Calendar Feeds
+-- Token protected ICS feed URLs for specialists and users
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.calendar_feed_tokens (
+    id SERIAL PRIMARY KEY,
+    owner_type VARCHAR(20) NOT NULL CHECK (owner_type IN ('specialist', 'user')),
+    owner_id INTEGER NOT NULL,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(owner_type, owner_id)
+);
@@ -0,0 +1,31 @@
+-- External Calendars
+-- ICS sources (URL or uploaded file) whose events block specialist availability
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.external_calendars (
+    id SERIAL PRIMARY KEY,
+    specialist_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.specialists(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    source_type VARCHAR(10) NOT NULL CHECK (source_type IN ('url', 'file')),
+    url TEXT,
+    ics_data TEXT,
+    active BOOLEAN DEFAULT true,
+    last_synced_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.external_busy_blocks (
+    id SERIAL PRIMARY KEY,
+    calendar_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.external_calendars(id) ON DELETE CASCADE,
+    specialist_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.specialists(id) ON DELETE CASCADE,
+    uid VARCHAR(500),
+    summary TEXT,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_external_calendars_specialist ON {SCHEMA_NAME}.external_calendars(specialist_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_external_busy_blocks_specialist_time ON {SCHEMA_NAME}.external_busy_blocks(specialist_id, starts_at, ends_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_external_busy_blocks_calendar ON {SCHEMA_NAME}.external_busy_blocks(calendar_id);
@@ -0,0 +1,35 @@
+-- Notifications
+-- Tenant notification templates and the outgoing message outbox
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.notification_templates (
+    id SERIAL PRIMARY KEY,
+    event VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'sms')),
+    subject VARCHAR(255),
+    body TEXT NOT NULL,
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(event, channel)
+);
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.notification_outbox (
+    id SERIAL PRIMARY KEY,
+    event VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'sms')),
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255),
+    body TEXT NOT NULL,
+    reference VARCHAR(100),
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 5,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_notification_outbox_due ON {SCHEMA_NAME}.notification_outbox(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_notification_outbox_created ON {SCHEMA_NAME}.notification_outbox(created_at);
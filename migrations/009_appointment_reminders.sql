@@ -0,0 +1,20 @@
+-- Appointment Reminders
+-- Sent reminder log and the reminder settings
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.appointment_reminders (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.appointments(id) ON DELETE CASCADE,
+    offset_minutes INTEGER NOT NULL,
+    appointment_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    channels VARCHAR(50) NOT NULL DEFAULT '',
+    sent BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(appointment_id, offset_minutes, appointment_start)
+);
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('reminders_enabled', 'true', 'Send appointment reminders'),
+('reminder_offsets', '24h,2h', 'Comma separated reminder times before the appointment (e.g. 1d, 24h, 2h, 30m)'),
+('reminder_channels', 'email,sms', 'Comma separated reminder channels (email, sms)')
+ON CONFLICT (key) DO NOTHING;
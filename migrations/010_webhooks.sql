@@ -0,0 +1,34 @@
+-- Webhooks
+-- Outgoing webhook subscriptions and the delivery log
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    events TEXT NOT NULL,
+    description TEXT,
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.webhook_subscriptions(id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 8,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    response_status INTEGER,
+    response_body TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_webhook_deliveries_due ON {SCHEMA_NAME}.webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_webhook_deliveries_subscription ON {SCHEMA_NAME}.webhook_deliveries(subscription_id, created_at);
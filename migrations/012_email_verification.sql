@@ -0,0 +1,30 @@
+-- Email Verification
+-- users.verified_at, verification tokens and the booking setting
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- Existing accounts predate verification and are treated as verified
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = '{SCHEMA_NAME}' AND table_name = 'users' AND column_name = 'verified_at'
+    ) THEN
+        ALTER TABLE {SCHEMA_NAME}.users ADD COLUMN verified_at TIMESTAMP WITH TIME ZONE;
+        UPDATE {SCHEMA_NAME}.users SET verified_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.email_verifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_email_verifications_user ON {SCHEMA_NAME}.email_verifications(user_id, created_at);
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('require_email_verification', 'true', 'Only users with a verified email can book appointments')
+ON CONFLICT (key) DO NOTHING;
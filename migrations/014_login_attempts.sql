@@ -0,0 +1,16 @@
+-- Login Attempts
+-- Failed-login tracking for throttling, account lockout and audit
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.login_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    user_id INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
+    event VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_login_attempts_email ON {SCHEMA_NAME}.login_attempts(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_login_attempts_ip ON {SCHEMA_NAME}.login_attempts(ip_address, created_at);
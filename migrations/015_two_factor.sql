@@ -0,0 +1,26 @@
+-- Two-Factor Authentication
+-- TOTP secrets, recovery codes and the staff 2FA setting
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.two_factor (
+    user_id INTEGER PRIMARY KEY REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.two_factor_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_two_factor_recovery_codes_user ON {SCHEMA_NAME}.two_factor_recovery_codes(user_id);
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('require_staff_two_factor', 'false', 'Staff accounts must use two-factor authentication to log in')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,39 @@
+-- Admin Audit Log
+-- Append-only record of admin changes with before/after diffs
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- Admin audit log, append-only: updates and deletes are rejected by a trigger
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INTEGER,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(100) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION {SCHEMA_NAME}.audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON {SCHEMA_NAME}.audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON {SCHEMA_NAME}.audit_log
+    FOR EACH ROW EXECUTE FUNCTION {SCHEMA_NAME}.audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON {SCHEMA_NAME}.audit_log;
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON {SCHEMA_NAME}.audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION {SCHEMA_NAME}.audit_log_append_only();
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_audit_log_created_at ON {SCHEMA_NAME}.audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_audit_log_entity ON {SCHEMA_NAME}.audit_log(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_audit_log_actor ON {SCHEMA_NAME}.audit_log(actor_type, actor_id);
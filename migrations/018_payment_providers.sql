@@ -0,0 +1,13 @@
+-- Payment Providers
+-- Gateway, failure reason and update time of payments, per-tenant payment provider setting
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS provider VARCHAR(50);
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_payments_provider_transaction ON {SCHEMA_NAME}.payments(provider, transaction_id);
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('payment_provider', 'fake', 'Payment gateway for card payments: fake (test) or stripe')
+ON CONFLICT (key) DO NOTHING;
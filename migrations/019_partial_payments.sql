@@ -0,0 +1,18 @@
+-- Partial Payments
+-- Paid amount and partially_paid status of appointments, per-tenant deposit percentage setting
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS paid_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE {SCHEMA_NAME}.appointments DROP CONSTRAINT IF EXISTS appointments_payment_status_check;
+ALTER TABLE {SCHEMA_NAME}.appointments ADD CONSTRAINT appointments_payment_status_check
+    CHECK (payment_status IN ('pending', 'partially_paid', 'completed', 'failed', 'refunded'));
+
+UPDATE {SCHEMA_NAME}.appointments a SET paid_amount = COALESCE((
+    SELECT SUM(p.amount) FROM {SCHEMA_NAME}.payments p
+    WHERE p.appointment_id = a.id AND p.status = 'completed'
+), 0);
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('deposit_percentage', '0', 'Percentage of the total to pay before a booking is confirmed, 0 disables deposits')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,26 @@
+-- Refunds
+-- Partial and repeated refunds of payments with reason, actor and provider reference
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+UPDATE {SCHEMA_NAME}.payments SET refunded_amount = amount WHERE status = 'refunded' AND refunded_amount = 0;
+
+-- Refunds, a payment can be refunded in several parts up to its amount
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.refunds (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.payments(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'completed' CHECK (status IN ('pending', 'completed')),
+    provider_reference VARCHAR(255),
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_refunds_payment ON {SCHEMA_NAME}.refunds(payment_id);
+
+ALTER TABLE {SCHEMA_NAME}.appointments DROP CONSTRAINT IF EXISTS appointments_payment_status_check;
+ALTER TABLE {SCHEMA_NAME}.appointments ADD CONSTRAINT appointments_payment_status_check
+    CHECK (payment_status IN ('pending', 'partially_paid', 'completed', 'failed', 'refunded', 'partially_refunded'));
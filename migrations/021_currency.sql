@@ -0,0 +1,20 @@
+-- Currency
+-- ISO 4217 currency of appointments, payments and refunds, per-tenant currency setting
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+-- Existing amounts get the currency setting; tenants that charged in another
+-- currency (PAYMENT_CURRENCY) should change 'TRY' below before running this
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('currency', 'TRY', 'ISO 4217 currency new appointments are priced and charged in')
+ON CONFLICT (key) DO NOTHING;
+
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'TRY';
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'TRY';
+ALTER TABLE {SCHEMA_NAME}.refunds ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'TRY';
+
+UPDATE {SCHEMA_NAME}.appointments SET currency = s.value
+FROM {SCHEMA_NAME}.settings s WHERE s.key = 'currency' AND appointments.currency <> s.value;
+UPDATE {SCHEMA_NAME}.payments SET currency = s.value
+FROM {SCHEMA_NAME}.settings s WHERE s.key = 'currency' AND payments.currency <> s.value;
+UPDATE {SCHEMA_NAME}.refunds SET currency = s.value
+FROM {SCHEMA_NAME}.settings s WHERE s.key = 'currency' AND refunds.currency <> s.value;
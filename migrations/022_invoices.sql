@@ -0,0 +1,56 @@
+-- Invoices
+-- Gap-free numbered invoices for completed payments and credit notes for refunds
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- Gap-free invoice numbers, one counter per series prefix and year
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.invoice_sequences (
+    prefix VARCHAR(3) NOT NULL,
+    year INTEGER NOT NULL,
+    last_number INTEGER NOT NULL,
+    PRIMARY KEY (prefix, year)
+);
+
+-- Invoices and credit notes, never changed once issued. They keep their
+-- copied details when the payment or customer is deleted.
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.invoices (
+    id SERIAL PRIMARY KEY,
+    number VARCHAR(16) UNIQUE NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('invoice', 'credit_note')),
+    year INTEGER NOT NULL,
+    sequence INTEGER NOT NULL,
+    payment_id INTEGER REFERENCES {SCHEMA_NAME}.payments(id) ON DELETE SET NULL,
+    refund_id INTEGER UNIQUE REFERENCES {SCHEMA_NAME}.refunds(id) ON DELETE SET NULL,
+    original_invoice_id INTEGER REFERENCES {SCHEMA_NAME}.invoices(id),
+    appointment_id INTEGER NOT NULL,
+    user_id INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    currency VARCHAR(3) NOT NULL,
+    total DECIMAL(10,2) NOT NULL CHECK (total >= 0),
+    seller JSONB NOT NULL,
+    buyer JSONB NOT NULL,
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.invoice_items (
+    id SERIAL PRIMARY KEY,
+    invoice_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.invoices(id) ON DELETE CASCADE,
+    description TEXT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    unit_price DECIMAL(10,2) NOT NULL,
+    total DECIMAL(10,2) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoices_payment ON {SCHEMA_NAME}.invoices(payment_id) WHERE type = 'invoice';
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoices_user ON {SCHEMA_NAME}.invoices(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoices_issued_at ON {SCHEMA_NAME}.invoices(issued_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoice_items_invoice ON {SCHEMA_NAME}.invoice_items(invoice_id);
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('invoice_prefix', 'INV', 'Invoice number prefix, 3 uppercase letters or digits'),
+('credit_note_prefix', 'CRN', 'Credit note number prefix, 3 uppercase letters or digits'),
+('invoice_seller_name', '', 'Company name printed on invoices'),
+('invoice_seller_address', '', 'Company address printed on invoices'),
+('invoice_seller_tax_office', '', 'Tax office printed on invoices'),
+('invoice_seller_tax_number', '', 'Tax number printed on invoices'),
+('invoice_seller_email', '', 'Contact e-mail printed on invoices'),
+('invoice_seller_phone', '', 'Contact phone printed on invoices')
+ON CONFLICT (key) DO NOTHING;
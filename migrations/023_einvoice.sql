@@ -0,0 +1,27 @@
+-- E-Invoice
+-- Customer tax details, invoice UUIDs (ETTN) and VAT rates for the UBL-TR XML export
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- TCKN/VKN and address customers are invoiced with
+ALTER TABLE {SCHEMA_NAME}.users ADD COLUMN IF NOT EXISTS tax_number VARCHAR(11) NOT NULL DEFAULT '';
+ALTER TABLE {SCHEMA_NAME}.users ADD COLUMN IF NOT EXISTS tax_office VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE {SCHEMA_NAME}.users ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '';
+ALTER TABLE {SCHEMA_NAME}.users ADD COLUMN IF NOT EXISTS district VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE {SCHEMA_NAME}.users ADD COLUMN IF NOT EXISTS city VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Invoices issued before this migration get a random UUID
+ALTER TABLE {SCHEMA_NAME}.invoices ADD COLUMN IF NOT EXISTS uuid UUID;
+UPDATE {SCHEMA_NAME}.invoices SET uuid = md5(random()::text || id::text)::uuid WHERE uuid IS NULL;
+ALTER TABLE {SCHEMA_NAME}.invoices ALTER COLUMN uuid SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_invoices_uuid ON {SCHEMA_NAME}.invoices(uuid);
+
+-- Earlier invoice items were issued without a rate
+ALTER TABLE {SCHEMA_NAME}.invoice_items ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0;
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('invoice_seller_district', '', 'District (ilçe) of the company address'),
+('invoice_seller_city', '', 'City (il) of the company address'),
+('invoice_seller_country', 'Türkiye', 'Country of the company address'),
+('einvoice_profile', 'EARSIVFATURA', 'UBL-TR profile of exported invoices: EARSIVFATURA, TEMELFATURA or TICARIFATURA'),
+('tax_rate', '20', 'VAT (KDV) rate in percent included in prices')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,40 @@
+-- Promo Codes
+-- Discount codes applied at booking, with validity windows, usage limits and service restrictions
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS promo_code VARCHAR(32);
+
+-- Promo codes customers enter when booking (service_ids and category_ids are
+-- JSON arrays, empty for every service)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(32) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percentage', 'fixed')),
+    percent_off DECIMAL(5,2) NOT NULL DEFAULT 0,
+    amount_off DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    valid_from TIMESTAMP WITH TIME ZONE,
+    valid_until TIMESTAMP WITH TIME ZONE,
+    max_uses INTEGER,
+    max_uses_per_user INTEGER,
+    service_ids JSONB NOT NULL DEFAULT '[]',
+    category_ids JSONB NOT NULL DEFAULT '[]',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Promo codes applied to appointments, one per appointment
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.promo_redemptions (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.promo_codes(id),
+    appointment_id INTEGER UNIQUE NOT NULL REFERENCES {SCHEMA_NAME}.appointments(id) ON DELETE CASCADE,
+    discount DECIMAL(10,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_promo_redemptions_promo_code_id ON {SCHEMA_NAME}.promo_redemptions(promo_code_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_promo_redemptions_created_at ON {SCHEMA_NAME}.promo_redemptions(created_at);
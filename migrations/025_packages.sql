@@ -0,0 +1,62 @@
+-- Session Packages
+-- Prepaid session packages and memberships, their balances and session history
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS customer_package_id INTEGER;
+
+-- Prepaid packages sold to customers, a package without sessions is a
+-- membership (service_ids and category_ids are JSON arrays, empty for every service)
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.packages (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    sessions INTEGER CHECK (sessions > 0),
+    validity_days INTEGER NOT NULL DEFAULT 0 CHECK (validity_days >= 0),
+    price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    service_ids JSONB NOT NULL DEFAULT '[]',
+    category_ids JSONB NOT NULL DEFAULT '[]',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Packages bought by customers with their session balance, the package's
+-- terms are copied at purchase
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.customer_packages (
+    id SERIAL PRIMARY KEY,
+    package_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.packages(id),
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    sessions_total INTEGER,
+    sessions_used INTEGER NOT NULL DEFAULT 0 CHECK (sessions_used >= 0),
+    service_ids JSONB NOT NULL DEFAULT '[]',
+    category_ids JSONB NOT NULL DEFAULT '[]',
+    price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    payment_method VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (sessions_total IS NULL OR sessions_used <= sessions_total)
+);
+
+-- Session history of customer packages: sessions used by bookings, given
+-- back on cancellation and adjusted by staff
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.package_sessions (
+    id SERIAL PRIMARY KEY,
+    customer_package_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.customer_packages(id) ON DELETE CASCADE,
+    appointment_id INTEGER REFERENCES {SCHEMA_NAME}.appointments(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('consumed', 'restored', 'adjusted')),
+    change INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_customer_packages_user_id ON {SCHEMA_NAME}.customer_packages(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_customer_packages_package_id ON {SCHEMA_NAME}.customer_packages(package_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_package_sessions_customer_package_id ON {SCHEMA_NAME}.package_sessions(customer_package_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_package_sessions_appointment_id ON {SCHEMA_NAME}.package_sessions(appointment_id);
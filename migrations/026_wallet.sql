@@ -0,0 +1,59 @@
+-- Wallet & Gift Cards
+-- Customer wallet ledger, gift cards and refunds credited to the wallet
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.refunds ADD COLUMN IF NOT EXISTS to_wallet BOOLEAN NOT NULL DEFAULT false;
+
+-- Gift cards sold at the desk, redeemed into the wallet of a customer
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.gift_cards (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    purchaser_id INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    recipient_name VARCHAR(255) NOT NULL DEFAULT '',
+    recipient_email VARCHAR(255) NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    payment_method VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    redeemed_by INTEGER REFERENCES {SCHEMA_NAME}.users(id) ON DELETE SET NULL,
+    redeemed_at TIMESTAMP WITH TIME ZONE,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Customer wallet ledger: credits are positive, debits negative and the
+-- balance is their sum. remaining is what is left of a credit to spend.
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.wallet_entries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('gift_card', 'refund', 'payment', 'expiry', 'adjustment')),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0),
+    remaining DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+    currency VARCHAR(3) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    payment_id INTEGER REFERENCES {SCHEMA_NAME}.payments(id) ON DELETE SET NULL,
+    refund_id INTEGER REFERENCES {SCHEMA_NAME}.refunds(id) ON DELETE SET NULL,
+    gift_card_id INTEGER REFERENCES {SCHEMA_NAME}.gift_cards(id),
+    credit_entry_id INTEGER REFERENCES {SCHEMA_NAME}.wallet_entries(id),
+    reason TEXT NOT NULL DEFAULT '',
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_gift_cards_created_at ON {SCHEMA_NAME}.gift_cards(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_gift_cards_redeemed_at ON {SCHEMA_NAME}.gift_cards(redeemed_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_user_id ON {SCHEMA_NAME}.wallet_entries(user_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_payment_id ON {SCHEMA_NAME}.wallet_entries(payment_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_refund_id ON {SCHEMA_NAME}.wallet_entries(refund_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_gift_card_id ON {SCHEMA_NAME}.wallet_entries(gift_card_id);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_created_at ON {SCHEMA_NAME}.wallet_entries(created_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_wallet_entries_expires_at ON {SCHEMA_NAME}.wallet_entries(expires_at) WHERE remaining > 0;
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('gift_card_validity_days', '365', 'Days a gift card and its wallet credit stay valid, 0 never expires')
+ON CONFLICT (key) DO NOTHING;
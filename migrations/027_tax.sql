@@ -0,0 +1,36 @@
+-- VAT Rates
+-- Per-service and per-category VAT rates, net/tax amounts on appointments and payments
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- NULL uses the category's rate, then the tenant's tax_rate setting
+ALTER TABLE {SCHEMA_NAME}.categories ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2);
+ALTER TABLE {SCHEMA_NAME}.services ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2);
+
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS net_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS net_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE {SCHEMA_NAME}.payments ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+-- Existing prices included the tenant's tax_rate, split them at that rate
+UPDATE {SCHEMA_NAME}.appointments a
+SET tax_rate = s.value::numeric,
+    tax_amount = ROUND(a.total_amount * s.value::numeric / (100 + s.value::numeric), 2),
+    net_amount = a.total_amount - ROUND(a.total_amount * s.value::numeric / (100 + s.value::numeric), 2)
+FROM {SCHEMA_NAME}.settings s
+WHERE s.key = 'tax_rate'
+  AND a.net_amount = 0 AND a.tax_amount = 0 AND a.total_amount <> 0;
+
+UPDATE {SCHEMA_NAME}.payments p
+SET tax_rate = s.value::numeric,
+    tax_amount = ROUND(p.amount * s.value::numeric / (100 + s.value::numeric), 2),
+    net_amount = p.amount - ROUND(p.amount * s.value::numeric / (100 + s.value::numeric), 2)
+FROM {SCHEMA_NAME}.settings s
+WHERE s.key = 'tax_rate'
+  AND p.net_amount = 0 AND p.tax_amount = 0 AND p.amount <> 0;
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('prices_include_tax', 'true', 'Whether service prices include VAT (true) or VAT is added on top (false)')
+ON CONFLICT (key) DO NOTHING;
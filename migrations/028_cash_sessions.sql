@@ -0,0 +1,37 @@
+-- Cash Sessions
+-- Front desk cash drawer sessions with counted amounts and Z-reports
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- Cash drawer sessions of the front desk, one open at a time
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.cash_sessions (
+    id SERIAL PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed', 'approved')),
+    currency VARCHAR(3) NOT NULL,
+    opening_float DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (opening_float >= 0),
+    counted_cash DECIMAL(10,2),
+    counted_credit_card DECIMAL(10,2),
+    counted_transfer DECIMAL(10,2),
+    notes TEXT NOT NULL DEFAULT '',
+    opened_by VARCHAR(255),
+    opened_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_by VARCHAR(255),
+    closed_at TIMESTAMP WITH TIME ZONE,
+    approved_by VARCHAR(255),
+    approved_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Z-report lines of a session, saved when it closes
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.cash_session_lines (
+    id SERIAL PRIMARY KEY,
+    cash_session_id INTEGER NOT NULL REFERENCES {SCHEMA_NAME}.cash_sessions(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL CHECK (source IN ('payment', 'gift_card', 'package')),
+    payment_method VARCHAR(50) NOT NULL,
+    device_id INTEGER REFERENCES {SCHEMA_NAME}.devices(id) ON DELETE SET NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    sales DECIMAL(10,2) NOT NULL DEFAULT 0,
+    refunds DECIMAL(10,2) NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_cash_sessions_open ON {SCHEMA_NAME}.cash_sessions(status) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_cash_sessions_opened_at ON {SCHEMA_NAME}.cash_sessions(opened_at);
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_cash_session_lines_session ON {SCHEMA_NAME}.cash_session_lines(cash_session_id);
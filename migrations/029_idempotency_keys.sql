@@ -0,0 +1,25 @@
+-- Idempotency Keys
+-- Stored responses of requests sent with an Idempotency-Key header
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- First responses to requests sent with an Idempotency-Key header, replayed
+-- to retries; status_code is NULL while the first request runs
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.idempotency_keys (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(255) NOT NULL,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_id INTEGER NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(actor_type, actor_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_idempotency_keys_created_at ON {SCHEMA_NAME}.idempotency_keys(created_at);
+
+INSERT INTO {SCHEMA_NAME}.settings (key, value, description) VALUES
+('idempotency_key_retention_hours', '24', 'Hours the response to a request with an Idempotency-Key is kept for retries, 1 to 720')
+ON CONFLICT (key) DO NOTHING;
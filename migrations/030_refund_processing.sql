@@ -0,0 +1,9 @@
+-- Refund Processing
+-- Refunds through a payment provider reserve their amount before the provider is asked
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- processing: amount reserved, waiting for the provider
+-- failed: refused by the provider, the amount was given back to the payment
+ALTER TABLE {SCHEMA_NAME}.refunds DROP CONSTRAINT IF EXISTS refunds_status_check;
+ALTER TABLE {SCHEMA_NAME}.refunds ADD CONSTRAINT refunds_status_check
+    CHECK (status IN ('processing', 'pending', 'completed', 'failed'));
@@ -0,0 +1,5 @@
+-- Appointment Sequence
+-- Revision counter of appointments, sent as the iCalendar SEQUENCE so calendar clients pick up changes
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.appointments ADD COLUMN IF NOT EXISTS sequence INTEGER NOT NULL DEFAULT 0;
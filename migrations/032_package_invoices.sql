@@ -0,0 +1,11 @@
+-- Package Invoices
+-- Tax split of package sales and invoices for them, package sales have no payment or appointment
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.customer_packages ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE {SCHEMA_NAME}.customer_packages ADD COLUMN IF NOT EXISTS net_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE {SCHEMA_NAME}.customer_packages ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+UPDATE {SCHEMA_NAME}.customer_packages SET net_amount = price WHERE net_amount = 0 AND tax_amount = 0;
+
+ALTER TABLE {SCHEMA_NAME}.invoices ALTER COLUMN appointment_id DROP NOT NULL;
+ALTER TABLE {SCHEMA_NAME}.invoices ADD COLUMN IF NOT EXISTS customer_package_id INTEGER UNIQUE;
@@ -0,0 +1,9 @@
+-- Calendar Feed Token Hash
+-- Feed tokens are stored as their SHA-256 hash like API keys, existing feed URLs keep working
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.calendar_feed_tokens ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
+UPDATE {SCHEMA_NAME}.calendar_feed_tokens SET token_hash = encode(sha256(token::bytea), 'hex') WHERE token_hash IS NULL;
+ALTER TABLE {SCHEMA_NAME}.calendar_feed_tokens ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE {SCHEMA_NAME}.calendar_feed_tokens ADD CONSTRAINT calendar_feed_tokens_token_hash_key UNIQUE (token_hash);
+ALTER TABLE {SCHEMA_NAME}.calendar_feed_tokens DROP COLUMN IF EXISTS token;
@@ -0,0 +1,5 @@
+-- Idempotency Response Headers
+-- Replayed responses carry the headers of the first response, e.g. Location
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+ALTER TABLE {SCHEMA_NAME}.idempotency_keys ADD COLUMN IF NOT EXISTS response_headers JSONB;
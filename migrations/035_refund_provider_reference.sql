@@ -0,0 +1,5 @@
+-- Refund Provider Reference
+-- Provider refund webhooks look refunds up by the provider's reference
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+CREATE INDEX IF NOT EXISTS idx_{SCHEMA_NAME}_refunds_provider_reference ON {SCHEMA_NAME}.refunds(provider_reference);
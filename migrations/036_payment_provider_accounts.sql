@@ -0,0 +1,13 @@
+-- Payment Provider Accounts
+-- Each tenant stores its own gateway API key and webhook signing secret
+-- Run once per existing tenant schema (Replace {SCHEMA_NAME} with actual schema)
+
+-- Payment gateway credentials of the tenant, encrypted with the server's
+-- PAYMENT_CREDENTIALS_KEY
+CREATE TABLE IF NOT EXISTS {SCHEMA_NAME}.payment_provider_accounts (
+    provider VARCHAR(50) PRIMARY KEY,
+    secret_key TEXT NOT NULL,
+    webhook_secret TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);